@@ -3,6 +3,7 @@ package analyzer
 import (
 	"go/ast"
 	"go/types"
+	"strings"
 
 	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/analysis/passes/inspect"
@@ -14,6 +15,10 @@ const (
 	analyzerDoc  = "reports usage of panic, log.Fatal, and os.Exit outside main function"
 )
 
+// allowFuncs lists extra package main functions, such as run, that may call
+// log.Fatal and os.Exit alongside main.
+var allowFuncs string
+
 // Analyzer checks for forbidden function calls (panic, log.Fatal, os.Exit) in the code.
 var Analyzer = &analysis.Analyzer{
 	Name:     analyzerName,
@@ -22,6 +27,11 @@ var Analyzer = &analysis.Analyzer{
 	Run:      run,
 }
 
+func init() {
+	Analyzer.Flags.StringVar(&allowFuncs, "allow", "",
+		"comma-separated package main functions that may call log.Fatal and os.Exit besides main")
+}
+
 func run(pass *analysis.Pass) (interface{}, error) {
 	insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
 
@@ -42,7 +52,9 @@ func checkCall(pass *analysis.Pass, callExpr *ast.CallExpr) {
 	case *ast.Ident:
 		if fn.Name == "panic" {
 			pass.Reportf(callExpr.Pos(), "panic is forbidden")
+			return
 		}
+		checkDotImportedCall(pass, fn, callExpr)
 	case *ast.SelectorExpr:
 		checkSelectorExpr(pass, fn, callExpr)
 	}
@@ -66,34 +78,70 @@ func checkSelectorExpr(pass *analysis.Pass, selectorExpr *ast.SelectorExpr, call
 			return
 		}
 
-		pkgPath := pkgName.Imported().Path()
+		checkExitCall(pass, pkgName.Imported().Path(), fn, callExpr)
+	}
+}
 
-		switch {
-		case pkgPath == "log" && fn == "Fatal":
-			if !isInMainFunction(pass, callExpr) {
-				pass.Reportf(callExpr.Pos(), "log.Fatal is forbidden outside main function")
-			}
-		case pkgPath == "os" && fn == "Exit":
-			if !isInMainFunction(pass, callExpr) {
-				pass.Reportf(callExpr.Pos(), "os.Exit is forbidden outside main function")
-			}
+// checkDotImportedCall handles bare calls such as Fatal(...) that resolve to
+// a function of a dot-imported package.
+func checkDotImportedCall(pass *analysis.Pass, ident *ast.Ident, callExpr *ast.CallExpr) {
+	if pass.TypesInfo == nil {
+		return
+	}
+
+	fn, ok := pass.TypesInfo.Uses[ident].(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg() == pass.Pkg {
+		return
+	}
+
+	checkExitCall(pass, fn.Pkg().Path(), fn.Name(), callExpr)
+}
+
+func checkExitCall(pass *analysis.Pass, pkgPath, fn string, callExpr *ast.CallExpr) {
+	switch {
+	case pkgPath == "log" && fn == "Fatal":
+		if !isInMainFunction(pass, callExpr) {
+			pass.Reportf(callExpr.Pos(), "log.Fatal is forbidden outside main function")
+		}
+	case pkgPath == "os" && fn == "Exit":
+		if !isInMainFunction(pass, callExpr) {
+			pass.Reportf(callExpr.Pos(), "os.Exit is forbidden outside main function")
 		}
 	}
 }
 
+// isInMainFunction reports whether node is inside func main of package main,
+// or inside one of the functions allowed with the -allow flag.
 func isInMainFunction(pass *analysis.Pass, node ast.Node) bool {
+	if pass.Pkg.Name() != "main" {
+		return false
+	}
+
+	allowed := allowedFuncs()
 	for _, f := range pass.Files {
 		for _, decl := range f.Decls {
-			if funcDecl, ok := decl.(*ast.FuncDecl); ok {
-				if funcDecl.Name.Name == "main" && isNodeInsideFunc(node, funcDecl) {
-					return true
-				}
+			funcDecl, ok := decl.(*ast.FuncDecl)
+			if !ok || funcDecl.Recv != nil || funcDecl.Body == nil || !allowed[funcDecl.Name.Name] {
+				continue
+			}
+			if isNodeInsideFunc(node, funcDecl) {
+				return true
 			}
 		}
 	}
 	return false
 }
 
+func allowedFuncs() map[string]bool {
+	allowed := map[string]bool{"main": true}
+	for _, name := range strings.Split(allowFuncs, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			allowed[name] = true
+		}
+	}
+	return allowed
+}
+
 func isNodeInsideFunc(target ast.Node, funcDecl *ast.FuncDecl) bool {
 	found := false
 	ast.Inspect(funcDecl.Body, func(n ast.Node) bool {
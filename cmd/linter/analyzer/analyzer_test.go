@@ -8,5 +8,20 @@ import (
 
 func TestAnalyzer(t *testing.T) {
 	testdata := analysistest.TestData()
-	analysistest.Run(t, testdata, Analyzer, "forbiddencalls")
+	analysistest.Run(t, testdata, Analyzer, "forbiddencalls", "mainpkg", "dotimport")
+}
+
+func TestAnalyzer_Allow(t *testing.T) {
+	if err := Analyzer.Flags.Set("allow", "run"); err != nil {
+		t.Fatalf("failed to set allow flag: %v", err)
+	}
+	t.Cleanup(func() { Analyzer.Flags.Set("allow", "") })
+
+	testdata := analysistest.TestData()
+	analysistest.Run(t, testdata, Analyzer, "allowrun")
+}
+
+func TestUncheckedWriteAnalyzer(t *testing.T) {
+	testdata := analysistest.TestData()
+	analysistest.Run(t, testdata, UncheckedWriteAnalyzer, "uncheckedwrite")
 }
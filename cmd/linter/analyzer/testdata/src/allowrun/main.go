@@ -0,0 +1,21 @@
+package main
+
+import (
+	"log"
+	"os"
+)
+
+func main() {
+	if err := run(); err != nil {
+		log.Fatal(err) // No want
+	}
+}
+
+func run() error {
+	os.Exit(1) // No want
+	return nil
+}
+
+func helper() {
+	os.Exit(2) // want "os.Exit is forbidden outside main function"
+}
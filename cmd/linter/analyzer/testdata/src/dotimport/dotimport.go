@@ -0,0 +1,28 @@
+package dotimport
+
+import (
+	. "log"
+	stdlog "log"
+	. "os"
+)
+
+// DotImportedFatal calls log.Fatal through a dot import.
+func DotImportedFatal() {
+	Fatal("this is forbidden") // want "log.Fatal is forbidden outside main function"
+}
+
+// DotImportedExit calls os.Exit through a dot import.
+func DotImportedExit() {
+	Exit(1) // want "os.Exit is forbidden outside main function"
+}
+
+// AliasedFatal calls log.Fatal through an aliased import.
+func AliasedFatal() {
+	stdlog.Fatal("this is forbidden") // want "log.Fatal is forbidden outside main function"
+}
+
+// LocalCall uses other dot-imported functions, which are allowed.
+func LocalCall() {
+	Println("allowed")
+	Getenv("HOME")
+}
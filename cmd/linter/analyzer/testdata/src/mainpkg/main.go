@@ -0,0 +1,22 @@
+package main
+
+import (
+	"log"
+	"os"
+)
+
+func main() {
+	log.Fatal("allowed in main") // No want
+	os.Exit(0)                   // No want
+}
+
+func run() error {
+	os.Exit(1) // want "os.Exit is forbidden outside main function"
+	return nil
+}
+
+type app struct{}
+
+func (app) main() {
+	log.Fatal("methods named main are not entry points") // want "log.Fatal is forbidden outside main function"
+}
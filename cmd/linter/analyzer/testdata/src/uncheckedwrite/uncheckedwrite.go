@@ -0,0 +1,43 @@
+package uncheckedwrite
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// IgnoredResponseWrite drops the error from http.ResponseWriter.Write.
+func IgnoredResponseWrite(w http.ResponseWriter) {
+	w.Write([]byte("ok")) // want "error returned by Write is not checked"
+}
+
+// IgnoredWriterWrite drops the error from io.Writer.Write.
+func IgnoredWriterWrite(w io.Writer) {
+	(w.Write([]byte("ok"))) // want "error returned by Write is not checked"
+}
+
+// HandledWrite checks the error.
+func HandledWrite(w http.ResponseWriter) error {
+	if _, err := w.Write([]byte("ok")); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ExplicitlyDiscardedWrite discards the results on purpose.
+func ExplicitlyDiscardedWrite(w io.Writer) {
+	_, _ = w.Write([]byte("ok"))
+}
+
+// DirectiveWrite silences the check with a directive.
+func DirectiveWrite(w http.ResponseWriter) {
+	w.Write([]byte("ok")) //uncheckedwrite:ignore
+
+	//uncheckedwrite:ignore client disconnects are logged elsewhere
+	w.Write([]byte("ok"))
+}
+
+// ConcreteWrite writes to a buffer, which never fails.
+func ConcreteWrite(buf *bytes.Buffer) {
+	buf.Write([]byte("ok"))
+}
@@ -0,0 +1,99 @@
+package analyzer
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const (
+	uncheckedWriteName = "uncheckedwrite"
+	uncheckedWriteDoc  = "reports Write calls on io.Writer interfaces, such as http.ResponseWriter, whose error is ignored"
+
+	// ignoreWriteDirective silences the check when placed on the Write line or the line above it.
+	ignoreWriteDirective = "//uncheckedwrite:ignore"
+)
+
+// UncheckedWriteAnalyzer checks for Write calls on interface writers whose results are discarded.
+var UncheckedWriteAnalyzer = &analysis.Analyzer{
+	Name:     uncheckedWriteName,
+	Doc:      uncheckedWriteDoc,
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      runUncheckedWrite,
+}
+
+func runUncheckedWrite(pass *analysis.Pass) (interface{}, error) {
+	insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	ignored := ignoredLines(pass)
+
+	nodeFilter := []ast.Node{
+		(*ast.ExprStmt)(nil),
+	}
+
+	insp.Preorder(nodeFilter, func(node ast.Node) {
+		callExpr, ok := ast.Unparen(node.(*ast.ExprStmt).X).(*ast.CallExpr)
+		if !ok || !isInterfaceWrite(pass, callExpr) {
+			return
+		}
+
+		pos := pass.Fset.Position(callExpr.Pos())
+		if ignored[pos.Filename][pos.Line] || ignored[pos.Filename][pos.Line-1] {
+			return
+		}
+
+		pass.Reportf(callExpr.Pos(), "error returned by Write is not checked")
+	})
+
+	return nil, nil
+}
+
+// isInterfaceWrite reports whether callExpr calls Write([]byte) (int, error)
+// through an interface value.
+func isInterfaceWrite(pass *analysis.Pass, callExpr *ast.CallExpr) bool {
+	selectorExpr, ok := callExpr.Fun.(*ast.SelectorExpr)
+	if !ok || selectorExpr.Sel.Name != "Write" || pass.TypesInfo == nil {
+		return false
+	}
+
+	selection := pass.TypesInfo.Selections[selectorExpr]
+	if selection == nil || selection.Kind() != types.MethodVal || !types.IsInterface(selection.Recv()) {
+		return false
+	}
+
+	sig, ok := selection.Type().(*types.Signature)
+	if !ok || sig.Params().Len() != 1 || sig.Results().Len() != 2 {
+		return false
+	}
+
+	param, ok := sig.Params().At(0).Type().(*types.Slice)
+	if !ok || !types.Identical(param.Elem(), types.Typ[types.Byte]) {
+		return false
+	}
+
+	return types.Identical(sig.Results().At(0).Type(), types.Typ[types.Int]) &&
+		types.Identical(sig.Results().At(1).Type(), types.Universe.Lookup("error").Type())
+}
+
+// ignoredLines returns, per file, the lines carrying the ignore directive.
+func ignoredLines(pass *analysis.Pass) map[string]map[int]bool {
+	lines := make(map[string]map[int]bool)
+	for _, f := range pass.Files {
+		for _, group := range f.Comments {
+			for _, comment := range group.List {
+				if !strings.HasPrefix(comment.Text, ignoreWriteDirective) {
+					continue
+				}
+				pos := pass.Fset.Position(comment.Slash)
+				if lines[pos.Filename] == nil {
+					lines[pos.Filename] = make(map[int]bool)
+				}
+				lines[pos.Filename][pos.Line] = true
+			}
+		}
+	}
+	return lines
+}
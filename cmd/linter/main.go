@@ -1,10 +1,101 @@
+// Command linter is the project's staticlint binary. It runs, in one pass:
+//
+//   - forbiddencalls: panic, log.Fatal and os.Exit outside package main's main;
+//   - uncheckedwrite: ignored errors from Write on io.Writer interfaces;
+//   - the go vet passes: asmdecl, assign, atomic, bools, buildtag, cgocall,
+//     copylocks, errorsas, httpresponse, loopclosure, lostcancel, nilfunc,
+//     printf, shadow, shift, stdmethods, structtag, tests, unmarshal,
+//     unreachable, unsafeptr and unusedresult;
+//   - the staticcheck checks selected in staticChecks, by default every
+//     SA-class (correctness) check.
+//
+// Usage:
+//
+//	go run ./cmd/linter ./...
 package main
 
 import (
+	"strings"
+
 	"github.com/MikhailRaia/url-shortener/cmd/linter/analyzer"
-	"golang.org/x/tools/go/analysis/singlechecker"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/multichecker"
+	"golang.org/x/tools/go/analysis/passes/asmdecl"
+	"golang.org/x/tools/go/analysis/passes/assign"
+	"golang.org/x/tools/go/analysis/passes/atomic"
+	"golang.org/x/tools/go/analysis/passes/bools"
+	"golang.org/x/tools/go/analysis/passes/buildtag"
+	"golang.org/x/tools/go/analysis/passes/cgocall"
+	"golang.org/x/tools/go/analysis/passes/copylock"
+	"golang.org/x/tools/go/analysis/passes/errorsas"
+	"golang.org/x/tools/go/analysis/passes/httpresponse"
+	"golang.org/x/tools/go/analysis/passes/loopclosure"
+	"golang.org/x/tools/go/analysis/passes/lostcancel"
+	"golang.org/x/tools/go/analysis/passes/nilfunc"
+	"golang.org/x/tools/go/analysis/passes/printf"
+	"golang.org/x/tools/go/analysis/passes/shadow"
+	"golang.org/x/tools/go/analysis/passes/shift"
+	"golang.org/x/tools/go/analysis/passes/stdmethods"
+	"golang.org/x/tools/go/analysis/passes/structtag"
+	"golang.org/x/tools/go/analysis/passes/tests"
+	"golang.org/x/tools/go/analysis/passes/unmarshal"
+	"golang.org/x/tools/go/analysis/passes/unreachable"
+	"golang.org/x/tools/go/analysis/passes/unsafeptr"
+	"golang.org/x/tools/go/analysis/passes/unusedresult"
+	"honnef.co/go/tools/staticcheck"
 )
 
+// staticChecks selects staticcheck checks by class prefix ("SA") or by full
+// name ("SA1019").
+var staticChecks = []string{"SA"}
+
 func main() {
-	singlechecker.Main(analyzer.Analyzer)
+	multichecker.Main(analyzers()...)
+}
+
+// analyzers returns every analyzer the linter runs.
+func analyzers() []*analysis.Analyzer {
+	checks := []*analysis.Analyzer{
+		analyzer.Analyzer,
+		analyzer.UncheckedWriteAnalyzer,
+		asmdecl.Analyzer,
+		assign.Analyzer,
+		atomic.Analyzer,
+		bools.Analyzer,
+		buildtag.Analyzer,
+		cgocall.Analyzer,
+		copylock.Analyzer,
+		errorsas.Analyzer,
+		httpresponse.Analyzer,
+		loopclosure.Analyzer,
+		lostcancel.Analyzer,
+		nilfunc.Analyzer,
+		printf.Analyzer,
+		shadow.Analyzer,
+		shift.Analyzer,
+		stdmethods.Analyzer,
+		structtag.Analyzer,
+		tests.Analyzer,
+		unmarshal.Analyzer,
+		unreachable.Analyzer,
+		unsafeptr.Analyzer,
+		unusedresult.Analyzer,
+	}
+
+	for _, check := range staticcheck.Analyzers {
+		if selected(check.Analyzer.Name) {
+			checks = append(checks, check.Analyzer)
+		}
+	}
+
+	return checks
+}
+
+func selected(name string) bool {
+	for _, check := range staticChecks {
+		if strings.HasPrefix(name, check) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+func TestAnalyzers(t *testing.T) {
+	checks := analyzers()
+	if err := analysis.Validate(checks); err != nil {
+		t.Fatalf("analysis.Validate() error = %v", err)
+	}
+
+	names := make(map[string]bool)
+	for _, check := range checks {
+		names[check.Name] = true
+	}
+
+	for _, want := range []string{"forbiddencalls", "uncheckedwrite", "printf", "shadow", "SA1000", "SA4006"} {
+		if !names[want] {
+			t.Errorf("analyzers() is missing %s", want)
+		}
+	}
+	for name := range names {
+		if strings.HasPrefix(name, "S1") || strings.HasPrefix(name, "ST") {
+			t.Errorf("analyzers() includes unselected check %s", name)
+		}
+	}
+}
+
+func TestLinterRuns(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the linter binary")
+	}
+
+	bin := filepath.Join(t.TempDir(), "linter")
+	if out, err := exec.Command("go", "build", "-o", bin, ".").CombinedOutput(); err != nil {
+		t.Fatalf("go build failed: %v\n%s", err, out)
+	}
+
+	out, err := exec.Command(bin, "help").CombinedOutput()
+	if err != nil {
+		t.Fatalf("linter help failed: %v\n%s", err, out)
+	}
+	for _, want := range []string{"forbiddencalls", "uncheckedwrite", "SA4006"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("linter help output does not list %s", want)
+		}
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
-	"runtime/pprof"
 	"syscall"
 
 	"github.com/MikhailRaia/url-shortener/internal/app"
@@ -20,15 +19,26 @@ var (
 	buildCommit  = "N/A"
 )
 
-var memprofile = flag.String("memprofile", "", "write memory profile to `file`")
+// profileFlags holds the profiling flags of the binary.
+type profileFlags struct {
+	cpu string
+	mem string
+}
+
+// parseFlags registers the binary's own flags and the configuration flags on
+// one FlagSet and parses args with it. It returns the configuration and the
+// profile paths.
+func parseFlags(args []string) (*config.Config, profileFlags, error) {
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	cpuprofile := fs.String("cpuprofile", "", "write CPU profile to `file`")
+	memprofile := fs.String("memprofile", "", "write memory profile to `file`")
 
-func writeHeapProfile(path string) {
-	f, err := os.Create(path)
-	if err == nil {
-		runtime.GC()
-		pprof.WriteHeapProfile(f)
-		_ = f.Close()
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		return nil, profileFlags{}, err
 	}
+
+	return cfg, profileFlags{cpu: *cpuprofile, mem: *memprofile}, nil
 }
 
 func main() {
@@ -36,34 +46,40 @@ func main() {
 	fmt.Printf("Build date: %s\n", buildDate)
 	fmt.Printf("Build commit: %s\n", buildCommit)
 
-	if *memprofile != "" {
+	cfg, profiles, err := parseFlags(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+
+	prof, err := startProfiling(profiles.cpu, profiles.mem)
+	if err != nil {
+		log.Fatalf("Error starting profiling: %v", err)
+	}
+
+	if profiles.cpu != "" || profiles.mem != "" {
+		// Flush profiles as soon as a signal arrives in case graceful
+		// shutdown hangs; the application handles the signal itself.
 		c := make(chan os.Signal, 1)
 		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 		go func() {
 			<-c
-			writeHeapProfile(*memprofile)
-			os.Exit(0)
+			prof.Stop()
 		}()
 	}
 
-	cfg, err := config.NewConfig()
-	if err != nil {
-		log.Fatalf("Error loading configuration: %v", err)
-	}
-
 	if cfg.MaxProcs > 0 {
 		runtime.GOMAXPROCS(cfg.MaxProcs)
 	}
 
-	application := app.NewApp(cfg)
+	application, err := app.NewApp(cfg)
+	if err != nil {
+		prof.Stop()
+		log.Fatalf("Error starting application: %v", err)
+	}
 	if err := application.Run(); err != nil {
-		if *memprofile != "" {
-			writeHeapProfile(*memprofile)
-		}
+		prof.Stop()
 		log.Fatalf("Error running application: %v", err)
 	}
 
-	if *memprofile != "" {
-		writeHeapProfile(*memprofile)
-	}
+	prof.Stop()
 }
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseFlags(t *testing.T) {
+	t.Setenv("SERVER_ADDRESS", "")
+	t.Setenv("CONFIG", "")
+
+	profilePath := filepath.Join(t.TempDir(), "mem.pprof")
+
+	tests := []struct {
+		name           string
+		args           []string
+		wantAddress    string
+		wantMemprofile string
+		wantCPUProfile string
+	}{
+		{name: "memprofile before config flag", args: []string{"-memprofile", profilePath, "-a", "localhost:9090"}, wantAddress: "localhost:9090", wantMemprofile: profilePath},
+		{name: "memprofile after config flag", args: []string{"-a=localhost:9091", "-memprofile=" + profilePath}, wantAddress: "localhost:9091", wantMemprofile: profilePath},
+		{name: "cpuprofile and memprofile", args: []string{"-cpuprofile", profilePath + ".cpu", "-memprofile", profilePath, "-a", "localhost:9093"}, wantAddress: "localhost:9093", wantMemprofile: profilePath, wantCPUProfile: profilePath + ".cpu"},
+		{name: "config flag only", args: []string{"-a", "localhost:9092"}, wantAddress: "localhost:9092"},
+		{name: "no flags", args: nil, wantAddress: ":8080"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, profiles, err := parseFlags(tt.args)
+			if err != nil {
+				t.Fatalf("parseFlags() error = %v", err)
+			}
+			if cfg.ServerAddress != tt.wantAddress {
+				t.Errorf("parseFlags() ServerAddress = %v, want %v", cfg.ServerAddress, tt.wantAddress)
+			}
+			if profiles.mem != tt.wantMemprofile {
+				t.Errorf("parseFlags() memprofile = %v, want %v", profiles.mem, tt.wantMemprofile)
+			}
+			if profiles.cpu != tt.wantCPUProfile {
+				t.Errorf("parseFlags() cpuprofile = %v, want %v", profiles.cpu, tt.wantCPUProfile)
+			}
+		})
+	}
+}
+
+func TestProfiling(t *testing.T) {
+	dir := t.TempDir()
+	cpuPath := filepath.Join(dir, "cpu.pprof")
+	memPath := filepath.Join(dir, "mem.pprof")
+
+	prof, err := startProfiling(cpuPath, memPath)
+	if err != nil {
+		t.Fatalf("startProfiling() error = %v", err)
+	}
+
+	sum := 0
+	for i := 0; i < 1_000_000; i++ {
+		sum += i % 7
+	}
+	_ = sum
+
+	prof.Stop()
+	prof.Stop()
+
+	for _, path := range []string{cpuPath, memPath} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Stat(%s) error = %v", path, err)
+		}
+		if info.Size() == 0 {
+			t.Errorf("Expected a non-empty profile at %s", path)
+		}
+	}
+}
+
+func TestProfiling_CreateError(t *testing.T) {
+	if _, err := startProfiling(filepath.Join(t.TempDir(), "missing", "cpu.pprof"), ""); err == nil {
+		t.Error("startProfiling() with an unwritable path: expected an error, got nil")
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+)
+
+// profiler owns the CPU and heap profiles requested with -cpuprofile and
+// -memprofile.
+type profiler struct {
+	cpuFile *os.File
+	memPath string
+	once    sync.Once
+}
+
+// startProfiling starts CPU profiling to cpuPath, if set, and remembers
+// memPath for the heap profile written by Stop.
+func startProfiling(cpuPath, memPath string) (*profiler, error) {
+	p := &profiler{memPath: memPath}
+	if cpuPath == "" {
+		return p, nil
+	}
+
+	f, err := os.Create(cpuPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CPU profile: %w", err)
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("failed to start CPU profile: %w", err)
+	}
+	p.cpuFile = f
+
+	return p, nil
+}
+
+// Stop flushes the CPU profile and writes the heap profile. Only the first
+// call has an effect, so it is safe to call from both the signal handler and
+// the shutdown path.
+func (p *profiler) Stop() {
+	p.once.Do(func() {
+		if p.cpuFile != nil {
+			pprof.StopCPUProfile()
+			if err := p.cpuFile.Close(); err != nil {
+				log.Printf("Error closing CPU profile: %v", err)
+			}
+		}
+		if p.memPath != "" {
+			writeHeapProfile(p.memPath)
+		}
+	})
+}
+
+func writeHeapProfile(path string) {
+	f, err := os.Create(path)
+	if err == nil {
+		runtime.GC()
+		pprof.WriteHeapProfile(f)
+		_ = f.Close()
+	}
+}
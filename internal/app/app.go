@@ -3,92 +3,423 @@ package app
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/MikhailRaia/url-shortener/internal/auth"
+	"github.com/MikhailRaia/url-shortener/internal/baseurl"
 	"github.com/MikhailRaia/url-shortener/internal/config"
+	"github.com/MikhailRaia/url-shortener/internal/generator"
 	"github.com/MikhailRaia/url-shortener/internal/handler"
 	"github.com/MikhailRaia/url-shortener/internal/logger"
+	"github.com/MikhailRaia/url-shortener/internal/metrics"
 	"github.com/MikhailRaia/url-shortener/internal/middleware"
 	"github.com/MikhailRaia/url-shortener/internal/service"
 	"github.com/MikhailRaia/url-shortener/internal/storage"
 	"github.com/MikhailRaia/url-shortener/internal/storage/file"
 	"github.com/MikhailRaia/url-shortener/internal/storage/memory"
 	"github.com/MikhailRaia/url-shortener/internal/storage/postgres"
+	"github.com/MikhailRaia/url-shortener/internal/storage/redis"
+	"github.com/MikhailRaia/url-shortener/internal/storage/sqlite"
 	"github.com/MikhailRaia/url-shortener/internal/tls"
 	"github.com/MikhailRaia/url-shortener/internal/worker"
 	"github.com/rs/zerolog/log"
 )
 
+// StorageError reports a configured storage that failed to initialize.
+type StorageError struct {
+	// Storage names the storage, e.g. PostgreSQL or file.
+	Storage string
+	Err     error
+}
+
+func (e *StorageError) Error() string {
+	return fmt.Sprintf("failed to initialize %s storage: %v", e.Storage, e.Err)
+}
+
+func (e *StorageError) Unwrap() error {
+	return e.Err
+}
+
 // App wires storage, services, middleware, and HTTP handlers and controls the server lifecycle.
 type App struct {
 	config         *config.Config
 	handler        http.Handler
 	dbStorage      *postgres.Storage
+	sqliteStorage  *sqlite.Storage
+	redisStorage   *redis.Storage
+	fileStorage    *file.Storage
 	jwtService     *auth.JWTService
 	authMiddleware *middleware.AuthMiddleware
 	deleteWorker   *worker.DeleteWorkerPool
+	visitRecorder  *worker.VisitRecorder
+	expirySweeper  *worker.ExpirySweeper
+
+	// shutdownRequests receives admin-triggered shutdown requests.
+	shutdownRequests chan struct{}
 }
 
 // NewApp creates and initializes application dependencies and HTTP routes.
-func NewApp(cfg *config.Config) *App {
-	logger.InitLogger()
+// It returns a *StorageError if the configured storage fails to initialize,
+// unless cfg.StorageUnavailable is degrade, which falls back to file storage
+// and then to memory.
+func NewApp(cfg *config.Config) (*App, error) {
+	if err := logger.InitLogger(cfg.LogLevel, cfg.LogFormat); err != nil {
+		_ = logger.InitLogger("", "")
+		log.Warn().Err(err).Msg("Invalid logger configuration, using defaults")
+	}
+	if err := logger.InitAccessLog(cfg.LogAccessFormat); err != nil {
+		_ = logger.InitAccessLog("")
+		log.Warn().Err(err).Msg("Invalid access log format, using json")
+	}
 
 	var urlStorage storage.URLStorage
 	var dbStorage *postgres.Storage
+	var sqliteStorage *sqlite.Storage
+	var redisStorage *redis.Storage
+	var fileStorage *file.Storage
+	var dbPinger handler.DBPinger
 	var err error
 
-	if cfg.DatabaseDSN != "" {
-		dbStorage, err = postgres.NewStorage(cfg.DatabaseDSN)
+	ids, err := generator.New(cfg.IDAlphabet)
+	if err != nil {
+		return nil, err
+	}
+
+	retries, backoff := cfg.DBStartupRetries, time.Duration(cfg.DBStartupBackoff)*time.Second
+	if strings.HasPrefix(cfg.DatabaseDSN, sqlite.DSNPrefix) {
+		err = connectWithRetry("SQLite", retries, backoff, func() (err error) {
+			sqliteStorage, err = sqlite.NewStorage(cfg.DatabaseDSN, sqlite.WithIDGenerator(ids), sqlite.WithDuplicateURLs(cfg.AllowDuplicateURLs))
+			return err
+		})
 		if err != nil {
-			log.Error().Err(err).Msg("Failed to initialize PostgreSQL storage")
+			if err := storageUnavailable(cfg, "SQLite", err); err != nil {
+				return nil, err
+			}
+		} else {
+			log.Info().Str("path", strings.TrimPrefix(cfg.DatabaseDSN, sqlite.DSNPrefix)).Msg("Using SQLite storage")
+			urlStorage = sqliteStorage
+			dbPinger = sqliteStorage
+		}
+	} else if redis.IsDSN(cfg.DatabaseDSN) {
+		err = connectWithRetry("Redis", retries, backoff, func() (err error) {
+			redisStorage, err = redis.NewStorage(cfg.DatabaseDSN, redis.WithIDGenerator(ids), redis.WithDuplicateURLs(cfg.AllowDuplicateURLs))
+			return err
+		})
+		if err != nil {
+			if err := storageUnavailable(cfg, "Redis", err); err != nil {
+				return nil, err
+			}
+		} else {
+			log.Info().Msg("Using Redis storage")
+			urlStorage = redisStorage
+			dbPinger = redisStorage
+		}
+	} else if cfg.DatabaseDSN != "" {
+		err = connectWithRetry("PostgreSQL", retries, backoff, func() (err error) {
+			dbStorage, err = postgres.NewStorage(cfg.DatabaseDSN,
+				postgres.WithMaxConns(cfg.DBMaxConns),
+				postgres.WithMinConns(cfg.DBMinConns),
+				postgres.WithConnMaxLifetime(time.Duration(cfg.DBConnMaxLifetime)*time.Second),
+				postgres.WithMaxIDAttempts(cfg.DBMaxIDAttempts),
+				postgres.WithIDGenerator(ids),
+				postgres.WithDuplicateURLs(cfg.AllowDuplicateURLs))
+			return err
+		})
+		if err != nil {
+			if err := storageUnavailable(cfg, "PostgreSQL", err); err != nil {
+				return nil, err
+			}
 		} else {
 			log.Info().Msg("Using PostgreSQL storage")
 			urlStorage = dbStorage
+			dbPinger = dbStorage
 		}
 	}
 
 	if urlStorage == nil && cfg.FileStoragePath != "" {
-		urlStorage, err = file.NewStorage(cfg.FileStoragePath)
+		fileStorage, err = file.NewStorage(cfg.FileStoragePath,
+			file.WithCacheSize(cfg.FileCacheSize),
+			file.WithBackgroundLoad(cfg.FileBackgroundLoad),
+			file.WithIDGenerator(ids),
+			file.WithDuplicateURLs(cfg.AllowDuplicateURLs))
 		if err != nil {
-			log.Error().Err(err).Str("path", cfg.FileStoragePath).Msg("Failed to initialize file storage, falling back to memory storage")
-			urlStorage = memory.NewStorage()
+			if err := storageUnavailable(cfg, "file", err); err != nil {
+				return nil, err
+			}
 		} else {
-			log.Info().Str("path", cfg.FileStoragePath).Msg("Using file storage")
+			log.Info().Str("path", cfg.FileStoragePath).Bool("backgroundLoad", cfg.FileBackgroundLoad).Msg("Using file storage")
+			if cfg.CompactFileStorage {
+				compact := func() {
+					if err := fileStorage.Compact(); err != nil {
+						log.Error().Err(err).Msg("Failed to compact file storage")
+					} else {
+						log.Info().Msg("File storage compacted")
+					}
+				}
+				// Compaction waits for the load, so keep it off the startup path.
+				if cfg.FileBackgroundLoad {
+					go compact()
+				} else {
+					compact()
+				}
+			}
+			urlStorage = fileStorage
 		}
 	}
 
 	if urlStorage == nil {
-		urlStorage = memory.NewStorage()
+		urlStorage = memory.NewStorage(memory.WithIDGenerator(ids), memory.WithDuplicateURLs(cfg.AllowDuplicateURLs))
 		log.Info().Msg("Using memory storage")
 	}
 
-	urlService := service.NewURLService(urlStorage, cfg.BaseURL)
+	if cfg.URLCacheSize > 0 {
+		urlStorage = storage.NewCachingStorage(urlStorage, cfg.URLCacheSize, time.Duration(cfg.URLCacheTTL)*time.Second)
+		log.Info().Int("size", cfg.URLCacheSize).Int("ttl", cfg.URLCacheTTL).Msg("URL lookup cache enabled")
+	}
+
+	var serviceOpts []service.Option
+	if cfg.NormalizeURLs {
+		serviceOpts = append(serviceOpts, service.WithNormalization(service.NormalizeOptions{
+			StripTrailingSlash: cfg.StripTrailingSlash,
+			StripFragment:      cfg.StripFragment,
+		}))
+		log.Info().Bool("stripTrailingSlash", cfg.StripTrailingSlash).Bool("stripFragment", cfg.StripFragment).Msg("URL normalization enabled")
+	}
+
+	if cfg.AllowDuplicateURLs {
+		serviceOpts = append(serviceOpts, service.WithDuplicateURLs())
+		log.Info().Msg("Duplicate URLs allowed, every shorten creates a new short URL")
+	}
+
+	if cfg.MaxURLsPerUser > 0 {
+		serviceOpts = append(serviceOpts, service.WithMaxURLsPerUser(cfg.MaxURLsPerUser))
+		log.Info().Int("maxURLsPerUser", cfg.MaxURLsPerUser).Msg("Per-user URL quota enabled")
+	}
+
+	var urlSigner *auth.URLSigner
+	if cfg.URLSigningKey != "" {
+		urlSigner = auth.NewURLSigner(cfg.URLSigningKey)
+		serviceOpts = append(serviceOpts, service.WithURLSigner(urlSigner))
+		log.Info().Msg("Signed short URLs enabled")
+	}
+
+	urlService := service.NewURLService(urlStorage, cfg.BaseURL, serviceOpts...)
 
 	// Создаем JWT сервис
-	jwtService := auth.NewJWTService(cfg.JWTSecretKey)
+	jwtService := auth.NewJWTService(cfg.JWTSecretKey, auth.WithRetiredKeys(config.SplitList(cfg.JWTRetiredKeys)...))
 
 	// Создаем middleware для аутентификации
 	authMiddleware := middleware.NewAuthMiddleware(jwtService)
 
-	deleteWorkerConfig := worker.DefaultConfig()
-	deleteWorker := worker.NewDeleteWorkerPool(urlService, deleteWorkerConfig)
-	deleteWorker.Start()
-	log.Info().Msg("Delete worker pool started")
+	var deleteWorker *worker.DeleteWorkerPool
+	var urlDeleter handler.DeleteWorker
+	if cfg.WorkerMode == "sync" {
+		urlDeleter = worker.NewSyncDeleteWorker(urlService)
+		log.Info().Msg("URLs are deleted synchronously")
+	} else {
+		deleteWorkerConfig := worker.DefaultConfig()
+		deleteWorkerConfig.SampleInterval = time.Duration(cfg.QueueSampleInterval) * time.Second
+		if cfg.QueueHistorySize > 0 {
+			deleteWorkerConfig.HistorySize = cfg.QueueHistorySize
+		}
+		deleteWorker = worker.NewDeleteWorkerPool(urlService, deleteWorkerConfig)
+		deleteWorker.Start()
+		urlDeleter = deleteWorker
+		log.Info().Msg("Delete worker pool started")
+	}
 
-	httpHandler := handler.NewHandlerWithDeleteWorker(urlService, dbStorage, deleteWorker)
+	handlerOpts := []handler.Option{
+		handler.WithBodyLimits(cfg.MaxBodyBytes, cfg.MaxBatchBodyBytes),
+		handler.WithMaxBatchSize(cfg.MaxBatchSize),
+		handler.WithGzipLevel(cfg.GzipLevel),
+		handler.WithRateLimits(
+			middleware.RateLimit{Rate: float64(cfg.ShortenRateLimit) / 60, Burst: cfg.ShortenRateBurst},
+			middleware.RateLimit{Rate: float64(cfg.RedirectRateLimit) / 60, Burst: cfg.RedirectRateBurst},
+		),
+		handler.WithTimeouts(time.Duration(cfg.RequestTimeout)*time.Second, time.Duration(cfg.BatchRequestTimeout)*time.Second),
+		handler.WithPingTimeout(time.Duration(cfg.PingTimeout) * time.Second),
+		handler.WithPrecompressedStatic(cfg.PrecompressStatic),
+		handler.WithHTMLRedirects(cfg.HTMLRedirects),
+		handler.WithRedirectETags(cfg.RedirectETags),
+		handler.WithRedirectCacheMaxAge(time.Duration(cfg.RedirectCacheMaxAge) * time.Second),
+		handler.WithAliases(cfg.EnableAliases),
+		handler.WithIDValidation(cfg.ValidateIDs),
+		handler.WithConflictDeprecation(cfg.ConflictDeprecation),
+		handler.WithPasswords(cfg.EnablePasswords),
+		handler.WithQueryForwarding(cfg.EnableQueryForwarding),
+		handler.WithURLSigner(urlSigner),
+		handler.WithMaintenanceRejectShortens(cfg.MaintenanceRejectShortens),
+		handler.WithCreatorCapture(cfg.CaptureCreator),
+		handler.WithIdempotency(time.Duration(cfg.IdempotencyTTL) * time.Second),
+		handler.WithTrustProxyHeaders(cfg.TrustProxyHeaders),
+	}
+	if fileStorage != nil {
+		handlerOpts = append(handlerOpts, handler.WithReadyChecker(fileStorage))
+	}
+	if cfg.EnableMetrics {
+		handlerOpts = append(handlerOpts, handler.WithMetrics(newMetrics(urlStorage, deleteWorker)))
+		log.Info().Msg("Prometheus metrics enabled on /metrics")
+	}
+
+	if cfg.TrustedSubnet != "" {
+		_, subnet, err := net.ParseCIDR(cfg.TrustedSubnet)
+		if err != nil {
+			log.Error().Err(err).Str("subnet", cfg.TrustedSubnet).Msg("Invalid trusted subnet, internal endpoints are disabled")
+		} else {
+			handlerOpts = append(handlerOpts, handler.WithTrustedSubnet(subnet))
+		}
+	}
 
-	return &App{
-		config:       cfg,
-		handler:      httpHandler.RegisterRoutesWithAuth(authMiddleware),
-		dbStorage:    dbStorage,
-		jwtService:   jwtService,
-		deleteWorker: deleteWorker,
+	if cfg.QueueSampleInterval > 0 && deleteWorker != nil {
+		handlerOpts = append(handlerOpts, handler.WithQueueHistory(deleteWorker))
+		log.Info().Int("interval", cfg.QueueSampleInterval).Msg("Delete queue history enabled")
 	}
+
+	var visitRecorder *worker.VisitRecorder
+	if cfg.EnableVisitStats {
+		visitRecorder = worker.NewVisitRecorder(urlService, worker.DefaultVisitConfig())
+		visitRecorder.Start()
+		handlerOpts = append(handlerOpts, handler.WithVisitTracking(visitRecorder))
+		log.Info().Msg("Visit statistics enabled on /api/url/{id}/stats")
+	}
+
+	var expirySweeper *worker.ExpirySweeper
+	if cfg.EnableExpiry {
+		handlerOpts = append(handlerOpts, handler.WithExpiry(true))
+		if cfg.ExpirySweepInterval > 0 {
+			expirySweeper = worker.NewExpirySweeper(urlService,
+				time.Duration(cfg.ExpirySweepInterval)*time.Second,
+				time.Duration(cfg.ExpiryGracePeriod)*time.Second)
+			expirySweeper.Start()
+		}
+		log.Info().Int("sweepInterval", cfg.ExpirySweepInterval).Int("gracePeriod", cfg.ExpiryGracePeriod).Msg("URL expiry enabled")
+	}
+
+	if origins := config.SplitList(cfg.CORSAllowedOrigins); len(origins) > 0 {
+		handlerOpts = append(handlerOpts, handler.WithCORS(middleware.CORSConfig{
+			AllowedOrigins:   origins,
+			AllowedMethods:   config.SplitList(cfg.CORSAllowedMethods),
+			AllowedHeaders:   config.SplitList(cfg.CORSAllowedHeaders),
+			AllowCredentials: cfg.CORSAllowCredentials,
+			MaxAge:           cfg.CORSMaxAge,
+		}))
+		log.Info().Strs("origins", origins).Bool("credentials", cfg.CORSAllowCredentials).Msg("CORS enabled")
+	}
+
+	if cfg.EnableTenants {
+		handlerOpts = append(handlerOpts, handler.WithTenants(cfg.TenantHeader))
+		log.Info().Str("header", cfg.TenantHeader).Msg("Tenant partitioning enabled")
+	}
+
+	if cfg.BaseURL == baseurl.Auto {
+		handlerOpts = append(handlerOpts, handler.WithHostBaseURL())
+		log.Info().Msg("Short URLs use the request host")
+	}
+
+	a := &App{
+		config:           cfg,
+		dbStorage:        dbStorage,
+		sqliteStorage:    sqliteStorage,
+		redisStorage:     redisStorage,
+		fileStorage:      fileStorage,
+		jwtService:       jwtService,
+		deleteWorker:     deleteWorker,
+		visitRecorder:    visitRecorder,
+		expirySweeper:    expirySweeper,
+		shutdownRequests: make(chan struct{}, 1),
+	}
+
+	if cfg.EnableAdminShutdown {
+		if cfg.AdminToken == "" || cfg.TrustedSubnet == "" {
+			log.Error().Msg("Admin shutdown requires an admin token and a trusted subnet, endpoint is disabled")
+		} else {
+			handlerOpts = append(handlerOpts, handler.WithAdminShutdown(cfg.AdminToken, a.requestShutdown))
+			log.Warn().Msg("Admin shutdown endpoint enabled on /api/admin/shutdown")
+		}
+	}
+
+	if cfg.EnableProfiling {
+		if cfg.TrustedSubnet == "" {
+			log.Error().Msg("Profiling requires a trusted subnet, pprof endpoints are disabled")
+		} else {
+			handlerOpts = append(handlerOpts, handler.WithProfiling(true))
+			log.Warn().Msg("Profiling endpoints enabled on /debug/pprof/")
+		}
+	}
+
+	httpHandler := handler.NewHandlerWithDeleteWorker(urlService, dbPinger, urlDeleter, handlerOpts...)
+	if cfg.EnableAuth {
+		a.handler = httpHandler.RegisterRoutesWithAuth(authMiddleware)
+	} else {
+		a.handler = httpHandler.RegisterRoutes()
+		log.Info().Msg("Authentication disabled, user endpoints are not served")
+	}
+
+	return a, nil
+}
+
+// storageUnavailable handles a storage that failed to initialize. It returns
+// a *StorageError, or nil if cfg allows falling back to another storage.
+func storageUnavailable(cfg *config.Config, name string, err error) error {
+	storageErr := &StorageError{Storage: name, Err: err}
+	if cfg.StorageUnavailable != config.StorageUnavailableDegrade {
+		log.Error().Err(err).Msgf("Failed to initialize %s storage, failing startup", name)
+		return storageErr
+	}
+
+	log.Warn().Err(err).Msgf("Failed to initialize %s storage, degrading to fallback storage", name)
+	return nil
+}
+
+// connectWithRetry calls connect until it succeeds or has been retried
+// retries times, doubling the wait between attempts from backoff.
+func connectWithRetry(name string, retries int, backoff time.Duration, connect func() error) error {
+	err := connect()
+	for attempt := 1; err != nil && attempt <= retries; attempt++ {
+		log.Warn().Err(err).Int("attempt", attempt).Dur("backoff", backoff).Msgf("Failed to connect to %s, retrying", name)
+		time.Sleep(backoff)
+		backoff *= 2
+		err = connect()
+	}
+	return err
+}
+
+// requestShutdown asks Run to shut down gracefully, as on SIGTERM.
+// Repeated requests while one is pending are ignored.
+func (a *App) requestShutdown() {
+	select {
+	case a.shutdownRequests <- struct{}{}:
+	default:
+	}
+}
+
+func newMetrics(urlStorage storage.URLStorage, deleteWorker *worker.DeleteWorkerPool) *metrics.Metrics {
+	m := metrics.New()
+
+	if deleteWorker != nil {
+		m.RegisterQueueDepth(func() float64 {
+			return float64(deleteWorker.Stats().QueueSize)
+		})
+	}
+
+	if counter, ok := urlStorage.(storage.URLCounter); ok {
+		m.RegisterStoredURLs(func() float64 {
+			count, err := counter.Count()
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to count stored URLs")
+				return 0
+			}
+			return float64(count)
+		})
+	}
+
+	return m
 }
 
 // Run starts the HTTP server and performs graceful shutdown of resources on exit.
@@ -106,24 +437,69 @@ func (a *App) Run() error {
 }
 
 func (a *App) cleanup() {
-	if a.dbStorage != nil {
-		log.Info().Msg("Closing database connection")
-		a.dbStorage.Close()
-	}
-
+	// Workers flush pending deletions and visits, so storages are closed after them.
 	if a.deleteWorker != nil {
 		log.Info().Msg("Shutting down delete worker pool")
+		start := time.Now()
 		timeout := time.Duration(a.config.WorkerShutdownTimeout) * time.Second
 		if err := a.deleteWorker.Shutdown(timeout); err != nil {
 			log.Error().Err(err).Msg("Error during worker pool shutdown")
 		}
+		log.Info().Dur("duration", time.Since(start)).Msg("Delete worker pool stopped")
+	}
+
+	if a.expirySweeper != nil {
+		log.Info().Msg("Stopping expiry sweeper")
+		a.expirySweeper.Stop()
+	}
+
+	if a.visitRecorder != nil {
+		log.Info().Msg("Flushing visit statistics")
+		start := time.Now()
+		timeout := time.Duration(a.config.WorkerShutdownTimeout) * time.Second
+		if err := a.visitRecorder.Shutdown(timeout); err != nil {
+			log.Error().Err(err).Msg("Error during visit recorder shutdown")
+		}
+		log.Info().Dur("duration", time.Since(start)).Msg("Visit recorder stopped")
+	}
+
+	if a.dbStorage != nil {
+		log.Info().Msg("Closing database connection")
+		a.dbStorage.Close()
+	}
+
+	if a.sqliteStorage != nil {
+		log.Info().Msg("Closing SQLite database")
+		if err := a.sqliteStorage.Close(); err != nil {
+			log.Error().Err(err).Msg("Error closing SQLite database")
+		}
+	}
+
+	if a.redisStorage != nil {
+		log.Info().Msg("Closing Redis connection")
+		if err := a.redisStorage.Close(); err != nil {
+			log.Error().Err(err).Msg("Error closing Redis connection")
+		}
+	}
+
+	if a.fileStorage != nil {
+		log.Info().Msg("Closing file storage")
+		if err := a.fileStorage.Close(); err != nil {
+			log.Error().Err(err).Msg("Error closing file storage")
+		}
 	}
 }
 
+// setupServer builds the HTTP server with the configured timeouts so that slow
+// clients cannot hold connections open indefinitely.
 func (a *App) setupServer() *http.Server {
 	return &http.Server{
-		Addr:    a.config.ServerAddress,
-		Handler: a.handler,
+		Addr:              a.config.ServerAddress,
+		Handler:           a.handler,
+		ReadHeaderTimeout: time.Duration(a.config.ReadHeaderTimeout) * time.Second,
+		ReadTimeout:       time.Duration(a.config.ReadTimeout) * time.Second,
+		WriteTimeout:      time.Duration(a.config.WriteTimeout) * time.Second,
+		IdleTimeout:       time.Duration(a.config.IdleTimeout) * time.Second,
 	}
 }
 
@@ -158,15 +534,19 @@ func (a *App) handleShutdown(server *http.Server, serverError <-chan error) erro
 		return err
 	case sig := <-stop:
 		log.Info().Str("signal", sig.String()).Msg("Shutting down gracefully...")
+	case <-a.shutdownRequests:
+		log.Info().Msg("Shutdown requested, shutting down gracefully...")
+	}
 
-		timeout := time.Duration(a.config.ShutdownTimeout) * time.Second
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
-		defer cancel()
+	start := time.Now()
+	timeout := time.Duration(a.config.ShutdownTimeout) * time.Second
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-		if err := server.Shutdown(shutdownCtx); err != nil {
-			return fmt.Errorf("server shutdown failed: %w", err)
-		}
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("server shutdown failed: %w", err)
 	}
+	log.Info().Dur("duration", time.Since(start)).Msg("HTTP server stopped")
 
 	return nil
 }
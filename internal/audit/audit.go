@@ -0,0 +1,24 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/MikhailRaia/url-shortener/internal/model"
+)
+
+type contextKey string
+
+// CreatorKey is the context key used to store the client creating short URLs.
+const CreatorKey contextKey = "creator"
+
+// WithCreator returns a copy of ctx carrying the client creating short URLs.
+func WithCreator(ctx context.Context, creator model.Creator) context.Context {
+	return context.WithValue(ctx, CreatorKey, creator)
+}
+
+// CreatorFromContext extracts the client creating short URLs from context.
+// It reports false when creator capture is disabled.
+func CreatorFromContext(ctx context.Context) (model.Creator, bool) {
+	creator, ok := ctx.Value(CreatorKey).(model.Creator)
+	return creator, ok
+}
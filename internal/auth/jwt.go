@@ -1,8 +1,12 @@
 package auth
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -23,15 +27,54 @@ type Claims struct {
 }
 
 // JWTService handles issuing and validating JWT tokens.
+// Tokens are signed with the current key and carry its id in the kid header;
+// retired keys are kept for validation only so secrets can be rotated without
+// invalidating existing sessions.
 type JWTService struct {
 	secretKey []byte
+	keyID     string
+	keys      map[string][]byte
 }
 
-// NewJWTService creates a JWT service using the provided secret key.
-func NewJWTService(secretKey string) *JWTService {
-	return &JWTService{
+// Option configures a JWTService.
+type Option func(*JWTService)
+
+// WithRetiredKeys lets the service validate tokens signed with previous
+// secret keys. Retired keys are never used for signing.
+func WithRetiredKeys(secretKeys ...string) Option {
+	return func(j *JWTService) {
+		for _, secretKey := range secretKeys {
+			if secretKey == "" {
+				continue
+			}
+			kid := KeyID(secretKey)
+			if _, ok := j.keys[kid]; ok {
+				continue
+			}
+			j.keys[kid] = []byte(secretKey)
+		}
+	}
+}
+
+// NewJWTService creates a JWT service using the provided secret key for signing.
+func NewJWTService(secretKey string, opts ...Option) *JWTService {
+	kid := KeyID(secretKey)
+	j := &JWTService{
 		secretKey: []byte(secretKey),
+		keyID:     kid,
+		keys:      map[string][]byte{kid: []byte(secretKey)},
+	}
+	for _, opt := range opts {
+		opt(j)
 	}
+	return j
+}
+
+// KeyID returns the key id placed in the kid header of tokens signed with secretKey.
+// It is derived from a hash of the key so it does not reveal the secret.
+func KeyID(secretKey string) string {
+	sum := sha256.Sum256([]byte("jwt-kid:" + secretKey))
+	return hex.EncodeToString(sum[:8])
 }
 
 // GenerateToken issues a signed JWT for the given user ID.
@@ -45,6 +88,7 @@ func (j *JWTService) GenerateToken(userID string) (string, error) {
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = j.keyID
 	tokenString, err := token.SignedString(j.secretKey)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
@@ -54,14 +98,23 @@ func (j *JWTService) GenerateToken(userID string) (string, error) {
 }
 
 // ValidateToken parses and validates a token string and returns its claims.
+// HMAC signatures are verified by the jwt library with hmac.Equal, so the
+// comparison runs in constant time.
 func (j *JWTService) ValidateToken(tokenString string) (*Claims, error) {
+	if !wellFormed(tokenString) {
+		return nil, ErrInvalidToken
+	}
+
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		// Проверяем, что используется именно HS256
-		if token.Method != jwt.SigningMethodHS256 {
+		if alg, _ := token.Header["alg"].(string); alg != jwt.SigningMethodHS256.Alg() || token.Method != jwt.SigningMethodHS256 {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return j.secretKey, nil
-	})
+		if typ, _ := token.Header["typ"].(string); typ != "JWT" {
+			return nil, fmt.Errorf("unexpected token type: %v", token.Header["typ"])
+		}
+		return j.verificationKey(token)
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
@@ -81,3 +134,38 @@ func (j *JWTService) ValidateToken(tokenString string) (*Claims, error) {
 
 	return claims, nil
 }
+
+// verificationKey selects the key for token by its kid header.
+// Tokens issued before key ids were introduced have no kid and are verified
+// with the current key.
+func (j *JWTService) verificationKey(token *jwt.Token) (interface{}, error) {
+	raw, ok := token.Header["kid"]
+	if !ok {
+		return j.secretKey, nil
+	}
+	kid, ok := raw.(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid kid header: %v", raw)
+	}
+	key, ok := j.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id: %s", kid)
+	}
+	return key, nil
+}
+
+// wellFormed reports whether tokenString has exactly three non-empty segments
+// and a signature that decodes as unpadded base64url.
+func wellFormed(tokenString string) bool {
+	segments := strings.Split(tokenString, ".")
+	if len(segments) != 3 {
+		return false
+	}
+	for _, segment := range segments {
+		if segment == "" {
+			return false
+		}
+	}
+	_, err := base64.RawURLEncoding.DecodeString(segments[2])
+	return err == nil
+}
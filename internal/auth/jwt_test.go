@@ -1,6 +1,11 @@
 package auth
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -147,3 +152,173 @@ func TestJWTService_TokenLifetime(t *testing.T) {
 	assert.True(t, timeDiff < time.Second && timeDiff > -time.Second,
 		"Token expiry time should be approximately 24 hours from now")
 }
+
+func TestJWTService_KeyRotation(t *testing.T) {
+	oldService := NewJWTService("old-secret-key")
+	oldToken, err := oldService.GenerateToken("test-user-old")
+	require.NoError(t, err)
+
+	rotated := NewJWTService("new-secret-key", WithRetiredKeys("old-secret-key"))
+
+	newToken, err := rotated.GenerateToken("test-user-new")
+	require.NoError(t, err)
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, &Claims{})
+	require.NoError(t, err)
+	assert.Equal(t, KeyID("new-secret-key"), parsed.Header["kid"])
+
+	claims, err := rotated.ValidateToken(newToken)
+	require.NoError(t, err)
+	assert.Equal(t, "test-user-new", claims.UserID)
+
+	claims, err = rotated.ValidateToken(oldToken)
+	require.NoError(t, err)
+	assert.Equal(t, "test-user-old", claims.UserID)
+
+	_, err = oldService.ValidateToken(newToken)
+	assert.Equal(t, ErrInvalidToken, err)
+
+	withoutRetired := NewJWTService("new-secret-key")
+	_, err = withoutRetired.ValidateToken(oldToken)
+	assert.Equal(t, ErrInvalidToken, err)
+}
+
+func TestJWTService_ValidateToken_KeyID(t *testing.T) {
+	secretKey := "test-secret-key"
+	jwtService := NewJWTService(secretKey, WithRetiredKeys("retired-secret-key"))
+
+	claims := Claims{
+		UserID: "test-user-kid",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	tests := []struct {
+		name    string
+		kid     interface{}
+		signKey string
+		wantErr error
+	}{
+		{name: "legacy token without kid", kid: nil, signKey: secretKey},
+		{name: "retired key", kid: KeyID("retired-secret-key"), signKey: "retired-secret-key"},
+		{name: "unknown kid", kid: "unknown", signKey: secretKey, wantErr: ErrInvalidToken},
+		{name: "kid of another key", kid: KeyID("retired-secret-key"), signKey: secretKey, wantErr: ErrInvalidToken},
+		{name: "non-string kid", kid: 42, signKey: secretKey, wantErr: ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+			if tt.kid != nil {
+				token.Header["kid"] = tt.kid
+			}
+			tokenString, err := token.SignedString([]byte(tt.signKey))
+			require.NoError(t, err)
+
+			validatedClaims, err := jwtService.ValidateToken(tokenString)
+			if tt.wantErr != nil {
+				assert.Equal(t, tt.wantErr, err)
+				assert.Nil(t, validatedClaims)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "test-user-kid", validatedClaims.UserID)
+		})
+	}
+}
+
+func TestJWTService_ValidateToken_Header(t *testing.T) {
+	secretKey := "test-secret-key"
+	jwtService := NewJWTService(secretKey)
+
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(
+		`{"user_id":"test-user-header","exp":%d}`, time.Now().Add(time.Hour).Unix())))
+
+	// craft builds a token with the given header, signed with HMAC-SHA256 regardless of alg.
+	craft := func(header string) string {
+		signingInput := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + payload
+		mac := hmac.New(sha256.New, []byte(secretKey))
+		mac.Write([]byte(signingInput))
+		return signingInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "HS256 JWT", token: craft(`{"alg":"HS256","typ":"JWT"}`)},
+		{name: "alg none", token: base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + payload + ".", wantErr: ErrInvalidToken},
+		{name: "alg RS256", token: craft(`{"alg":"RS256","typ":"JWT"}`), wantErr: ErrInvalidToken},
+		{name: "alg lowercase", token: craft(`{"alg":"hs256","typ":"JWT"}`), wantErr: ErrInvalidToken},
+		{name: "missing alg", token: craft(`{"typ":"JWT"}`), wantErr: ErrInvalidToken},
+		{name: "missing typ", token: craft(`{"alg":"HS256"}`), wantErr: ErrInvalidToken},
+		{name: "wrong typ", token: craft(`{"alg":"HS256","typ":"JWE"}`), wantErr: ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := jwtService.ValidateToken(tt.token)
+			if tt.wantErr != nil {
+				assert.Equal(t, tt.wantErr, err)
+				assert.Nil(t, claims)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "test-user-header", claims.UserID)
+		})
+	}
+}
+
+func TestJWTService_ValidateToken_Tampered(t *testing.T) {
+	jwtService := NewJWTService("test-secret-key")
+
+	token, err := jwtService.GenerateToken("test-user-tampered")
+	require.NoError(t, err)
+
+	segments := strings.Split(token, ".")
+	require.Len(t, segments, 3)
+
+	// flip changes the first character of a base64url segment to another valid one,
+	// altering the decoded bytes.
+	flip := func(segment string) string {
+		replacement := "A"
+		if segment[0] == 'A' {
+			replacement = "B"
+		}
+		return replacement + segment[1:]
+	}
+
+	otherPayload := base64.RawURLEncoding.EncodeToString([]byte(`{"user_id":"attacker"}`))
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "valid token", token: token},
+		{name: "tampered signature", token: segments[0] + "." + segments[1] + "." + flip(segments[2]), wantErr: ErrInvalidToken},
+		{name: "tampered payload", token: segments[0] + "." + otherPayload + "." + segments[2], wantErr: ErrInvalidToken},
+		{name: "empty signature", token: segments[0] + "." + segments[1] + ".", wantErr: ErrInvalidToken},
+		{name: "empty payload", token: segments[0] + ".." + segments[2], wantErr: ErrInvalidToken},
+		{name: "two segments", token: segments[0] + "." + segments[1], wantErr: ErrInvalidToken},
+		{name: "four segments", token: token + "." + segments[2], wantErr: ErrInvalidToken},
+		{name: "signature not base64url", token: segments[0] + "." + segments[1] + ".!!" + segments[2], wantErr: ErrInvalidToken},
+		{name: "padded signature", token: token + "=", wantErr: ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := jwtService.ValidateToken(tt.token)
+			if tt.wantErr != nil {
+				assert.Equal(t, tt.wantErr, err)
+				assert.Nil(t, claims)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "test-user-tampered", claims.UserID)
+		})
+	}
+}
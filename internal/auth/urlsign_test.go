@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestURLSigner_Verify(t *testing.T) {
+	signer := NewURLSigner("link-key")
+	signed := signer.SignedID("abc123")
+
+	tests := []struct {
+		name   string
+		code   string
+		wantID string
+		wantOK bool
+	}{
+		{name: "valid signature", code: signed, wantID: "abc123", wantOK: true},
+		{name: "missing signature", code: "abc123"},
+		{name: "empty signature", code: "abc123."},
+		{name: "empty ID", code: "." + signer.Sign("")},
+		{name: "forged signature", code: "abc123.AAAAAAAAAAAAAAAA"},
+		{name: "signature of another ID", code: "other." + signer.Sign("abc123")},
+		{name: "signature from another key", code: NewURLSigner("other-key").SignedID("abc123")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, ok := signer.Verify(tt.code)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantID, id)
+		})
+	}
+}
+
+func TestURLSigner_SignedID(t *testing.T) {
+	signer := NewURLSigner("link-key")
+
+	signed := signer.SignedID("my.alias")
+	assert.True(t, strings.HasPrefix(signed, "my.alias."))
+	assert.Equal(t, signed, signer.SignedID("my.alias"), "signatures must be deterministic")
+
+	id, ok := signer.Verify(signed)
+	assert.True(t, ok)
+	assert.Equal(t, "my.alias", id)
+}
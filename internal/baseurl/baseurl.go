@@ -0,0 +1,59 @@
+package baseurl
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Auto is the base URL setting that builds short URLs from the host and
+// scheme of each request instead of a fixed base URL.
+const Auto = "auto"
+
+type contextKey string
+
+// Key is the context key used to store the request base URL.
+const Key contextKey = "baseURL"
+
+// WithURL returns a copy of ctx carrying the given base URL.
+func WithURL(ctx context.Context, baseURL string) context.Context {
+	return context.WithValue(ctx, Key, baseURL)
+}
+
+// FromContext extracts the base URL from context.
+// An empty string means no request base URL was resolved.
+func FromContext(ctx context.Context) string {
+	baseURL, _ := ctx.Value(Key).(string)
+	return baseURL
+}
+
+// FromRequest returns the scheme and host the client used to reach the server,
+// e.g. https://short.example. They are taken from the connection and the Host
+// header, or, if trustProxy is set, from X-Forwarded-Proto and X-Forwarded-Host
+// when a reverse proxy set them. Only trust these headers behind a proxy that
+// overwrites them, as clients can send any value.
+func FromRequest(r *http.Request, trustProxy bool) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host := r.Host
+
+	if trustProxy {
+		if proto := strings.ToLower(firstValue(r.Header.Get("X-Forwarded-Proto"))); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwardedHost := firstValue(r.Header.Get("X-Forwarded-Host")); forwardedHost != "" {
+			host = forwardedHost
+		}
+	}
+
+	return scheme + "://" + host
+}
+
+// firstValue returns the first of the comma-separated values a chain of
+// proxies may have appended to a header.
+func firstValue(header string) string {
+	value, _, _ := strings.Cut(header, ",")
+	return strings.TrimSpace(value)
+}
@@ -0,0 +1,64 @@
+package baseurl
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// Format selects how short URLs are written in responses.
+type Format string
+
+// Short URL formats accepted by ParseFormat.
+const (
+	// FormatAbsolute writes the base URL followed by the ID, the default.
+	FormatAbsolute Format = "absolute"
+	// FormatRelative writes the ID as a root-relative path, e.g. /abc123,
+	// for clients serving the shortener behind their own path prefix.
+	FormatRelative Format = "relative"
+	// FormatID writes the bare ID.
+	FormatID Format = "id"
+)
+
+// FormatKey is the context key used to store the requested short URL format.
+const FormatKey contextKey = "shortURLFormat"
+
+// ParseFormat parses a format name. An empty name means FormatAbsolute.
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(name); f {
+	case "":
+		return FormatAbsolute, nil
+	case FormatAbsolute, FormatRelative, FormatID:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown short URL format %q: must be id, relative or absolute", name)
+	}
+}
+
+// WithFormat returns a copy of ctx carrying the given short URL format.
+func WithFormat(ctx context.Context, format Format) context.Context {
+	return context.WithValue(ctx, FormatKey, format)
+}
+
+// FormatFromContext extracts the short URL format from context, defaulting
+// to FormatAbsolute.
+func FormatFromContext(ctx context.Context) Format {
+	if format, ok := ctx.Value(FormatKey).(Format); ok && format != "" {
+		return format
+	}
+	return FormatAbsolute
+}
+
+// ShortURL writes the short URL of id in the given format. baseURL is only
+// used by FormatAbsolute.
+func ShortURL(baseURL, id string, format Format) string {
+	switch format {
+	case FormatID:
+		return id
+	case FormatRelative:
+		return "/" + url.PathEscape(id)
+	default:
+		shortURL, _ := url.JoinPath(baseURL, id)
+		return shortURL
+	}
+}
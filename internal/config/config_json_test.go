@@ -95,3 +95,56 @@ func TestNewConfigJSONPriority(t *testing.T) {
 		t.Errorf("NewConfig() ServerAddress = %v, want %v", cfg.ServerAddress, "env:8080")
 	}
 }
+
+func TestNewConfigJSONSources(t *testing.T) {
+	oldArgs := os.Args
+	oldServerAddress := os.Getenv("SERVER_ADDRESS")
+	oldConfig := os.Getenv("CONFIG")
+
+	defer func() {
+		os.Args = oldArgs
+		os.Setenv("SERVER_ADDRESS", oldServerAddress)
+		os.Setenv("CONFIG", oldConfig)
+	}()
+
+	os.Unsetenv("SERVER_ADDRESS")
+
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configPath, []byte(`{"server_address": "json:8080"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		env     string
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{name: "CONFIG env", env: configPath, args: nil, want: "json:8080"},
+		{name: "-config flag", args: []string{"-config", configPath}, want: "json:8080"},
+		{name: "-config= flag", args: []string{"-config=" + configPath}, want: "json:8080"},
+		{name: "flag overrides JSON", args: []string{"-c", configPath, "-a", "flag:8080"}, want: "flag:8080"},
+		{name: "missing file", args: []string{"-c", filepath.Join(t.TempDir(), "missing.json")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("CONFIG", tt.env)
+			flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+			os.Args = append([]string{"cmd"}, tt.args...)
+
+			cfg, err := NewConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if cfg.ServerAddress != tt.want {
+				t.Errorf("NewConfig() ServerAddress = %v, want %v", cfg.ServerAddress, tt.want)
+			}
+		})
+	}
+}
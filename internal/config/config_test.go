@@ -1,8 +1,11 @@
 package config
 
 import (
+	"errors"
 	"flag"
+	"io"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -35,6 +38,10 @@ func TestNewConfigDefault(t *testing.T) {
 	if cfg.BaseURL != "http://localhost:8080" {
 		t.Errorf("NewConfig() BaseURL = %v, want %v", cfg.BaseURL, "http://localhost:8080")
 	}
+
+	if cfg.TrustProxyHeaders {
+		t.Errorf("NewConfig() TrustProxyHeaders = %v, want %v", cfg.TrustProxyHeaders, false)
+	}
 }
 
 func TestNewConfigWithArgs(t *testing.T) {
@@ -129,3 +136,176 @@ func TestNewConfigEnvOverridesArgs(t *testing.T) {
 		t.Errorf("NewConfig() BaseURL = %v, want %v", cfg.BaseURL, "http://localhost:9000")
 	}
 }
+
+func TestLoadWithCallerFlags(t *testing.T) {
+	t.Setenv("SERVER_ADDRESS", "")
+	t.Setenv("CONFIG", "")
+
+	fs := flag.NewFlagSet("cmd", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	extra := fs.String("extra", "", "flag registered by the caller")
+
+	cfg, err := Load(fs, []string{"-extra", "value", "-a", "localhost:9090"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.ServerAddress != "localhost:9090" {
+		t.Errorf("Load() ServerAddress = %v, want %v", cfg.ServerAddress, "localhost:9090")
+	}
+	if *extra != "value" {
+		t.Errorf("Load() extra = %v, want %v", *extra, "value")
+	}
+
+	fs = flag.NewFlagSet("cmd", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if _, err := Load(fs, []string{"-unknown"}); err == nil {
+		t.Error("Load() with an unknown flag: expected an error, got nil")
+	}
+}
+
+func TestLoadRedirectAndAccessLogFlags(t *testing.T) {
+	t.Setenv("CONFIG", "")
+	t.Setenv("REDIRECT_ETAGS", "")
+	t.Setenv("REDIRECT_CACHE_MAX_AGE", "")
+	t.Setenv("LOG_ACCESS_FORMAT", "")
+
+	fs := flag.NewFlagSet("cmd", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	cfg, err := Load(fs, []string{"-redirect-etags", "-redirect-cache-max-age", "60", "-log-access-format", "combined"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !cfg.RedirectETags {
+		t.Errorf("Load() RedirectETags = %v, want %v", cfg.RedirectETags, true)
+	}
+	if cfg.RedirectCacheMaxAge != 60 {
+		t.Errorf("Load() RedirectCacheMaxAge = %v, want %v", cfg.RedirectCacheMaxAge, 60)
+	}
+	if cfg.LogAccessFormat != "combined" {
+		t.Errorf("Load() LogAccessFormat = %v, want %v", cfg.LogAccessFormat, "combined")
+	}
+}
+
+func TestLoadValidatesEnv(t *testing.T) {
+	t.Setenv("CONFIG", "")
+	t.Setenv("GZIP_LEVEL", "42")
+
+	fs := flag.NewFlagSet("cmd", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	if _, err := Load(fs, nil); err == nil {
+		t.Error("Load() with GZIP_LEVEL=42: expected an error, got nil")
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "defaults", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080"}},
+		{name: "host and port", cfg: Config{ServerAddress: "localhost:8888", BaseURL: "https://short.example/s", MaxProcs: 4}},
+		{name: "base URL without scheme", cfg: Config{ServerAddress: ":8080", BaseURL: "localhost:8080"}, wantErr: true},
+		{name: "relative base URL", cfg: Config{ServerAddress: ":8080", BaseURL: "/short"}, wantErr: true},
+		{name: "auto base URL", cfg: Config{ServerAddress: ":8080", BaseURL: "auto"}},
+		{name: "empty base URL", cfg: Config{ServerAddress: ":8080", BaseURL: ""}, wantErr: true},
+		{name: "address without port", cfg: Config{ServerAddress: "localhost", BaseURL: "http://localhost:8080"}, wantErr: true},
+		{name: "non-numeric port", cfg: Config{ServerAddress: "localhost:http", BaseURL: "http://localhost:8080"}, wantErr: true},
+		{name: "port out of range", cfg: Config{ServerAddress: ":70000", BaseURL: "http://localhost:8080"}, wantErr: true},
+		{name: "negative max procs", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", MaxProcs: -1}, wantErr: true},
+		{name: "negative max batch size", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", MaxBatchSize: -1}, wantErr: true},
+		{name: "best compression gzip level", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", GzipLevel: 9}},
+		{name: "default gzip level", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", GzipLevel: -1}},
+		{name: "gzip level too high", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", GzipLevel: 10}, wantErr: true},
+		{name: "gzip level too low", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", GzipLevel: -3}, wantErr: true},
+		{name: "negative max URLs per user", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", MaxURLsPerUser: -1}, wantErr: true},
+		{name: "sync worker mode", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", WorkerMode: "sync"}},
+		{name: "unknown worker mode", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", WorkerMode: "batch"}, wantErr: true},
+		{name: "degrade storage policy", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", StorageUnavailable: "degrade"}},
+		{name: "unknown storage policy", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", StorageUnavailable: "retry"}, wantErr: true},
+		{name: "negative database startup retries", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", DBStartupRetries: -1}, wantErr: true},
+		{name: "negative database max ID attempts", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", DBMaxIDAttempts: -1}, wantErr: true},
+		{name: "base58 ID alphabet", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", IDAlphabet: "base58"}, wantErr: false},
+		{name: "unknown ID alphabet", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", IDAlphabet: "base32"}, wantErr: true},
+		{name: "negative idempotency TTL", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", IdempotencyTTL: -1}, wantErr: true},
+		{name: "negative rate limit", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", ShortenRateLimit: -1}, wantErr: true},
+		{name: "negative write timeout", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", WriteTimeout: -1}, wantErr: true},
+		{name: "negative ping timeout", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", PingTimeout: -1}, wantErr: true},
+		{name: "db pool limits", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", DBMaxConns: 10, DBMinConns: 2, DBConnMaxLifetime: 300}},
+		{name: "db min conns without max", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", DBMinConns: 2}},
+		{name: "db min conns above max", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", DBMaxConns: 2, DBMinConns: 5}, wantErr: true},
+		{name: "CORS credentials with explicit origins", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", CORSAllowedOrigins: "https://app.example", CORSAllowCredentials: true}},
+		{name: "CORS credentials with wildcard origin", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", CORSAllowedOrigins: "https://app.example, *", CORSAllowCredentials: true}, wantErr: true},
+		{name: "negative db max conns", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", DBMaxConns: -1}, wantErr: true},
+		{name: "dedicated URL signing key", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", JWTSecretKey: "jwt", URLSigningKey: "links"}},
+		{name: "URL signing key reuses JWT key", cfg: Config{ServerAddress: ":8080", BaseURL: "http://localhost:8080", JWTSecretKey: "shared", URLSigningKey: "shared"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Config.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDefaultStoragePath(t *testing.T) {
+	tests := []struct {
+		name     string
+		dataHome string
+		homeDir  string
+		homeErr  error
+		want     string
+	}{
+		{
+			name:     "XDG_DATA_HOME set",
+			dataHome: "/data",
+			homeDir:  "/home/user",
+			want:     filepath.Join("/data", "url-shortener", "storage.json"),
+		},
+		{
+			name:     "relative XDG_DATA_HOME is ignored",
+			dataHome: "data",
+			homeDir:  "/home/user",
+			want:     filepath.Join("/home/user", ".url-shortener", "storage.json"),
+		},
+		{
+			name:    "home set",
+			homeDir: "/home/user",
+			want:    filepath.Join("/home/user", ".url-shortener", "storage.json"),
+		},
+		{
+			name:    "home is the root directory",
+			homeDir: "/",
+			want:    filepath.Join("/tmp", "url-shortener", "storage.json"),
+		},
+		{
+			name:    "both unset",
+			homeErr: errors.New("$HOME is not defined"),
+			want:    filepath.Join("/tmp", "url-shortener", "storage.json"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := pathEnv{
+				getenv: func(key string) string {
+					if key == "XDG_DATA_HOME" {
+						return tt.dataHome
+					}
+					return ""
+				},
+				homeDir: func() (string, error) { return tt.homeDir, tt.homeErr },
+				tempDir: func() string { return "/tmp" },
+			}
+
+			if got := defaultStoragePath(env); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// DefaultIDLength is the length of the short IDs storages generate by default.
+const DefaultIDLength = 8
+
+// ErrSequenceExhausted is returned by a Sequence that has no IDs left.
+var ErrSequenceExhausted = errors.New("ID sequence exhausted")
+
+// IDGenerator generates short IDs for new URLs. Storages generate another ID
+// when one is already taken.
+type IDGenerator interface {
+	Generate() (string, error)
+}
+
+// Random generates random IDs of Length characters with GenerateID.
+type Random struct {
+	Length int
+}
+
+// Generate returns a new random ID.
+func (g Random) Generate() (string, error) {
+	return GenerateID(g.Length)
+}
+
+// Default returns the generator storages use unless given another: random
+// IDs of DefaultIDLength characters.
+func Default() IDGenerator {
+	return Random{Length: DefaultIDLength}
+}
+
+// Sequence is a deterministic IDGenerator for tests. It returns its IDs in
+// order and ErrSequenceExhausted afterwards. It is safe for concurrent use.
+type Sequence struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+// NewSequence creates a Sequence returning ids.
+func NewSequence(ids ...string) *Sequence {
+	return &Sequence{ids: ids}
+}
+
+// Generate returns the next ID of the sequence.
+func (g *Sequence) Generate() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(g.ids) == 0 {
+		return "", ErrSequenceExhausted
+	}
+	id := g.ids[0]
+	g.ids = g.ids[1:]
+	return id, nil
+}
+
+// Alphabets that random short IDs can be drawn from.
+const (
+	// AlphabetBase64URL is the URL-safe base64 alphabet, the default.
+	AlphabetBase64URL = "base64url"
+	// AlphabetBase58 leaves out 0, O, I, l, - and _ so that IDs are easy to
+	// read out and select with a double click.
+	AlphabetBase58 = "base58"
+)
+
+// ErrUnknownAlphabet is returned by New for an unsupported alphabet name.
+var ErrUnknownAlphabet = errors.New("unknown ID alphabet")
+
+// New returns a generator of random IDs of DefaultIDLength characters drawn
+// from the named alphabet. An empty name selects AlphabetBase64URL.
+func New(alphabet string) (IDGenerator, error) {
+	switch alphabet {
+	case "", AlphabetBase64URL:
+		return Default(), nil
+	case AlphabetBase58:
+		return Base58{Length: DefaultIDLength}, nil
+	default:
+		return nil, fmt.Errorf("%w %q: use %s or %s", ErrUnknownAlphabet, alphabet, AlphabetBase64URL, AlphabetBase58)
+	}
+}
+
+// Base58 generates random IDs of Length characters with GenerateBase58ID.
+type Base58 struct {
+	Length int
+}
+
+// Generate returns a new random base58 ID.
+func (g Base58) Generate() (string, error) {
+	return GenerateBase58ID(g.Length)
+}
@@ -0,0 +1,57 @@
+package generator
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSequence(t *testing.T) {
+	g := NewSequence("first", "second")
+
+	for _, want := range []string{"first", "second"} {
+		got, err := g.Generate()
+		if err != nil || got != want {
+			t.Errorf("Generate() = %q, %v, want %q", got, err, want)
+		}
+	}
+
+	if _, err := g.Generate(); !errors.Is(err, ErrSequenceExhausted) {
+		t.Errorf("Generate() past the end error = %v, want ErrSequenceExhausted", err)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	id, err := Default().Generate()
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if len(id) != DefaultIDLength || !ValidID(id) {
+		t.Errorf("Generate() = %q, want a valid ID of length %d", id, DefaultIDLength)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		alphabet string
+		want     IDGenerator
+		wantErr  error
+	}{
+		{name: "default", alphabet: "", want: Random{Length: DefaultIDLength}},
+		{name: "base64url", alphabet: AlphabetBase64URL, want: Random{Length: DefaultIDLength}},
+		{name: "base58", alphabet: AlphabetBase58, want: Base58{Length: DefaultIDLength}},
+		{name: "unknown", alphabet: "base32", wantErr: ErrUnknownAlphabet},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(tt.alphabet)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("New(%q) error = %v, want %v", tt.alphabet, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("New(%q) = %#v, want %#v", tt.alphabet, got, tt.want)
+			}
+		})
+	}
+}
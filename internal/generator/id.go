@@ -3,10 +3,20 @@ package generator
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"strings"
 )
 
-// GenerateID returns a URL-safe random identifier of approximately the given length.
+// alphabet is the URL-safe base64 alphabet GenerateID draws from.
+const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+
+// GenerateID returns a URL-safe random identifier of exactly the given length.
+// If the encoded output is shorter than requested, it is padded with random
+// characters from the same alphabet.
 func GenerateID(length int) (string, error) {
+	if length <= 0 {
+		return "", nil
+	}
+
 	b := make([]byte, length)
 	_, err := rand.Read(b)
 	if err != nil {
@@ -18,5 +28,73 @@ func GenerateID(length int) (string, error) {
 		id = id[:length]
 	}
 
+	if len(id) < length {
+		pad, err := randomFromAlphabet(length - len(id))
+		if err != nil {
+			return "", err
+		}
+		id += pad
+	}
+
 	return id, nil
 }
+
+// base58Alphabet is the Bitcoin base58 alphabet: digits and letters without
+// 0, O, I and l. It is a subset of alphabet, so base58 IDs pass ValidID.
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// GenerateBase58ID returns a random identifier of exactly the given length
+// drawn uniformly from the base58 alphabet.
+func GenerateBase58ID(length int) (string, error) {
+	if length <= 0 {
+		return "", nil
+	}
+
+	// Bytes at or above the largest multiple of the alphabet size are
+	// dropped, so that every character is equally likely.
+	limit := byte(256 - 256%len(base58Alphabet))
+	id := make([]byte, 0, length)
+	buf := make([]byte, length)
+	for len(id) < length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if b >= limit || len(id) == length {
+				continue
+			}
+			id = append(id, base58Alphabet[int(b)%len(base58Alphabet)])
+		}
+	}
+
+	return string(id), nil
+}
+
+func randomFromAlphabet(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	for i := range b {
+		b[i] = alphabet[int(b[i])%len(alphabet)]
+	}
+
+	return string(b), nil
+}
+
+// ValidID reports whether id is non-empty and consists only of characters
+// from the alphabet GenerateID draws from.
+func ValidID(id string) bool {
+	if id == "" {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if strings.IndexByte(alphabet, id[i]) < 0 {
+			return false
+		}
+	}
+
+	return true
+}
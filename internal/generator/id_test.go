@@ -1,6 +1,7 @@
 package generator
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -50,6 +51,39 @@ func TestGenerateID(t *testing.T) {
 	}
 }
 
+func TestGenerateIDExactLength(t *testing.T) {
+	lengths := []int{1, 2, 3, 4, 5, 7, 8, 15, 16, 31, 32, 64}
+
+	for _, length := range lengths {
+		for i := 0; i < 200; i++ {
+			got, err := GenerateID(length)
+			if err != nil {
+				t.Fatalf("GenerateID(%d) error = %v", length, err)
+			}
+			if len(got) != length {
+				t.Fatalf("GenerateID(%d) returned ID with length = %v, want %v", length, len(got), length)
+			}
+		}
+	}
+}
+
+func TestRandomFromAlphabet(t *testing.T) {
+	got, err := randomFromAlphabet(100)
+	if err != nil {
+		t.Fatalf("randomFromAlphabet() error = %v", err)
+	}
+
+	if len(got) != 100 {
+		t.Errorf("randomFromAlphabet() returned length = %v, want %v", len(got), 100)
+	}
+
+	for _, c := range got {
+		if !strings.ContainsRune(alphabet, c) {
+			t.Errorf("randomFromAlphabet() returned character %q outside alphabet", c)
+		}
+	}
+}
+
 func BenchmarkGenerateID8(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		GenerateID(8)
@@ -67,3 +101,41 @@ func BenchmarkGenerateID32(b *testing.B) {
 		GenerateID(32)
 	}
 }
+
+func TestGenerateBase58ID(t *testing.T) {
+	lengths := []int{0, 1, 7, 8, 16, 64}
+	for _, length := range lengths {
+		for i := 0; i < 200; i++ {
+			got, err := GenerateBase58ID(length)
+			if err != nil {
+				t.Fatalf("GenerateBase58ID(%d) error = %v", length, err)
+			}
+			if len(got) != length {
+				t.Fatalf("GenerateBase58ID(%d) returned ID with length = %v, want %v", length, len(got), length)
+			}
+			if strings.ContainsAny(got, "0OIl-_") {
+				t.Fatalf("GenerateBase58ID(%d) = %q, contains an excluded character", length, got)
+			}
+			if length > 0 && !ValidID(got) {
+				t.Fatalf("GenerateBase58ID(%d) = %q, not a valid ID", length, got)
+			}
+		}
+	}
+}
+
+func TestGenerateBase58IDUsesWholeAlphabet(t *testing.T) {
+	seen := make(map[rune]bool)
+	for i := 0; i < 200; i++ {
+		id, err := GenerateBase58ID(64)
+		if err != nil {
+			t.Fatalf("GenerateBase58ID() error = %v", err)
+		}
+		for _, c := range id {
+			seen[c] = true
+		}
+	}
+
+	if len(seen) != len(base58Alphabet) {
+		t.Errorf("GenerateBase58ID() used %d distinct characters, want %d", len(seen), len(base58Alphabet))
+	}
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MikhailRaia/url-shortener/internal/auth"
+	"github.com/MikhailRaia/url-shortener/internal/middleware"
+	"github.com/MikhailRaia/url-shortener/internal/service"
+	"github.com/MikhailRaia/url-shortener/internal/storage/memory"
+)
+
+func TestHandler_AuthRoutes(t *testing.T) {
+	jwtService := auth.NewJWTService("test-secret")
+	validToken, err := jwtService.GenerateToken("user1")
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	urlService := service.NewURLService(memory.NewStorage(), "http://localhost:8080")
+	router := NewHandler(urlService, nil).RegisterRoutesWithAuth(middleware.NewAuthMiddleware(jwtService))
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		token      string
+		wantStatus int
+		wantCookie bool
+	}{
+		{name: "shorten mints a user", method: http.MethodPost, path: "/", body: "https://example.com", wantStatus: http.StatusCreated, wantCookie: true},
+		{name: "shorten with invalid token mints a user", method: http.MethodPost, path: "/", body: "https://example.org", token: "garbage", wantStatus: http.StatusCreated, wantCookie: true},
+		{name: "shorten keeps a valid user", method: http.MethodPost, path: "/", body: "https://example.net", token: validToken, wantStatus: http.StatusCreated},
+		{name: "user URLs without token", method: http.MethodGet, path: "/api/user/urls", wantStatus: http.StatusUnauthorized},
+		{name: "user URLs with invalid token", method: http.MethodGet, path: "/api/user/urls", token: "garbage", wantStatus: http.StatusUnauthorized},
+		{name: "deleted user URLs without token", method: http.MethodGet, path: "/api/user/urls/deleted", wantStatus: http.StatusUnauthorized},
+		{name: "delete user URLs without token", method: http.MethodDelete, path: "/api/user/urls", body: `["abc123"]`, wantStatus: http.StatusUnauthorized},
+		{name: "user URLs with valid token list the shortened URL", method: http.MethodGet, path: "/api/user/urls", token: validToken, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.method == http.MethodDelete {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if tt.token != "" {
+				req.AddCookie(&http.Cookie{Name: "auth_token", Value: tt.token})
+			}
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected status code %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if gotCookie := len(rr.Result().Cookies()) > 0; gotCookie != tt.wantCookie {
+				t.Errorf("Expected a new auth cookie: %v, got cookies %v", tt.wantCookie, rr.Result().Cookies())
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/MikhailRaia/url-shortener/internal/model"
+)
+
+// maxBatchJSONDepth is the deepest nesting a batch body may have: an array of
+// flat objects. Deeper documents are rejected before decoding.
+const maxBatchJSONDepth = 2
+
+var (
+	errBatchTooDeep      = errors.New("batch JSON is nested too deeply")
+	errBatchTrailingData = errors.New("unexpected data after batch JSON array")
+)
+
+// decodeBatch parses a batch request body strictly: it must be a single JSON
+// array of batch items with no unknown fields, no nesting below the items and
+// nothing after the array. The body size is bounded by the MaxBodySize
+// middleware on the batch routes.
+func decodeBatch(body []byte) ([]model.BatchRequestItem, error) {
+	if jsonDepth(body) > maxBatchJSONDepth {
+		return nil, errBatchTooDeep
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+
+	var items []model.BatchRequestItem
+	if err := decoder.Decode(&items); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, errBatchTrailingData
+	}
+
+	return items, nil
+}
+
+// jsonDepth returns the deepest array or object nesting in data, ignoring
+// brackets inside strings. It does not validate data.
+func jsonDepth(data []byte) int {
+	depth, deepest := 0, 0
+	inString, escaped := false, false
+	for _, c := range data {
+		switch {
+		case escaped:
+			escaped = false
+		case inString:
+			switch c {
+			case '\\':
+				escaped = true
+			case '"':
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '[' || c == '{':
+			depth++
+			deepest = max(deepest, depth)
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return deepest
+}
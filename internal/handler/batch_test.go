@@ -4,11 +4,19 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/MikhailRaia/url-shortener/internal/middleware"
 	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/service"
+	"github.com/MikhailRaia/url-shortener/internal/storage/file"
+	"github.com/MikhailRaia/url-shortener/internal/storage/memory"
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -24,6 +32,10 @@ func (m *MockBatchURLService) ShortenURLWithUser(ctx context.Context, originalUR
 	return "http://localhost:8080/abc123", nil
 }
 
+func (m *MockBatchURLService) ShortenURLWithAlias(ctx context.Context, originalURL, alias, userID string) (string, error) {
+	return "http://localhost:8080/" + alias, nil
+}
+
 func (m *MockBatchURLService) GetOriginalURL(ctx context.Context, id string) (string, bool) {
 	if id == "abc123" {
 		return "https://example.com", true
@@ -57,6 +69,14 @@ func (m *MockBatchURLService) GetUserURLs(ctx context.Context, userID string) ([
 	return []model.UserURL{}, nil
 }
 
+func (m *MockBatchURLService) GetUserURLsPaged(ctx context.Context, userID string, order model.UserURLOrder, limit, offset int) ([]model.UserURL, int, error) {
+	return []model.UserURL{}, 0, nil
+}
+
+func (m *MockBatchURLService) GetDeletedUserURLs(ctx context.Context, userID string) ([]string, error) {
+	return []string{}, nil
+}
+
 func (m *MockBatchURLService) GetOriginalURLWithDeletedStatus(ctx context.Context, id string) (string, error) {
 	if id == "abc123" {
 		return "https://example.com", nil
@@ -140,3 +160,200 @@ func TestHandleShortenBatchEmptyRequest(t *testing.T) {
 
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 }
+
+func TestHandleShortenBatchValidation(t *testing.T) {
+	tests := []struct {
+		name         string
+		contentType  string
+		body         string
+		expectedCode int
+		expectedErr  string
+	}{
+		{
+			name:         "within limit",
+			body:         `[{"correlation_id":"1","original_url":"https://example.com"},{"correlation_id":"2","original_url":"https://example.org"}]`,
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "too many items",
+			body:         `[{"correlation_id":"1","original_url":"https://example.com"},{"correlation_id":"2","original_url":"https://example.org"},{"correlation_id":"3","original_url":"https://example.net"}]`,
+			expectedCode: http.StatusRequestEntityTooLarge,
+			expectedErr:  ErrCodeBatchTooLarge,
+		},
+		{
+			name:         "empty URL",
+			body:         `[{"correlation_id":"1","original_url":""}]`,
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  ErrCodeInvalidBatchItem,
+		},
+		{
+			name:         "missing correlation ID",
+			body:         `[{"original_url":"https://example.com"}]`,
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  ErrCodeInvalidBatchItem,
+		},
+		{
+			name:         "duplicate correlation ID",
+			body:         `[{"correlation_id":"1","original_url":"https://example.com"},{"correlation_id":"1","original_url":"https://example.org"}]`,
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  ErrCodeInvalidBatchItem,
+		},
+		{
+			name:         "malformed URL",
+			body:         `[{"correlation_id":"1","original_url":"not a url"}]`,
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  ErrCodeInvalidBatchItem,
+		},
+		{
+			name:         "non-JSON content type",
+			contentType:  "text/plain",
+			body:         `[{"correlation_id":"1","original_url":"https://example.com"}]`,
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  ErrCodeInvalidContentType,
+		},
+	}
+
+	h := NewHandler(&MockBatchURLService{}, nil, WithMaxBatchSize(2))
+
+	paths := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{name: "unauthenticated", handler: h.handleShortenBatch},
+		{name: "authenticated", handler: h.handleShortenBatchWithAuth},
+	}
+
+	for _, path := range paths {
+		for _, tt := range tests {
+			t.Run(path.name+"/"+tt.name, func(t *testing.T) {
+				contentType := tt.contentType
+				if contentType == "" {
+					contentType = "application/json"
+				}
+				req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", bytes.NewBufferString(tt.body))
+				req.Header.Set("Content-Type", contentType)
+				req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "user1"))
+
+				rec := httptest.NewRecorder()
+				path.handler(rec, req)
+
+				assert.Equal(t, tt.expectedCode, rec.Code)
+				if tt.expectedErr == "" {
+					return
+				}
+
+				var response ErrorResponse
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+				assert.Equal(t, tt.expectedErr, response.Error.Code)
+			})
+		}
+	}
+}
+
+func TestHandleShortenBatchCreatedStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		urls       []string
+		wantStatus int
+	}{
+		{name: "all new", urls: []string{"https://example.com/a", "https://example.com/b"}, wantStatus: http.StatusCreated},
+		{name: "all existing", urls: []string{"https://example.com/a", "https://example.com/b"}, wantStatus: http.StatusOK},
+		{name: "mixed", urls: []string{"https://example.com/a", "https://example.com/c"}, wantStatus: http.StatusCreated},
+		{name: "all existing with repeats", urls: []string{"https://example.com/c", "https://example.com/c"}, wantStatus: http.StatusOK},
+	}
+
+	for _, withUser := range []bool{false, true} {
+		urlStorage, err := file.NewStorage(filepath.Join(t.TempDir(), "urls.json"))
+		require.NoError(t, err)
+		h := NewHandler(service.NewURLService(urlStorage, "http://localhost:8080"), nil)
+
+		handle := h.handleShortenBatch
+		if withUser {
+			handle = h.handleShortenBatchWithAuth
+		}
+
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s/user=%v", tt.name, withUser), func(t *testing.T) {
+				items := make([]model.BatchRequestItem, len(tt.urls))
+				for i, u := range tt.urls {
+					items[i] = model.BatchRequestItem{CorrelationID: strconv.Itoa(i), OriginalURL: u}
+				}
+				body, err := json.Marshal(items)
+				require.NoError(t, err)
+
+				req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", bytes.NewBuffer(body))
+				req.Header.Set("Content-Type", "application/json")
+				if withUser {
+					req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "user1"))
+				}
+				rec := httptest.NewRecorder()
+
+				handle(rec, req)
+
+				assert.Equal(t, tt.wantStatus, rec.Code)
+
+				var response []model.BatchResponseItem
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+				assert.Len(t, response, len(tt.urls))
+			})
+		}
+	}
+}
+
+func TestHandleShortenBatchStrictJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "unknown field", body: `[{"correlation_id":"1","original_url":"https://example.com","alias":"x"}]`},
+		{name: "trailing data", body: `[{"correlation_id":"1","original_url":"https://example.com"}] []`},
+		{name: "nested too deeply", body: `[{"correlation_id":"1","original_url":["https://example.com"]}]`},
+		{name: "deep nesting", body: strings.Repeat("[", 100000) + strings.Repeat("]", 100000)},
+		{name: "object instead of array", body: `{"correlation_id":"1","original_url":"https://example.com"}`},
+	}
+
+	h := NewHandler(&MockBatchURLService{}, nil)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			h.handleShortenBatch(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, ErrCodeInvalidBody, decodeError(t, rec).Code)
+		})
+	}
+}
+
+// FuzzHandleShortenBatch feeds arbitrary bodies to the batch handler, which
+// must answer 2xx or 4xx and never fail with 5xx on malformed input.
+// Run it with: go test -run '^$' -fuzz FuzzHandleShortenBatch ./internal/handler
+func FuzzHandleShortenBatch(f *testing.F) {
+	f.Add([]byte(`[{"correlation_id":"1","original_url":"https://example.com/a"}]`))
+	f.Add([]byte(`[{"correlation_id":"1","original_url":"https://example.com/a"},{"correlation_id":"2","original_url":"https://example.com/a"}]`))
+	f.Add([]byte(`[{"correlation_id":"1","original_url":"not a url"}]`))
+	f.Add([]byte(`[{"correlation_id":"1","original_url":"https://example.com","extra":true}]`))
+	f.Add([]byte(`[{"correlation_id":"1","original_url":"https://example.com"}] trailing`))
+	f.Add([]byte(`[null, 1, "a", {}]`))
+	f.Add([]byte(`[[[[[[[[]]]]]]]]`))
+	f.Add([]byte(`{"a":"\u0000\"]["}`))
+	f.Add([]byte(`null`))
+	f.Add([]byte(``))
+
+	h := NewHandler(service.NewURLService(memory.NewStorage(), "http://localhost:8080"), nil)
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+
+		h.handleShortenBatch(rec, req)
+
+		if rec.Code < 200 || rec.Code >= 500 || (rec.Code >= 300 && rec.Code < 400) {
+			t.Errorf("Expected a 2xx or 4xx status for body %q, got %d: %s", body, rec.Code, rec.Body.String())
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/MikhailRaia/url-shortener/internal/audit"
+	"github.com/MikhailRaia/url-shortener/internal/middleware"
+	"github.com/MikhailRaia/url-shortener/internal/model"
+)
+
+// maxCreatorUserAgent caps the stored User-Agent so clients cannot bloat
+// records with huge headers.
+const maxCreatorUserAgent = 512
+
+// CreatorURLService is implemented by services that can report who created a
+// short URL.
+type CreatorURLService interface {
+	URLCreator(ctx context.Context, id string) (model.Creator, error)
+}
+
+// WithCreatorCapture records the client IP and User-Agent of every shortened
+// URL for audit. It is off by default because the data identifies users.
+func WithCreatorCapture(enabled bool) Option {
+	return func(h *Handler) {
+		h.captureCreator = enabled
+	}
+}
+
+// captureCreatorInfo stores the client IP and User-Agent in context, where the
+// URL service picks them up when it creates a short URL. The IP is the remote
+// address, so RealIP must run first behind a reverse proxy.
+func (h *Handler) captureCreatorInfo(next http.Handler) http.Handler {
+	if !h.captureCreator {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+
+		userAgent := r.UserAgent()
+		if len(userAgent) > maxCreatorUserAgent {
+			userAgent = userAgent[:maxCreatorUserAgent]
+		}
+
+		creator := model.Creator{IP: host, UserAgent: userAgent}
+		next.ServeHTTP(w, r.WithContext(audit.WithCreator(r.Context(), creator)))
+	})
+}
+
+// creatorFor returns the creator of id for the stats response. It is only
+// revealed to clients within the trusted subnet; nil means omit it.
+func (h *Handler) creatorFor(r *http.Request, id string) (*model.Creator, error) {
+	if !middleware.InSubnet(r, h.trustedSubnet) {
+		return nil, nil
+	}
+
+	creatorService, ok := h.urlService.(CreatorURLService)
+	if !ok {
+		return nil, nil
+	}
+
+	creator, err := creatorService.URLCreator(r.Context(), id)
+	if err != nil || creator.IsZero() {
+		return nil, err
+	}
+	return &creator, nil
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/service"
+	"github.com/MikhailRaia/url-shortener/internal/storage/memory"
+	"github.com/MikhailRaia/url-shortener/internal/worker"
+)
+
+func TestHandler_CreatorCapture(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("192.0.2.0/24")
+	longUserAgent := strings.Repeat("a", maxCreatorUserAgent+100)
+
+	tests := []struct {
+		name        string
+		capture     bool
+		userAgent   string
+		statsRemote string
+		want        *model.Creator
+	}{
+		{
+			name:        "trusted client sees creator",
+			capture:     true,
+			userAgent:   "curl/8.5.0",
+			statsRemote: "192.0.2.10:1234",
+			want:        &model.Creator{IP: "198.51.100.7", UserAgent: "curl/8.5.0"},
+		},
+		{
+			name:        "untrusted client does not see creator",
+			capture:     true,
+			userAgent:   "curl/8.5.0",
+			statsRemote: "203.0.113.1:1234",
+		},
+		{
+			name:        "capture disabled",
+			capture:     false,
+			userAgent:   "curl/8.5.0",
+			statsRemote: "192.0.2.10:1234",
+		},
+		{
+			name:        "user agent is truncated",
+			capture:     true,
+			userAgent:   longUserAgent,
+			statsRemote: "192.0.2.10:1234",
+			want:        &model.Creator{IP: "198.51.100.7", UserAgent: longUserAgent[:maxCreatorUserAgent]},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlService := service.NewURLService(memory.NewStorage(), "http://short.example")
+			recorder := worker.NewVisitRecorder(urlService, worker.VisitConfig{
+				BufferSize:    10,
+				BatchSize:     10,
+				FlushInterval: time.Hour,
+			})
+			router := NewHandler(urlService, nil,
+				WithVisitTracking(recorder),
+				WithTrustedSubnet(subnet),
+				WithCreatorCapture(tt.capture),
+			).RegisterRoutes()
+
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://example.com/audited"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("User-Agent", tt.userAgent)
+			req.RemoteAddr = "198.51.100.7:40000"
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+			}
+			var shortened ShortenResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &shortened); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			id := strings.TrimPrefix(shortened.Result, "http://short.example/")
+
+			req = httptest.NewRequest(http.MethodGet, "/api/url/"+id+"/stats", nil)
+			req.RemoteAddr = tt.statsRemote
+			rec = httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+			}
+			var stats URLStatsResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+				t.Fatalf("Failed to unmarshal stats: %v", err)
+			}
+
+			switch {
+			case tt.want == nil && stats.Creator != nil:
+				t.Errorf("Expected no creator, got %+v", *stats.Creator)
+			case tt.want != nil && (stats.Creator == nil || *stats.Creator != *tt.want):
+				t.Errorf("Expected creator %+v, got %+v", *tt.want, stats.Creator)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/MikhailRaia/url-shortener/internal/middleware"
+	"github.com/rs/zerolog/log"
+)
+
+// Error codes returned in the JSON error envelope.
+const (
+	ErrCodeUnauthorized          = "unauthorized"
+	ErrCodeInvalidContentType    = "invalid_content_type"
+	ErrCodeInvalidBody           = "invalid_body"
+	ErrCodeBodyTooLarge          = "body_too_large"
+	ErrCodeMissingURL            = "missing_url"
+	ErrCodeInvalidRequest        = "invalid_request"
+	ErrCodeEmptyBatch            = "empty_batch"
+	ErrCodeBatchTooLarge         = "batch_too_large"
+	ErrCodeInvalidBatchItem      = "invalid_batch_item"
+	ErrCodeInvalidImportRecord   = "invalid_import_record"
+	ErrCodeInvalidPage           = "invalid_page"
+	ErrCodeInvalidSort           = "invalid_sort"
+	ErrCodeInvalidFormat         = "invalid_format"
+	ErrCodeFeatureDisabled       = "feature_disabled"
+	ErrCodeInvalidAlias          = "invalid_alias"
+	ErrCodeAliasTaken            = "alias_taken"
+	ErrCodeInvalidPassword       = "invalid_password"
+	ErrCodeInvalidExpiry         = "invalid_expiry"
+	ErrCodeForbidden             = "forbidden"
+	ErrCodeNotFound              = "not_found"
+	ErrCodeGone                  = "gone"
+	ErrCodeNotImplemented        = "not_implemented"
+	ErrCodeQueueFull             = "queue_full"
+	ErrCodeShuttingDown          = "shutting_down"
+	ErrCodeQuotaExceeded         = "quota_exceeded"
+	ErrCodeInvalidIdempotencyKey = "invalid_idempotency_key"
+	ErrCodeRequestInProgress     = "request_in_progress"
+	ErrCodeIdempotencyKeyReused  = "idempotency_key_reused"
+	ErrCodeDatabaseUnavailable   = "database_unavailable"
+	ErrCodeMaintenance           = "maintenance"
+	ErrCodeInternal              = "internal_error"
+)
+
+// deleteRetryAfter is how long, in seconds, clients are asked to wait before
+// retrying a deletion rejected because the delete queue is full.
+const deleteRetryAfter = 5
+
+// ErrorResponse is the body of error responses from JSON endpoints.
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail describes an error with a stable machine-readable code and a
+// human-readable message.
+type ErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// writeJSONError writes the JSON error envelope with the given status.
+func writeJSONError(w http.ResponseWriter, status int, code, message string) {
+	response, err := json.Marshal(ErrorResponse{Error: ErrorDetail{Code: code, Message: message}})
+	if err != nil {
+		w.WriteHeader(status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(response); err != nil {
+		log.Debug().Err(err).Msg("Failed to write error response")
+	}
+}
+
+// writeBodyError reports a request body read error in the JSON error envelope.
+func writeBodyError(w http.ResponseWriter, err error) {
+	status := bodyErrorStatus(err)
+	if status == http.StatusRequestEntityTooLarge {
+		writeJSONError(w, status, ErrCodeBodyTooLarge, "request body is too large")
+		return
+	}
+	if errors.Is(err, middleware.ErrInvalidGzip) {
+		writeJSONError(w, status, ErrCodeInvalidBody, "request body is not valid gzip")
+		return
+	}
+	writeJSONError(w, status, ErrCodeInvalidBody, "failed to read request body")
+}
+
+// writeInternalError reports an unexpected server-side failure.
+func writeInternalError(w http.ResponseWriter) {
+	writeJSONError(w, http.StatusInternalServerError, ErrCodeInternal, "internal server error")
+}
+
+// writeQueueFull reports that the delete queue cannot take more work and
+// tells the client when to retry.
+func writeQueueFull(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(deleteRetryAfter))
+	writeJSONError(w, http.StatusServiceUnavailable, ErrCodeQueueFull, "delete queue is full, retry later")
+}
+
+// writeQuotaExceeded reports that the user owns the maximum number of URLs.
+func writeQuotaExceeded(w http.ResponseWriter) {
+	writeJSONError(w, http.StatusForbidden, ErrCodeQuotaExceeded, "URL quota exceeded, delete some URLs first")
+}
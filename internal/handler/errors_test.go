@@ -0,0 +1,245 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MikhailRaia/url-shortener/internal/middleware"
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/service"
+	"github.com/MikhailRaia/url-shortener/internal/storage"
+	"github.com/MikhailRaia/url-shortener/internal/storage/memory"
+	"github.com/MikhailRaia/url-shortener/internal/worker"
+)
+
+type fullDeleteWorker struct{}
+
+func (fullDeleteWorker) Submit(userID string, urlIDs []string) error {
+	return worker.ErrQueueFull
+}
+
+func decodeError(t *testing.T, rr *httptest.ResponseRecorder) ErrorDetail {
+	t.Helper()
+
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected Content-Type application/json, got %q", ct)
+	}
+
+	var response ErrorResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal error response %q: %v", rr.Body.String(), err)
+	}
+	if response.Error.Message == "" {
+		t.Errorf("Expected an error message, got none")
+	}
+	return response.Error
+}
+
+func TestHandler_JSONErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantCode    string
+	}{
+		{name: "wrong content type", path: "/api/shorten", contentType: "text/plain", body: `{"url":"https://example.com"}`, wantStatus: http.StatusBadRequest, wantCode: ErrCodeInvalidContentType},
+		{name: "malformed JSON", path: "/api/shorten", contentType: "application/json", body: `{"url":`, wantStatus: http.StatusBadRequest, wantCode: ErrCodeInvalidBody},
+		{name: "missing URL", path: "/api/shorten", contentType: "application/json", body: `{}`, wantStatus: http.StatusBadRequest, wantCode: ErrCodeMissingURL},
+		{name: "alias disabled", path: "/api/shorten", contentType: "application/json", body: `{"url":"https://example.com","alias":"docs"}`, wantStatus: http.StatusBadRequest, wantCode: ErrCodeFeatureDisabled},
+		{name: "empty batch", path: "/api/shorten/batch", contentType: "application/json", body: `[]`, wantStatus: http.StatusBadRequest, wantCode: ErrCodeEmptyBatch},
+		{name: "internal error", path: "/api/shorten/batch", contentType: "application/json", body: `[{"correlation_id":"1","original_url":"https://example.com"}]`, wantStatus: http.StatusInternalServerError, wantCode: ErrCodeInternal},
+	}
+
+	mockService := &mockURLService{
+		shortenBatchFunc: func(ctx context.Context, items []model.BatchRequestItem) ([]model.BatchResponseItem, error) {
+			return nil, errors.New("storage unavailable")
+		},
+	}
+	router := NewHandler(mockService, nil).RegisterRoutes()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected status code %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if got := decodeError(t, rr); got.Code != tt.wantCode {
+				t.Errorf("Expected error code %q, got %q", tt.wantCode, got.Code)
+			}
+		})
+	}
+}
+
+func TestHandler_DeleteQueueFull(t *testing.T) {
+	handler := NewHandlerWithDeleteWorker(&mockURLService{}, nil, fullDeleteWorker{})
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/user/urls", strings.NewReader(`["abc123"]`))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "user1"))
+	rr := httptest.NewRecorder()
+
+	handler.handleDeleteUserURLs(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status code %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+	if got := rr.Header().Get("Retry-After"); got != strconv.Itoa(deleteRetryAfter) {
+		t.Errorf("Expected Retry-After %d, got %q", deleteRetryAfter, got)
+	}
+	if got := decodeError(t, rr); got.Code != ErrCodeQueueFull {
+		t.Errorf("Expected error code %q, got %q", ErrCodeQueueFull, got.Code)
+	}
+}
+
+func TestHandler_DeletePoolFull(t *testing.T) {
+	pool := worker.NewDeleteWorkerPool(&mockURLService{}, worker.Config{WorkerCount: 1, BufferSize: 1, BatchSize: 10, BatchTimeout: time.Second})
+	t.Cleanup(func() { pool.Shutdown(time.Second) })
+	handler := NewHandlerWithDeleteWorker(&mockURLService{}, nil, pool)
+
+	// The pool is not started, so the first request fills its queue.
+	if err := pool.Submit("user1", []string{"queued"}); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/user/urls", strings.NewReader(`["abc123"]`))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "user1"))
+	rr := httptest.NewRecorder()
+
+	handler.handleDeleteUserURLs(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status code %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+	if got := rr.Header().Get("Retry-After"); got != strconv.Itoa(deleteRetryAfter) {
+		t.Errorf("Expected Retry-After %d, got %q", deleteRetryAfter, got)
+	}
+	if got := decodeError(t, rr); got.Code != ErrCodeQueueFull {
+		t.Errorf("Expected error code %q, got %q", ErrCodeQueueFull, got.Code)
+	}
+}
+
+func TestHandler_DeletePoolShutDown(t *testing.T) {
+	pool := worker.NewDeleteWorkerPool(&mockURLService{}, worker.Config{WorkerCount: 1, BufferSize: 1, BatchSize: 10, BatchTimeout: time.Second})
+	pool.Shutdown(time.Second)
+	handler := NewHandlerWithDeleteWorker(&mockURLService{}, nil, pool)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/user/urls", strings.NewReader(`["abc123"]`))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "user1"))
+	rr := httptest.NewRecorder()
+
+	handler.handleDeleteUserURLs(rr, req)
+
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status code %d, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "" {
+		t.Errorf("Expected no Retry-After, got %q", got)
+	}
+	if got := decodeError(t, rr); got.Code != ErrCodeShuttingDown {
+		t.Errorf("Expected error code %q, got %q", ErrCodeShuttingDown, got.Code)
+	}
+}
+
+func TestHandler_QuotaExceeded(t *testing.T) {
+	urlService := service.NewURLService(memory.NewStorage(), "http://localhost:8080", service.WithMaxURLsPerUser(1))
+	handler := NewHandler(urlService, nil)
+
+	tests := []struct {
+		name       string
+		serve      http.HandlerFunc
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{name: "just below quota", serve: handler.HandleShortenJSONWithAuth, body: `{"url":"https://a.example.com"}`, wantStatus: http.StatusCreated},
+		{name: "at quota", serve: handler.HandleShortenJSONWithAuth, body: `{"url":"https://b.example.com"}`, wantStatus: http.StatusForbidden, wantCode: ErrCodeQuotaExceeded},
+		{name: "batch at quota", serve: handler.handleShortenBatchWithAuth, body: `[{"correlation_id":"1","original_url":"https://b.example.com"}]`, wantStatus: http.StatusForbidden, wantCode: ErrCodeQuotaExceeded},
+		{name: "text at quota", serve: handler.handleShortenWithAuth, body: "https://b.example.com", wantStatus: http.StatusForbidden, wantCode: ErrCodeQuotaExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if strings.HasPrefix(tt.body, "https://") {
+				req.Header.Set("Content-Type", "text/plain")
+			} else {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "user1"))
+			rr := httptest.NewRecorder()
+
+			tt.serve(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected status code %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if tt.wantCode == "" {
+				return
+			}
+			if got := decodeError(t, rr); got.Code != tt.wantCode {
+				t.Errorf("Expected error code %q, got %q", tt.wantCode, got.Code)
+			}
+		})
+	}
+}
+
+func TestHandler_DeleteSyncWorker(t *testing.T) {
+	urlStorage := memory.NewStorage()
+	urlService := service.NewURLService(urlStorage, "http://localhost:8080")
+	id, _ := urlStorage.SaveWithUser("https://example.com/gone", "user1")
+
+	handler := NewHandlerWithDeleteWorker(urlService, nil, worker.NewSyncDeleteWorker(urlService))
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/user/urls", strings.NewReader(`["`+id+`"]`))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "user1"))
+	rr := httptest.NewRecorder()
+
+	handler.handleDeleteUserURLs(rr, req)
+
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected status code %d, got %d", http.StatusAccepted, rr.Code)
+	}
+	if _, err := urlStorage.GetWithDeletedStatus(id); !errors.Is(err, storage.ErrURLDeleted) {
+		t.Errorf("Expected URL to be deleted once the response is written, got %v", err)
+	}
+}
+
+func TestHandler_DeleteSyncWorkerError(t *testing.T) {
+	urlService := &mockURLService{
+		deleteUserURLsFunc: func(userID string, urlIDs []string) error {
+			return errors.New("storage unavailable")
+		},
+	}
+	handler := NewHandlerWithDeleteWorker(urlService, nil, worker.NewSyncDeleteWorker(urlService))
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/user/urls", strings.NewReader(`["abc123"]`))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "user1"))
+	rr := httptest.NewRecorder()
+
+	handler.handleDeleteUserURLs(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, rr.Code)
+	}
+	if got := decodeError(t, rr); got.Code != ErrCodeInternal {
+		t.Errorf("Expected error code %q, got %q", ErrCodeInternal, got.Code)
+	}
+}
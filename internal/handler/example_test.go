@@ -34,6 +34,13 @@ func (s *exampleURLService) ShortenURLWithUser(ctx context.Context, originalURL,
 	return fmt.Sprintf("%s/%s", s.baseURL, id), nil
 }
 
+func (s *exampleURLService) ShortenURLWithAlias(ctx context.Context, originalURL, alias, userID string) (string, error) {
+	if err := s.Storage.SaveWithAlias(alias, originalURL, userID); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%s", s.baseURL, alias), nil
+}
+
 func (s *exampleURLService) GetOriginalURL(ctx context.Context, id string) (string, bool) {
 	return s.Storage.Get(id)
 }
@@ -50,13 +57,13 @@ func (s *exampleURLService) ShortenBatch(ctx context.Context, items []model.Batc
 
 	result := make([]model.BatchResponseItem, 0, len(items))
 	for _, item := range items {
-		id, ok := idMap[item.CorrelationID]
+		saved, ok := idMap[item.CorrelationID]
 		if !ok {
 			continue
 		}
 		result = append(result, model.BatchResponseItem{
 			CorrelationID: item.CorrelationID,
-			ShortURL:      fmt.Sprintf("%s/%s", s.baseURL, id),
+			ShortURL:      fmt.Sprintf("%s/%s", s.baseURL, saved.ID),
 		})
 	}
 	return result, nil
@@ -70,13 +77,13 @@ func (s *exampleURLService) ShortenBatchWithUser(ctx context.Context, items []mo
 
 	result := make([]model.BatchResponseItem, 0, len(items))
 	for _, item := range items {
-		id, ok := idMap[item.CorrelationID]
+		saved, ok := idMap[item.CorrelationID]
 		if !ok {
 			continue
 		}
 		result = append(result, model.BatchResponseItem{
 			CorrelationID: item.CorrelationID,
-			ShortURL:      fmt.Sprintf("%s/%s", s.baseURL, id),
+			ShortURL:      fmt.Sprintf("%s/%s", s.baseURL, saved.ID),
 		})
 	}
 	return result, nil
@@ -98,6 +105,26 @@ func (s *exampleURLService) GetUserURLs(ctx context.Context, userID string) ([]m
 	return result, nil
 }
 
+func (s *exampleURLService) GetUserURLsPaged(ctx context.Context, userID string, order model.UserURLOrder, limit, offset int) ([]model.UserURL, int, error) {
+	urls, total, err := s.Storage.GetUserURLsPaged(userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result := make([]model.UserURL, len(urls))
+	for i, url := range urls {
+		result[i] = model.UserURL{
+			ShortURL:    fmt.Sprintf("%s/%s", s.baseURL, url.ShortURL),
+			OriginalURL: url.OriginalURL,
+		}
+	}
+	return result, total, nil
+}
+
+func (s *exampleURLService) GetDeletedUserURLs(ctx context.Context, userID string) ([]string, error) {
+	return s.Storage.GetDeletedUserURLs(userID)
+}
+
 func (s *exampleURLService) DeleteUserURLs(userID string, urlIDs []string) error {
 	return s.Storage.DeleteUserURLs(userID, urlIDs)
 }
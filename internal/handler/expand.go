@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/rs/zerolog/log"
+)
+
+// ExpandURLService is implemented by services that can resolve many short IDs at once.
+type ExpandURLService interface {
+	ExpandBatch(ctx context.Context, ids []string) ([]model.ExpandedURL, error)
+}
+
+// handleExpandBatch resolves a JSON array of short IDs to their original URLs
+// for POST /api/expand/batch, answering one item per ID in request order.
+// When URL signing is on, the IDs must be signed codes; codes with a missing
+// or forged signature are answered as unknown.
+func (h *Handler) handleExpandBatch(w http.ResponseWriter, r *http.Request) {
+	expandService, ok := h.urlService.(ExpandURLService)
+	if !ok {
+		writeJSONError(w, http.StatusNotImplemented, ErrCodeNotImplemented, "expanding short URLs is not supported")
+		return
+	}
+
+	if !strings.Contains(r.Header.Get("Content-Type"), "application/json") {
+		writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidContentType, "Content-Type must be application/json")
+		return
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	var codes []string
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &codes); err != nil {
+			writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidBody, "request body is not a valid JSON array of short IDs")
+			return
+		}
+	}
+
+	if len(codes) == 0 {
+		writeJSONError(w, http.StatusBadRequest, ErrCodeEmptyBatch, "batch must not be empty")
+		return
+	}
+
+	if h.maxBatchSize > 0 && len(codes) > h.maxBatchSize {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, ErrCodeBatchTooLarge, "batch must have at most "+strconv.Itoa(h.maxBatchSize)+" items")
+		return
+	}
+
+	ids := make([]string, 0, len(codes))
+	verified := make([]bool, len(codes))
+	for i, code := range codes {
+		if id, ok := h.verifiedID(code); ok {
+			ids = append(ids, id)
+			verified[i] = true
+		}
+	}
+
+	expanded, err := expandService.ExpandBatch(r.Context(), ids)
+	if err != nil {
+		log.Error().Err(err).Int("ids", len(ids)).Msg("Failed to expand short URLs")
+		writeInternalError(w)
+		return
+	}
+
+	// Items echo the codes as sent; unverified codes are reported as unknown.
+	result := make([]model.ExpandedURL, len(codes))
+	for i, code := range codes {
+		if verified[i] {
+			result[i] = expanded[0]
+			expanded = expanded[1:]
+		}
+		result[i].ID = code
+	}
+
+	response, err := json.Marshal(result)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to marshal expand response")
+		writeInternalError(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
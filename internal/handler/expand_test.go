@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/service"
+	"github.com/MikhailRaia/url-shortener/internal/storage/memory"
+)
+
+func TestHandler_ExpandBatch(t *testing.T) {
+	urlStorage := memory.NewStorage()
+	kept, _ := urlStorage.SaveWithUser("https://a.example.com", "user1")
+	gone, _ := urlStorage.SaveWithUser("https://b.example.com", "user1")
+	urlStorage.DeleteUserURLs("user1", []string{gone})
+
+	urlService := service.NewURLService(urlStorage, "http://localhost:8080")
+	router := NewHandler(urlService, nil, WithMaxBatchSize(3)).RegisterRoutes()
+
+	tests := []struct {
+		name         string
+		contentType  string
+		body         string
+		expectedCode int
+		expectedErr  string
+		expected     []model.ExpandedURL
+	}{
+		{
+			name:         "mixed known, unknown and deleted IDs",
+			contentType:  "application/json",
+			body:         `["` + kept + `","missing","` + gone + `"]`,
+			expectedCode: http.StatusOK,
+			expected: []model.ExpandedURL{
+				{ID: kept, OriginalURL: "https://a.example.com"},
+				{ID: "missing"},
+				{ID: gone, Deleted: true},
+			},
+		},
+		{
+			name:         "empty batch",
+			contentType:  "application/json",
+			body:         `[]`,
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  ErrCodeEmptyBatch,
+		},
+		{
+			name:         "invalid JSON",
+			contentType:  "application/json",
+			body:         `{"id":"abc"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  ErrCodeInvalidBody,
+		},
+		{
+			name:         "batch too large",
+			contentType:  "application/json",
+			body:         `["a","b","c","d"]`,
+			expectedCode: http.StatusRequestEntityTooLarge,
+			expectedErr:  ErrCodeBatchTooLarge,
+		},
+		{
+			name:         "wrong content type",
+			contentType:  "text/plain",
+			body:         `["a"]`,
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  ErrCodeInvalidContentType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/expand/batch", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedCode {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.expectedCode, rr.Code, rr.Body.String())
+			}
+			if tt.expectedErr != "" {
+				if detail := decodeError(t, rr); detail.Code != tt.expectedErr {
+					t.Errorf("Expected error code %q, got %q", tt.expectedErr, detail.Code)
+				}
+				return
+			}
+
+			var got []model.ExpandedURL
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatalf("Failed to unmarshal response %q: %v", rr.Body.String(), err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestHandler_ExpandBatchNotImplemented(t *testing.T) {
+	router := NewHandler(&MockBatchURLService{}, nil).RegisterRoutes()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/expand/batch", strings.NewReader(`["a"]`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotImplemented, rr.Code)
+	}
+}
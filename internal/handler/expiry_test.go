@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MikhailRaia/url-shortener/internal/service"
+	"github.com/MikhailRaia/url-shortener/internal/storage/memory"
+)
+
+func TestHandler_ShortenWithExpiry(t *testing.T) {
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		name       string
+		enabled    bool
+		body       string
+		wantStatus int
+	}{
+		{name: "expires_in", enabled: true, body: `{"url":"https://a.example.com","expires_in":3600}`, wantStatus: http.StatusCreated},
+		{name: "expires_at", enabled: true, body: `{"url":"https://b.example.com","expires_at":"` + future + `"}`, wantStatus: http.StatusCreated},
+		{name: "both fields", enabled: true, body: `{"url":"https://c.example.com","expires_in":60,"expires_at":"` + future + `"}`, wantStatus: http.StatusBadRequest},
+		{name: "non-positive expires_in", enabled: true, body: `{"url":"https://d.example.com","expires_in":0}`, wantStatus: http.StatusBadRequest},
+		{name: "expires_at in the past", enabled: true, body: `{"url":"https://e.example.com","expires_at":"` + past + `"}`, wantStatus: http.StatusBadRequest},
+		{name: "expiry disabled", enabled: false, body: `{"url":"https://f.example.com","expires_in":3600}`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlService := service.NewURLService(memory.NewStorage(), "http://short.example")
+			router := NewHandler(urlService, nil, WithExpiry(tt.enabled)).RegisterRoutes()
+
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status code %d, got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestHandler_RedirectExpired(t *testing.T) {
+	urlStorage := memory.NewStorage()
+	urlService := service.NewURLService(urlStorage, "http://short.example")
+	router := NewHandler(urlService, nil, WithExpiry(true)).RegisterRoutes()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://example.com","expires_in":3600}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d", http.StatusCreated, rec.Code)
+	}
+
+	var response ShortenResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	id := strings.TrimPrefix(response.Result, "http://short.example/")
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+id, nil))
+	if rec.Code != http.StatusTemporaryRedirect {
+		t.Errorf("Expected status code %d before expiry, got %d", http.StatusTemporaryRedirect, rec.Code)
+	}
+
+	urlStorage.SetExpiry(id, time.Now().Add(-time.Second))
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+id, nil))
+	if rec.Code != http.StatusGone {
+		t.Errorf("Expected status code %d after expiry, got %d", http.StatusGone, rec.Code)
+	}
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/service"
+	"github.com/rs/zerolog/log"
+)
+
+// ExportURLService is implemented by services that can stream all stored URLs.
+type ExportURLService interface {
+	ExportURLs(ctx context.Context, fn func(model.URLRecord) error) error
+}
+
+// handleExport streams every stored URL as newline-delimited JSON records
+// that POST /api/admin/import accepts. The status is sent with the first
+// record, so an error after that can only be logged and cuts the stream short.
+func (h *Handler) handleExport(w http.ResponseWriter, r *http.Request) {
+	exportService, ok := h.urlService.(ExportURLService)
+	if !ok {
+		writeJSONError(w, http.StatusNotImplemented, ErrCodeNotImplemented, "exporting URLs is not supported")
+		return
+	}
+
+	encoder := json.NewEncoder(w)
+	started := false
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+	}
+
+	exported := 0
+	err := exportService.ExportURLs(r.Context(), func(record model.URLRecord) error {
+		start()
+		exported++
+		return encoder.Encode(record)
+	})
+	if err != nil {
+		if started {
+			log.Error().Err(err).Int("exported", exported).Msg("URL export interrupted")
+			return
+		}
+		if errors.Is(err, service.ErrExportUnsupported) {
+			writeJSONError(w, http.StatusNotImplemented, ErrCodeNotImplemented, "storage does not support exporting URLs")
+			return
+		}
+		log.Error().Err(err).Msg("Failed to export URLs")
+		writeInternalError(w)
+		return
+	}
+
+	start()
+	log.Info().Int("exported", exported).Str("remote", r.RemoteAddr).Msg("URLs exported")
+}
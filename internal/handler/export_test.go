@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/service"
+	"github.com/MikhailRaia/url-shortener/internal/storage/file"
+)
+
+// newExportRouter serves a fresh file storage to the 10.0.0.0/8 trusted subnet.
+func newExportRouter(t *testing.T) (http.Handler, *file.Storage) {
+	t.Helper()
+
+	_, subnet, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("ParseCIDR() error = %v", err)
+	}
+
+	urlStorage, err := file.NewStorage(filepath.Join(t.TempDir(), "urls.json"))
+	if err != nil {
+		t.Fatalf("file.NewStorage() error = %v", err)
+	}
+	t.Cleanup(func() { urlStorage.Close() })
+
+	urlService := service.NewURLService(urlStorage, "http://localhost:8080")
+	return NewHandler(urlService, nil, WithTrustedSubnet(subnet), WithTrustProxyHeaders(true)).RegisterRoutes(), urlStorage
+}
+
+func exportRecords(t *testing.T, router http.Handler) []model.URLRecord {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/export", nil)
+	req.Header.Set("X-Real-IP", "10.1.2.3")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Expected Content-Type application/x-ndjson, got %q", ct)
+	}
+
+	var records []model.URLRecord
+	decoder := json.NewDecoder(rr.Body)
+	for {
+		var record model.URLRecord
+		if err := decoder.Decode(&record); errors.Is(err, io.EOF) {
+			return records
+		} else if err != nil {
+			t.Fatalf("Failed to decode exported record: %v", err)
+		}
+		records = append(records, record)
+	}
+}
+
+func TestHandler_ExportImportRoundTrip(t *testing.T) {
+	source, sourceStorage := newExportRouter(t)
+
+	kept, _ := sourceStorage.SaveWithUser("https://a.example.com", "user1")
+	gone, _ := sourceStorage.SaveWithUser("https://b.example.com", "user1")
+	expiring, _ := sourceStorage.Save("https://c.example.com")
+	protected, _ := sourceStorage.Save("https://d.example.com")
+	sourceStorage.DeleteUserURLs("user1", []string{gone})
+	sourceStorage.SetExpiry(expiring, time.Now().Add(time.Hour).UTC().Truncate(time.Second))
+	sourceStorage.SetPasswordHash(protected, "$2a$10$hash")
+
+	exported := exportRecords(t, source)
+	if len(exported) != 4 {
+		t.Fatalf("Expected 4 exported records, got %d: %v", len(exported), exported)
+	}
+
+	var dump bytes.Buffer
+	encoder := json.NewEncoder(&dump)
+	for _, record := range exported {
+		encoder.Encode(record)
+	}
+
+	destination, destinationStorage := newExportRouter(t)
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/import", &dump)
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("X-Real-IP", "10.1.2.3")
+	rr := httptest.NewRecorder()
+	destination.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected import status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	if reimported := exportRecords(t, destination); !reflect.DeepEqual(reimported, exported) {
+		t.Errorf("Expected re-export %v, got %v", exported, reimported)
+	}
+
+	if got, found := destinationStorage.Get(kept); !found || got != "https://a.example.com" {
+		t.Errorf("Get(%s) = %v, %v, want %v, true", kept, got, found, "https://a.example.com")
+	}
+	if _, err := destinationStorage.GetWithDeletedStatus(gone); err == nil {
+		t.Errorf("GetWithDeletedStatus(%s) error = nil, want deleted", gone)
+	}
+	if urls, _ := destinationStorage.GetUserURLs("user1"); len(urls) != 1 || urls[0].ShortURL != kept {
+		t.Errorf("GetUserURLs(user1) = %v, want [%s]", urls, kept)
+	}
+}
+
+func TestHandler_ExportUntrusted(t *testing.T) {
+	router, _ := newExportRouter(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/export", nil)
+	req.Header.Set("X-Real-IP", "192.168.1.1")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d, got %d", http.StatusForbidden, rr.Code)
+	}
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/MikhailRaia/url-shortener/internal/baseurl"
+)
+
+// FormatParam is the query parameter selecting how shorten endpoints write
+// short URLs: id, relative or absolute (the default).
+const FormatParam = "format"
+
+// shortURLFormat stores the short URL format requested with the format query
+// parameter in context, where the URL service picks it up. Unknown formats
+// are rejected with 400.
+func shortURLFormat(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get(FormatParam)
+		if name == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		format, err := baseurl.ParseFormat(name)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidFormat, err.Error())
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(baseurl.WithFormat(r.Context(), format)))
+	})
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/service"
+	"github.com/MikhailRaia/url-shortener/internal/storage/memory"
+)
+
+func TestHandler_ShortURLFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		prefix string
+	}{
+		{name: "default", query: "", prefix: "http://short.example/s/"},
+		{name: "absolute", query: "?format=absolute", prefix: "http://short.example/s/"},
+		{name: "relative", query: "?format=relative", prefix: "/"},
+		{name: "id", query: "?format=id", prefix: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlService := service.NewURLService(memory.NewStorage(), "http://short.example/s")
+			router := NewHandler(urlService, nil).RegisterRoutes()
+
+			post := func(path, contentType, body string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodPost, path+tt.query, strings.NewReader(body))
+				req.Header.Set("Content-Type", contentType)
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+				if rec.Code != http.StatusCreated {
+					t.Fatalf("POST %s: expected status code %d, got %d: %s", path, http.StatusCreated, rec.Code, rec.Body.String())
+				}
+				return rec
+			}
+
+			var results []string
+			results = append(results, post("/", "text/plain", "https://example.com/text").Body.String())
+
+			var shortened ShortenResponse
+			if err := json.Unmarshal(post("/api/shorten", "application/json", `{"url":"https://example.com/json"}`).Body.Bytes(), &shortened); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			results = append(results, shortened.Result)
+
+			var batch []model.BatchResponseItem
+			body := `[{"correlation_id":"1","original_url":"https://example.com/batch"}]`
+			if err := json.Unmarshal(post("/api/shorten/batch", "application/json", body).Body.Bytes(), &batch); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if len(batch) != 1 {
+				t.Fatalf("Expected 1 batch item, got %d", len(batch))
+			}
+			results = append(results, batch[0].ShortURL)
+
+			for _, result := range results {
+				id, ok := strings.CutPrefix(result, tt.prefix)
+				if !ok || id == "" || strings.Contains(id, "/") {
+					t.Errorf("Expected a short URL of the form %q + ID, got %q", tt.prefix, result)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_ShortURLFormat_Invalid(t *testing.T) {
+	urlService := service.NewURLService(memory.NewStorage(), "http://short.example")
+	router := NewHandler(urlService, nil).RegisterRoutes()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten?format=full", strings.NewReader(`{"url":"https://example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status code %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if detail := decodeError(t, rec); detail.Code != ErrCodeInvalidFormat {
+		t.Errorf("Expected error code %q, got %q", ErrCodeInvalidFormat, detail.Code)
+	}
+}
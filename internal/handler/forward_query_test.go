@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/MikhailRaia/url-shortener/internal/service"
+	"github.com/MikhailRaia/url-shortener/internal/storage/memory"
+)
+
+func TestForwardQuery(t *testing.T) {
+	tests := []struct {
+		name        string
+		originalURL string
+		query       string
+		want        string
+	}{
+		{
+			name:        "no stored query",
+			originalURL: "https://dest.example.com/page",
+			query:       "utm_source=x",
+			want:        "https://dest.example.com/page?utm_source=x",
+		},
+		{
+			name:        "stored parameters take precedence",
+			originalURL: "https://dest.example.com/page?utm_source=stored&id=1",
+			query:       "utm_source=incoming&utm_medium=email",
+			want:        "https://dest.example.com/page?utm_source=stored&id=1&utm_medium=email",
+		},
+		{
+			name:        "repeated parameters",
+			originalURL: "https://dest.example.com/",
+			query:       "tag=a&tag=b",
+			want:        "https://dest.example.com/?tag=a&tag=b",
+		},
+		{
+			name:        "password is not forwarded",
+			originalURL: "https://dest.example.com/",
+			query:       "pw=secret",
+			want:        "https://dest.example.com/",
+		},
+		{
+			name:        "fragment is kept",
+			originalURL: "https://dest.example.com/page#section",
+			query:       "ref=short",
+			want:        "https://dest.example.com/page?ref=short#section",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("ParseQuery() error = %v", err)
+			}
+
+			if got := forwardQuery(tt.originalURL, query); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHandler_QueryForwarding(t *testing.T) {
+	urlService := service.NewURLService(memory.NewStorage(), "http://short.example")
+	router := NewHandler(urlService, nil, WithQueryForwarding(true)).RegisterRoutes()
+
+	shorten := func(body string) string {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+		}
+		var response ShortenResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		return strings.TrimPrefix(response.Result, "http://short.example/")
+	}
+
+	forwarding := shorten(`{"url":"https://dest.example.com/a?utm_source=stored","forward_query":true}`)
+	plain := shorten(`{"url":"https://dest.example.com/b"}`)
+
+	tests := []struct {
+		name   string
+		target string
+		want   string
+	}{
+		{name: "passthrough on", target: "/" + forwarding + "?utm_source=x&utm_campaign=y", want: "https://dest.example.com/a?utm_source=stored&utm_campaign=y"},
+		{name: "passthrough on without query", target: "/" + forwarding, want: "https://dest.example.com/a?utm_source=stored"},
+		{name: "passthrough off", target: "/" + plain + "?utm_source=x", want: "https://dest.example.com/b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if rec.Code != http.StatusTemporaryRedirect {
+				t.Fatalf("Expected status code %d, got %d", http.StatusTemporaryRedirect, rec.Code)
+			}
+			if got := rec.Header().Get("Location"); got != tt.want {
+				t.Errorf("Expected Location %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHandler_ShortenWithForwardQuery(t *testing.T) {
+	tests := []struct {
+		name       string
+		enabled    bool
+		body       string
+		wantStatus int
+		wantErr    string
+	}{
+		{name: "enabled", enabled: true, body: `{"url":"https://a.example.com","forward_query":true}`, wantStatus: http.StatusCreated},
+		{name: "disabled", enabled: false, body: `{"url":"https://b.example.com","forward_query":true}`, wantStatus: http.StatusBadRequest, wantErr: ErrCodeFeatureDisabled},
+		{name: "with alias", enabled: true, body: `{"url":"https://c.example.com","forward_query":true,"alias":"custom"}`, wantStatus: http.StatusBadRequest, wantErr: ErrCodeInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlService := service.NewURLService(memory.NewStorage(), "http://short.example")
+			router := NewHandler(urlService, nil, WithQueryForwarding(tt.enabled)).RegisterRoutes()
+
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status code %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantErr == "" {
+				return
+			}
+			if detail := decodeError(t, rec); detail.Code != tt.wantErr {
+				t.Errorf("Expected error code %q, got %q", tt.wantErr, detail.Code)
+			}
+		})
+	}
+}
@@ -26,6 +26,10 @@ func (m *MockGzipURLService) ShortenURLWithUser(ctx context.Context, originalURL
 	return "http://localhost:8080/abc123", nil
 }
 
+func (m *MockGzipURLService) ShortenURLWithAlias(ctx context.Context, originalURL, alias, userID string) (string, error) {
+	return "http://localhost:8080/" + alias, nil
+}
+
 func (m *MockGzipURLService) GetOriginalURL(ctx context.Context, id string) (string, bool) {
 	if id == "abc123" {
 		return "https://example.com", true
@@ -59,6 +63,14 @@ func (m *MockGzipURLService) GetUserURLs(ctx context.Context, userID string) ([]
 	return []model.UserURL{}, nil
 }
 
+func (m *MockGzipURLService) GetUserURLsPaged(ctx context.Context, userID string, order model.UserURLOrder, limit, offset int) ([]model.UserURL, int, error) {
+	return []model.UserURL{}, 0, nil
+}
+
+func (m *MockGzipURLService) GetDeletedUserURLs(ctx context.Context, userID string) ([]string, error) {
+	return []string{}, nil
+}
+
 func (m *MockGzipURLService) GetOriginalURLWithDeletedStatus(ctx context.Context, id string) (string, error) {
 	if id == "abc123" {
 		return "https://example.com", nil
@@ -198,3 +210,57 @@ func TestTextPlainGzipCompression(t *testing.T) {
 		t.Errorf("Expected result to be %s, got %s", "http://localhost:8080/abc123", string(body))
 	}
 }
+
+func TestHandleShortenGzipBodies(t *testing.T) {
+	gzipped := func(body string) []byte {
+		var buf bytes.Buffer
+		gzWriter := gzip.NewWriter(&buf)
+		if _, err := gzWriter.Write([]byte(body)); err != nil {
+			t.Fatalf("Failed to write to gzip writer: %v", err)
+		}
+		gzWriter.Close()
+		return buf.Bytes()
+	}
+
+	valid := gzipped("https://example.com/gzipped")
+	corrupt := append([]byte(nil), valid...)
+	for i := 10; i < len(corrupt)-8; i++ {
+		corrupt[i] ^= 0xff
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		body       []byte
+		wantStatus int
+	}{
+		{name: "gzipped URL", path: "/", body: valid, wantStatus: http.StatusCreated},
+		{name: "gzipped JSON object", path: "/", body: gzipped(`{"url":"https://example.com"}`), wantStatus: http.StatusBadRequest},
+		{name: "gzipped JSON array", path: "/", body: gzipped(`[{"correlation_id":"1","original_url":"https://example.com"}]`), wantStatus: http.StatusBadRequest},
+		{name: "corrupt gzip", path: "/", body: corrupt, wantStatus: http.StatusBadRequest},
+		{name: "truncated gzip", path: "/", body: valid[:len(valid)-6], wantStatus: http.StatusBadRequest},
+		{name: "not gzip", path: "/", body: []byte("https://example.com/plain"), wantStatus: http.StatusBadRequest},
+		{name: "corrupt gzip on JSON endpoint", path: "/api/shorten", body: corrupt, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &mockURLService{
+				shortenURLFunc: func(ctx context.Context, originalURL string) (string, error) {
+					return "http://localhost:8080/abc123", nil
+				},
+			}
+			router := NewHandler(mockService, nil).RegisterRoutes()
+
+			req := httptest.NewRequest(http.MethodPost, tt.path, bytes.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/x-gzip")
+			req.Header.Set("Content-Encoding", "gzip")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status code %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
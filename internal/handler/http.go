@@ -1,22 +1,44 @@
 package handler
 
 import (
+	"bytes"
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"io"
+	"net"
 	"net/http"
+	"net/http/pprof"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/MikhailRaia/url-shortener/internal/auth"
+	"github.com/MikhailRaia/url-shortener/internal/generator"
 	"github.com/MikhailRaia/url-shortener/internal/logger"
+	"github.com/MikhailRaia/url-shortener/internal/metrics"
 	"github.com/MikhailRaia/url-shortener/internal/middleware"
 	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/service"
 	"github.com/MikhailRaia/url-shortener/internal/storage"
+	"github.com/MikhailRaia/url-shortener/internal/tenant"
+	"github.com/MikhailRaia/url-shortener/internal/worker"
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/rs/zerolog/log"
 )
 
+// Pagination defaults for GET /api/user/urls.
+const (
+	// DefaultPageSize is the page size used when the limit parameter is absent.
+	DefaultPageSize = 100
+	// MaxPageSize is the largest page size a client may request.
+	MaxPageSize = 1000
+)
+
 // URLService defines operations for creating and resolving shortened URLs.
 type URLService interface {
 	// ShortenURL shortens a URL without user association.
@@ -35,6 +57,10 @@ type URLService interface {
 	// Returns the original URL or an error if not found or if the URL is deleted.
 	GetOriginalURLWithDeletedStatus(ctx context.Context, id string) (string, error)
 
+	// ShortenURLWithAlias stores a URL under a caller-chosen alias, optionally for a user.
+	// Returns the shortened URL or an error if the alias is invalid or taken.
+	ShortenURLWithAlias(ctx context.Context, originalURL, alias, userID string) (string, error)
+
 	// ShortenBatch shortens multiple URLs in a single operation.
 	// Returns a slice of batch response items or an error.
 	ShortenBatch(ctx context.Context, items []model.BatchRequestItem) ([]model.BatchResponseItem, error)
@@ -47,6 +73,12 @@ type URLService interface {
 	// Returns a slice of user URL records or an error.
 	GetUserURLs(ctx context.Context, userID string) ([]model.UserURL, error)
 
+	// GetUserURLsPaged retrieves a page of a user's shortened URLs in the given order and the user's total URL count.
+	GetUserURLsPaged(ctx context.Context, userID string, order model.UserURLOrder, limit, offset int) ([]model.UserURL, int, error)
+
+	// GetDeletedUserURLs retrieves the short IDs of a user's URLs that have been deleted.
+	GetDeletedUserURLs(ctx context.Context, userID string) ([]string, error)
+
 	// DeleteUserURLs marks user URLs as deleted.
 	// Returns an error if the operation fails.
 	DeleteUserURLs(userID string, urlIDs []string) error
@@ -62,88 +94,671 @@ type DeleteWorker interface {
 	Submit(userID string, urlIDs []string) error
 }
 
+// TenantDeleteWorker is implemented by delete workers that can scope jobs to a tenant.
+type TenantDeleteWorker interface {
+	SubmitForTenant(tenantID, userID string, urlIDs []string) error
+}
+
+// TenantURLDeleter is implemented by services that can delete URLs within a tenant.
+type TenantURLDeleter interface {
+	DeleteTenantUserURLs(tenantID, userID string, urlIDs []string) error
+}
+
+// ExpiringURLService is implemented by services that can create expiring short URLs.
+type ExpiringURLService interface {
+	ShortenURLWithExpiry(ctx context.Context, originalURL string, expiresAt time.Time, userID string) (string, error)
+}
+
+// URLExpiryProvider is implemented by services that can report when a short
+// URL expires, the zero time meaning never.
+type URLExpiryProvider interface {
+	URLExpiry(ctx context.Context, id string) (time.Time, error)
+}
+
+// DetailedURLService is implemented by services that can describe the short
+// URLs they create.
+type DetailedURLService interface {
+	ShortenURLDetailed(ctx context.Context, originalURL, userID string) (model.ShortenResult, error)
+}
+
+// PasswordURLService is implemented by services that can gate short URLs behind a password.
+type PasswordURLService interface {
+	ShortenURLWithPassword(ctx context.Context, originalURL, password, userID string) (string, error)
+	VerifyURLPassword(ctx context.Context, id, password string) error
+}
+
+// ForwardQueryURLService is implemented by services that can create short URLs
+// whose redirects forward their query string to the original URL.
+type ForwardQueryURLService interface {
+	ShortenURLWithForwardQuery(ctx context.Context, originalURL, userID string) (string, error)
+	ForwardsQuery(ctx context.Context, id string) (bool, error)
+}
+
+// OwnerURLDeleter is implemented by services that can synchronously delete a
+// single short URL after checking that the user owns it.
+type OwnerURLDeleter interface {
+	DeleteURL(ctx context.Context, userID, id string) error
+}
+
+// QueueHistoryProvider reports sampled delete queue depth over time.
+type QueueHistoryProvider interface {
+	History() []worker.QueueSample
+	SampleInterval() time.Duration
+}
+
+// QueueHistoryResponse is the JSON response of GET /api/internal/queue/history.
+type QueueHistoryResponse struct {
+	IntervalSeconds float64              `json:"interval_seconds"`
+	Samples         []worker.QueueSample `json:"samples"`
+}
+
 // Handler exposes HTTP endpoints for the URL shortener service.
 // It provides endpoints for shortening URLs, retrieving original URLs,
 // managing user URLs, and checking database health.
 type Handler struct {
 	urlService   URLService
 	dbPinger     DBPinger
+	pingTimeout  time.Duration
 	deleteWorker DeleteWorker
+	metrics      *metrics.Metrics
+	tenancy      bool
+	tenantHeader string
+	hostBaseURL  bool
+
+	trustProxyHeaders bool
+
+	trustedSubnet *net.IPNet
+	queueHistory  QueueHistoryProvider
+
+	maxBodyBytes      int64
+	maxBatchBodyBytes int64
+	maxBatchSize      int
+	requestTimeout    time.Duration
+	cors              *middleware.CORSConfig
+	htmlRedirects     bool
+	batchTimeout      time.Duration
+
+	precompressStatic bool
+	gzip              func(http.Handler) http.Handler
+	aliases           bool
+	validateIDs       bool
+
+	conflictDeprecation bool
+	passwords           bool
+	forwardQuery        bool
+	signer              *auth.URLSigner
+
+	adminToken string
+	shutdown   func()
+	profiling  bool
+
+	// maintenance is switched at runtime via POST /api/admin/maintenance.
+	maintenance               atomic.Bool
+	maintenanceRejectShortens bool
+	captureCreator            bool
+	readyChecker              ReadyChecker
+
+	visits VisitRecorder
+	expiry bool
+
+	shortenLimiter  *middleware.RateLimiter
+	redirectLimiter *middleware.RateLimiter
+
+	redirectETags  bool
+	redirectMaxAge time.Duration
+
+	idempotency *idempotencyStore
+}
+
+// Option configures optional Handler features.
+type Option func(*Handler)
+
+// WithMetrics instruments routes and exposes GET /metrics.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(h *Handler) {
+		h.metrics = m
+	}
+}
+
+// WithTenants partitions URLs by tenant, resolved from the given request header
+// or, if header is empty, from the request host.
+func WithTenants(header string) Option {
+	return func(h *Handler) {
+		h.tenancy = true
+		h.tenantHeader = header
+	}
+}
+
+// WithHostBaseURL resolves the base URL of every request from its host, for
+// services that build short URLs per request.
+func WithHostBaseURL() Option {
+	return func(h *Handler) {
+		h.hostBaseURL = true
+	}
+}
+
+// WithTrustProxyHeaders sets whether headers set by a reverse proxy are
+// trusted; by default they are not. When they are, the client IP used by the
+// trusted subnet check, rate limits and logs comes from True-Client-IP,
+// X-Real-IP or X-Forwarded-For, and request base URLs from X-Forwarded-Proto
+// and X-Forwarded-Host. Enable it only behind a proxy that sets these
+// headers, as clients reaching the server directly could otherwise spoof them.
+func WithTrustProxyHeaders(trust bool) Option {
+	return func(h *Handler) {
+		h.trustProxyHeaders = trust
+	}
+}
+
+// WithTrustedSubnet sets the subnet allowed to access internal endpoints.
+func WithTrustedSubnet(subnet *net.IPNet) Option {
+	return func(h *Handler) {
+		h.trustedSubnet = subnet
+	}
 }
 
+// WithQueueHistory exposes GET /api/internal/queue/history to the trusted subnet.
+func WithQueueHistory(provider QueueHistoryProvider) Option {
+	return func(h *Handler) {
+		h.queueHistory = provider
+	}
+}
+
+// WithGzipLevel sets the compression level of gzipped responses, trading CPU
+// for bandwidth; brotli responses use the quality of the same number. Invalid
+// levels fall back to gzip.BestSpeed, the default.
+func WithGzipLevel(level int) Option {
+	return func(h *Handler) {
+		h.gzip = middleware.GzipLevel(level)
+	}
+}
+
+// WithBodyLimits caps request body sizes for single-URL and batch write endpoints.
+// A non-positive limit disables the corresponding cap.
+func WithBodyLimits(maxBodyBytes, maxBatchBodyBytes int64) Option {
+	return func(h *Handler) {
+		h.maxBodyBytes = maxBodyBytes
+		h.maxBatchBodyBytes = maxBatchBodyBytes
+	}
+}
+
+// WithMaxBatchSize caps the number of items in a batch shorten request; larger
+// batches get 413. A non-positive size disables the cap.
+func WithMaxBatchSize(size int) Option {
+	return func(h *Handler) {
+		h.maxBatchSize = size
+	}
+}
+
+// WithRateLimits limits shorten and redirect requests per client. Redirects
+// usually get a looser limit; a limit with a non-positive rate disables it.
+func WithRateLimits(shorten, redirect middleware.RateLimit) Option {
+	return func(h *Handler) {
+		if shorten.Rate > 0 {
+			h.shortenLimiter = middleware.NewRateLimiter(shorten)
+		}
+		if redirect.Rate > 0 {
+			h.redirectLimiter = middleware.NewRateLimiter(redirect)
+		}
+	}
+}
+
+// WithIdempotency lets clients retry shorten requests safely: a request
+// repeating the Idempotency-Key of one made within ttl gets the first
+// response again instead of being processed twice. A non-positive ttl
+// disables idempotency keys.
+func WithIdempotency(ttl time.Duration) Option {
+	return func(h *Handler) {
+		if ttl <= 0 {
+			h.idempotency = nil
+			return
+		}
+		h.idempotency = newIdempotencyStore(ttl)
+	}
+}
+
+// WithRedirectETags lets clients revalidate cached redirects: responses carry
+// an ETag, and a request whose If-None-Match matches gets 304 Not Modified.
+// Deleted and expired URLs are never cached.
+func WithRedirectETags(enabled bool) Option {
+	return func(h *Handler) {
+		h.redirectETags = enabled
+	}
+}
+
+// WithRedirectCacheMaxAge lets browsers and CDNs cache redirects for maxAge
+// with Cache-Control: public, max-age. When URL passwords are enabled the
+// cache is private so shared caches cannot bypass a password. The max-age of
+// an expiring URL is capped at its remaining lifetime, and 410 responses get
+// Cache-Control: no-store. A non-positive maxAge sends no max-age.
+func WithRedirectCacheMaxAge(maxAge time.Duration) Option {
+	return func(h *Handler) {
+		h.redirectMaxAge = maxAge
+	}
+}
+
+// WithTimeouts bounds how long single-URL and batch endpoints may take before
+// the client gets 503. A non-positive timeout disables the corresponding bound.
+func WithTimeouts(requestTimeout, batchTimeout time.Duration) Option {
+	return func(h *Handler) {
+		h.requestTimeout = requestTimeout
+		h.batchTimeout = batchTimeout
+	}
+}
+
+// WithPingTimeout bounds how long GET /ping waits for the database before
+// answering 503. A non-positive timeout waits as long as the request does.
+func WithPingTimeout(timeout time.Duration) Option {
+	return func(h *Handler) {
+		h.pingTimeout = timeout
+	}
+}
+
+// WithCORS answers preflight requests and adds CORS headers for the configured origins.
+func WithCORS(cfg middleware.CORSConfig) Option {
+	return func(h *Handler) {
+		h.cors = &cfg
+	}
+}
+
+// WithHTMLRedirects adds a small HTML page to redirect and 410 Gone responses
+// for requests that accept text/html. Location is set as usual.
+func WithHTMLRedirects(enabled bool) Option {
+	return func(h *Handler) {
+		h.htmlRedirects = enabled
+	}
+}
+
+// WithPrecompressedStatic controls whether static responses are gzipped once up front.
+func WithPrecompressedStatic(enabled bool) Option {
+	return func(h *Handler) {
+		h.precompressStatic = enabled
+	}
+}
+
+// WithAliases allows clients to choose the short ID via the "alias" JSON field.
+func WithAliases(enabled bool) Option {
+	return func(h *Handler) {
+		h.aliases = enabled
+	}
+}
+
+// WithIDValidation rejects redirect IDs containing characters outside the
+// short ID alphabet with 404 before touching storage.
+func WithIDValidation(enabled bool) Option {
+	return func(h *Handler) {
+		h.validateIDs = enabled
+	}
+}
+
+// WithConflictDeprecation announces on JSON 409 duplicate-URL responses that
+// duplicates will be answered with 200 OK in a future release.
+func WithConflictDeprecation(enabled bool) Option {
+	return func(h *Handler) {
+		h.conflictDeprecation = enabled
+	}
+}
+
+// WithPasswords lets clients protect a short URL with the "password" JSON field.
+// Redirects to protected URLs then require the password via ?pw= or HTTP Basic auth.
+func WithPasswords(enabled bool) Option {
+	return func(h *Handler) {
+		h.passwords = enabled
+	}
+}
+
+// WithQueryForwarding lets clients create short URLs with the "forward_query"
+// JSON field. Redirects of such URLs merge their query string into the
+// original URL, see forwardQuery.
+func WithQueryForwarding(enabled bool) Option {
+	return func(h *Handler) {
+		h.forwardQuery = enabled
+	}
+}
+
+// WithURLSigner requires short codes to carry a valid signature, /{id}.{sig},
+// on every route that takes one. Unsigned or forged short codes get 404 as if
+// they did not exist, so the ID space cannot be scanned. The URL service must
+// sign with the same key.
+func WithURLSigner(signer *auth.URLSigner) Option {
+	return func(h *Handler) {
+		h.signer = signer
+	}
+}
+
+// verifiedID returns the short ID a client-supplied code stands for. When URL
+// signing is on, it strips the signature and reports false for codes whose
+// signature is missing or forged.
+func (h *Handler) verifiedID(code string) (string, bool) {
+	if h.signer == nil {
+		return code, true
+	}
+	return h.signer.Verify(code)
+}
+
+// WithAdminShutdown exposes POST /api/admin/shutdown to the trusted subnet.
+// Requests must carry token in the X-Admin-Token header; shutdown is called to
+// start a graceful shutdown. An empty token leaves the endpoint disabled.
+func WithAdminShutdown(token string, shutdown func()) Option {
+	return func(h *Handler) {
+		h.adminToken = token
+		h.shutdown = shutdown
+	}
+}
+
+// WithProfiling exposes the net/http/pprof handlers under /debug/pprof/ to the
+// trusted subnet.
+func WithProfiling(enabled bool) Option {
+	return func(h *Handler) {
+		h.profiling = enabled
+	}
+}
+
+// WithVisitTracking counts redirects through recorder and exposes
+// GET /api/url/{id}/stats.
+func WithVisitTracking(recorder VisitRecorder) Option {
+	return func(h *Handler) {
+		h.visits = recorder
+	}
+}
+
+// WithExpiry lets clients make a short URL expire via the "expires_in" or
+// "expires_at" JSON fields. Expired URLs answer 410 Gone.
+func WithExpiry(enabled bool) Option {
+	return func(h *Handler) {
+		h.expiry = enabled
+	}
+}
+
+// defaultPingTimeout is how long GET /ping waits for the database unless
+// WithPingTimeout says otherwise.
+const defaultPingTimeout = 2 * time.Second
+
 // NewHandler constructs a Handler without auth-specific routes.
 // It requires a URLService and an optional DBPinger for health checks.
-func NewHandler(urlService URLService, dbPinger DBPinger) *Handler {
-	return &Handler{
-		urlService: urlService,
-		dbPinger:   dbPinger,
+func NewHandler(urlService URLService, dbPinger DBPinger, opts ...Option) *Handler {
+	h := &Handler{
+		urlService:  urlService,
+		dbPinger:    dbPinger,
+		pingTimeout: defaultPingTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(h)
 	}
+
+	return h
 }
 
 // NewHandlerWithDeleteWorker constructs a Handler and configures an async delete worker.
 // The delete worker enables asynchronous processing of user URL deletion requests.
-func NewHandlerWithDeleteWorker(urlService URLService, dbPinger DBPinger, deleteWorker DeleteWorker) *Handler {
-	return &Handler{
+func NewHandlerWithDeleteWorker(urlService URLService, dbPinger DBPinger, deleteWorker DeleteWorker, opts ...Option) *Handler {
+	h := &Handler{
 		urlService:   urlService,
 		dbPinger:     dbPinger,
+		pingTimeout:  defaultPingTimeout,
 		deleteWorker: deleteWorker,
 	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// gzipMiddleware returns the response compression middleware.
+func (h *Handler) gzipMiddleware() func(http.Handler) http.Handler {
+	if h.gzip == nil {
+		return middleware.GzipMiddleware
+	}
+	return h.gzip
 }
 
 // RegisterRoutes registers public endpoints that don't require authentication.
-// Endpoints: POST /, POST /api/shorten, POST /api/shorten/batch, GET /{id}, GET /ping
+// Endpoints: POST /, POST /api/shorten, POST /api/shorten/batch, POST /api/expand/batch, GET /{id}, GET /ping, GET /health
 func (h *Handler) RegisterRoutes() http.Handler {
 	r := chi.NewRouter()
 
 	r.Use(chimiddleware.RequestID)
-	r.Use(chimiddleware.RealIP)
-	r.Use(chimiddleware.Recoverer)
+	h.registerRealIP(r)
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.TrimAPISlashes)
+	r.Use(middleware.Trace)
 
 	r.Use(logger.RequestLogger)
 
 	r.Use(middleware.GzipReader)
-	r.Use(middleware.GzipMiddleware)
-
-	r.Post("/", h.handleShorten)
-	r.Post("/api/shorten", h.HandleShortenJSON)
-	r.Post("/api/shorten/batch", h.handleShortenBatch)
-	r.Get("/{id}", h.handleRedirect)
-	r.Get("/ping", h.handlePing)
+	r.Use(h.gzipMiddleware())
+
+	h.registerCORS(r)
+	h.registerMetrics(r)
+	h.registerTenancy(r)
+	h.registerHostBaseURL(r)
+	h.registerInternal(r)
+	h.registerStatic(r)
+	h.registerStats(r)
+
+	single := middleware.MaxBodySize(h.maxBodyBytes)
+	batch := middleware.MaxBodySize(h.maxBatchBodyBytes)
+	timeout := middleware.Timeout(h.requestTimeout)
+	batchTimeout := middleware.Timeout(h.batchTimeout)
+	shortenLimit := h.shortenLimiter.Middleware
+	redirectLimit := h.redirectLimiter.Middleware
+
+	r.With(h.rejectInMaintenance, shortenLimit, timeout, single, shortURLFormat, h.captureCreatorInfo, h.idempotent).Post("/", h.handleShorten)
+	r.With(h.rejectInMaintenance, shortenLimit, timeout, single, shortURLFormat, h.captureCreatorInfo, h.idempotent).Post("/api/shorten", h.HandleShortenJSON)
+	r.With(h.rejectInMaintenance, shortenLimit, batchTimeout, batch, shortURLFormat, h.captureCreatorInfo, h.idempotent).Post("/api/shorten/batch", h.handleShortenBatch)
+	r.With(redirectLimit, batchTimeout, batch).Post("/api/expand/batch", h.handleExpandBatch)
+	r.With(redirectLimit, timeout).Get("/{id}", h.handleRedirect)
+	r.With(timeout).Get("/{id}/qr", h.handleQRCode)
+	r.With(timeout).Get("/ping", h.handlePing)
+	r.Get("/health", h.handleHealth)
 
 	return r
 }
 
 // RegisterRoutesWithAuth registers endpoints with authentication and user-specific features.
-// Endpoints include all public routes plus: GET /api/user/urls, DELETE /api/user/urls
+// Endpoints include all public routes plus: GET /api/user/urls, DELETE /api/user/urls, DELETE /{id}.
+// Public routes issue a new user cookie when none is valid; user routes answer 401 instead.
 func (h *Handler) RegisterRoutesWithAuth(authMiddleware *middleware.AuthMiddleware) http.Handler {
 	r := chi.NewRouter()
 
 	r.Use(chimiddleware.RequestID)
-	r.Use(chimiddleware.RealIP)
-	r.Use(chimiddleware.Recoverer)
+	h.registerRealIP(r)
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.TrimAPISlashes)
+	r.Use(middleware.Trace)
 
 	r.Use(logger.RequestLogger)
 
 	r.Use(middleware.GzipReader)
-	r.Use(middleware.GzipMiddleware)
-	r.Use(authMiddleware.AuthenticateUser)
+	r.Use(h.gzipMiddleware())
+
+	h.registerCORS(r)
+	h.registerMetrics(r)
+	h.registerTenancy(r)
+	h.registerHostBaseURL(r)
+	h.registerInternal(r)
+	h.registerStatic(r)
+	h.registerStats(r)
+
+	single := middleware.MaxBodySize(h.maxBodyBytes)
+	batch := middleware.MaxBodySize(h.maxBatchBodyBytes)
+	timeout := middleware.Timeout(h.requestTimeout)
+	batchTimeout := middleware.Timeout(h.batchTimeout)
+	shortenLimit := h.shortenLimiter.Middleware
+	redirectLimit := h.redirectLimiter.Middleware
+
+	r.Group(func(r chi.Router) {
+		r.Use(authMiddleware.AuthenticateUser)
+
+		r.With(h.rejectInMaintenance, shortenLimit, timeout, single, shortURLFormat, h.captureCreatorInfo, h.idempotent).Post("/", h.handleShortenWithAuth)
+		r.With(h.rejectInMaintenance, shortenLimit, timeout, single, shortURLFormat, h.captureCreatorInfo, h.idempotent).Post("/api/shorten", h.HandleShortenJSONWithAuth)
+		r.With(h.rejectInMaintenance, shortenLimit, batchTimeout, batch, shortURLFormat, h.captureCreatorInfo, h.idempotent).Post("/api/shorten/batch", h.handleShortenBatchWithAuth)
+		r.With(redirectLimit, batchTimeout, batch).Post("/api/expand/batch", h.handleExpandBatch)
+		r.With(redirectLimit, timeout).Get("/{id}", h.handleRedirect)
+		r.With(timeout).Get("/{id}/qr", h.handleQRCode)
+		r.With(timeout).Get("/ping", h.handlePing)
+	})
+
+	// Health checks come from load balancers, which have no use for a user cookie.
+	r.Get("/health", h.handleHealth)
+
+	// User endpoints only serve existing users: minting a new one here
+	// would just answer with an empty list instead of 401.
+	r.Group(func(r chi.Router) {
+		r.Use(authMiddleware.RequireAuth)
+
+		r.With(timeout).Get("/api/user/urls", h.handleGetUserURLs)
+		r.With(timeout).Get("/api/user/urls/deleted", h.handleGetDeletedUserURLs)
+		r.With(batchTimeout, batch).Delete("/api/user/urls", h.handleDeleteUserURLs)
+		r.With(timeout).Delete("/{id}", h.handleDeleteURL)
+	})
 
-	r.Post("/", h.handleShortenWithAuth)
-	r.Post("/api/shorten", h.HandleShortenJSONWithAuth)
-	r.Post("/api/shorten/batch", h.handleShortenBatchWithAuth)
-	r.Get("/{id}", h.handleRedirect)
-	r.Get("/ping", h.handlePing)
+	return r
+}
 
-	r.Get("/api/user/urls", h.handleGetUserURLs)
-	r.Delete("/api/user/urls", h.handleDeleteUserURLs)
+// registerCORS installs the CORS middleware when cross-origin access is configured.
+// It runs before routing so preflight requests get answered for every route.
+func (h *Handler) registerCORS(r chi.Router) {
+	if h.cors == nil {
+		return
+	}
 
-	return r
+	r.Use(middleware.CORS(*h.cors))
+}
+
+// registerMetrics installs the metrics middleware and GET /metrics when enabled.
+func (h *Handler) registerMetrics(r chi.Router) {
+	if h.metrics == nil {
+		return
+	}
+
+	r.Use(h.metrics.Middleware)
+	r.Method(http.MethodGet, "/metrics", h.metrics.Handler())
+}
+
+// registerTenancy installs the tenant resolver when tenancy is enabled.
+func (h *Handler) registerTenancy(r chi.Router) {
+	if !h.tenancy {
+		return
+	}
+
+	r.Use(middleware.TenantResolver(h.tenantHeader))
+}
+
+// registerRealIP replaces the remote address with the client IP reported by
+// a reverse proxy when proxy headers are trusted.
+func (h *Handler) registerRealIP(r chi.Router) {
+	if !h.trustProxyHeaders {
+		return
+	}
+
+	r.Use(chimiddleware.RealIP)
+}
+
+// registerHostBaseURL installs the base URL resolver when short URLs follow the request host.
+func (h *Handler) registerHostBaseURL(r chi.Router) {
+	if !h.hostBaseURL {
+		return
+	}
+
+	r.Use(middleware.BaseURLResolver(h.trustProxyHeaders))
+}
+
+// registerInternal mounts operator endpoints restricted to the trusted subnet.
+func (h *Handler) registerInternal(r chi.Router) {
+	adminShutdown := h.shutdown != nil && h.adminToken != ""
+	_, canImport := h.urlService.(ImportURLService)
+	_, canExport := h.urlService.(ExportURLService)
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.TrustedSubnet(h.trustedSubnet))
+		r.With(middleware.MaxBodySize(h.maxBodyBytes)).Post("/api/admin/maintenance", h.handleMaintenance)
+		if h.queueHistory != nil {
+			r.Get("/api/internal/queue/history", h.handleQueueHistory)
+		}
+		if adminShutdown {
+			r.Post("/api/admin/shutdown", h.handleAdminShutdown)
+		}
+		if canImport {
+			r.With(middleware.MaxBodySize(h.maxBatchBodyBytes)).Post("/api/admin/import", h.handleImport)
+		}
+		if canExport {
+			r.Get("/api/admin/export", h.handleExport)
+		}
+		if h.profiling {
+			r.HandleFunc("/debug/pprof/*", pprof.Index)
+			r.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+			r.HandleFunc("/debug/pprof/profile", pprof.Profile)
+			r.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+			r.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		}
+	})
+}
+
+// registerStatic installs handlers for fixed responses.
+func (h *Handler) registerStatic(r chi.Router) {
+	notFound, err := h.staticAsset(http.StatusNotFound, "text/plain; charset=utf-8", []byte("404 page not found\n"))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to prepare static 404 response")
+		return
+	}
+	r.NotFound(notFound.ServeHTTP)
+
+	openAPI, err := h.staticAsset(http.StatusOK, "application/json", openAPISpec)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to prepare OpenAPI document")
+		return
+	}
+	r.Method(http.MethodGet, "/openapi.json", openAPI)
+}
+
+// staticAsset builds a fixed response honoring the precompression setting.
+func (h *Handler) staticAsset(statusCode int, contentType string, body []byte) (*middleware.StaticAsset, error) {
+	return middleware.NewStaticAsset(statusCode, contentType, body, h.precompressStatic)
 }
 
+func (h *Handler) handleQueueHistory(w http.ResponseWriter, r *http.Request) {
+	samples := h.queueHistory.History()
+	if samples == nil {
+		samples = []worker.QueueSample{}
+	}
+
+	response, err := json.Marshal(QueueHistoryResponse{
+		IntervalSeconds: h.queueHistory.SampleInterval().Seconds(),
+		Samples:         samples,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to marshal queue history response")
+		writeInternalError(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// handleAdminShutdown starts a graceful shutdown when the request carries the admin token.
+func (h *Handler) handleAdminShutdown(w http.ResponseWriter, r *http.Request) {
+	token := r.Header.Get("X-Admin-Token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) != 1 {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	log.Warn().Str("remote", r.RemoteAddr).Msg("Graceful shutdown requested via admin endpoint")
+	w.WriteHeader(http.StatusAccepted)
+	h.shutdown()
+}
+
+// handleShorten handles POST / with the URL as a plain-text body. The short URL is
+// returned as text/plain unless the Accept header prefers application/json.
 func (h *Handler) handleShorten(w http.ResponseWriter, r *http.Request) {
-	contentEncoding := r.Header.Get("Content-Encoding")
+	gzipped := r.Header.Get("Content-Encoding") == "gzip"
 
-	if contentEncoding != "gzip" {
+	if !gzipped {
 		contentType := r.Header.Get("Content-Type")
 		if !strings.Contains(contentType, "text/plain") && contentType != "" {
 			w.WriteHeader(http.StatusBadRequest)
@@ -151,17 +766,18 @@ func (h *Handler) handleShorten(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	body, err := io.ReadAll(r.Body)
+	body, err := readBody(r)
 	if err != nil {
+		w.WriteHeader(bodyErrorStatus(err))
+		return
+	}
+
+	// Gzipped bodies skip the Content-Type check, so make sure the client did
+	// not mean to send JSON to /api/shorten.
+	if gzipped && looksLikeJSON(body) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			panic(err)
-		}
-	}(r.Body)
 
 	originalURL := strings.TrimSpace(string(body))
 	if originalURL == "" {
@@ -172,9 +788,7 @@ func (h *Handler) handleShorten(w http.ResponseWriter, r *http.Request) {
 	shortenedURL, err := h.urlService.ShortenURL(r.Context(), originalURL)
 	if err != nil {
 		if errors.Is(err, storage.ErrURLExists) {
-			w.Header().Set("Content-Type", "text/plain")
-			w.WriteHeader(http.StatusConflict)
-			w.Write([]byte(shortenedURL))
+			writeShortenResult(w, r, http.StatusConflict, shortenedURL)
 			return
 		}
 
@@ -183,9 +797,7 @@ func (h *Handler) handleShorten(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "text/plain")
-	w.WriteHeader(http.StatusCreated)
-	w.Write([]byte(shortenedURL))
+	writeShortenResult(w, r, http.StatusCreated, shortenedURL)
 }
 
 func (h *Handler) handleRedirect(w http.ResponseWriter, r *http.Request) {
@@ -195,10 +807,21 @@ func (h *Handler) handleRedirect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	id, ok := h.verifiedID(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if h.validateIDs && !generator.ValidID(id) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
 	originalURL, err := h.urlService.GetOriginalURLWithDeletedStatus(r.Context(), id)
 	if err != nil {
-		if errors.Is(err, storage.ErrURLDeleted) {
-			w.WriteHeader(http.StatusGone)
+		if errors.Is(err, storage.ErrURLDeleted) || errors.Is(err, storage.ErrURLExpired) {
+			h.writeGone(w, r)
 			return
 		}
 		log.Error().Err(err).Msg("Failed to get original URL")
@@ -211,8 +834,86 @@ func (h *Handler) handleRedirect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Location", originalURL)
-	w.WriteHeader(http.StatusTemporaryRedirect)
+	if h.passwords {
+		if passwordService, ok := h.urlService.(PasswordURLService); ok {
+			if err := passwordService.VerifyURLPassword(r.Context(), id, redirectPassword(r)); err != nil {
+				if errors.Is(err, service.ErrWrongPassword) {
+					w.Header().Set("WWW-Authenticate", `Basic realm="short-url"`)
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				log.Error().Err(err).Msg("Failed to verify URL password")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	if h.forwardQuery && r.URL.RawQuery != "" {
+		if forwardService, ok := h.urlService.(ForwardQueryURLService); ok {
+			forward, err := forwardService.ForwardsQuery(r.Context(), id)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to get query forwarding")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if forward {
+				originalURL = forwardQuery(originalURL, r.URL.Query())
+			}
+		}
+	}
+
+	if h.visits != nil {
+		h.visits.Record(tenant.FromContext(r.Context()), id)
+	}
+
+	if h.writeNotModified(w, r, id, originalURL) {
+		return
+	}
+
+	h.writeRedirect(w, r, originalURL)
+}
+
+// forwardQuery adds the query parameters of a redirect request to the original
+// URL. Parameters the original URL already has take precedence and keep their
+// stored values, so a short link cannot be used to override them; the pw
+// parameter of password-protected redirects is never forwarded.
+func forwardQuery(originalURL string, query url.Values) string {
+	target, err := url.Parse(originalURL)
+	if err != nil {
+		return originalURL
+	}
+
+	stored := target.Query()
+	extra := url.Values{}
+	for name, values := range query {
+		if _, exists := stored[name]; exists || name == "pw" {
+			continue
+		}
+		extra[name] = values
+	}
+	if len(extra) == 0 {
+		return originalURL
+	}
+
+	if target.RawQuery == "" {
+		target.RawQuery = extra.Encode()
+	} else {
+		target.RawQuery += "&" + extra.Encode()
+	}
+	return target.String()
+}
+
+// redirectPassword returns the password supplied for a protected redirect,
+// taken from the pw query parameter or else from HTTP Basic auth.
+func redirectPassword(r *http.Request) string {
+	if pw := r.URL.Query().Get("pw"); pw != "" {
+		return pw
+	}
+	if _, pw, ok := r.BasicAuth(); ok {
+		return pw
+	}
+	return ""
 }
 
 func (h *Handler) handlePing(w http.ResponseWriter, r *http.Request) {
@@ -221,62 +922,94 @@ func (h *Handler) handlePing(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.dbPinger.Ping(r.Context())
-	if err != nil {
+	ctx := r.Context()
+	if h.pingTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.pingTimeout)
+		defer cancel()
+	}
+
+	if err := h.dbPinger.Ping(ctx); err != nil {
 		log.Error().Err(err).Msg("Failed to ping database")
-		w.WriteHeader(http.StatusInternalServerError)
+		message := "database is unreachable"
+		if h.pingTimeout > 0 && errors.Is(err, context.DeadlineExceeded) {
+			message = "database did not answer within " + h.pingTimeout.String()
+		}
+		writeJSONError(w, http.StatusServiceUnavailable, ErrCodeDatabaseUnavailable, message)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// validateBatch checks items with service.ValidateBatch and writes the error
+// response if they are rejected. It reports whether the batch may proceed.
+func (h *Handler) validateBatch(w http.ResponseWriter, items []model.BatchRequestItem) bool {
+	err := service.ValidateBatch(items, h.maxBatchSize)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, service.ErrBatchTooLarge):
+		writeJSONError(w, http.StatusRequestEntityTooLarge, ErrCodeBatchTooLarge, "batch must have at most "+strconv.Itoa(h.maxBatchSize)+" items")
+	default:
+		writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidBatchItem, strings.TrimPrefix(err.Error(), service.ErrInvalidBatchItem.Error()+": "))
+	}
+	return false
+}
+
 func (h *Handler) handleShortenBatch(w http.ResponseWriter, r *http.Request) {
 	contentType := r.Header.Get("Content-Type")
 	if !strings.Contains(contentType, "application/json") {
-		w.WriteHeader(http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidContentType, "Content-Type must be application/json")
 		return
 	}
 
-	body, err := io.ReadAll(r.Body)
+	body, err := readBody(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		writeBodyError(w, err)
 		return
 	}
-	defer r.Body.Close()
 
 	if len(body) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, ErrCodeEmptyBatch, "batch must not be empty")
 		return
 	}
 
-	var items []model.BatchRequestItem
-	if err := json.Unmarshal(body, &items); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	items, err := decodeBatch(body)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidBody, "request body is not a valid JSON array of batch items")
 		return
 	}
 
 	if len(items) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, ErrCodeEmptyBatch, "batch must not be empty")
 		return
 	}
 
+	if !h.validateBatch(w, items) {
+		return
+	}
+
+	// Nothing new was stored when every URL had been shortened before.
+	status := http.StatusCreated
 	result, err := h.urlService.ShortenBatch(r.Context(), items)
-	if err != nil {
+	if errors.Is(err, storage.ErrURLExists) {
+		status = http.StatusOK
+	} else if err != nil {
 		log.Error().Err(err).Msg("Failed to shorten batch URLs")
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
 	response, err := json.Marshal(result)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to marshal batch response")
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(status)
 	w.Write(response)
 }
 
@@ -284,19 +1017,33 @@ func (h *Handler) handleGetUserURLs(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserIDFromContext(r.Context())
 	if !ok {
 		log.Debug().Msg("No userID found in context")
-		w.WriteHeader(http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "authentication required")
 		return
 	}
 	log.Debug().Str("userID", userID).Msg("Found userID in context")
 
-	urls, err := h.urlService.GetUserURLs(r.Context(), userID)
+	limit, offset, ok := parsePage(r)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidPage, "limit must be positive and offset non-negative")
+		return
+	}
+
+	order, ok := parseUserURLOrder(r)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidSort, "sort must be newest or oldest")
+		return
+	}
+
+	urls, total, err := h.urlService.GetUserURLsPaged(r.Context(), userID, order, limit, offset)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to get user URLs")
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
-	if len(urls) == 0 {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+
+	if total == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
@@ -304,7 +1051,7 @@ func (h *Handler) handleGetUserURLs(w http.ResponseWriter, r *http.Request) {
 	response, err := json.Marshal(urls)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to marshal user URLs response")
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
@@ -313,6 +1060,76 @@ func (h *Handler) handleGetUserURLs(w http.ResponseWriter, r *http.Request) {
 	w.Write(response)
 }
 
+// handleGetDeletedUserURLs lists the short IDs of the user's URLs that have been deleted,
+// letting clients confirm that an asynchronous DELETE /api/user/urls took effect.
+func (h *Handler) handleGetDeletedUserURLs(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		writeJSONError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "authentication required")
+		return
+	}
+
+	ids, err := h.urlService.GetDeletedUserURLs(r.Context(), userID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get deleted user URLs")
+		writeInternalError(w)
+		return
+	}
+
+	if len(ids) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	response, err := json.Marshal(ids)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to marshal deleted user URLs response")
+		writeInternalError(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// parsePage reads the limit and offset query parameters of a paginated request.
+// A missing limit defaults to DefaultPageSize and limits above MaxPageSize are clamped.
+func parsePage(r *http.Request) (limit, offset int, ok bool) {
+	limit = DefaultPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		limit = min(n, MaxPageSize)
+	}
+
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, false
+		}
+		offset = n
+	}
+
+	return limit, offset, true
+}
+
+// parseUserURLOrder reads the sort query parameter of a user URL listing,
+// defaulting to newest first.
+func parseUserURLOrder(r *http.Request) (model.UserURLOrder, bool) {
+	switch v := model.UserURLOrder(r.URL.Query().Get("sort")); v {
+	case "", model.NewestFirst:
+		return model.NewestFirst, true
+	case model.OldestFirst:
+		return model.OldestFirst, true
+	default:
+		return "", false
+	}
+}
+
+// handleShortenWithAuth is handleShorten for authenticated users.
 func (h *Handler) handleShortenWithAuth(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserIDFromContext(r.Context())
 	if !ok {
@@ -320,9 +1137,9 @@ func (h *Handler) handleShortenWithAuth(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	contentEncoding := r.Header.Get("Content-Encoding")
+	gzipped := r.Header.Get("Content-Encoding") == "gzip"
 
-	if contentEncoding != "gzip" {
+	if !gzipped {
 		contentType := r.Header.Get("Content-Type")
 		if !strings.Contains(contentType, "text/plain") && contentType != "" {
 			w.WriteHeader(http.StatusBadRequest)
@@ -330,8 +1147,15 @@ func (h *Handler) handleShortenWithAuth(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	body, err := io.ReadAll(r.Body)
+	body, err := readBody(r)
 	if err != nil {
+		w.WriteHeader(bodyErrorStatus(err))
+		return
+	}
+
+	// Gzipped bodies skip the Content-Type check, so make sure the client did
+	// not mean to send JSON to /api/shorten.
+	if gzipped && looksLikeJSON(body) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
@@ -345,8 +1169,11 @@ func (h *Handler) handleShortenWithAuth(w http.ResponseWriter, r *http.Request)
 	shortenedURL, err := h.urlService.ShortenURLWithUser(r.Context(), originalURL, userID)
 	if err != nil {
 		if errors.Is(err, storage.ErrURLExists) {
-			w.WriteHeader(http.StatusConflict)
-			w.Write([]byte(shortenedURL))
+			writeShortenResult(w, r, http.StatusConflict, shortenedURL)
+			return
+		}
+		if errors.Is(err, service.ErrQuotaExceeded) {
+			writeQuotaExceeded(w)
 			return
 		}
 		log.Error().Err(err).Msg("Failed to shorten URL with user")
@@ -354,32 +1181,67 @@ func (h *Handler) handleShortenWithAuth(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	w.Write([]byte(shortenedURL))
+	writeShortenResult(w, r, http.StatusCreated, shortenedURL)
 }
 
 // HandleShortenJSONWithAuth handles POST /api/shorten with user authentication.
 func (h *Handler) HandleShortenJSONWithAuth(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserIDFromContext(r.Context())
 	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "authentication required")
 		return
 	}
 
 	contentType := r.Header.Get("Content-Type")
 	if !strings.Contains(contentType, "application/json") {
-		w.WriteHeader(http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidContentType, "Content-Type must be application/json")
 		return
 	}
 
 	var request ShortenRequest
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	body, err := readBody(r)
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	if err := json.Unmarshal(body, &request); err != nil {
+		writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidBody, "request body is not valid JSON")
 		return
 	}
 
 	if request.URL == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, ErrCodeMissingURL, "url is required")
+		return
+	}
+
+	if combinesOptions(r, request) {
+		writeCombinedOptionsError(w)
+		return
+	}
+
+	if request.ForwardQuery {
+		h.shortenWithForwardQuery(w, r, request, userID)
+		return
+	}
+
+	if request.ExpiresIn != nil || request.ExpiresAt != nil {
+		h.shortenWithExpiry(w, r, request, userID)
+		return
+	}
+
+	if request.Password != "" {
+		h.shortenWithPassword(w, r, request, userID)
+		return
+	}
+
+	if request.Alias != "" {
+		h.shortenWithAlias(w, r, request, userID)
+		return
+	}
+
+	if verboseRequested(r) {
+		h.shortenVerbose(w, r, request, userID)
 		return
 	}
 
@@ -389,12 +1251,17 @@ func (h *Handler) HandleShortenJSONWithAuth(w http.ResponseWriter, r *http.Reque
 			response := ShortenResponse{Result: shortenedURL}
 			jsonResponse, _ := json.Marshal(response)
 			w.Header().Set("Content-Type", "application/json")
+			h.setConflictDeprecation(w)
 			w.WriteHeader(http.StatusConflict)
 			w.Write(jsonResponse)
 			return
 		}
+		if errors.Is(err, service.ErrQuotaExceeded) {
+			writeQuotaExceeded(w)
+			return
+		}
 		log.Error().Err(err).Msg("Failed to shorten JSON URL with user")
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
@@ -402,7 +1269,7 @@ func (h *Handler) HandleShortenJSONWithAuth(w http.ResponseWriter, r *http.Reque
 	jsonResponse, err := json.Marshal(response)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to marshal shorten response")
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
@@ -414,69 +1281,109 @@ func (h *Handler) HandleShortenJSONWithAuth(w http.ResponseWriter, r *http.Reque
 func (h *Handler) handleShortenBatchWithAuth(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserIDFromContext(r.Context())
 	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "authentication required")
 		return
 	}
 
-	var items []model.BatchRequestItem
-	if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	contentType := r.Header.Get("Content-Type")
+	if !strings.Contains(contentType, "application/json") {
+		writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidContentType, "Content-Type must be application/json")
+		return
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	items, err := decodeBatch(body)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidBody, "request body is not a valid JSON array of batch items")
 		return
 	}
 
 	if len(items) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, ErrCodeEmptyBatch, "batch must not be empty")
 		return
 	}
 
+	if !h.validateBatch(w, items) {
+		return
+	}
+
+	status := http.StatusCreated
 	result, err := h.urlService.ShortenBatchWithUser(r.Context(), items, userID)
-	if err != nil {
+	if errors.Is(err, storage.ErrURLExists) {
+		status = http.StatusOK
+	} else if err != nil {
+		if errors.Is(err, service.ErrQuotaExceeded) {
+			writeQuotaExceeded(w)
+			return
+		}
 		log.Error().Err(err).Msg("Failed to shorten batch URLs with user")
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
 	response, err := json.Marshal(result)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to marshal batch response with user")
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(status)
 	w.Write(response)
 }
 
 func (h *Handler) handleDeleteUserURLs(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserIDFromContext(r.Context())
 	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
+		writeJSONError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "authentication required")
 		return
 	}
 
 	contentType := r.Header.Get("Content-Type")
 	if !strings.Contains(contentType, "application/json") {
-		w.WriteHeader(http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidContentType, "Content-Type must be application/json")
 		return
 	}
 
 	var urlIDs []string
-	if err := json.NewDecoder(r.Body).Decode(&urlIDs); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	body, err := readBody(r)
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	if err := json.Unmarshal(body, &urlIDs); err != nil {
+		writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidBody, "request body is not a valid JSON array of IDs")
 		return
 	}
 
 	if len(urlIDs) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, ErrCodeEmptyBatch, "at least one ID is required")
 		return
 	}
 
+	tenantID := tenant.FromContext(r.Context())
+
 	// Отправляем запрос на удаление в воркер-пул
 	if h.deleteWorker != nil {
-		if err := h.deleteWorker.Submit(userID, urlIDs); err != nil {
-			log.Error().Err(err).Msg("Failed to submit delete request to worker pool")
-			w.WriteHeader(http.StatusServiceUnavailable)
+		if err := h.submitDelete(tenantID, userID, urlIDs); err != nil {
+			switch {
+			case errors.Is(err, worker.ErrQueueFull):
+				log.Warn().Err(err).Msg("Delete queue is full")
+				writeQueueFull(w)
+			case errors.Is(err, context.Canceled):
+				log.Warn().Err(err).Msg("Delete worker pool is shutting down")
+				writeJSONError(w, http.StatusServiceUnavailable, ErrCodeShuttingDown, "server is shutting down, retry later")
+			default:
+				log.Error().Err(err).Msg("Failed to delete user URLs")
+				writeInternalError(w)
+			}
 			return
 		}
 		log.Debug().
@@ -485,7 +1392,7 @@ func (h *Handler) handleDeleteUserURLs(w http.ResponseWriter, r *http.Request) {
 			Msg("Delete request submitted to worker pool")
 	} else {
 		go func() {
-			if err := h.urlService.DeleteUserURLs(userID, urlIDs); err != nil {
+			if err := h.deleteUserURLs(tenantID, userID, urlIDs); err != nil {
 				log.Error().Err(err).Msg("Failed to delete user URLs")
 			}
 		}()
@@ -494,3 +1401,87 @@ func (h *Handler) handleDeleteUserURLs(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusAccepted)
 }
+
+// handleDeleteURL deletes one of the user's short URLs synchronously for
+// DELETE /{id}, answering 204, or 403 if another user owns it.
+func (h *Handler) handleDeleteURL(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r.Context())
+	if !ok {
+		writeJSONError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "authentication required")
+		return
+	}
+
+	deleter, ok := h.urlService.(OwnerURLDeleter)
+	if !ok {
+		writeJSONError(w, http.StatusNotImplemented, ErrCodeNotImplemented, "deleting a single URL is not supported")
+		return
+	}
+
+	id, ok := h.verifiedID(chi.URLParam(r, "id"))
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, ErrCodeNotFound, "short URL not found")
+		return
+	}
+
+	err := deleter.DeleteURL(r.Context(), userID, id)
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, storage.ErrURLNotFound):
+		writeJSONError(w, http.StatusNotFound, ErrCodeNotFound, "short URL not found")
+	case errors.Is(err, service.ErrNotURLOwner):
+		writeJSONError(w, http.StatusForbidden, ErrCodeForbidden, "short URL belongs to another user")
+	default:
+		log.Error().Err(err).Msg("Failed to delete URL")
+		writeInternalError(w)
+	}
+}
+
+func (h *Handler) submitDelete(tenantID, userID string, urlIDs []string) error {
+	if tenantID != "" {
+		if tenantWorker, ok := h.deleteWorker.(TenantDeleteWorker); ok {
+			return tenantWorker.SubmitForTenant(tenantID, userID, urlIDs)
+		}
+	}
+	return h.deleteWorker.Submit(userID, urlIDs)
+}
+
+func (h *Handler) deleteUserURLs(tenantID, userID string, urlIDs []string) error {
+	if tenantID != "" {
+		if tenantDeleter, ok := h.urlService.(TenantURLDeleter); ok {
+			return tenantDeleter.DeleteTenantUserURLs(tenantID, userID, urlIDs)
+		}
+	}
+	return h.urlService.DeleteUserURLs(userID, urlIDs)
+}
+
+// readBody reads the whole request body and closes it.
+// A close error is logged rather than failing the request, since the body has been consumed.
+func readBody(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+
+	if closeErr := r.Body.Close(); closeErr != nil {
+		log.Warn().Err(closeErr).Msg("Failed to close request body")
+	}
+
+	return body, err
+}
+
+// looksLikeJSON reports whether body is a JSON object or array, which is
+// never a URL.
+func looksLikeJSON(body []byte) bool {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return false
+	}
+	return json.Valid(trimmed)
+}
+
+// bodyErrorStatus maps a request body read error to a response status.
+func bodyErrorStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
@@ -3,24 +3,38 @@ package handler
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/MikhailRaia/url-shortener/internal/auth"
+	"github.com/MikhailRaia/url-shortener/internal/middleware"
 	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/service"
 	"github.com/MikhailRaia/url-shortener/internal/storage"
+	"github.com/MikhailRaia/url-shortener/internal/storage/memory"
+	"github.com/MikhailRaia/url-shortener/internal/worker"
 	"github.com/go-chi/chi/v5"
 )
 
 type mockURLService struct {
 	shortenURLFunc                      func(ctx context.Context, originalURL string) (string, error)
 	shortenURLWithUserFunc              func(ctx context.Context, originalURL, userID string) (string, error)
+	shortenURLWithAliasFunc             func(ctx context.Context, originalURL, alias, userID string) (string, error)
 	getOriginalURLFunc                  func(ctx context.Context, id string) (string, bool)
 	getOriginalURLWithDeletedStatusFunc func(ctx context.Context, id string) (string, error)
 	shortenBatchFunc                    func(ctx context.Context, items []model.BatchRequestItem) ([]model.BatchResponseItem, error)
 	shortenBatchWithUserFunc            func(ctx context.Context, items []model.BatchRequestItem, userID string) ([]model.BatchResponseItem, error)
 	getUserURLsFunc                     func(ctx context.Context, userID string) ([]model.UserURL, error)
+	getUserURLsPagedFunc                func(ctx context.Context, userID string, order model.UserURLOrder, limit, offset int) ([]model.UserURL, int, error)
+	getDeletedUserURLsFunc              func(ctx context.Context, userID string) ([]string, error)
 	deleteUserURLsFunc                  func(userID string, urlIDs []string) error
 }
 
@@ -35,6 +49,13 @@ func (m *mockURLService) ShortenURLWithUser(ctx context.Context, originalURL, us
 	return "", nil
 }
 
+func (m *mockURLService) ShortenURLWithAlias(ctx context.Context, originalURL, alias, userID string) (string, error) {
+	if m.shortenURLWithAliasFunc != nil {
+		return m.shortenURLWithAliasFunc(ctx, originalURL, alias, userID)
+	}
+	return "", nil
+}
+
 func (m *mockURLService) GetOriginalURL(ctx context.Context, id string) (string, bool) {
 	return m.getOriginalURLFunc(ctx, id)
 }
@@ -60,6 +81,20 @@ func (m *mockURLService) GetUserURLs(ctx context.Context, userID string) ([]mode
 	return []model.UserURL{}, nil
 }
 
+func (m *mockURLService) GetUserURLsPaged(ctx context.Context, userID string, order model.UserURLOrder, limit, offset int) ([]model.UserURL, int, error) {
+	if m.getUserURLsPagedFunc != nil {
+		return m.getUserURLsPagedFunc(ctx, userID, order, limit, offset)
+	}
+	return []model.UserURL{}, 0, nil
+}
+
+func (m *mockURLService) GetDeletedUserURLs(ctx context.Context, userID string) ([]string, error) {
+	if m.getDeletedUserURLsFunc != nil {
+		return m.getDeletedUserURLsFunc(ctx, userID)
+	}
+	return []string{}, nil
+}
+
 func (m *mockURLService) GetOriginalURLWithDeletedStatus(ctx context.Context, id string) (string, error) {
 	if m.getOriginalURLWithDeletedStatusFunc != nil {
 		return m.getOriginalURLWithDeletedStatusFunc(ctx, id)
@@ -249,3 +284,1065 @@ func TestHandler_RegisterRoutes(t *testing.T) {
 		t.Error("Failed to create Chi router")
 	}
 }
+
+type mockQueueHistory struct {
+	samples []worker.QueueSample
+}
+
+func (m *mockQueueHistory) History() []worker.QueueSample {
+	return m.samples
+}
+
+func (m *mockQueueHistory) SampleInterval() time.Duration {
+	return time.Second
+}
+
+func TestHandler_handleQueueHistory(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history := &mockQueueHistory{
+		samples: []worker.QueueSample{{QueueSize: 1}, {QueueSize: 4}},
+	}
+
+	tests := []struct {
+		name       string
+		subnet     *net.IPNet
+		realIP     string
+		wantStatus int
+	}{
+		{
+			name:       "Trusted client",
+			subnet:     subnet,
+			realIP:     "10.1.2.3",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Untrusted client",
+			subnet:     subnet,
+			realIP:     "192.168.1.1",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "No trusted subnet configured",
+			subnet:     nil,
+			realIP:     "10.1.2.3",
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(&mockURLService{}, nil, WithTrustedSubnet(tt.subnet), WithTrustProxyHeaders(true), WithQueueHistory(history))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/internal/queue/history", nil)
+			req.Header.Set("X-Real-IP", tt.realIP)
+			rr := httptest.NewRecorder()
+
+			handler.RegisterRoutes().ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("handler.handleQueueHistory() status = %v, want %v", rr.Code, tt.wantStatus)
+			}
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var response QueueHistoryResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+
+			if len(response.Samples) != 2 || response.Samples[1].QueueSize != 4 {
+				t.Errorf("handler.handleQueueHistory() samples = %v", response.Samples)
+			}
+
+			if response.IntervalSeconds != 1 {
+				t.Errorf("handler.handleQueueHistory() interval = %v, want 1", response.IntervalSeconds)
+			}
+		})
+	}
+}
+
+func TestHandler_BodyLimits(t *testing.T) {
+	const limit = 64
+
+	mockService := &mockURLService{
+		shortenURLFunc: func(ctx context.Context, originalURL string) (string, error) {
+			return "http://localhost:8080/abc123", nil
+		},
+		shortenBatchFunc: func(ctx context.Context, items []model.BatchRequestItem) ([]model.BatchResponseItem, error) {
+			return []model.BatchResponseItem{{CorrelationID: "1", ShortURL: "http://localhost:8080/abc123"}}, nil
+		},
+	}
+
+	handler := NewHandler(mockService, nil, WithBodyLimits(limit, 2*limit))
+	router := handler.RegisterRoutes()
+
+	textBody := func(n int) string {
+		prefix := "https://example.com/"
+		return prefix + strings.Repeat("a", n-len(prefix))
+	}
+
+	jsonBody := func(n int) string {
+		prefix := `{"url":"https://example.com/`
+		suffix := `"}`
+		return prefix + strings.Repeat("a", n-len(prefix)-len(suffix)) + suffix
+	}
+
+	batchBody := func(n int) string {
+		prefix := `[{"correlation_id":"1","original_url":"https://example.com/`
+		suffix := `"}]`
+		return prefix + strings.Repeat("a", n-len(prefix)-len(suffix)) + suffix
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		wantStatus  int
+	}{
+		{"Text at limit", "/", "text/plain", textBody(limit), http.StatusCreated},
+		{"Text over limit", "/", "text/plain", textBody(limit + 1), http.StatusRequestEntityTooLarge},
+		{"JSON at limit", "/api/shorten", "application/json", jsonBody(limit), http.StatusCreated},
+		{"JSON over limit", "/api/shorten", "application/json", jsonBody(limit + 1), http.StatusRequestEntityTooLarge},
+		{"Batch above single limit", "/api/shorten/batch", "application/json", batchBody(2 * limit), http.StatusCreated},
+		{"Batch over limit", "/api/shorten/batch", "application/json", batchBody(2*limit + 1), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("%s status = %v, want %v", tt.path, rr.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+type errCloseBody struct {
+	io.Reader
+}
+
+func (b *errCloseBody) Close() error {
+	return errors.New("close failed")
+}
+
+func TestHandler_BodyCloseError(t *testing.T) {
+	mockService := &mockURLService{
+		shortenURLFunc: func(ctx context.Context, originalURL string) (string, error) {
+			return "http://localhost:8080/abc123", nil
+		},
+	}
+
+	handler := NewHandler(mockService, nil)
+
+	tests := []struct {
+		name        string
+		handle      http.HandlerFunc
+		body        string
+		contentType string
+	}{
+		{"Text endpoint", handler.handleShorten, "https://example.com", "text/plain"},
+		{"JSON endpoint", handler.HandleShortenJSON, `{"url":"https://example.com"}`, "application/json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.Body = &errCloseBody{Reader: strings.NewReader(tt.body)}
+			req.Header.Set("Content-Type", tt.contentType)
+			rr := httptest.NewRecorder()
+
+			defer func() {
+				if rec := recover(); rec != nil {
+					t.Fatalf("handler panicked on body close error: %v", rec)
+				}
+			}()
+
+			tt.handle(rr, req)
+
+			if rr.Code != http.StatusCreated {
+				t.Errorf("status = %v, want %v", rr.Code, http.StatusCreated)
+			}
+		})
+	}
+}
+
+func TestHandler_handleRedirect_IDValidation(t *testing.T) {
+	tests := []struct {
+		name         string
+		urlID        string
+		wantStatus   int
+		wantLookedUp bool
+	}{
+		{"Valid ID", "abc-123_XYZ", http.StatusTemporaryRedirect, true},
+		{"Out-of-alphabet ID", "abc.123", http.StatusNotFound, false},
+		{"Encoded characters", "abc%20def", http.StatusNotFound, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookedUp := false
+			mockService := &mockURLService{
+				getOriginalURLWithDeletedStatusFunc: func(ctx context.Context, id string) (string, error) {
+					lookedUp = true
+					return "https://example.com", nil
+				},
+			}
+
+			handler := NewHandler(mockService, nil, WithIDValidation(true))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", tt.urlID)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			rr := httptest.NewRecorder()
+			handler.handleRedirect(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("handler.handleRedirect() status = %v, want %v", rr.Code, tt.wantStatus)
+			}
+			if lookedUp != tt.wantLookedUp {
+				t.Errorf("storage lookup = %v, want %v", lookedUp, tt.wantLookedUp)
+			}
+		})
+	}
+}
+
+func TestHandler_handleRedirect_SignedURLs(t *testing.T) {
+	signer := auth.NewURLSigner("link-key")
+	urlService := service.NewURLService(memory.NewStorage(), "http://short.example", service.WithURLSigner(signer))
+	router := NewHandler(urlService, nil, WithURLSigner(signer), WithIDValidation(true)).RegisterRoutes()
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://example.com/signed"))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d", http.StatusCreated, rec.Code)
+	}
+
+	code := strings.TrimPrefix(rec.Body.String(), "http://short.example/")
+	id, ok := signer.Verify(code)
+	if !ok {
+		t.Fatalf("Expected a signed short URL, got %q", rec.Body.String())
+	}
+
+	tests := []struct {
+		name       string
+		code       string
+		wantStatus int
+	}{
+		{"Valid signature", code, http.StatusTemporaryRedirect},
+		{"Missing signature", id, http.StatusNotFound},
+		{"Forged signature", id + ".AAAAAAAAAAAAAAAA", http.StatusNotFound},
+		{"Signature of another ID", "abcdefgh." + signer.Sign(id), http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+tt.code, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status code %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantStatus == http.StatusTemporaryRedirect && rec.Header().Get("Location") != "https://example.com/signed" {
+				t.Errorf("Expected Location %q, got %q", "https://example.com/signed", rec.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+// discardVisits is a VisitRecorder that drops every visit.
+type discardVisits struct{}
+
+func (discardVisits) Record(tenantID, id string) {}
+
+func TestHandler_SignedRoutes(t *testing.T) {
+	signer := auth.NewURLSigner("link-key")
+	urlService := service.NewURLService(memory.NewStorage(), "http://short.example", service.WithURLSigner(signer))
+	router := NewHandler(urlService, nil, WithURLSigner(signer), WithIDValidation(true), WithVisitTracking(discardVisits{})).RegisterRoutes()
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://example.com/signed")))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d", http.StatusCreated, rec.Code)
+	}
+
+	code := strings.TrimPrefix(rec.Body.String(), "http://short.example/")
+	id, ok := signer.Verify(code)
+	if !ok {
+		t.Fatalf("Expected a signed short URL, got %q", rec.Body.String())
+	}
+	forged := id + ".AAAAAAAAAAAAAAAA"
+
+	routes := []struct {
+		name string
+		path func(code string) string
+	}{
+		{name: "QR code", path: func(code string) string { return "/" + code + "/qr" }},
+		{name: "stats", path: func(code string) string { return "/api/url/" + code + "/stats" }},
+	}
+	codes := []struct {
+		name       string
+		code       string
+		wantStatus int
+	}{
+		{"valid signature", code, http.StatusOK},
+		{"missing signature", id, http.StatusNotFound},
+		{"forged signature", forged, http.StatusNotFound},
+	}
+
+	for _, route := range routes {
+		for _, tt := range codes {
+			t.Run(route.name+"/"+tt.name, func(t *testing.T) {
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, route.path(tt.code), nil))
+
+				if rec.Code != tt.wantStatus {
+					t.Errorf("Expected status code %d, got %d", tt.wantStatus, rec.Code)
+				}
+			})
+		}
+	}
+
+	t.Run("expand batch", func(t *testing.T) {
+		body, _ := json.Marshal([]string{code, id, forged})
+		req := httptest.NewRequest(http.MethodPost, "/api/expand/batch", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status code %d, got %d", http.StatusOK, rec.Code)
+		}
+
+		var got []model.ExpandedURL
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		want := []model.ExpandedURL{
+			{ID: code, OriginalURL: "https://example.com/signed"},
+			{ID: id},
+			{ID: forged},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected %+v, got %+v", want, got)
+		}
+	})
+}
+
+func TestHandler_handleGetUserURLs_Pagination(t *testing.T) {
+	allURLs := []model.UserURL{
+		{ShortURL: "http://localhost:8080/a", OriginalURL: "https://a.example.com"},
+		{ShortURL: "http://localhost:8080/b", OriginalURL: "https://b.example.com"},
+		{ShortURL: "http://localhost:8080/c", OriginalURL: "https://c.example.com"},
+	}
+
+	tests := []struct {
+		name       string
+		userID     string
+		query      string
+		wantStatus int
+		wantLimit  int
+		wantOffset int
+		wantCount  int
+		wantTotal  string
+		wantOrder  model.UserURLOrder
+	}{
+		{"Defaults", "user1", "", http.StatusOK, DefaultPageSize, 0, 3, "3", model.NewestFirst},
+		{"Limit and offset", "user1", "?limit=2&offset=1", http.StatusOK, 2, 1, 2, "3", model.NewestFirst},
+		{"Offset past end", "user1", "?offset=5", http.StatusOK, DefaultPageSize, 5, 0, "3", model.NewestFirst},
+		{"Limit clamped", "user1", "?limit=100000", http.StatusOK, MaxPageSize, 0, 3, "3", model.NewestFirst},
+		{"Newest first", "user1", "?sort=newest", http.StatusOK, DefaultPageSize, 0, 3, "3", model.NewestFirst},
+		{"Oldest first", "user1", "?sort=oldest&limit=2", http.StatusOK, 2, 0, 2, "3", model.OldestFirst},
+		{"No URLs", "user2", "", http.StatusNoContent, DefaultPageSize, 0, 0, "0", model.NewestFirst},
+		{"Zero limit", "user1", "?limit=0", http.StatusBadRequest, 0, 0, 0, "", ""},
+		{"Negative offset", "user1", "?offset=-1", http.StatusBadRequest, 0, 0, 0, "", ""},
+		{"Non-numeric limit", "user1", "?limit=ten", http.StatusBadRequest, 0, 0, 0, "", ""},
+		{"Unknown sort", "user1", "?sort=alphabetical", http.StatusBadRequest, 0, 0, 0, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotLimit, gotOffset int
+			var gotOrder model.UserURLOrder
+			mockService := &mockURLService{
+				getUserURLsPagedFunc: func(ctx context.Context, userID string, order model.UserURLOrder, limit, offset int) ([]model.UserURL, int, error) {
+					gotLimit, gotOffset, gotOrder = limit, offset, order
+					if userID != "user1" {
+						return []model.UserURL{}, 0, nil
+					}
+					if offset >= len(allURLs) {
+						return []model.UserURL{}, len(allURLs), nil
+					}
+					return allURLs[offset:min(offset+limit, len(allURLs))], len(allURLs), nil
+				},
+			}
+
+			handler := NewHandler(mockService, nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/user/urls"+tt.query, nil)
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, tt.userID))
+			rr := httptest.NewRecorder()
+
+			handler.handleGetUserURLs(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("handler.handleGetUserURLs() status = %v, want %v", rr.Code, tt.wantStatus)
+			}
+
+			if got := rr.Header().Get("X-Total-Count"); got != tt.wantTotal {
+				t.Errorf("X-Total-Count = %q, want %q", got, tt.wantTotal)
+			}
+
+			if tt.wantStatus == http.StatusBadRequest {
+				return
+			}
+
+			if gotLimit != tt.wantLimit || gotOffset != tt.wantOffset {
+				t.Errorf("page = limit %d offset %d, want limit %d offset %d", gotLimit, gotOffset, tt.wantLimit, tt.wantOffset)
+			}
+			if gotOrder != tt.wantOrder {
+				t.Errorf("order = %q, want %q", gotOrder, tt.wantOrder)
+			}
+
+			if tt.wantStatus == http.StatusOK {
+				var urls []model.UserURL
+				if err := json.Unmarshal(rr.Body.Bytes(), &urls); err != nil {
+					t.Fatalf("Failed to unmarshal response: %v", err)
+				}
+				if len(urls) != tt.wantCount {
+					t.Errorf("returned %d URLs, want %d", len(urls), tt.wantCount)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_handleGetUserURLs_Sort(t *testing.T) {
+	urlService := service.NewURLService(memory.NewStorage(), "http://localhost:8080")
+	handler := NewHandler(urlService, nil)
+
+	originals := []string{"https://a.example.com", "https://b.example.com", "https://c.example.com"}
+	for _, original := range originals {
+		if _, err := urlService.ShortenURLWithUser(context.Background(), original, "user1"); err != nil {
+			t.Fatalf("ShortenURLWithUser() error = %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		oldest bool
+		want   []string
+	}{
+		{"Default is newest first", "", false, []string{originals[2], originals[1], originals[0]}},
+		{"Oldest first", "?sort=oldest", true, originals},
+		{"Newest first page", "?limit=2&offset=1", false, []string{originals[1], originals[0]}},
+		{"Oldest first page", "?sort=oldest&limit=1&offset=1", true, []string{originals[1]}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/user/urls"+tt.query, nil)
+			req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "user1"))
+			rr := httptest.NewRecorder()
+
+			handler.handleGetUserURLs(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+			}
+
+			var urls []map[string]string
+			if err := json.Unmarshal(rr.Body.Bytes(), &urls); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if len(urls) != len(tt.want) {
+				t.Fatalf("Expected %d URLs, got %d", len(tt.want), len(urls))
+			}
+
+			var prev time.Time
+			for i, url := range urls {
+				if url["original_url"] != tt.want[i] {
+					t.Errorf("Expected URL %d to be %q, got %q", i, tt.want[i], url["original_url"])
+				}
+				createdAt, err := time.Parse(time.RFC3339, url["created_at"])
+				if err != nil {
+					t.Fatalf("Expected RFC3339 created_at, got %q: %v", url["created_at"], err)
+				}
+				inOrder := !createdAt.After(prev)
+				if tt.oldest {
+					inOrder = !createdAt.Before(prev)
+				}
+				if i > 0 && !inOrder {
+					t.Errorf("Expected created_at %v to be listed before %v", createdAt, prev)
+				}
+				prev = createdAt
+			}
+		})
+	}
+}
+
+func TestHandler_PasswordProtectedRedirect(t *testing.T) {
+	urlService := service.NewURLService(memory.NewStorage(), "http://localhost:8080")
+	handler := NewHandler(urlService, nil, WithPasswords(true))
+
+	body, _ := json.Marshal(ShortenRequest{URL: "https://example.com/secret", Password: "s3cret"})
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.HandleShortenJSON(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("HandleShortenJSON() status = %v, want %v", rr.Code, http.StatusCreated)
+	}
+
+	var response ShortenResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	id := strings.TrimPrefix(response.Result, "http://localhost:8080/")
+
+	tests := []struct {
+		name       string
+		query      string
+		basicAuth  string
+		wantStatus int
+	}{
+		{"Correct password in query", "?pw=s3cret", "", http.StatusTemporaryRedirect},
+		{"Correct password in basic auth", "", "s3cret", http.StatusTemporaryRedirect},
+		{"Wrong password", "?pw=guess", "", http.StatusUnauthorized},
+		{"Missing password", "", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/"+id+tt.query, nil)
+			if tt.basicAuth != "" {
+				req.SetBasicAuth("", tt.basicAuth)
+			}
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("id", id)
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+			rr := httptest.NewRecorder()
+			handler.handleRedirect(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("handler.handleRedirect() status = %v, want %v", rr.Code, tt.wantStatus)
+			}
+
+			wantLocation := ""
+			if tt.wantStatus == http.StatusTemporaryRedirect {
+				wantLocation = "https://example.com/secret"
+			}
+			if location := rr.Header().Get("Location"); location != wantLocation {
+				t.Errorf("handler.handleRedirect() Location = %q, want %q", location, wantLocation)
+			}
+		})
+	}
+}
+
+func TestHandler_PasswordsDisabled(t *testing.T) {
+	handler := NewHandler(service.NewURLService(memory.NewStorage(), "http://localhost:8080"), nil)
+
+	body, _ := json.Marshal(ShortenRequest{URL: "https://example.com/secret", Password: "s3cret"})
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.HandleShortenJSON(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("HandleShortenJSON() status = %v, want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandler_handleGetDeletedUserURLs(t *testing.T) {
+	tests := []struct {
+		name       string
+		userID     string
+		ids        []string
+		wantStatus int
+	}{
+		{"Deleted URLs", "user1", []string{"abc123", "def456"}, http.StatusOK},
+		{"No deleted URLs", "user1", []string{}, http.StatusNoContent},
+		{"No user", "", nil, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &mockURLService{
+				getDeletedUserURLsFunc: func(ctx context.Context, userID string) ([]string, error) {
+					return tt.ids, nil
+				},
+			}
+
+			handler := NewHandler(mockService, nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/user/urls/deleted", nil)
+			if tt.userID != "" {
+				req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, tt.userID))
+			}
+			rr := httptest.NewRecorder()
+
+			handler.handleGetDeletedUserURLs(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("handler.handleGetDeletedUserURLs() status = %v, want %v", rr.Code, tt.wantStatus)
+			}
+
+			if tt.wantStatus == http.StatusOK {
+				var ids []string
+				if err := json.Unmarshal(rr.Body.Bytes(), &ids); err != nil {
+					t.Fatalf("Failed to unmarshal response: %v", err)
+				}
+				if len(ids) != len(tt.ids) {
+					t.Errorf("returned %v, want %v", ids, tt.ids)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_handleDeleteURL(t *testing.T) {
+	jwtService := auth.NewJWTService("test-secret")
+	ownerToken, _ := jwtService.GenerateToken("owner")
+	otherToken, _ := jwtService.GenerateToken("other")
+
+	st := memory.NewStorage()
+	urlService := service.NewURLService(st, "http://localhost:8080")
+	router := NewHandler(urlService, nil).RegisterRoutesWithAuth(middleware.NewAuthMiddleware(jwtService))
+
+	owned, _ := st.SaveWithUser("https://example.com/owned", "owner")
+	kept, _ := st.SaveWithUser("https://example.com/kept", "owner")
+
+	tests := []struct {
+		name       string
+		id         string
+		token      string
+		wantStatus int
+		wantErr    string
+	}{
+		{name: "without token", id: owned, wantStatus: http.StatusUnauthorized},
+		{name: "non-owner", id: owned, token: otherToken, wantStatus: http.StatusForbidden, wantErr: ErrCodeForbidden},
+		{name: "unknown ID", id: "missing1", token: ownerToken, wantStatus: http.StatusNotFound, wantErr: ErrCodeNotFound},
+		{name: "owner", id: owned, token: ownerToken, wantStatus: http.StatusNoContent},
+		{name: "owner deleting again", id: owned, token: ownerToken, wantStatus: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/"+tt.id, nil)
+			if tt.token != "" {
+				req.AddCookie(&http.Cookie{Name: "auth_token", Value: tt.token})
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if tt.wantErr != "" {
+				if detail := decodeError(t, rr); detail.Code != tt.wantErr {
+					t.Errorf("Expected error code %q, got %q", tt.wantErr, detail.Code)
+				}
+			}
+		})
+	}
+
+	if _, err := st.GetWithDeletedStatus(owned); !errors.Is(err, storage.ErrURLDeleted) {
+		t.Errorf("Expected the owned URL to be deleted, got %v", err)
+	}
+	if _, err := st.GetWithDeletedStatus(kept); err != nil {
+		t.Errorf("Expected other URLs of the owner to be kept, got %v", err)
+	}
+}
+
+func TestHandler_handleDeleteURLSigned(t *testing.T) {
+	jwtService := auth.NewJWTService("test-secret")
+	ownerToken, _ := jwtService.GenerateToken("owner")
+	otherToken, _ := jwtService.GenerateToken("other")
+
+	signer := auth.NewURLSigner("link-key")
+	st := memory.NewStorage()
+	urlService := service.NewURLService(st, "http://localhost:8080")
+	router := NewHandler(urlService, nil, WithURLSigner(signer)).RegisterRoutesWithAuth(middleware.NewAuthMiddleware(jwtService))
+
+	owned, _ := st.SaveWithUser("https://example.com/owned", "owner")
+
+	tests := []struct {
+		name       string
+		id         string
+		token      string
+		wantStatus int
+	}{
+		{name: "unsigned ID of another user", id: owned, token: otherToken, wantStatus: http.StatusNotFound},
+		{name: "unsigned ID of the owner", id: owned, token: ownerToken, wantStatus: http.StatusNotFound},
+		{name: "forged signature", id: owned + ".forged", token: ownerToken, wantStatus: http.StatusNotFound},
+		{name: "signed ID", id: signer.SignedID(owned), token: ownerToken, wantStatus: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/"+tt.id, nil)
+			req.AddCookie(&http.Cookie{Name: "auth_token", Value: tt.token})
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if tt.wantStatus == http.StatusNotFound {
+				if detail := decodeError(t, rr); detail.Code != ErrCodeNotFound {
+					t.Errorf("Expected error code %q, got %q", ErrCodeNotFound, detail.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_handleAdminShutdown(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("ParseCIDR() error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		realIP       string
+		token        string
+		wantStatus   int
+		wantShutdown bool
+	}{
+		{"Valid token from trusted subnet", "10.1.2.3", "admin-secret", http.StatusAccepted, true},
+		{"Missing token", "10.1.2.3", "", http.StatusUnauthorized, false},
+		{"Wrong token", "10.1.2.3", "guess", http.StatusUnauthorized, false},
+		{"Untrusted client", "192.168.1.1", "admin-secret", http.StatusForbidden, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shutdownCalled := false
+			handler := NewHandler(&mockURLService{}, nil,
+				WithTrustedSubnet(subnet),
+				WithTrustProxyHeaders(true),
+				WithAdminShutdown("admin-secret", func() { shutdownCalled = true }),
+			)
+			router := handler.RegisterRoutes()
+
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/shutdown", nil)
+			req.Header.Set("X-Real-IP", tt.realIP)
+			if tt.token != "" {
+				req.Header.Set("X-Admin-Token", tt.token)
+			}
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("status = %v, want %v", rr.Code, tt.wantStatus)
+			}
+			if shutdownCalled != tt.wantShutdown {
+				t.Errorf("shutdown called = %v, want %v", shutdownCalled, tt.wantShutdown)
+			}
+		})
+	}
+}
+
+func TestHandler_Profiling(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("ParseCIDR() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		profiling  bool
+		path       string
+		realIP     string
+		wantStatus int
+	}{
+		{"Index from trusted subnet", true, "/debug/pprof/", "10.1.2.3", http.StatusOK},
+		{"Named profile from trusted subnet", true, "/debug/pprof/goroutine?debug=1", "10.1.2.3", http.StatusOK},
+		{"Cmdline from trusted subnet", true, "/debug/pprof/cmdline", "10.1.2.3", http.StatusOK},
+		{"Untrusted client", true, "/debug/pprof/", "192.168.1.1", http.StatusForbidden},
+		{"Disabled", false, "/debug/pprof/", "10.1.2.3", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(&mockURLService{}, nil,
+				WithTrustedSubnet(subnet),
+				WithTrustProxyHeaders(true),
+				WithProfiling(tt.profiling),
+			)
+			router := handler.RegisterRoutes()
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("X-Real-IP", tt.realIP)
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("status = %v, want %v", rr.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestHandler_RequestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	mockService := &mockURLService{
+		getOriginalURLWithDeletedStatusFunc: func(ctx context.Context, id string) (string, error) {
+			<-release // storage that ignores ctx
+			return "https://example.com", nil
+		},
+	}
+
+	router := NewHandler(mockService, nil, WithTimeouts(50*time.Millisecond, time.Second)).RegisterRoutes()
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/abc123", nil))
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Handler returned after %v, want within the deadline", elapsed)
+	}
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status code %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+}
+
+func TestHandler_CORSPreflight(t *testing.T) {
+	router := NewHandler(&mockURLService{}, nil, WithCORS(middleware.CORSConfig{
+		AllowedOrigins: []string{"https://app.example"},
+		AllowedMethods: []string{http.MethodPost},
+		AllowedHeaders: []string{"Content-Type"},
+	})).RegisterRoutes()
+
+	req := httptest.NewRequest(http.MethodOptions, "/api/shorten", nil)
+	req.Header.Set("Origin", "https://app.example")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	rec := httptest.NewRecorder()
+
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("Expected status code %d, got %d", http.StatusNoContent, rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example" {
+		t.Errorf("Expected Access-Control-Allow-Origin %q, got %q", "https://app.example", got)
+	}
+}
+
+func TestHandler_HostBaseURL(t *testing.T) {
+	urlService := service.NewURLService(memory.NewStorage(), "auto")
+	router := NewHandler(urlService, nil, WithHostBaseURL(), WithTrustProxyHeaders(true)).RegisterRoutes()
+
+	tests := []struct {
+		name       string
+		host       string
+		proto      string
+		url        string
+		wantPrefix string
+	}{
+		{name: "first host", host: "short.example", url: "https://example.com/a", wantPrefix: "http://short.example/"},
+		{name: "second host behind TLS proxy", host: "go.example:8443", proto: "https", url: "https://example.com/b", wantPrefix: "https://go.example:8443/"},
+		{name: "unknown forwarded proto", host: "short.example", proto: "ftp", url: "https://example.com/c", wantPrefix: "http://short.example/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.url))
+			req.Host = tt.host
+			if tt.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("Expected status code %d, got %d", http.StatusCreated, rec.Code)
+			}
+			if got := rec.Body.String(); !strings.HasPrefix(got, tt.wantPrefix) {
+				t.Errorf("Expected short URL starting with %q, got %q", tt.wantPrefix, got)
+			}
+		})
+	}
+
+	body := `[{"correlation_id":"1","original_url":"https://example.com/batch"}]`
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader(body))
+	req.Host = "go.example"
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	router.ServeHTTP(rec, req)
+
+	var items []model.BatchResponseItem
+	if err := json.NewDecoder(rec.Body).Decode(&items); err != nil {
+		t.Fatalf("Failed to decode batch response: %v", err)
+	}
+	if len(items) != 1 || !strings.HasPrefix(items[0].ShortURL, "http://go.example/") {
+		t.Errorf("Expected batch short URL on http://go.example/, got %+v", items)
+	}
+}
+
+func TestHandler_TrustProxyHeaders(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("10.0.0.0/8")
+	history := &mockQueueHistory{}
+
+	tests := []struct {
+		name        string
+		opts        []Option
+		wantBaseURL string
+		wantHistory int
+	}{
+		{name: "trusted", opts: []Option{WithTrustProxyHeaders(true)}, wantBaseURL: "https://go.example/", wantHistory: http.StatusOK},
+		{name: "untrusted", opts: []Option{WithTrustProxyHeaders(false)}, wantBaseURL: "http://short.example/", wantHistory: http.StatusForbidden},
+		{name: "default", wantBaseURL: "http://short.example/", wantHistory: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlService := service.NewURLService(memory.NewStorage(), "auto")
+			opts := append([]Option{
+				WithHostBaseURL(),
+				WithTrustedSubnet(subnet),
+				WithQueueHistory(history),
+			}, tt.opts...)
+			router := NewHandler(urlService, nil, opts...).RegisterRoutes()
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://example.com"))
+			req.Host = "short.example"
+			req.Header.Set("X-Forwarded-Proto", "https")
+			req.Header.Set("X-Forwarded-Host", "go.example, proxy.internal")
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("Expected status code %d, got %d", http.StatusCreated, rec.Code)
+			}
+			if got := rec.Body.String(); !strings.HasPrefix(got, tt.wantBaseURL) {
+				t.Errorf("Expected short URL starting with %q, got %q", tt.wantBaseURL, got)
+			}
+
+			req = httptest.NewRequest(http.MethodGet, "/api/internal/queue/history", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			req.Header.Set("X-Real-IP", "10.1.2.3")
+			rec = httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantHistory {
+				t.Errorf("Expected status code %d for a spoofed X-Real-IP, got %d", tt.wantHistory, rec.Code)
+			}
+		})
+	}
+}
+
+func TestHandler_APITrailingSlash(t *testing.T) {
+	urlStorage := memory.NewStorage()
+	urlService := service.NewURLService(urlStorage, "http://localhost:8080")
+	router := NewHandler(urlService, nil).RegisterRoutes()
+
+	id, _ := urlStorage.Save("https://example.com/target")
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		body        string
+		wantStatus  int
+		wantHeader  string
+		wantHeaderV string
+	}{
+		{name: "shorten with slash", method: http.MethodPost, path: "/api/shorten/", body: `{"url":"https://example.com/a"}`, wantStatus: http.StatusCreated},
+		{name: "batch with slash", method: http.MethodPost, path: "/api/shorten/batch/", body: `[{"correlation_id":"1","original_url":"https://example.com/b"}]`, wantStatus: http.StatusCreated},
+		{name: "redirect", method: http.MethodGet, path: "/" + id, wantStatus: http.StatusTemporaryRedirect, wantHeader: "Location", wantHeaderV: "https://example.com/target"},
+		{name: "redirect with slash is not rewritten", method: http.MethodGet, path: "/" + id + "/", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status code %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantHeader != "" && rec.Header().Get(tt.wantHeader) != tt.wantHeaderV {
+				t.Errorf("Expected %s %q, got %q", tt.wantHeader, tt.wantHeaderV, rec.Header().Get(tt.wantHeader))
+			}
+		})
+	}
+}
+
+// pingerFunc adapts a function to DBPinger.
+type pingerFunc func(ctx context.Context) error
+
+func (f pingerFunc) Ping(ctx context.Context) error {
+	return f(ctx)
+}
+
+func TestHandler_Ping(t *testing.T) {
+	blocking := pingerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	tests := []struct {
+		name         string
+		pinger       DBPinger
+		expectedCode int
+	}{
+		{name: "reachable", pinger: pingerFunc(func(context.Context) error { return nil }), expectedCode: http.StatusOK},
+		{name: "unreachable", pinger: pingerFunc(func(context.Context) error { return errors.New("connection refused") }), expectedCode: http.StatusServiceUnavailable},
+		{name: "blocking", pinger: blocking, expectedCode: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := NewHandler(&mockURLService{}, tt.pinger, WithPingTimeout(50*time.Millisecond)).RegisterRoutes()
+
+			req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+			rr := httptest.NewRecorder()
+
+			start := time.Now()
+			router.ServeHTTP(rr, req)
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("Expected /ping to return within the timeout, took %v", elapsed)
+			}
+
+			if rr.Code != tt.expectedCode {
+				t.Fatalf("Expected status code %d, got %d", tt.expectedCode, rr.Code)
+			}
+			if tt.expectedCode == http.StatusOK {
+				return
+			}
+			if detail := decodeError(t, rr); detail.Code != ErrCodeDatabaseUnavailable {
+				t.Errorf("Expected error code %q, got %q", ErrCodeDatabaseUnavailable, detail.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/MikhailRaia/url-shortener/internal/middleware"
+	"github.com/MikhailRaia/url-shortener/internal/storage"
+	"github.com/MikhailRaia/url-shortener/internal/tenant"
+)
+
+// IdempotencyKeyHeader is the request header carrying a client-chosen key that
+// makes a retried shorten request return the response of the first attempt.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader marks a response replayed for a repeated key.
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// MaxIdempotencyKeyLength is the longest accepted idempotency key.
+const MaxIdempotencyKeyLength = 255
+
+// IdempotencyCacheSize is the number of idempotency keys remembered at once;
+// the least recently used keys are forgotten first.
+const IdempotencyCacheSize = 10000
+
+// replayedHeaders are the response headers stored with an idempotent response.
+var replayedHeaders = []string{"Content-Type", "Deprecation", "Warning"}
+
+// idempotentResponse is a response stored for an idempotency key together
+// with the SHA-256 of the request body that produced it. A nil response marks
+// a request with that key still being processed.
+type idempotentResponse struct {
+	status      int
+	header      http.Header
+	body        []byte
+	fingerprint [sha256.Size]byte
+}
+
+// idempotencyStore remembers the responses of requests by idempotency key.
+// It is per process, so retries must reach the same instance.
+type idempotencyStore struct {
+	mu        sync.Mutex
+	responses *storage.TTLCache[*idempotentResponse]
+}
+
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	return &idempotencyStore{
+		responses: storage.NewTTLCache[*idempotentResponse](IdempotencyCacheSize, ttl),
+	}
+}
+
+// begin reserves key for a new request and reports true, or returns the
+// stored response for it. A nil response with false means another request
+// with the key is in progress.
+func (s *idempotencyStore) begin(key string) (*idempotentResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if resp, ok := s.responses.Get(key); ok {
+		return resp, false
+	}
+
+	s.responses.Add(key, nil)
+	return nil, true
+}
+
+// finish stores the response for a reserved key.
+func (s *idempotencyStore) finish(key string, resp *idempotentResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responses.Add(key, resp)
+}
+
+// abort releases a reserved key so the request can be retried.
+func (s *idempotencyStore) abort(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responses.Remove(key)
+}
+
+// recordingWriter passes a response through while keeping a copy of it.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.header = make(http.Header)
+		for _, name := range replayedHeaders {
+			if values := w.Header().Values(name); len(values) > 0 {
+				w.header[name] = values
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// idempotent makes next replay its first response to requests repeating an
+// Idempotency-Key, without running again. Keys are scoped to the tenant, the
+// user and the route; requests without a user ID are not made idempotent. A
+// key reused with a different request body is rejected. Server errors are not
+// stored, so such requests can be retried with the same key.
+func (h *Handler) idempotent(next http.Handler) http.Handler {
+	if h.idempotency == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > MaxIdempotencyKeyLength {
+			writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidIdempotencyKey, "Idempotency-Key is too long")
+			return
+		}
+
+		userID, ok := middleware.GetUserIDFromContext(r.Context())
+		if !ok || userID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := sha256.Sum256(body)
+
+		key = tenant.FromContext(r.Context()) + "\x00" + userID + "\x00" + r.URL.Path + "\x00" + key
+
+		resp, started := h.idempotency.begin(key)
+		if !started {
+			if resp == nil {
+				writeJSONError(w, http.StatusConflict, ErrCodeRequestInProgress, "a request with this Idempotency-Key is in progress")
+				return
+			}
+			if resp.fingerprint != fingerprint {
+				writeJSONError(w, http.StatusUnprocessableEntity, ErrCodeIdempotencyKeyReused, "Idempotency-Key was used with a different request body")
+				return
+			}
+			replayResponse(w, resp)
+			return
+		}
+
+		rec := &recordingWriter{ResponseWriter: w}
+		stored := false
+		defer func() {
+			if !stored {
+				h.idempotency.abort(key)
+			}
+		}()
+
+		next.ServeHTTP(rec, r)
+
+		if rec.status != 0 && rec.status < http.StatusInternalServerError {
+			h.idempotency.finish(key, &idempotentResponse{status: rec.status, header: rec.header, body: rec.body.Bytes(), fingerprint: fingerprint})
+			stored = true
+		}
+	})
+}
+
+func replayResponse(w http.ResponseWriter, resp *idempotentResponse) {
+	for name, values := range resp.header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(resp.status)
+	w.Write(resp.body)
+}
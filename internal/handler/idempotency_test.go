@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MikhailRaia/url-shortener/internal/middleware"
+	"github.com/MikhailRaia/url-shortener/internal/service"
+	"github.com/MikhailRaia/url-shortener/internal/storage/memory"
+)
+
+// withUser serves requests to next as the given user, as the auth middleware
+// would.
+func withUser(next http.Handler, userID string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), middleware.UserIDKey, userID)))
+	})
+}
+
+func TestHandler_IdempotencyKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		wantStatus  int
+	}{
+		{name: "text", path: "/", contentType: "text/plain", body: "https://example.com/text", wantStatus: http.StatusCreated},
+		{name: "JSON", path: "/api/shorten", contentType: "application/json", body: `{"url":"https://example.com/json"}`, wantStatus: http.StatusCreated},
+		{name: "batch", path: "/api/shorten/batch", contentType: "application/json", body: `[{"correlation_id":"1","original_url":"https://example.com/batch"}]`, wantStatus: http.StatusCreated},
+		{name: "invalid JSON", path: "/api/shorten", contentType: "application/json", body: `{"url":`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlStorage := memory.NewStorage()
+			urlService := service.NewURLService(urlStorage, "http://localhost:8080")
+			router := withUser(NewHandler(urlService, nil, WithIdempotency(time.Minute)).RegisterRoutes(), "user1")
+
+			send := func(key string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+				req.Header.Set("Content-Type", tt.contentType)
+				req.Header.Set(IdempotencyKeyHeader, key)
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+				return rec
+			}
+
+			first := send("key-1")
+			second := send("key-1")
+
+			if first.Code != tt.wantStatus {
+				t.Fatalf("Expected status code %d, got %d", tt.wantStatus, first.Code)
+			}
+			if second.Code != first.Code {
+				t.Errorf("Expected replayed status code %d, got %d", first.Code, second.Code)
+			}
+			if second.Body.String() != first.Body.String() {
+				t.Errorf("Expected replayed body %q, got %q", first.Body.String(), second.Body.String())
+			}
+			if got := second.Header().Get("Content-Type"); got != first.Header().Get("Content-Type") {
+				t.Errorf("Expected replayed Content-Type %q, got %q", first.Header().Get("Content-Type"), got)
+			}
+			if got := second.Header().Get(IdempotentReplayedHeader); got != "true" {
+				t.Errorf("Expected %s header true, got %q", IdempotentReplayedHeader, got)
+			}
+			if first.Header().Get(IdempotentReplayedHeader) != "" {
+				t.Errorf("Expected no %s header on the first response", IdempotentReplayedHeader)
+			}
+
+			wantCount := 0
+			if tt.wantStatus == http.StatusCreated {
+				wantCount = 1
+			}
+			if count, _ := urlStorage.Count(); count != wantCount {
+				t.Errorf("Expected %d stored URLs, got %d", wantCount, count)
+			}
+
+			if tt.wantStatus == http.StatusCreated {
+				if other := send("key-2"); other.Header().Get(IdempotentReplayedHeader) != "" {
+					t.Errorf("Expected a different key not to be replayed, got %q", other.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_IdempotencyKeyServerError(t *testing.T) {
+	calls := 0
+	urlService := &mockURLService{
+		shortenURLFunc: func(ctx context.Context, originalURL string) (string, error) {
+			calls++
+			if calls == 1 {
+				return "", errors.New("storage unavailable")
+			}
+			return "http://localhost:8080/abc123", nil
+		},
+	}
+	router := withUser(NewHandler(urlService, nil, WithIdempotency(time.Minute)).RegisterRoutes(), "user1")
+
+	for _, wantStatus := range []int{http.StatusInternalServerError, http.StatusCreated, http.StatusCreated} {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://example.com"))
+		req.Header.Set(IdempotencyKeyHeader, "retry")
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != wantStatus {
+			t.Errorf("Expected status code %d, got %d", wantStatus, rec.Code)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Expected the service to be called 2 times, got %d", calls)
+	}
+}
+
+func TestHandler_IdempotencyKeyTooLong(t *testing.T) {
+	router := NewHandler(&mockURLService{}, nil, WithIdempotency(time.Minute)).RegisterRoutes()
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://example.com"))
+	req.Header.Set(IdempotencyKeyHeader, strings.Repeat("k", MaxIdempotencyKeyLength+1))
+	rec := httptest.NewRecorder()
+
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if got := decodeError(t, rec); got.Code != ErrCodeInvalidIdempotencyKey {
+		t.Errorf("Expected error code %q, got %q", ErrCodeInvalidIdempotencyKey, got.Code)
+	}
+}
+
+func TestHandler_IdempotencyKeyDifferentBody(t *testing.T) {
+	urlStorage := memory.NewStorage()
+	urlService := service.NewURLService(urlStorage, "http://localhost:8080")
+	router := withUser(NewHandler(urlService, nil, WithIdempotency(time.Minute)).RegisterRoutes(), "user1")
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if first := send("https://example.com/first"); first.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d", http.StatusCreated, first.Code)
+	}
+
+	second := send("https://example.com/second")
+	if second.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code %d, got %d", http.StatusUnprocessableEntity, second.Code)
+	}
+	if got := decodeError(t, second); got.Code != ErrCodeIdempotencyKeyReused {
+		t.Errorf("Expected error code %q, got %q", ErrCodeIdempotencyKeyReused, got.Code)
+	}
+	if count, _ := urlStorage.Count(); count != 1 {
+		t.Errorf("Expected 1 stored URL, got %d", count)
+	}
+}
+
+func TestHandler_IdempotencyKeyWithoutUser(t *testing.T) {
+	calls := 0
+	urlService := &mockURLService{
+		shortenURLFunc: func(ctx context.Context, originalURL string) (string, error) {
+			calls++
+			return "http://localhost:8080/abc123", nil
+		},
+	}
+	router := NewHandler(urlService, nil, WithIdempotency(time.Minute)).RegisterRoutes()
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://example.com"))
+		req.Header.Set(IdempotencyKeyHeader, "anonymous")
+		rec := httptest.NewRecorder()
+
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusCreated {
+			t.Errorf("Expected status code %d, got %d", http.StatusCreated, rec.Code)
+		}
+		if got := rec.Header().Get(IdempotentReplayedHeader); got != "" {
+			t.Errorf("Expected no %s header without a user, got %q", IdempotentReplayedHeader, got)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Expected the service to be called 2 times, got %d", calls)
+	}
+}
+
+func TestIdempotencyStore_InProgress(t *testing.T) {
+	store := newIdempotencyStore(time.Minute)
+
+	if _, started := store.begin("key"); !started {
+		t.Fatal("Expected the first request to start")
+	}
+	if resp, started := store.begin("key"); started || resp != nil {
+		t.Errorf("Expected a concurrent request to be reported in progress, got started=%v resp=%v", started, resp)
+	}
+
+	store.abort("key")
+	if _, started := store.begin("key"); !started {
+		t.Error("Expected an aborted key to be reusable")
+	}
+}
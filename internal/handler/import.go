@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/service"
+	"github.com/rs/zerolog/log"
+)
+
+// ImportURLService is implemented by services that can bulk-load URLs under
+// given short IDs.
+type ImportURLService interface {
+	ImportURLs(ctx context.Context, records []model.URLRecord) (int, []string, error)
+}
+
+// ImportResponse is the JSON response of POST /api/admin/import.
+type ImportResponse struct {
+	Imported  int      `json:"imported"`
+	Conflicts []string `json:"conflicts"`
+}
+
+// handleImport loads a dump of existing short URLs, keeping their short IDs.
+// The body is either a JSON array of {"short_url", "original_url", "user_id"}
+// objects, the same objects one per line as GET /api/admin/export writes them
+// (Content-Type application/x-ndjson) or, with Content-Type text/csv, rows of
+// short ID, original URL and an optional user ID, with an optional header row. Records that conflict
+// with stored URLs are skipped and listed in the response.
+func (h *Handler) handleImport(w http.ResponseWriter, r *http.Request) {
+	importService, ok := h.urlService.(ImportURLService)
+	if !ok {
+		writeJSONError(w, http.StatusNotImplemented, ErrCodeNotImplemented, "importing URLs is not supported")
+		return
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	var records []model.URLRecord
+	switch contentType := r.Header.Get("Content-Type"); {
+	case strings.Contains(contentType, "application/json"):
+		if err := json.Unmarshal(body, &records); err != nil {
+			writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidBody, "request body is not a valid JSON array")
+			return
+		}
+	case strings.Contains(contentType, "application/x-ndjson"):
+		records, err = parseImportNDJSON(body)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidBody, "request body is not valid newline-delimited JSON")
+			return
+		}
+	case strings.Contains(contentType, "text/csv"):
+		records, err = parseImportCSV(body)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidBody, err.Error())
+			return
+		}
+	default:
+		writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidContentType, "Content-Type must be application/json, application/x-ndjson or text/csv")
+		return
+	}
+
+	if len(records) == 0 {
+		writeJSONError(w, http.StatusBadRequest, ErrCodeEmptyBatch, "at least one record is required")
+		return
+	}
+
+	imported, conflicts, err := importService.ImportURLs(r.Context(), records)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidImportRecord):
+			writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidImportRecord, strings.TrimPrefix(err.Error(), service.ErrInvalidImportRecord.Error()+": "))
+		case errors.Is(err, service.ErrImportUnsupported):
+			writeJSONError(w, http.StatusNotImplemented, ErrCodeNotImplemented, "storage does not support importing URLs")
+		default:
+			log.Error().Err(err).Msg("Failed to import URLs")
+			writeInternalError(w)
+		}
+		return
+	}
+
+	if conflicts == nil {
+		conflicts = []string{}
+	}
+	log.Info().Int("imported", imported).Int("conflicts", len(conflicts)).Str("remote", r.RemoteAddr).Msg("URLs imported")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(ImportResponse{Imported: imported, Conflicts: conflicts}); err != nil {
+		log.Error().Err(err).Msg("Failed to encode import response")
+	}
+}
+
+// parseImportNDJSON reads one JSON record per line.
+func parseImportNDJSON(body []byte) ([]model.URLRecord, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+
+	var records []model.URLRecord
+	for {
+		var record model.URLRecord
+		err := decoder.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+}
+
+// parseImportCSV reads rows of short ID, original URL and an optional user ID.
+// A first row starting with "short_id" or "short_url" is taken as a header.
+func parseImportCSV(body []byte) ([]model.URLRecord, error) {
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var records []model.URLRecord
+	for line := 1; ; line++ {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("request body is not valid CSV: %w", err)
+		}
+
+		if line == 1 && (row[0] == "short_id" || row[0] == "short_url") {
+			continue
+		}
+		if len(row) < 2 || len(row) > 3 {
+			return nil, fmt.Errorf("CSV line %d: want short ID, original URL and an optional user ID", line)
+		}
+
+		record := model.URLRecord{ShortURL: row[0], OriginalURL: row[1]}
+		if len(row) == 3 {
+			record.UserID = row[2]
+		}
+		records = append(records, record)
+	}
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MikhailRaia/url-shortener/internal/service"
+	"github.com/MikhailRaia/url-shortener/internal/storage/file"
+)
+
+func TestHandler_Import(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("ParseCIDR() error = %v", err)
+	}
+
+	urlStorage, err := file.NewStorage(filepath.Join(t.TempDir(), "urls.json"))
+	if err != nil {
+		t.Fatalf("file.NewStorage() error = %v", err)
+	}
+	defer urlStorage.Close()
+
+	urlService := service.NewURLService(urlStorage, "http://localhost:8080")
+	router := NewHandler(urlService, nil, WithTrustedSubnet(subnet), WithTrustProxyHeaders(true)).RegisterRoutes()
+
+	tests := []struct {
+		name          string
+		contentType   string
+		body          string
+		realIP        string
+		wantStatus    int
+		wantImported  int
+		wantConflicts []string
+		wantCode      string
+	}{
+		{
+			name:         "JSON",
+			contentType:  "application/json",
+			body:         `[{"short_url":"old-1","original_url":"https://a.example.com","user_id":"user1"},{"short_url":"old_2","original_url":"https://b.example.com"}]`,
+			realIP:       "10.1.2.3",
+			wantStatus:   http.StatusOK,
+			wantImported: 2,
+		},
+		{
+			name:          "CSV with header and conflict",
+			contentType:   "text/csv",
+			body:          "short_id,original_url\nold-1,https://other.example.com\nxyz,https://c.example.com\n",
+			realIP:        "10.1.2.3",
+			wantStatus:    http.StatusOK,
+			wantImported:  1,
+			wantConflicts: []string{"old-1"},
+		},
+		{
+			name:        "invalid short ID",
+			contentType: "text/csv",
+			body:        "a b,https://d.example.com\n",
+			realIP:      "10.1.2.3",
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    ErrCodeInvalidImportRecord,
+		},
+		{
+			name:        "unsupported content type",
+			contentType: "text/plain",
+			body:        "old-3 https://d.example.com",
+			realIP:      "10.1.2.3",
+			wantStatus:  http.StatusBadRequest,
+			wantCode:    ErrCodeInvalidContentType,
+		},
+		{
+			name:        "untrusted client",
+			contentType: "text/csv",
+			body:        "old-3,https://d.example.com\n",
+			realIP:      "192.168.1.1",
+			wantStatus:  http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/import", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.Header.Set("X-Real-IP", tt.realIP)
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status code %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if tt.wantCode != "" {
+				if got := decodeError(t, rr); got.Code != tt.wantCode {
+					t.Errorf("Expected error code %q, got %q", tt.wantCode, got.Code)
+				}
+				return
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var response ImportResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Imported != tt.wantImported {
+				t.Errorf("Expected %d imported, got %d", tt.wantImported, response.Imported)
+			}
+			if tt.wantConflicts == nil {
+				tt.wantConflicts = []string{}
+			}
+			if !reflect.DeepEqual(response.Conflicts, tt.wantConflicts) {
+				t.Errorf("Expected conflicts %v, got %v", tt.wantConflicts, response.Conflicts)
+			}
+		})
+	}
+
+	redirects := map[string]string{
+		"old-1": "https://a.example.com",
+		"old_2": "https://b.example.com",
+		"xyz":   "https://c.example.com",
+	}
+	for id, want := range redirects {
+		req := httptest.NewRequest(http.MethodGet, "/"+id, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusTemporaryRedirect {
+			t.Errorf("GET /%s: expected status code %d, got %d", id, http.StatusTemporaryRedirect, rr.Code)
+		}
+		if got := rr.Header().Get("Location"); got != want {
+			t.Errorf("GET /%s: expected Location %q, got %q", id, want, got)
+		}
+	}
+}
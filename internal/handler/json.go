@@ -3,21 +3,41 @@ package handler
 import (
 	"encoding/json"
 	"errors"
-	"io"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/service"
 	"github.com/MikhailRaia/url-shortener/internal/storage"
+	"github.com/rs/zerolog/log"
 )
 
 // ShortenRequest is the JSON payload for shortening a single URL.
+// Alias optionally requests a specific short ID when aliases are enabled.
+// Password optionally gates the short URL when passwords are enabled.
+// ExpiresIn (seconds) or ExpiresAt (RFC 3339) optionally make the short URL
+// expire when expiry is enabled.
+// ForwardQuery optionally makes redirects forward their query string to the
+// URL when query forwarding is enabled.
 type ShortenRequest struct {
-	URL string `json:"url"`
+	URL          string     `json:"url"`
+	Alias        string     `json:"alias,omitempty"`
+	Password     string     `json:"password,omitempty"`
+	ExpiresIn    *int64     `json:"expires_in,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	ForwardQuery bool       `json:"forward_query,omitempty"`
 }
 
 // ShortenResponse is the JSON response containing a shortened URL.
+// OriginalURL and CreatedAt are only set for ?verbose=true requests;
+// CreatedAt is omitted when the URL had already been shortened.
 type ShortenResponse struct {
-	Result string `json:"result"`
+	Result      string     `json:"result"`
+	OriginalURL string     `json:"original_url,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
 }
 
 // HandleShortenJSON handles POST /api/shorten requests with JSON payload.
@@ -26,30 +46,54 @@ func (h *Handler) HandleShortenJSON(w http.ResponseWriter, r *http.Request) {
 	contentEncoding := r.Header.Get("Content-Encoding")
 
 	if contentEncoding != "gzip" && !strings.Contains(contentType, "application/json") {
-		w.WriteHeader(http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidContentType, "Content-Type must be application/json")
 		return
 	}
 
-	body, err := io.ReadAll(r.Body)
+	body, err := readBody(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		writeBodyError(w, err)
 		return
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			panic(err)
-		}
-	}(r.Body)
 
 	var request ShortenRequest
 	if err := json.Unmarshal(body, &request); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidBody, "request body is not valid JSON")
 		return
 	}
 
 	if request.URL == "" {
-		w.WriteHeader(http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, ErrCodeMissingURL, "url is required")
+		return
+	}
+
+	if combinesOptions(r, request) {
+		writeCombinedOptionsError(w)
+		return
+	}
+
+	if request.ForwardQuery {
+		h.shortenWithForwardQuery(w, r, request, "")
+		return
+	}
+
+	if request.ExpiresIn != nil || request.ExpiresAt != nil {
+		h.shortenWithExpiry(w, r, request, "")
+		return
+	}
+
+	if request.Password != "" {
+		h.shortenWithPassword(w, r, request, "")
+		return
+	}
+
+	if request.Alias != "" {
+		h.shortenWithAlias(w, r, request, "")
+		return
+	}
+
+	if verboseRequested(r) {
+		h.shortenVerbose(w, r, request, "")
 		return
 	}
 
@@ -62,17 +106,18 @@ func (h *Handler) HandleShortenJSON(w http.ResponseWriter, r *http.Request) {
 
 			responseJSON, err := json.Marshal(response)
 			if err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
+				writeInternalError(w)
 				return
 			}
 
 			w.Header().Set("Content-Type", "application/json")
+			h.setConflictDeprecation(w)
 			w.WriteHeader(http.StatusConflict)
 			w.Write(responseJSON)
 			return
 		}
 
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
 		return
 	}
 
@@ -82,7 +127,131 @@ func (h *Handler) HandleShortenJSON(w http.ResponseWriter, r *http.Request) {
 
 	responseJSON, err := json.Marshal(response)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		writeInternalError(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(responseJSON)
+}
+
+// combinesOptions reports whether request sets more than one of alias,
+// password, expiry, forward_query and ?verbose, each of which is served by its
+// own shorten path.
+func combinesOptions(r *http.Request, request ShortenRequest) bool {
+	options := 0
+	for _, set := range []bool{
+		request.Alias != "",
+		request.Password != "",
+		request.ExpiresIn != nil || request.ExpiresAt != nil,
+		request.ForwardQuery,
+		verboseRequested(r),
+	} {
+		if set {
+			options++
+		}
+	}
+	return options > 1
+}
+
+// writeCombinedOptionsError rejects a request that combines shorten options.
+func writeCombinedOptionsError(w http.ResponseWriter) {
+	writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidRequest, "alias, password, expiry, forward_query and verbose cannot be combined")
+}
+
+// verboseRequested reports whether the ?verbose query parameter asks for the
+// stored URL and creation time in the response.
+func verboseRequested(r *http.Request) bool {
+	verbose, err := strconv.ParseBool(r.URL.Query().Get("verbose"))
+	return err == nil && verbose
+}
+
+// shortenVerbose shortens request.URL and writes a response that also echoes
+// the stored URL and creation time. For services that cannot describe short
+// URLs the requested URL is echoed and the creation time is omitted.
+func (h *Handler) shortenVerbose(w http.ResponseWriter, r *http.Request, request ShortenRequest, userID string) {
+	result := model.ShortenResult{OriginalURL: request.URL}
+	var err error
+	if detailedService, ok := h.urlService.(DetailedURLService); ok {
+		result, err = detailedService.ShortenURLDetailed(r.Context(), request.URL, userID)
+	} else if userID == "" {
+		result.ShortURL, err = h.urlService.ShortenURL(r.Context(), request.URL)
+	} else {
+		result.ShortURL, err = h.urlService.ShortenURLWithUser(r.Context(), request.URL, userID)
+	}
+
+	status := http.StatusCreated
+	if err != nil {
+		if errors.Is(err, service.ErrQuotaExceeded) {
+			writeQuotaExceeded(w)
+			return
+		}
+		if !errors.Is(err, storage.ErrURLExists) {
+			log.Error().Err(err).Msg("Failed to shorten URL")
+			writeInternalError(w)
+			return
+		}
+		status = http.StatusConflict
+		h.setConflictDeprecation(w)
+	}
+
+	response := ShortenResponse{Result: result.ShortURL, OriginalURL: result.OriginalURL}
+	if !result.CreatedAt.IsZero() {
+		createdAt := result.CreatedAt.UTC()
+		response.CreatedAt = &createdAt
+	}
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		writeInternalError(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(responseJSON)
+}
+
+// conflictDeprecationWarning is sent with JSON 409 duplicate-URL responses
+// when conflict deprecation is enabled.
+const conflictDeprecationWarning = `299 - "409 Conflict for already shortened URLs is deprecated; a future release will respond 200 OK"`
+
+// setConflictDeprecation marks a duplicate-URL conflict response as deprecated behavior.
+func (h *Handler) setConflictDeprecation(w http.ResponseWriter) {
+	if !h.conflictDeprecation {
+		return
+	}
+	w.Header().Set("Deprecation", "true")
+	w.Header().Set("Warning", conflictDeprecationWarning)
+}
+
+// shortenWithAlias stores request.URL under request.Alias and writes the JSON response.
+func (h *Handler) shortenWithAlias(w http.ResponseWriter, r *http.Request, request ShortenRequest, userID string) {
+	if !h.aliases {
+		writeJSONError(w, http.StatusBadRequest, ErrCodeFeatureDisabled, "aliases are not enabled")
+		return
+	}
+
+	shortenedURL, err := h.urlService.ShortenURLWithAlias(r.Context(), request.URL, request.Alias, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidAlias):
+			writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidAlias, fmt.Sprintf("alias must be %d to %d letters, digits, '-' or '_'", service.MinAliasLength, service.MaxAliasLength))
+		case errors.Is(err, storage.ErrAliasTaken), errors.Is(err, storage.ErrURLExists):
+			writeJSONError(w, http.StatusConflict, ErrCodeAliasTaken, "alias is already taken")
+		case errors.Is(err, service.ErrQuotaExceeded):
+			writeQuotaExceeded(w)
+		default:
+			log.Error().Err(err).Msg("Failed to shorten URL with alias")
+			writeInternalError(w)
+		}
+		return
+	}
+
+	responseJSON, err := json.Marshal(ShortenResponse{Result: shortenedURL})
+	if err != nil {
+		writeInternalError(w)
 		return
 	}
 
@@ -90,3 +259,143 @@ func (h *Handler) HandleShortenJSON(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 	w.Write(responseJSON)
 }
+
+// shortenWithPassword stores request.URL behind request.Password and writes the JSON response.
+func (h *Handler) shortenWithPassword(w http.ResponseWriter, r *http.Request, request ShortenRequest, userID string) {
+	passwordService, ok := h.urlService.(PasswordURLService)
+	if !h.passwords || !ok {
+		writeJSONError(w, http.StatusBadRequest, ErrCodeFeatureDisabled, "URL passwords are not enabled")
+		return
+	}
+
+	shortenedURL, err := passwordService.ShortenURLWithPassword(r.Context(), request.URL, request.Password, userID)
+	status := http.StatusCreated
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrURLExists):
+			status = http.StatusConflict
+			h.setConflictDeprecation(w)
+		case errors.Is(err, service.ErrInvalidPassword):
+			writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidPassword, fmt.Sprintf("password must be 1 to %d bytes", service.MaxPasswordLength))
+			return
+		case errors.Is(err, service.ErrPasswordsUnsupported):
+			writeJSONError(w, http.StatusBadRequest, ErrCodeFeatureDisabled, "storage does not support URL passwords")
+			return
+		case errors.Is(err, service.ErrQuotaExceeded):
+			writeQuotaExceeded(w)
+			return
+		default:
+			log.Error().Err(err).Msg("Failed to shorten URL with password")
+			writeInternalError(w)
+			return
+		}
+	}
+
+	responseJSON, err := json.Marshal(ShortenResponse{Result: shortenedURL})
+	if err != nil {
+		writeInternalError(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(responseJSON)
+}
+
+// shortenWithForwardQuery stores request.URL with query forwarding enabled and
+// writes the JSON response.
+func (h *Handler) shortenWithForwardQuery(w http.ResponseWriter, r *http.Request, request ShortenRequest, userID string) {
+	forwardService, ok := h.urlService.(ForwardQueryURLService)
+	if !h.forwardQuery || !ok {
+		writeJSONError(w, http.StatusBadRequest, ErrCodeFeatureDisabled, "query forwarding is not enabled")
+		return
+	}
+
+	shortenedURL, err := forwardService.ShortenURLWithForwardQuery(r.Context(), request.URL, userID)
+	status := http.StatusCreated
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrURLExists):
+			status = http.StatusConflict
+			h.setConflictDeprecation(w)
+		case errors.Is(err, service.ErrForwardQueryUnsupported):
+			writeJSONError(w, http.StatusBadRequest, ErrCodeFeatureDisabled, "storage does not support query forwarding")
+			return
+		case errors.Is(err, service.ErrQuotaExceeded):
+			writeQuotaExceeded(w)
+			return
+		default:
+			log.Error().Err(err).Msg("Failed to shorten URL with query forwarding")
+			writeInternalError(w)
+			return
+		}
+	}
+
+	responseJSON, err := json.Marshal(ShortenResponse{Result: shortenedURL})
+	if err != nil {
+		writeInternalError(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(responseJSON)
+}
+
+// shortenWithExpiry stores request.URL with an expiry and writes the JSON response.
+// Exactly one of expires_in and expires_at must be set.
+func (h *Handler) shortenWithExpiry(w http.ResponseWriter, r *http.Request, request ShortenRequest, userID string) {
+	expiringService, ok := h.urlService.(ExpiringURLService)
+	if !h.expiry || !ok {
+		writeJSONError(w, http.StatusBadRequest, ErrCodeFeatureDisabled, "URL expiry is not enabled")
+		return
+	}
+
+	var expiresAt time.Time
+	switch {
+	case request.ExpiresIn != nil && request.ExpiresAt != nil:
+		writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidExpiry, "set only one of expires_in and expires_at")
+		return
+	case request.ExpiresIn != nil:
+		if *request.ExpiresIn <= 0 {
+			writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidExpiry, "expires_in must be positive")
+			return
+		}
+		expiresAt = time.Now().Add(time.Duration(*request.ExpiresIn) * time.Second)
+	default:
+		expiresAt = *request.ExpiresAt
+	}
+
+	shortenedURL, err := expiringService.ShortenURLWithExpiry(r.Context(), request.URL, expiresAt, userID)
+	status := http.StatusCreated
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrURLExists):
+			status = http.StatusConflict
+			h.setConflictDeprecation(w)
+		case errors.Is(err, service.ErrInvalidExpiry):
+			writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidExpiry, "expiry must be in the future")
+			return
+		case errors.Is(err, service.ErrExpiryUnsupported):
+			writeJSONError(w, http.StatusBadRequest, ErrCodeFeatureDisabled, "storage does not support URL expiry")
+			return
+		case errors.Is(err, service.ErrQuotaExceeded):
+			writeQuotaExceeded(w)
+			return
+		default:
+			log.Error().Err(err).Msg("Failed to shorten URL with expiry")
+			writeInternalError(w)
+			return
+		}
+	}
+
+	responseJSON, err := json.Marshal(ShortenResponse{Result: shortenedURL})
+	if err != nil {
+		writeInternalError(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(responseJSON)
+}
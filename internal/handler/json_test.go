@@ -7,20 +7,30 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/MikhailRaia/url-shortener/internal/middleware"
 	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/service"
 	"github.com/MikhailRaia/url-shortener/internal/storage"
+	"github.com/MikhailRaia/url-shortener/internal/storage/memory"
+	"github.com/MikhailRaia/url-shortener/internal/storage/sqlite"
 )
 
 type MockURLService struct {
 	ShortenURLFunc                      func(ctx context.Context, originalURL string) (string, error)
 	ShortenURLWithUserFunc              func(ctx context.Context, originalURL, userID string) (string, error)
+	ShortenURLWithAliasFunc             func(ctx context.Context, originalURL, alias, userID string) (string, error)
 	GetOriginalURLFunc                  func(ctx context.Context, id string) (string, bool)
 	GetOriginalURLWithDeletedStatusFunc func(ctx context.Context, id string) (string, error)
 	ShortenBatchFunc                    func(ctx context.Context, items []model.BatchRequestItem) ([]model.BatchResponseItem, error)
 	ShortenBatchWithUserFunc            func(ctx context.Context, items []model.BatchRequestItem, userID string) ([]model.BatchResponseItem, error)
 	GetUserURLsFunc                     func(ctx context.Context, userID string) ([]model.UserURL, error)
+	GetUserURLsPagedFunc                func(ctx context.Context, userID string, order model.UserURLOrder, limit, offset int) ([]model.UserURL, int, error)
 	DeleteUserURLsFunc                  func(userID string, urlIDs []string) error
 }
 
@@ -39,6 +49,13 @@ func (m *MockURLService) ShortenURLWithUser(ctx context.Context, originalURL, us
 	return "", nil
 }
 
+func (m *MockURLService) ShortenURLWithAlias(ctx context.Context, originalURL, alias, userID string) (string, error) {
+	if m.ShortenURLWithAliasFunc != nil {
+		return m.ShortenURLWithAliasFunc(ctx, originalURL, alias, userID)
+	}
+	return "", nil
+}
+
 func (m *MockURLService) ShortenBatch(ctx context.Context, items []model.BatchRequestItem) ([]model.BatchResponseItem, error) {
 	if m.ShortenBatchFunc != nil {
 		return m.ShortenBatchFunc(ctx, items)
@@ -60,6 +77,17 @@ func (m *MockURLService) GetUserURLs(ctx context.Context, userID string) ([]mode
 	return []model.UserURL{}, nil
 }
 
+func (m *MockURLService) GetUserURLsPaged(ctx context.Context, userID string, order model.UserURLOrder, limit, offset int) ([]model.UserURL, int, error) {
+	if m.GetUserURLsPagedFunc != nil {
+		return m.GetUserURLsPagedFunc(ctx, userID, order, limit, offset)
+	}
+	return []model.UserURL{}, 0, nil
+}
+
+func (m *MockURLService) GetDeletedUserURLs(ctx context.Context, userID string) ([]string, error) {
+	return []string{}, nil
+}
+
 func (m *MockURLService) GetOriginalURLWithDeletedStatus(ctx context.Context, id string) (string, error) {
 	if m.GetOriginalURLWithDeletedStatusFunc != nil {
 		return m.GetOriginalURLWithDeletedStatusFunc(ctx, id)
@@ -240,3 +268,280 @@ func TestShortenResponseMarshal(t *testing.T) {
 		t.Errorf("Expected JSON '%s', got '%s'", expectedJSON, string(jsonBytes))
 	}
 }
+
+func TestHandleShortenJSON_Alias(t *testing.T) {
+	urlStorage := memory.NewStorage()
+	if err := urlStorage.SaveWithAlias("taken", "https://example.com", ""); err != nil {
+		t.Fatalf("Failed to seed alias: %v", err)
+	}
+
+	h := NewHandler(service.NewURLService(urlStorage, "http://localhost:8080"), nil, WithAliases(true))
+
+	tests := []struct {
+		name           string
+		request        ShortenRequest
+		expectedStatus int
+		expectedResult string
+	}{
+		{
+			name:           "Free alias",
+			request:        ShortenRequest{URL: "https://practicum.yandex.ru", Alias: "my-link"},
+			expectedStatus: http.StatusCreated,
+			expectedResult: "http://localhost:8080/my-link",
+		},
+		{
+			name:           "Taken alias",
+			request:        ShortenRequest{URL: "https://practicum.yandex.ru/other", Alias: "taken"},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "Invalid alias",
+			request:        ShortenRequest{URL: "https://practicum.yandex.ru/bad", Alias: "no/slash"},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(tt.request)
+			if err != nil {
+				t.Fatalf("Failed to marshal request body: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			h.HandleShortenJSON(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			if tt.expectedResult != "" {
+				var response ShortenResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+					t.Fatalf("Failed to unmarshal response: %v", err)
+				}
+				if response.Result != tt.expectedResult {
+					t.Errorf("Expected result %s, got %s", tt.expectedResult, response.Result)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleShortenJSON_Verbose(t *testing.T) {
+	urlStorage, err := sqlite.NewStorage(sqlite.DSNPrefix + filepath.Join(t.TempDir(), "urls.db"))
+	if err != nil {
+		t.Fatalf("sqlite.NewStorage() error = %v", err)
+	}
+	defer urlStorage.Close()
+
+	urlService := service.NewURLService(urlStorage, "http://localhost:8080",
+		service.WithNormalization(service.NormalizeOptions{StripFragment: true}))
+	h := NewHandler(urlService, nil)
+
+	tests := []struct {
+		name            string
+		query           string
+		url             string
+		userID          string
+		expectedStatus  int
+		expectedKeys    []string
+		expectedStored  string
+		expectCreatedAt bool
+	}{
+		{
+			name:           "Default shape",
+			url:            "https://practicum.yandex.ru/default",
+			expectedStatus: http.StatusCreated,
+			expectedKeys:   []string{"result"},
+		},
+		{
+			name:           "Verbose false",
+			query:          "?verbose=false",
+			url:            "https://practicum.yandex.ru/not-verbose",
+			expectedStatus: http.StatusCreated,
+			expectedKeys:   []string{"result"},
+		},
+		{
+			name:            "Verbose",
+			query:           "?verbose=true",
+			url:             "https://PRACTICUM.yandex.ru/verbose#section",
+			expectedStatus:  http.StatusCreated,
+			expectedKeys:    []string{"created_at", "original_url", "result"},
+			expectedStored:  "https://practicum.yandex.ru/verbose",
+			expectCreatedAt: true,
+		},
+		{
+			name:           "Verbose duplicate",
+			query:          "?verbose=1",
+			url:            "https://practicum.yandex.ru/verbose",
+			expectedStatus: http.StatusConflict,
+			expectedKeys:   []string{"original_url", "result"},
+			expectedStored: "https://practicum.yandex.ru/verbose",
+		},
+		{
+			name:            "Verbose with user",
+			query:           "?verbose=true",
+			url:             "https://practicum.yandex.ru/user",
+			userID:          "user1",
+			expectedStatus:  http.StatusCreated,
+			expectedKeys:    []string{"created_at", "original_url", "result"},
+			expectedStored:  "https://practicum.yandex.ru/user",
+			expectCreatedAt: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(ShortenRequest{URL: tt.url})
+			if err != nil {
+				t.Fatalf("Failed to marshal request body: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten"+tt.query, bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			before := time.Now().Add(-time.Second)
+			if tt.userID != "" {
+				req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, tt.userID))
+				h.HandleShortenJSONWithAuth(w, req)
+			} else {
+				h.HandleShortenJSON(w, req)
+			}
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d", tt.expectedStatus, w.Code)
+			}
+
+			var raw map[string]json.RawMessage
+			if err := json.Unmarshal(w.Body.Bytes(), &raw); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			keys := make([]string, 0, len(raw))
+			for key := range raw {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			if strings.Join(keys, ",") != strings.Join(tt.expectedKeys, ",") {
+				t.Errorf("Expected response keys %v, got %v", tt.expectedKeys, keys)
+			}
+
+			var response ShortenResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if !strings.HasPrefix(response.Result, "http://localhost:8080/") {
+				t.Errorf("Expected a short URL, got %s", response.Result)
+			}
+			if response.OriginalURL != tt.expectedStored {
+				t.Errorf("Expected original_url %q, got %q", tt.expectedStored, response.OriginalURL)
+			}
+			if tt.expectCreatedAt && (response.CreatedAt == nil || response.CreatedAt.Before(before)) {
+				t.Errorf("Expected a recent created_at, got %v", response.CreatedAt)
+			}
+		})
+	}
+}
+
+func TestHandleShortenJSON_CombinedOptions(t *testing.T) {
+	expiresIn := int64(3600)
+
+	tests := []struct {
+		name    string
+		query   string
+		request ShortenRequest
+	}{
+		{name: "forward query and expiry", request: ShortenRequest{URL: "https://a.example.com", ForwardQuery: true, ExpiresIn: &expiresIn}},
+		{name: "password and alias", request: ShortenRequest{URL: "https://b.example.com", Password: "secret", Alias: "b-link"}},
+		{name: "alias and verbose", query: "?verbose=true", request: ShortenRequest{URL: "https://c.example.com", Alias: "c-link"}},
+		{name: "expiry and verbose", query: "?verbose=true", request: ShortenRequest{URL: "https://d.example.com", ExpiresIn: &expiresIn}},
+	}
+
+	for _, tt := range tests {
+		for _, auth := range []bool{false, true} {
+			urlStorage := memory.NewStorage()
+			h := NewHandler(service.NewURLService(urlStorage, "http://localhost:8080"), nil,
+				WithAliases(true), WithPasswords(true), WithExpiry(true), WithQueryForwarding(true))
+
+			body, _ := json.Marshal(tt.request)
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten"+tt.query, bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			if auth {
+				req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, "user1"))
+				h.HandleShortenJSONWithAuth(w, req)
+			} else {
+				h.HandleShortenJSON(w, req)
+			}
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%s (auth %v): expected status code %d, got %d", tt.name, auth, http.StatusBadRequest, w.Code)
+				continue
+			}
+			if got := decodeError(t, w); got.Code != ErrCodeInvalidRequest {
+				t.Errorf("%s (auth %v): expected error code %q, got %q", tt.name, auth, ErrCodeInvalidRequest, got.Code)
+			}
+			if count, _ := urlStorage.Count(); count != 0 {
+				t.Errorf("%s (auth %v): expected no stored URLs, got %d", tt.name, auth, count)
+			}
+		}
+	}
+}
+
+func TestHandleShortenJSON_AliasDisabled(t *testing.T) {
+	h := NewHandler(service.NewURLService(memory.NewStorage(), "http://localhost:8080"), nil)
+
+	body, _ := json.Marshal(ShortenRequest{URL: "https://practicum.yandex.ru", Alias: "my-link"})
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	h.HandleShortenJSON(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestHandleShortenJSON_ConflictDeprecation(t *testing.T) {
+	mockService := &MockURLService{
+		ShortenURLFunc: func(ctx context.Context, originalURL string) (string, error) {
+			return "http://localhost:8080/existing123", storage.ErrURLExists
+		},
+	}
+
+	tests := []struct {
+		name    string
+		enabled bool
+	}{
+		{"Enabled", true},
+		{"Disabled", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler(mockService, nil, WithConflictDeprecation(tt.enabled))
+
+			body, _ := json.Marshal(ShortenRequest{URL: "https://practicum.yandex.ru"})
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			h.HandleShortenJSON(w, req)
+
+			if w.Code != http.StatusConflict {
+				t.Fatalf("Expected status code %d, got %d", http.StatusConflict, w.Code)
+			}
+
+			gotDeprecation := w.Header().Get("Deprecation") != "" && w.Header().Get("Warning") != ""
+			if gotDeprecation != tt.enabled {
+				t.Errorf("Deprecation headers present = %v, want %v", gotDeprecation, tt.enabled)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+)
+
+// HealthResponse is the JSON response of GET /health.
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+// Health statuses reported by GET /health.
+const (
+	HealthStatusOK          = "ok"
+	HealthStatusMaintenance = "maintenance"
+	HealthStatusLoading     = "loading"
+)
+
+// ReadyChecker reports whether a dependency, such as storage loading in the
+// background, is ready to serve traffic.
+type ReadyChecker interface {
+	Ready() bool
+}
+
+// MaintenanceRequest is the JSON body of POST /api/admin/maintenance.
+type MaintenanceRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// MaintenanceResponse reports whether maintenance mode is on.
+type MaintenanceResponse struct {
+	Maintenance bool `json:"maintenance"`
+}
+
+// WithMaintenanceRejectShortens makes shorten endpoints answer 503 while
+// maintenance mode is on. Redirects and user endpoints keep working either way.
+func WithMaintenanceRejectShortens(enabled bool) Option {
+	return func(h *Handler) {
+		h.maintenanceRejectShortens = enabled
+	}
+}
+
+// WithReadyChecker makes GET /health answer 503 until checker is ready.
+func WithReadyChecker(checker ReadyChecker) Option {
+	return func(h *Handler) {
+		h.readyChecker = checker
+	}
+}
+
+// handleHealth reports whether the instance should receive traffic, for load
+// balancer health checks. It answers 503 in maintenance mode so the instance is
+// drained, and while storage is still loading. It does not check the
+// database; use /ping for that.
+func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
+	status, response := http.StatusOK, HealthResponse{Status: HealthStatusOK}
+	switch {
+	case h.maintenance.Load():
+		status, response = http.StatusServiceUnavailable, HealthResponse{Status: HealthStatusMaintenance}
+	case h.readyChecker != nil && !h.readyChecker.Ready():
+		status, response = http.StatusServiceUnavailable, HealthResponse{Status: HealthStatusLoading}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Error().Err(err).Msg("Failed to encode health response")
+	}
+}
+
+// handleMaintenance switches maintenance mode on or off for
+// POST /api/admin/maintenance and answers with the new state.
+func (h *Handler) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody(r)
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+
+	var request MaintenanceRequest
+	if err := json.Unmarshal(body, &request); err != nil || request.Enabled == nil {
+		writeJSONError(w, http.StatusBadRequest, ErrCodeInvalidBody, `request body must be {"enabled": true|false}`)
+		return
+	}
+
+	if previous := h.maintenance.Swap(*request.Enabled); previous != *request.Enabled {
+		log.Warn().Bool("maintenance", *request.Enabled).Str("remote", r.RemoteAddr).Msg("Maintenance mode changed via admin endpoint")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(MaintenanceResponse{Maintenance: *request.Enabled}); err != nil {
+		log.Error().Err(err).Msg("Failed to encode maintenance response")
+	}
+}
+
+// rejectInMaintenance answers 503 instead of calling next while maintenance
+// mode is on, if shortens are to be rejected then.
+func (h *Handler) rejectInMaintenance(next http.Handler) http.Handler {
+	if !h.maintenanceRejectShortens {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.maintenance.Load() {
+			writeJSONError(w, http.StatusServiceUnavailable, ErrCodeMaintenance, "the instance is in maintenance mode")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/MikhailRaia/url-shortener/internal/service"
+	"github.com/MikhailRaia/url-shortener/internal/storage/memory"
+)
+
+func TestHandler_Maintenance(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("ParseCIDR() error = %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		rejectShorten bool
+		wantShorten   int
+	}{
+		{name: "shortens keep working", rejectShorten: false, wantShorten: http.StatusCreated},
+		{name: "shortens rejected", rejectShorten: true, wantShorten: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlService := service.NewURLService(memory.NewStorage(), "http://localhost:8080")
+			router := NewHandler(urlService, nil,
+				WithTrustedSubnet(subnet),
+				WithTrustProxyHeaders(true),
+				WithMaintenanceRejectShortens(tt.rejectShorten),
+			).RegisterRoutes()
+
+			do := func(method, target, body, realIP string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(method, target, strings.NewReader(body))
+				if realIP != "" {
+					req.Header.Set("X-Real-IP", realIP)
+				}
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+				return rec
+			}
+			health := func() (int, string) {
+				rec := do(http.MethodGet, "/health", "", "")
+				var response HealthResponse
+				if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+					t.Fatalf("Failed to unmarshal health response: %v", err)
+				}
+				return rec.Code, response.Status
+			}
+
+			created := do(http.MethodPost, "/", "https://example.com/before", "")
+			if created.Code != http.StatusCreated {
+				t.Fatalf("Expected status code %d, got %d", http.StatusCreated, created.Code)
+			}
+			if code, status := health(); code != http.StatusOK || status != HealthStatusOK {
+				t.Errorf("Expected healthy instance, got %d %q", code, status)
+			}
+
+			if rec := do(http.MethodPost, "/api/admin/maintenance", `{"enabled":true}`, "192.168.1.1"); rec.Code != http.StatusForbidden {
+				t.Errorf("Expected untrusted client to get %d, got %d", http.StatusForbidden, rec.Code)
+			}
+			if code, _ := health(); code != http.StatusOK {
+				t.Errorf("Expected untrusted client not to change maintenance mode, got %d", code)
+			}
+
+			rec := do(http.MethodPost, "/api/admin/maintenance", `{"enabled":true}`, "10.1.2.3")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected status code %d, got %d", http.StatusOK, rec.Code)
+			}
+			var response MaintenanceResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil || !response.Maintenance {
+				t.Errorf("Expected maintenance on, got %s", rec.Body.String())
+			}
+			if code, status := health(); code != http.StatusServiceUnavailable || status != HealthStatusMaintenance {
+				t.Errorf("Expected instance in maintenance, got %d %q", code, status)
+			}
+
+			shortID := strings.TrimPrefix(created.Body.String(), "http://localhost:8080/")
+			if rec := do(http.MethodGet, "/"+shortID, "", ""); rec.Code != http.StatusTemporaryRedirect {
+				t.Errorf("Expected redirects to keep working, got %d", rec.Code)
+			}
+			if rec := do(http.MethodPost, "/", "https://example.com/during", ""); rec.Code != tt.wantShorten {
+				t.Errorf("Expected shorten status %d, got %d", tt.wantShorten, rec.Code)
+			}
+
+			if rec := do(http.MethodPost, "/api/admin/maintenance", `{"enabled":false}`, "10.1.2.3"); rec.Code != http.StatusOK {
+				t.Fatalf("Expected status code %d, got %d", http.StatusOK, rec.Code)
+			}
+			if code, _ := health(); code != http.StatusOK {
+				t.Errorf("Expected healthy instance after maintenance, got %d", code)
+			}
+		})
+	}
+}
+
+func TestHandler_MaintenanceInvalidBody(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("10.0.0.0/8")
+	router := NewHandler(&mockURLService{}, nil, WithTrustedSubnet(subnet), WithTrustProxyHeaders(true)).RegisterRoutes()
+
+	for _, body := range []string{"", "{}", `{"enabled":"yes"}`} {
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/maintenance", strings.NewReader(body))
+		req.Header.Set("X-Real-IP", "10.1.2.3")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("body %q: expected status code %d, got %d", body, http.StatusBadRequest, rec.Code)
+		}
+	}
+}
+
+type fakeReadyChecker struct {
+	ready atomic.Bool
+}
+
+func (c *fakeReadyChecker) Ready() bool {
+	return c.ready.Load()
+}
+
+func TestHandler_HealthStorageLoading(t *testing.T) {
+	checker := &fakeReadyChecker{}
+	urlService := service.NewURLService(memory.NewStorage(), "http://localhost:8080")
+	router := NewHandler(urlService, nil, WithReadyChecker(checker)).RegisterRoutes()
+
+	tests := []struct {
+		name       string
+		ready      bool
+		wantCode   int
+		wantStatus string
+	}{
+		{name: "loading", ready: false, wantCode: http.StatusServiceUnavailable, wantStatus: HealthStatusLoading},
+		{name: "loaded", ready: true, wantCode: http.StatusOK, wantStatus: HealthStatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker.ready.Store(tt.ready)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+			var response HealthResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to unmarshal health response: %v", err)
+			}
+			if rec.Code != tt.wantCode || response.Status != tt.wantStatus {
+				t.Errorf("Expected %d %q, got %d %q", tt.wantCode, tt.wantStatus, rec.Code, response.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// prefersJSON reports whether the Accept header ranks application/json above
+// text/plain. Ties, a missing header and wildcards favor text/plain.
+func prefersJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return false
+	}
+
+	return acceptQuality(accept, "application/json") > acceptQuality(accept, "text/plain")
+}
+
+// acceptQuality returns the q-value the Accept header gives mediaType, using
+// the most specific matching range.
+func acceptQuality(accept, mediaType string) float64 {
+	typ, _, _ := strings.Cut(mediaType, "/")
+
+	quality, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		var s int
+		switch mediaRange {
+		case mediaType:
+			s = 2
+		case typ + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		default:
+			continue
+		}
+		if s <= specificity {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		quality, specificity = q, s
+	}
+
+	return quality
+}
+
+// writeShortenResult writes the short URL as text/plain, or as a JSON
+// ShortenResponse when the client prefers JSON.
+func writeShortenResult(w http.ResponseWriter, r *http.Request, status int, shortenedURL string) {
+	var body []byte
+	if prefersJSON(r) {
+		response, err := json.Marshal(ShortenResponse{Result: shortenedURL})
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		body = response
+	} else {
+		w.Header().Set("Content-Type", "text/plain")
+		body = []byte(shortenedURL)
+	}
+
+	w.WriteHeader(status)
+	if _, err := w.Write(body); err != nil {
+		log.Debug().Err(err).Msg("Failed to write shorten response")
+	}
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MikhailRaia/url-shortener/internal/middleware"
+)
+
+func TestHandler_ShortenContentNegotiation(t *testing.T) {
+	const shortURL = "http://localhost:8080/abc123"
+
+	tests := []struct {
+		name            string
+		accept          string
+		wantContentType string
+	}{
+		{name: "no Accept header", accept: "", wantContentType: "text/plain"},
+		{name: "JSON", accept: "application/json", wantContentType: "application/json"},
+		{name: "plain text", accept: "text/plain", wantContentType: "text/plain"},
+		{name: "any type", accept: "*/*", wantContentType: "text/plain"},
+		{name: "JSON preferred by quality", accept: "text/plain;q=0.5, application/json", wantContentType: "application/json"},
+		{name: "plain text preferred by quality", accept: "application/json;q=0.2, text/*", wantContentType: "text/plain"},
+		{name: "JSON over wildcard", accept: "application/json, */*;q=0.1", wantContentType: "application/json"},
+	}
+
+	mockService := &mockURLService{
+		shortenURLFunc: func(ctx context.Context, originalURL string) (string, error) {
+			return shortURL, nil
+		},
+		shortenURLWithUserFunc: func(ctx context.Context, originalURL, userID string) (string, error) {
+			return shortURL, nil
+		},
+	}
+	handler := NewHandler(mockService, nil)
+
+	endpoints := []struct {
+		name    string
+		serve   http.HandlerFunc
+		context context.Context
+	}{
+		{name: "anonymous", serve: handler.handleShorten, context: context.Background()},
+		{name: "authenticated", serve: handler.handleShortenWithAuth, context: context.WithValue(context.Background(), middleware.UserIDKey, "user1")},
+	}
+
+	for _, endpoint := range endpoints {
+		for _, tt := range tests {
+			t.Run(endpoint.name+"/"+tt.name, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://example.com")).WithContext(endpoint.context)
+				req.Header.Set("Content-Type", "text/plain")
+				if tt.accept != "" {
+					req.Header.Set("Accept", tt.accept)
+				}
+				rr := httptest.NewRecorder()
+
+				endpoint.serve(rr, req)
+
+				if rr.Code != http.StatusCreated {
+					t.Errorf("Expected status code %d, got %d", http.StatusCreated, rr.Code)
+				}
+				if got := rr.Header().Get("Content-Type"); got != tt.wantContentType {
+					t.Errorf("Expected Content-Type %q, got %q", tt.wantContentType, got)
+				}
+
+				if tt.wantContentType == "application/json" {
+					var response ShortenResponse
+					if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+						t.Fatalf("Failed to unmarshal response: %v", err)
+					}
+					if response.Result != shortURL {
+						t.Errorf("Expected result %q, got %q", shortURL, response.Result)
+					}
+				} else if rr.Body.String() != shortURL {
+					t.Errorf("Expected body %q, got %q", shortURL, rr.Body.String())
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package handler
+
+import _ "embed"
+
+// openAPISpec is the hand-maintained OpenAPI 3 description served at GET /openapi.json.
+// Keep it in step with the routes registered in RegisterRoutes and RegisterRoutesWithAuth.
+//
+//go:embed openapi.json
+var openAPISpec []byte
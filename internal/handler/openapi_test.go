@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MikhailRaia/url-shortener/internal/auth"
+	"github.com/MikhailRaia/url-shortener/internal/middleware"
+	"github.com/MikhailRaia/url-shortener/internal/service"
+	"github.com/MikhailRaia/url-shortener/internal/storage/memory"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestHandler_OpenAPI(t *testing.T) {
+	urlService := service.NewURLService(memory.NewStorage(), "http://localhost:8080")
+	router := NewHandler(urlService, nil).RegisterRoutesWithAuth(middleware.NewAuthMiddleware(auth.NewJWTService("test-secret")))
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if contentType := rec.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "application/json") {
+		t.Errorf("Expected Content-Type application/json, got %q", contentType)
+	}
+
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("Expected valid JSON, got error: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Errorf("Expected an OpenAPI 3 document, got version %q", spec.OpenAPI)
+	}
+
+	knownPaths := map[string][]string{
+		"/":                      {"post"},
+		"/api/shorten":           {"post"},
+		"/api/shorten/batch":     {"post"},
+		"/api/expand/batch":      {"post"},
+		"/{id}":                  {"get", "delete"},
+		"/api/user/urls":         {"get", "delete"},
+		"/api/user/urls/deleted": {"get"},
+	}
+	for path, methods := range knownPaths {
+		operations, ok := spec.Paths[path]
+		if !ok {
+			t.Errorf("Expected path %s in the document", path)
+			continue
+		}
+		for _, method := range methods {
+			if _, ok := operations[method]; !ok {
+				t.Errorf("Expected %s %s in the document", strings.ToUpper(method), path)
+			}
+		}
+	}
+
+	// Every documented operation must be served by the router.
+	registered := make(map[string]bool)
+	err := chi.Walk(router.(chi.Routes), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		registered[strings.ToLower(method)+" "+route] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to walk routes: %v", err)
+	}
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			if !registered[method+" "+path] {
+				t.Errorf("Documented operation %s %s is not registered", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
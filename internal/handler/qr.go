@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/MikhailRaia/url-shortener/internal/baseurl"
+	"github.com/MikhailRaia/url-shortener/internal/generator"
+	"github.com/MikhailRaia/url-shortener/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// QR code image size bounds in pixels.
+const (
+	DefaultQRSize = 256
+	MinQRSize     = 64
+	MaxQRSize     = 1024
+)
+
+// ShortURLResolver is implemented by services that can build the absolute short URL for an ID.
+type ShortURLResolver interface {
+	ShortURL(ctx context.Context, id string) string
+}
+
+// shortURLFor returns the absolute short URL for id, falling back to the
+// request's own scheme and host when the service cannot build it.
+func (h *Handler) shortURLFor(r *http.Request, id string) string {
+	if resolver, ok := h.urlService.(ShortURLResolver); ok {
+		return resolver.ShortURL(r.Context(), id)
+	}
+
+	return baseurl.FromRequest(r, h.trustProxyHeaders) + "/" + id
+}
+
+// handleQRCode serves a QR code encoding the absolute short URL for an ID.
+// The image format is chosen with ?format=png|svg and its size with ?size=.
+func (h *Handler) handleQRCode(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.verifiedID(chi.URLParam(r, "id"))
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if h.validateIDs && !generator.ValidID(id) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	size := DefaultQRSize
+	if s := r.URL.Query().Get("size"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < MinQRSize || n > MaxQRSize {
+			http.Error(w, fmt.Sprintf("size must be between %d and %d", MinQRSize, MaxQRSize), http.StatusBadRequest)
+			return
+		}
+		size = n
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "png"
+	}
+	if format != "png" && format != "svg" {
+		http.Error(w, "format must be png or svg", http.StatusBadRequest)
+		return
+	}
+
+	originalURL, err := h.urlService.GetOriginalURLWithDeletedStatus(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, storage.ErrURLDeleted) || errors.Is(err, storage.ErrURLExpired) {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		log.Error().Err(err).Msg("Failed to get original URL")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if originalURL == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	code, err := qrcode.New(h.shortURLFor(r, id), qrcode.Medium)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to encode QR code")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if format == "svg" {
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(qrSVG(code.Bitmap(), size)))
+		return
+	}
+
+	png, err := code.PNG(size)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to render QR code")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.WriteHeader(http.StatusOK)
+	w.Write(png)
+}
+
+// qrSVG renders a QR bitmap, quiet zone included, as an SVG of size by size pixels.
+func qrSVG(bitmap [][]bool, size int) string {
+	var path strings.Builder
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+
+	modules := len(bitmap)
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+
+		`<rect width="100%%" height="100%%" fill="#fff"/><path fill="#000" d="%s"/></svg>`,
+		size, size, modules, modules, path.String())
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MikhailRaia/url-shortener/internal/service"
+	"github.com/MikhailRaia/url-shortener/internal/storage/memory"
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+func TestHandler_handleQRCode(t *testing.T) {
+	urlStorage := memory.NewStorage()
+	urlService := service.NewURLService(urlStorage, "http://short.example")
+
+	id, err := urlStorage.SaveWithUser("https://example.com/long", "user1")
+	if err != nil {
+		t.Fatalf("SaveWithUser() error = %v", err)
+	}
+	deletedID, _ := urlStorage.SaveWithUser("https://example.com/gone", "user1")
+	if err := urlStorage.DeleteUserURLs("user1", []string{deletedID}); err != nil {
+		t.Fatalf("DeleteUserURLs() error = %v", err)
+	}
+
+	router := NewHandler(urlService, nil).RegisterRoutes()
+
+	tests := []struct {
+		name            string
+		path            string
+		wantStatus      int
+		wantContentType string
+	}{
+		{name: "png by default", path: "/" + id + "/qr", wantStatus: http.StatusOK, wantContentType: "image/png"},
+		{name: "svg", path: "/" + id + "/qr?format=svg", wantStatus: http.StatusOK, wantContentType: "image/svg+xml"},
+		{name: "custom size", path: "/" + id + "/qr?size=128", wantStatus: http.StatusOK, wantContentType: "image/png"},
+		{name: "size out of range", path: "/" + id + "/qr?size=5000", wantStatus: http.StatusBadRequest},
+		{name: "unknown format", path: "/" + id + "/qr?format=gif", wantStatus: http.StatusBadRequest},
+		{name: "unknown ID", path: "/missing/qr", wantStatus: http.StatusNotFound},
+		{name: "deleted ID", path: "/" + deletedID + "/qr", wantStatus: http.StatusGone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status code %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantContentType != "" && rec.Header().Get("Content-Type") != tt.wantContentType {
+				t.Errorf("Expected Content-Type %s, got %s", tt.wantContentType, rec.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestHandler_handleQRCode_Decodes(t *testing.T) {
+	urlStorage := memory.NewStorage()
+	urlService := service.NewURLService(urlStorage, "http://short.example")
+
+	id, _ := urlStorage.Save("https://example.com/long")
+
+	router := NewHandler(urlService, nil).RegisterRoutes()
+
+	req := httptest.NewRequest(http.MethodGet, "/"+id+"/qr?size=300", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	img, err := png.Decode(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("Failed to decode PNG: %v", err)
+	}
+	if img.Bounds().Dx() != 300 {
+		t.Errorf("Expected image width 300, got %d", img.Bounds().Dx())
+	}
+
+	bitmap, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		t.Fatalf("Failed to binarize image: %v", err)
+	}
+	result, err := qrcode.NewQRCodeReader().Decode(bitmap, nil)
+	if err != nil {
+		t.Fatalf("Failed to decode QR code: %v", err)
+	}
+
+	want := "http://short.example/" + id
+	if result.GetText() != want {
+		t.Errorf("Expected QR code to encode %s, got %s", want, result.GetText())
+	}
+}
+
+func TestQRSVG(t *testing.T) {
+	svg := qrSVG([][]bool{{true, false}, {false, true}}, 100)
+
+	if !strings.Contains(svg, `width="100"`) || !strings.Contains(svg, `viewBox="0 0 2 2"`) {
+		t.Errorf("Unexpected SVG dimensions: %s", svg)
+	}
+	if !strings.Contains(svg, "M0 0h1v1h-1z") || !strings.Contains(svg, "M1 1h1v1h-1z") {
+		t.Errorf("Expected dark modules in SVG path: %s", svg)
+	}
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MikhailRaia/url-shortener/internal/auth"
+	"github.com/MikhailRaia/url-shortener/internal/middleware"
+	"github.com/MikhailRaia/url-shortener/internal/service"
+	"github.com/MikhailRaia/url-shortener/internal/storage/memory"
+)
+
+func TestHandler_RateLimits(t *testing.T) {
+	urlService := service.NewURLService(memory.NewStorage(), "http://localhost:8080")
+	h := NewHandler(urlService, nil, WithRateLimits(
+		middleware.RateLimit{Rate: 0.001, Burst: 2},
+		middleware.RateLimit{Rate: 0.001, Burst: 5},
+	))
+	router := h.RegisterRoutesWithAuth(middleware.NewAuthMiddleware(auth.NewJWTService("test-secret")))
+
+	shortURL := ""
+	for i, wantStatus := range []int{http.StatusCreated, http.StatusCreated, http.StatusTooManyRequests} {
+		// No cookie: every request mints a new user, which must not reset the limit.
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://example.com/`+string(rune('a'+i))+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = "192.0.2.1:1234"
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != wantStatus {
+			t.Fatalf("Shorten request %d: expected status code %d, got %d", i+1, wantStatus, rec.Code)
+		}
+		if rec.Code == http.StatusCreated && shortURL == "" {
+			var response ShortenResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			shortURL = strings.TrimPrefix(response.Result, "http://localhost:8080")
+		}
+		if rec.Code == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+			t.Error("Expected a Retry-After header on 429")
+		}
+	}
+
+	// Redirects have their own, looser bucket.
+	for i := 0; i < 6; i++ {
+		req := httptest.NewRequest(http.MethodGet, shortURL, nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		wantStatus := http.StatusTemporaryRedirect
+		if i == 5 {
+			wantStatus = http.StatusTooManyRequests
+		}
+		if rec.Code != wantStatus {
+			t.Fatalf("Redirect %d: expected status code %d, got %d", i+1, wantStatus, rec.Code)
+		}
+	}
+
+	// Other clients are not affected.
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://example.org"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = "192.0.2.2:1234"
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Errorf("Expected status code %d for another client, got %d", http.StatusCreated, rec.Code)
+	}
+}
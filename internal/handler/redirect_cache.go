@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// redirectETag derives a strong ETag from the short ID, the target URL and
+// the response variant, so it changes whenever the redirect would.
+func (h *Handler) redirectETag(r *http.Request, id, originalURL string) string {
+	sum := sha256.New()
+	sum.Write([]byte(id))
+	sum.Write([]byte{0})
+	sum.Write([]byte(originalURL))
+	if h.wantsHTML(r) {
+		sum.Write([]byte{0, 'h'})
+	}
+	return `"` + hex.EncodeToString(sum.Sum(nil)[:12]) + `"`
+}
+
+// redirectCacheControl returns the Cache-Control value for a successful
+// redirect of a URL expiring at expiresAt, zero meaning never, or "" when
+// redirects carry no caching hints. max-age never outlasts the URL.
+func (h *Handler) redirectCacheControl(expiresAt time.Time) string {
+	scope := "public"
+	if h.passwords {
+		scope = "private"
+	}
+
+	switch {
+	case h.redirectMaxAge > 0:
+		maxAge := h.redirectMaxAge
+		if !expiresAt.IsZero() {
+			maxAge = min(maxAge, max(time.Until(expiresAt), 0))
+		}
+		return scope + ", max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+	case h.redirectETags:
+		return scope + ", no-cache"
+	default:
+		return ""
+	}
+}
+
+// redirectExpiry returns when the short URL id expires, or the zero time if it
+// never does or redirects are not cached for a max-age. URLs whose expiry
+// cannot be read are treated as expiring now, so they are not cached.
+func (h *Handler) redirectExpiry(r *http.Request, id string) time.Time {
+	if h.redirectMaxAge <= 0 {
+		return time.Time{}
+	}
+
+	expiryService, ok := h.urlService.(URLExpiryProvider)
+	if !ok {
+		return time.Time{}
+	}
+
+	expiresAt, err := expiryService.URLExpiry(r.Context(), id)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get URL expiry")
+		return time.Now()
+	}
+
+	return expiresAt
+}
+
+// redirectCacheable reports whether redirect responses carry caching headers,
+// in which case 410 responses are marked uncacheable.
+func (h *Handler) redirectCacheable() bool {
+	return h.redirectETags || h.redirectMaxAge > 0
+}
+
+// writeNotModified sets the caching headers of a redirect and answers 304 if
+// the client's If-None-Match matches its ETag. It reports whether the
+// response was written.
+func (h *Handler) writeNotModified(w http.ResponseWriter, r *http.Request, id, originalURL string) bool {
+	if cacheControl := h.redirectCacheControl(h.redirectExpiry(r, id)); cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+	if !h.redirectETags {
+		return false
+	}
+
+	etag := h.redirectETag(r, id, originalURL)
+	w.Header().Set("ETag", etag)
+	if h.htmlRedirects {
+		w.Header().Add("Vary", "Accept")
+	}
+
+	if !etagMatches(r.Header.Get("If-None-Match"), etag) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, using the
+// weak comparison RFC 9110 prescribes for If-None-Match.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
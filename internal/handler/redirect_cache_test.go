@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MikhailRaia/url-shortener/internal/storage"
+)
+
+func TestHandler_RedirectETags(t *testing.T) {
+	deleted := false
+	target := "https://example.com/page"
+	mockService := &mockURLService{
+		getOriginalURLWithDeletedStatusFunc: func(ctx context.Context, id string) (string, error) {
+			if deleted {
+				return "", storage.ErrURLDeleted
+			}
+			return target, nil
+		},
+	}
+	router := NewHandler(mockService, nil, WithRedirectETags(true), WithRedirectCacheMaxAge(time.Hour)).RegisterRoutes()
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := get("")
+	if first.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("Expected status code %d, got %d", http.StatusTemporaryRedirect, first.Code)
+	}
+	etag := first.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected an ETag on the redirect")
+	}
+	if cc := first.Header().Get("Cache-Control"); cc != "public, max-age=3600" {
+		t.Errorf("Expected Cache-Control %q, got %q", "public, max-age=3600", cc)
+	}
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		wantStatus  int
+	}{
+		{name: "matching ETag", ifNoneMatch: etag, wantStatus: http.StatusNotModified},
+		{name: "weak matching ETag", ifNoneMatch: "W/" + etag, wantStatus: http.StatusNotModified},
+		{name: "ETag in list", ifNoneMatch: `"other", ` + etag, wantStatus: http.StatusNotModified},
+		{name: "wildcard", ifNoneMatch: "*", wantStatus: http.StatusNotModified},
+		{name: "stale ETag", ifNoneMatch: `"other"`, wantStatus: http.StatusTemporaryRedirect},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := get(tt.ifNoneMatch)
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected status code %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if rr.Header().Get("ETag") != etag {
+				t.Errorf("Expected ETag %s, got %s", etag, rr.Header().Get("ETag"))
+			}
+			if rr.Header().Get("Cache-Control") == "" {
+				t.Error("Expected Cache-Control to be set")
+			}
+			if tt.wantStatus == http.StatusNotModified && rr.Body.Len() != 0 {
+				t.Errorf("Expected empty 304 body, got %q", rr.Body.String())
+			}
+		})
+	}
+
+	t.Run("changed target", func(t *testing.T) {
+		target = "https://example.com/moved"
+		defer func() { target = "https://example.com/page" }()
+
+		if rr := get(etag); rr.Code != http.StatusTemporaryRedirect {
+			t.Errorf("Expected status code %d, got %d", http.StatusTemporaryRedirect, rr.Code)
+		}
+	})
+
+	t.Run("deleted URL", func(t *testing.T) {
+		deleted = true
+		defer func() { deleted = false }()
+
+		for _, ifNoneMatch := range []string{etag, "*"} {
+			rr := get(ifNoneMatch)
+			if rr.Code != http.StatusGone {
+				t.Errorf("If-None-Match %s: expected status code %d, got %d", ifNoneMatch, http.StatusGone, rr.Code)
+			}
+			if rr.Header().Get("ETag") != "" {
+				t.Errorf("Expected no ETag on 410, got %s", rr.Header().Get("ETag"))
+			}
+			if cc := rr.Header().Get("Cache-Control"); cc != "no-store" {
+				t.Errorf("Expected Cache-Control no-store on 410, got %q", cc)
+			}
+		}
+	})
+}
+
+func TestHandler_RedirectETagsDisabled(t *testing.T) {
+	router := NewHandler(&mockURLService{}, nil).RegisterRoutes()
+
+	req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+	req.Header.Set("If-None-Match", "*")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if rr.Code == http.StatusNotModified {
+		t.Error("Expected no 304 with redirect ETags disabled")
+	}
+	if rr.Header().Get("ETag") != "" {
+		t.Errorf("Expected no ETag with redirect ETags disabled, got %s", rr.Header().Get("ETag"))
+	}
+}
+
+func TestHandler_RedirectETagsNoCache(t *testing.T) {
+	router := NewHandler(&mockURLService{
+		getOriginalURLWithDeletedStatusFunc: func(ctx context.Context, id string) (string, error) {
+			return "https://example.com", nil
+		},
+	}, nil, WithRedirectETags(true)).RegisterRoutes()
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/abc123", nil))
+
+	if cc := rr.Header().Get("Cache-Control"); cc != "public, no-cache" {
+		t.Errorf("Expected Cache-Control %q, got %q", "public, no-cache", cc)
+	}
+}
+
+func TestHandler_RedirectCacheControl(t *testing.T) {
+	mockService := &mockURLService{
+		getOriginalURLWithDeletedStatusFunc: func(ctx context.Context, id string) (string, error) {
+			if id == "deleted" {
+				return "", storage.ErrURLDeleted
+			}
+			return "https://example.com", nil
+		},
+	}
+
+	tests := []struct {
+		name             string
+		opts             []Option
+		id               string
+		wantStatus       int
+		wantCacheControl string
+	}{
+		{name: "found", opts: []Option{WithRedirectCacheMaxAge(5 * time.Minute)}, id: "abc123",
+			wantStatus: http.StatusTemporaryRedirect, wantCacheControl: "public, max-age=300"},
+		{name: "deleted", opts: []Option{WithRedirectCacheMaxAge(5 * time.Minute)}, id: "deleted",
+			wantStatus: http.StatusGone, wantCacheControl: "no-store"},
+		{name: "found with passwords", opts: []Option{WithRedirectCacheMaxAge(5 * time.Minute), WithPasswords(true)}, id: "abc123",
+			wantStatus: http.StatusTemporaryRedirect, wantCacheControl: "private, max-age=300"},
+		{name: "disabled found", id: "abc123", wantStatus: http.StatusTemporaryRedirect},
+		{name: "disabled deleted", id: "deleted", wantStatus: http.StatusGone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := NewHandler(mockService, nil, tt.opts...).RegisterRoutes()
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+tt.id, nil))
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected status code %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if cc := rr.Header().Get("Cache-Control"); cc != tt.wantCacheControl {
+				t.Errorf("Expected Cache-Control %q, got %q", tt.wantCacheControl, cc)
+			}
+			if rr.Header().Get("ETag") != "" {
+				t.Errorf("Expected no ETag without redirect ETags, got %s", rr.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+// expiryURLService reports the expiry of short URLs from a map.
+type expiryURLService struct {
+	*mockURLService
+	expiries map[string]time.Time
+}
+
+func (s *expiryURLService) URLExpiry(ctx context.Context, id string) (time.Time, error) {
+	expiresAt, ok := s.expiries[id]
+	if !ok {
+		return time.Time{}, errors.New("storage error")
+	}
+	return expiresAt, nil
+}
+
+func TestHandler_RedirectCacheControlExpiry(t *testing.T) {
+	mockService := &expiryURLService{
+		mockURLService: &mockURLService{
+			getOriginalURLWithDeletedStatusFunc: func(ctx context.Context, id string) (string, error) {
+				return "https://example.com", nil
+			},
+		},
+		expiries: map[string]time.Time{
+			"forever": {},
+			"soon":    time.Now().Add(90*time.Second + 500*time.Millisecond),
+			"later":   time.Now().Add(time.Hour),
+		},
+	}
+	router := NewHandler(mockService, nil, WithRedirectCacheMaxAge(5*time.Minute)).RegisterRoutes()
+
+	tests := []struct {
+		id               string
+		wantCacheControl string
+	}{
+		{id: "forever", wantCacheControl: "public, max-age=300"},
+		{id: "later", wantCacheControl: "public, max-age=300"},
+		{id: "soon", wantCacheControl: "public, max-age=90"},
+		{id: "unknown", wantCacheControl: "public, max-age=0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+tt.id, nil))
+
+			if rr.Code != http.StatusTemporaryRedirect {
+				t.Errorf("Expected status code %d, got %d", http.StatusTemporaryRedirect, rr.Code)
+			}
+			if cc := rr.Header().Get("Cache-Control"); cc != tt.wantCacheControl {
+				t.Errorf("Expected Cache-Control %q, got %q", tt.wantCacheControl, cc)
+			}
+		})
+	}
+}
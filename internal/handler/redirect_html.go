@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"bytes"
+	"html/template"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// redirectPage is served with 307 redirects to browsers that don't follow
+// Location on their own, such as link previewers.
+var redirectPage = template.Must(template.New("redirect").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="0; url={{.}}">
+<title>Redirecting</title>
+</head>
+<body>
+<p>Redirecting to <a href="{{.}}">{{.}}</a>.</p>
+</body>
+</html>
+`))
+
+// gonePage explains a 410 response for a deleted or expired short URL.
+const gonePage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Link unavailable</title>
+</head>
+<body>
+<p>This short link has been deleted or has expired.</p>
+</body>
+</html>
+`
+
+// wantsHTML reports whether HTML redirect pages are enabled and the request
+// explicitly accepts text/html. Wildcards alone don't count, so API clients
+// sending */* keep getting empty bodies.
+func (h *Handler) wantsHTML(r *http.Request) bool {
+	if !h.htmlRedirects {
+		return false
+	}
+
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaRange, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || mediaRange != "text/html" {
+			continue
+		}
+		if q, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil && parsed <= 0 {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// writeRedirect sends a 307 to originalURL, with an HTML fallback page for browsers.
+func (h *Handler) writeRedirect(w http.ResponseWriter, r *http.Request, originalURL string) {
+	w.Header().Set("Location", originalURL)
+
+	if !h.wantsHTML(r) {
+		w.WriteHeader(http.StatusTemporaryRedirect)
+		return
+	}
+
+	var page bytes.Buffer
+	if err := redirectPage.Execute(&page, originalURL); err != nil {
+		log.Error().Err(err).Msg("Failed to render redirect page")
+		w.WriteHeader(http.StatusTemporaryRedirect)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusTemporaryRedirect)
+	if _, err := w.Write(page.Bytes()); err != nil {
+		log.Debug().Err(err).Msg("Failed to write redirect page")
+	}
+}
+
+// writeGone sends 410 for a deleted or expired short URL, explaining it to browsers.
+func (h *Handler) writeGone(w http.ResponseWriter, r *http.Request) {
+	if h.redirectCacheable() {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+
+	if !h.wantsHTML(r) {
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusGone)
+	if _, err := w.Write([]byte(gonePage)); err != nil {
+		log.Debug().Err(err).Msg("Failed to write gone page")
+	}
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MikhailRaia/url-shortener/internal/storage"
+)
+
+func TestHandler_RedirectHTML(t *testing.T) {
+	const browserAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+
+	mockService := &mockURLService{
+		getOriginalURLWithDeletedStatusFunc: func(ctx context.Context, id string) (string, error) {
+			if id == "deleted" {
+				return "", storage.ErrURLDeleted
+			}
+			return "https://example.com/?a=1&b=<2>", nil
+		},
+	}
+
+	tests := []struct {
+		name         string
+		enabled      bool
+		id           string
+		accept       string
+		wantStatus   int
+		wantLocation string
+		wantHTML     bool
+		wantBody     string
+	}{
+		{name: "browser redirect", enabled: true, id: "abc123", accept: browserAccept, wantStatus: http.StatusTemporaryRedirect,
+			wantLocation: "https://example.com/?a=1&b=<2>", wantHTML: true, wantBody: `href="https://example.com/?a=1&amp;b=%3c2%3e"`},
+		{name: "API client redirect", enabled: true, id: "abc123", accept: "*/*", wantStatus: http.StatusTemporaryRedirect,
+			wantLocation: "https://example.com/?a=1&b=<2>"},
+		{name: "JSON client redirect", enabled: true, id: "abc123", accept: "application/json", wantStatus: http.StatusTemporaryRedirect,
+			wantLocation: "https://example.com/?a=1&b=<2>"},
+		{name: "HTML refused by quality", enabled: true, id: "abc123", accept: "text/html;q=0, */*", wantStatus: http.StatusTemporaryRedirect,
+			wantLocation: "https://example.com/?a=1&b=<2>"},
+		{name: "browser gone", enabled: true, id: "deleted", accept: browserAccept, wantStatus: http.StatusGone,
+			wantHTML: true, wantBody: "deleted or has expired"},
+		{name: "API client gone", enabled: true, id: "deleted", accept: "*/*", wantStatus: http.StatusGone},
+		{name: "disabled", enabled: false, id: "abc123", accept: browserAccept, wantStatus: http.StatusTemporaryRedirect,
+			wantLocation: "https://example.com/?a=1&b=<2>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := NewHandler(mockService, nil, WithHTMLRedirects(tt.enabled)).RegisterRoutes()
+
+			req := httptest.NewRequest(http.MethodGet, "/"+tt.id, nil)
+			req.Header.Set("Accept", tt.accept)
+			rr := httptest.NewRecorder()
+
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected status code %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if got := rr.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Expected Location %q, got %q", tt.wantLocation, got)
+			}
+
+			isHTML := strings.HasPrefix(rr.Header().Get("Content-Type"), "text/html")
+			if isHTML != tt.wantHTML {
+				t.Errorf("Expected HTML response: %v, got Content-Type %q", tt.wantHTML, rr.Header().Get("Content-Type"))
+			}
+			if !tt.wantHTML && rr.Body.Len() != 0 {
+				t.Errorf("Expected empty body, got %q", rr.Body.String())
+			}
+			if tt.wantBody != "" && !strings.Contains(rr.Body.String(), tt.wantBody) {
+				t.Errorf("Expected body to contain %q, got %q", tt.wantBody, rr.Body.String())
+			}
+		})
+	}
+}
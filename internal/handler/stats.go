@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/service"
+	"github.com/MikhailRaia/url-shortener/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// VisitRecorder counts redirects without blocking the request.
+type VisitRecorder interface {
+	Record(tenantID, id string)
+}
+
+// StatsURLService is implemented by services that can report visit statistics.
+type StatsURLService interface {
+	GetURLStats(ctx context.Context, id string) (string, model.URLStats, error)
+}
+
+// URLStatsResponse is the JSON response of GET /api/url/{id}/stats.
+type URLStatsResponse struct {
+	ShortURL    string     `json:"short_url"`
+	OriginalURL string     `json:"original_url"`
+	Visits      int64      `json:"visits"`
+	LastVisited *time.Time `json:"last_visited"`
+	// Creator is only reported to clients within the trusted subnet.
+	Creator *model.Creator `json:"creator,omitempty"`
+}
+
+// registerStats exposes per-URL visit statistics when visit tracking is enabled.
+func (h *Handler) registerStats(r chi.Router) {
+	if h.visits == nil {
+		return
+	}
+
+	r.Get("/api/url/{id}/stats", h.handleURLStats)
+}
+
+func (h *Handler) handleURLStats(w http.ResponseWriter, r *http.Request) {
+	statsService, ok := h.urlService.(StatsURLService)
+	if !ok {
+		writeJSONError(w, http.StatusNotImplemented, ErrCodeNotImplemented, "visit statistics are not supported")
+		return
+	}
+
+	id, ok := h.verifiedID(chi.URLParam(r, "id"))
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, ErrCodeNotFound, "short URL not found")
+		return
+	}
+
+	originalURL, stats, err := statsService.GetURLStats(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrURLDeleted), errors.Is(err, storage.ErrURLExpired):
+			writeJSONError(w, http.StatusGone, ErrCodeGone, "short URL is no longer available")
+		case errors.Is(err, service.ErrStatsUnsupported):
+			writeJSONError(w, http.StatusNotImplemented, ErrCodeNotImplemented, "storage does not support visit statistics")
+		default:
+			log.Error().Err(err).Msg("Failed to get URL stats")
+			writeInternalError(w)
+		}
+		return
+	}
+
+	if originalURL == "" {
+		writeJSONError(w, http.StatusNotFound, ErrCodeNotFound, "short URL not found")
+		return
+	}
+
+	creator, err := h.creatorFor(r, id)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get URL creator")
+		writeInternalError(w)
+		return
+	}
+
+	response := URLStatsResponse{
+		ShortURL:    h.shortURLFor(r, id),
+		OriginalURL: originalURL,
+		Visits:      stats.Visits,
+		Creator:     creator,
+	}
+	if !stats.LastVisited.IsZero() {
+		lastVisited := stats.LastVisited.UTC()
+		response.LastVisited = &lastVisited
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Error().Err(err).Msg("Failed to encode stats response")
+	}
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MikhailRaia/url-shortener/internal/service"
+	"github.com/MikhailRaia/url-shortener/internal/storage/memory"
+	"github.com/MikhailRaia/url-shortener/internal/worker"
+)
+
+func TestHandler_VisitStats(t *testing.T) {
+	urlStorage := memory.NewStorage()
+	urlService := service.NewURLService(urlStorage, "http://short.example")
+
+	id, _ := urlStorage.SaveWithUser("https://example.com/long", "user1")
+	deletedID, _ := urlStorage.SaveWithUser("https://example.com/gone", "user1")
+	urlStorage.DeleteUserURLs("user1", []string{deletedID})
+
+	recorder := worker.NewVisitRecorder(urlService, worker.VisitConfig{
+		BufferSize:    100,
+		BatchSize:     100,
+		FlushInterval: time.Hour,
+	})
+	recorder.Start()
+
+	router := NewHandler(urlService, nil, WithVisitTracking(recorder)).RegisterRoutes()
+
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+id, nil))
+		if rec.Code != http.StatusTemporaryRedirect {
+			t.Fatalf("Expected status code %d, got %d", http.StatusTemporaryRedirect, rec.Code)
+		}
+	}
+
+	if err := recorder.Shutdown(time.Second); err != nil {
+		t.Fatalf("Failed to flush visits: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/url/"+id+"/stats", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	var response URLStatsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if response.Visits != 3 {
+		t.Errorf("Expected 3 visits, got %d", response.Visits)
+	}
+	if response.ShortURL != "http://short.example/"+id {
+		t.Errorf("Expected short URL %s, got %s", "http://short.example/"+id, response.ShortURL)
+	}
+	if response.OriginalURL != "https://example.com/long" {
+		t.Errorf("Expected original URL %s, got %s", "https://example.com/long", response.OriginalURL)
+	}
+	if response.LastVisited == nil {
+		t.Errorf("Expected last_visited to be set")
+	}
+
+	tests := []struct {
+		name       string
+		id         string
+		wantStatus int
+	}{
+		{name: "unknown ID", id: "missing", wantStatus: http.StatusNotFound},
+		{name: "deleted ID", id: deletedID, wantStatus: http.StatusGone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/url/"+tt.id+"/stats", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status code %d, got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestHandler_VisitStatsDisabled(t *testing.T) {
+	urlStorage := memory.NewStorage()
+	id, _ := urlStorage.Save("https://example.com/long")
+
+	router := NewHandler(service.NewURLService(urlStorage, "http://short.example"), nil).RegisterRoutes()
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/url/"+id+"/stats", nil))
+
+	if rec.Code == http.StatusOK {
+		t.Errorf("Expected stats endpoint to be unavailable, got %d", rec.Code)
+	}
+}
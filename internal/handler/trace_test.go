@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MikhailRaia/url-shortener/internal/trace"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func TestHandler_TraceID(t *testing.T) {
+	tests := []struct {
+		name        string
+		traceparent string
+		want        string
+	}{
+		{
+			name: "generated",
+		},
+		{
+			name:        "propagated from traceparent",
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			want:        "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name:        "malformed traceparent is replaced",
+			traceparent: "00-not-a-trace-01",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			previous := log.Logger
+			log.Logger = zerolog.New(&logs)
+			t.Cleanup(func() { log.Logger = previous })
+
+			var serviceTraceID string
+			mockService := &mockURLService{
+				shortenURLFunc: func(ctx context.Context, originalURL string) (string, error) {
+					serviceTraceID = trace.IDFromContext(ctx)
+					return "http://localhost:8080/abc123", nil
+				},
+			}
+			router := NewHandler(mockService, nil).RegisterRoutes()
+
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://example.com"}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.traceparent != "" {
+				req.Header.Set(trace.Header, tt.traceparent)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+			}
+			if len(serviceTraceID) != 32 {
+				t.Fatalf("Expected a 32 character trace ID in the service call, got %q", serviceTraceID)
+			}
+			if tt.want != "" && serviceTraceID != tt.want {
+				t.Errorf("Expected trace ID %q in the service call, got %q", tt.want, serviceTraceID)
+			}
+			if got := rec.Header().Get(trace.ResponseHeader); got != serviceTraceID {
+				t.Errorf("Expected %s header %q, got %q", trace.ResponseHeader, serviceTraceID, got)
+			}
+
+			logged := map[string]string{}
+			for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+				var entry struct {
+					Message string `json:"message"`
+					TraceID string `json:"trace_id"`
+				}
+				if err := json.Unmarshal([]byte(line), &entry); err != nil {
+					t.Fatalf("Failed to parse log line %q: %v", line, err)
+				}
+				logged[entry.Message] = entry.TraceID
+			}
+			for _, message := range []string{"Request processed", "Response sent"} {
+				if got, ok := logged[message]; !ok || got != serviceTraceID {
+					t.Errorf("Expected %q to be logged with trace ID %q, got %q", message, serviceTraceID, got)
+				}
+			}
+		})
+	}
+}
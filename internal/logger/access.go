@@ -0,0 +1,123 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Supported access log formats.
+const (
+	// AccessFormatJSON logs requests as zerolog JSON events.
+	AccessFormatJSON = "json"
+	// AccessFormatCommon writes NCSA Common Log Format lines.
+	AccessFormatCommon = "common"
+	// AccessFormatCombined writes NCSA Combined Log Format lines, which add
+	// the referer and user agent to the common format.
+	AccessFormatCombined = "combined"
+)
+
+// clfTimeLayout is the timestamp layout of NCSA access logs.
+const clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// accessLog holds the access log format used by RequestLogger and where
+// NCSA-style lines are written.
+var accessLog = struct {
+	sync.Mutex
+	format string
+	out    io.Writer
+}{format: AccessFormatJSON, out: os.Stdout}
+
+// InitAccessLog selects the format RequestLogger uses: "json" (the default,
+// also used when format is empty), "common" or "combined". NCSA-style lines
+// go to standard output.
+func InitAccessLog(format string) error {
+	switch format {
+	case "":
+		format = AccessFormatJSON
+	case AccessFormatJSON, AccessFormatCommon, AccessFormatCombined:
+	default:
+		return fmt.Errorf("invalid access log format %q", format)
+	}
+
+	accessLog.Lock()
+	defer accessLog.Unlock()
+	accessLog.format = format
+	return nil
+}
+
+// accessFormat returns the configured access log format.
+func accessFormat() string {
+	accessLog.Lock()
+	defer accessLog.Unlock()
+	return accessLog.format
+}
+
+// writeAccessLine writes one NCSA-style access line for a finished request.
+func writeAccessLine(format string, r *http.Request, start time.Time, ww *ResponseWriter) {
+	line := formatAccessLine(format, r, start, ww.Status(), ww.Size())
+
+	accessLog.Lock()
+	defer accessLog.Unlock()
+	_, _ = io.WriteString(accessLog.out, line)
+}
+
+// formatAccessLine renders a request in Common or Combined Log Format.
+func formatAccessLine(format string, r *http.Request, start time.Time, status, size int) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	bytesSent := "-"
+	if size > 0 {
+		bytesSent = strconv.Itoa(size)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s - - [%s] \"%s %s %s\" %d %s",
+		clfField(host),
+		start.Format(clfTimeLayout),
+		escapeCLF(r.Method), escapeCLF(redactedURI(r)), escapeCLF(r.Proto),
+		status, bytesSent,
+	)
+	if format == AccessFormatCombined {
+		fmt.Fprintf(&b, " \"%s\" \"%s\"", escapeCLF(r.Referer()), escapeCLF(r.UserAgent()))
+	}
+	b.WriteByte('\n')
+
+	return b.String()
+}
+
+// clfField returns s escaped, or "-" when it is empty.
+func clfField(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return escapeCLF(s)
+}
+
+// escapeCLF escapes quotes, backslashes and non-printable bytes the way
+// Apache does, so a field cannot break the line format.
+func escapeCLF(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
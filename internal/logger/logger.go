@@ -1,24 +1,59 @@
 package logger
 
 import (
+	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"time"
 
+	"github.com/MikhailRaia/url-shortener/internal/trace"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
+// Supported log output formats.
+const (
+	// FormatJSON writes one JSON object per log line.
+	FormatJSON = "json"
+	// FormatConsole writes human-readable colored lines.
+	FormatConsole = "console"
+)
+
 // InitLogger initializes default zerolog logger for the application.
-func InitLogger() {
-	log.Logger = zerolog.New(os.Stdout).
+// Level is parsed with zerolog.ParseLevel (empty means info) and format is
+// either "json" or "console" (empty means json).
+func InitLogger(level, format string) error {
+	lvl := zerolog.InfoLevel
+	if level != "" {
+		parsed, err := zerolog.ParseLevel(level)
+		if err != nil {
+			return fmt.Errorf("invalid log level %q: %w", level, err)
+		}
+		lvl = parsed
+	}
+
+	var out io.Writer
+	switch format {
+	case "", FormatJSON:
+		out = os.Stdout
+	case FormatConsole:
+		out = zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}
+	default:
+		return fmt.Errorf("invalid log format %q", format)
+	}
+
+	log.Logger = zerolog.New(out).
 		With().
 		Timestamp().
 		Logger().
-		Level(zerolog.InfoLevel)
+		Level(lvl)
+
+	return nil
 }
 
-// RequestLogger logs basic request/response metadata for each HTTP call.
+// RequestLogger logs basic request/response metadata for each HTTP call,
+// as zerolog events or as NCSA access lines depending on InitAccessLog.
 func RequestLogger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -27,21 +62,40 @@ func RequestLogger(next http.Handler) http.Handler {
 
 		next.ServeHTTP(ww, r)
 
+		if format := accessFormat(); format != AccessFormatJSON {
+			writeAccessLine(format, r, start, ww)
+			return
+		}
+
 		duration := time.Since(start)
+		traceID := trace.IDFromContext(r.Context())
 
 		log.Info().
+			Str("trace_id", traceID).
 			Str("method", r.Method).
-			Str("uri", r.RequestURI).
+			Str("uri", redactedURI(r)).
 			Dur("duration", duration).
 			Msg("Request processed")
 
 		log.Info().
+			Str("trace_id", traceID).
 			Int("status", ww.Status()).
 			Int("size", ww.Size()).
 			Msg("Response sent")
 	})
 }
 
+// redactedURI returns the request URI with secret query parameters masked.
+func redactedURI(r *http.Request) string {
+	query := r.URL.Query()
+	if !query.Has("pw") {
+		return r.RequestURI
+	}
+
+	query.Set("pw", "REDACTED")
+	return r.URL.Path + "?" + query.Encode()
+}
+
 // ResponseWriter wraps http.ResponseWriter to capture status code and size.
 type ResponseWriter struct {
 	http.ResponseWriter
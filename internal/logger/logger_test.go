@@ -28,7 +28,7 @@ func TestInitLogger(t *testing.T) {
 	require.NoError(t, err)
 	os.Stdout = w
 
-	InitLogger()
+	require.NoError(t, InitLogger("", ""))
 
 	assert.Equal(t, zerolog.InfoLevel, log.Logger.GetLevel())
 
@@ -46,6 +46,53 @@ func TestInitLogger(t *testing.T) {
 	assert.Contains(t, logStr, "test message")
 }
 
+func TestInitLoggerLevels(t *testing.T) {
+	originalLogger := log.Logger
+	originalStdout := os.Stdout
+
+	defer func() {
+		log.Logger = originalLogger
+		os.Stdout = originalStdout
+	}()
+
+	capture := func(level string) string {
+		r, w, err := os.Pipe()
+		require.NoError(t, err)
+		os.Stdout = w
+
+		require.NoError(t, InitLogger(level, FormatJSON))
+		log.Debug().Msg("debug message")
+		log.Info().Msg("info message")
+
+		w.Close()
+
+		var buf bytes.Buffer
+		_, err = buf.ReadFrom(r)
+		require.NoError(t, err)
+		return buf.String()
+	}
+
+	debugOutput := capture("debug")
+	assert.Contains(t, debugOutput, "debug message")
+	assert.Contains(t, debugOutput, "info message")
+
+	infoOutput := capture("info")
+	assert.NotContains(t, infoOutput, "debug message")
+	assert.Contains(t, infoOutput, "info message")
+}
+
+func TestInitLoggerInvalidConfig(t *testing.T) {
+	originalLogger := log.Logger
+	defer func() {
+		log.Logger = originalLogger
+	}()
+
+	assert.Error(t, InitLogger("verbose", FormatJSON))
+	assert.Error(t, InitLogger("info", "xml"))
+	assert.NoError(t, InitLogger("warn", FormatConsole))
+	assert.Equal(t, zerolog.WarnLevel, log.Logger.GetLevel())
+}
+
 func TestRequestLogger(t *testing.T) {
 	var buf bytes.Buffer
 
@@ -120,3 +167,93 @@ func TestResponseWriter(t *testing.T) {
 
 	assert.Equal(t, "test data", rr.Body.String())
 }
+
+func TestRequestLogger_RedactsPassword(t *testing.T) {
+	originalLogger := log.Logger
+	defer func() { log.Logger = originalLogger }()
+
+	var buf bytes.Buffer
+	log.Logger = zerolog.New(&buf)
+
+	handler := RequestLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodGet, "/abc123?pw=s3cret", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.NotContains(t, buf.String(), "s3cret")
+	assert.Contains(t, buf.String(), "/abc123?pw=REDACTED")
+}
+
+func TestFormatAccessLine(t *testing.T) {
+	start := time.Date(2000, time.October, 10, 13, 55, 36, 0, time.FixedZone("", -7*60*60))
+
+	req := httptest.NewRequest(http.MethodGet, "/abc123?pw=s3cret", nil)
+	req.RemoteAddr = "192.0.2.1:54321"
+	req.Header.Set("Referer", "https://example.com/")
+	req.Header.Set("User-Agent", `Mozilla/5.0 "quoted"`)
+
+	tests := []struct {
+		name   string
+		format string
+		status int
+		size   int
+		want   string
+	}{
+		{
+			name:   "common",
+			format: AccessFormatCommon,
+			status: http.StatusTemporaryRedirect,
+			size:   2326,
+			want:   `192.0.2.1 - - [10/Oct/2000:13:55:36 -0700] "GET /abc123?pw=REDACTED HTTP/1.1" 307 2326` + "\n",
+		},
+		{
+			name:   "common without body",
+			format: AccessFormatCommon,
+			status: http.StatusNoContent,
+			want:   `192.0.2.1 - - [10/Oct/2000:13:55:36 -0700] "GET /abc123?pw=REDACTED HTTP/1.1" 204 -` + "\n",
+		},
+		{
+			name:   "combined",
+			format: AccessFormatCombined,
+			status: http.StatusOK,
+			size:   13,
+			want:   `192.0.2.1 - - [10/Oct/2000:13:55:36 -0700] "GET /abc123?pw=REDACTED HTTP/1.1" 200 13 "https://example.com/" "Mozilla/5.0 \"quoted\""` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, formatAccessLine(tt.format, req, start, tt.status, tt.size))
+		})
+	}
+}
+
+func TestRequestLogger_AccessFormat(t *testing.T) {
+	originalOut := accessLog.out
+	defer func() {
+		accessLog.out = originalOut
+		require.NoError(t, InitAccessLog(""))
+	}()
+
+	var buf bytes.Buffer
+	accessLog.out = &buf
+	require.NoError(t, InitAccessLog(AccessFormatCommon))
+
+	handler := RequestLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("http://localhost:8080/abc123"))
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten", nil)
+	req.RemoteAddr = "192.0.2.1:54321"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Regexp(t, `^192\.0\.2\.1 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "POST /api/shorten HTTP/1\.1" 201 28\n$`, buf.String())
+}
+
+func TestInitAccessLog(t *testing.T) {
+	defer func() { require.NoError(t, InitAccessLog("")) }()
+
+	for _, format := range []string{"", AccessFormatJSON, AccessFormatCommon, AccessFormatCombined} {
+		assert.NoError(t, InitAccessLog(format), format)
+	}
+	assert.Error(t, InitAccessLog("apache"))
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MikhailRaia/url-shortener/internal/logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "url_shortener"
+
+// Metrics holds the Prometheus registry and collectors exposed by the service.
+type Metrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// New creates a Metrics instance with HTTP request collectors registered.
+func New() *Metrics {
+	registry := prometheus.NewRegistry()
+
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Total number of HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	registry.MustRegister(requests, duration)
+
+	return &Metrics{
+		registry: registry,
+		requests: requests,
+		duration: duration,
+	}
+}
+
+// RegisterQueueDepth exposes the delete queue depth reported by fn.
+func (m *Metrics) RegisterQueueDepth(fn func() float64) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "delete_queue_depth",
+		Help:      "Number of pending requests in the delete worker queue.",
+	}, fn))
+}
+
+// RegisterStoredURLs exposes the number of stored URLs reported by fn.
+func (m *Metrics) RegisterStoredURLs(fn func() float64) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "stored_urls",
+		Help:      "Number of stored, non-deleted short URLs.",
+	}, fn))
+}
+
+// Handler returns the HTTP handler serving the metrics in Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{
+		DisableCompression: true,
+	})
+}
+
+// Middleware records request counts and latencies keyed by chi route pattern.
+// It must be installed on a chi router so the matched pattern is available.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		ww := logger.NewResponseWriter(w)
+
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if pattern := rctx.RoutePattern(); pattern != "" {
+				route = pattern
+			}
+		}
+
+		status := strconv.Itoa(ww.Status())
+		m.requests.WithLabelValues(r.Method, route, status).Inc()
+		m.duration.WithLabelValues(r.Method, route, status).Observe(time.Since(start).Seconds())
+	})
+}
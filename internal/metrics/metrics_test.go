@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	m := New()
+	m.RegisterQueueDepth(func() float64 { return 3 })
+	m.RegisterStoredURLs(func() float64 { return 7 })
+
+	r := chi.NewRouter()
+	r.Use(m.Middleware)
+	r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	})
+	r.Method(http.MethodGet, "/metrics", m.Handler())
+
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Get(server.URL + "/abc123")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	resp, err = client.Get(server.URL + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	output := string(body)
+
+	assert.True(t, strings.Contains(output, `url_shortener_http_requests_total{method="GET",route="/{id}",status="307"} 1`), output)
+	assert.Contains(t, output, `url_shortener_http_request_duration_seconds_count{method="GET",route="/{id}",status="307"} 1`)
+	assert.Contains(t, output, "url_shortener_delete_queue_depth 3")
+	assert.Contains(t, output, "url_shortener_stored_urls 7")
+}
@@ -14,6 +14,9 @@ type contextKey string
 // UserIDKey is the context key used to store authenticated user ID.
 const UserIDKey contextKey = "userID"
 
+// newUserKey marks requests whose user was created by AuthenticateUser.
+const newUserKey contextKey = "newUser"
+
 // AuthMiddleware manages user authentication using JWT cookies.
 type AuthMiddleware struct {
 	jwtService *auth.JWTService
@@ -30,6 +33,7 @@ func NewAuthMiddleware(jwtService *auth.JWTService) *AuthMiddleware {
 func (a *AuthMiddleware) AuthenticateUser(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var userID string
+		var created bool
 
 		log.Debug().Msg("AuthenticateUser middleware called")
 
@@ -72,10 +76,14 @@ func (a *AuthMiddleware) AuthenticateUser(next http.Handler) http.Handler {
 			})
 
 			userID = newUserID
+			created = true
 			log.Debug().Str("userID", userID).Msg("Created new user")
 		}
 
 		ctx := context.WithValue(r.Context(), UserIDKey, userID)
+		if created {
+			ctx = context.WithValue(ctx, newUserKey, true)
+		}
 		log.Debug().Str("userID", userID).Msg("Setting userID in context")
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -106,3 +114,10 @@ func GetUserIDFromContext(ctx context.Context) (string, bool) {
 	userID, ok := ctx.Value(UserIDKey).(string)
 	return userID, ok
 }
+
+// IsNewUser reports whether the user in ctx was created for this request
+// rather than authenticated from an existing token.
+func IsNewUser(ctx context.Context) bool {
+	created, _ := ctx.Value(newUserKey).(bool)
+	return created
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/MikhailRaia/url-shortener/internal/baseurl"
+)
+
+// BaseURLResolver stores the base URL the client used in context. It is built
+// from the request host, or, if trustProxy is set, from the X-Forwarded-Proto
+// and X-Forwarded-Host headers of a reverse proxy.
+func BaseURLResolver(trustProxy bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := baseurl.WithURL(r.Context(), baseurl.FromRequest(r, trustProxy))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import "net/http"
+
+// MaxBodySize limits request bodies to limit bytes using http.MaxBytesReader.
+// Handlers see an *http.MaxBytesError once the limit is exceeded.
+// A non-positive limit leaves the body unrestricted.
+func MaxBodySize(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
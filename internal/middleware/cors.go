@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CORSConfig configures cross-origin access for browser clients.
+type CORSConfig struct {
+	// AllowedOrigins lists origins allowed to call the API, or "*" for any
+	// origin. "*" is never honoured together with AllowCredentials.
+	AllowedOrigins []string
+	// AllowedMethods lists methods allowed in preflighted requests.
+	AllowedMethods []string
+	// AllowedHeaders lists request headers allowed in preflighted requests.
+	AllowedHeaders []string
+	// AllowCredentials lets browsers send the auth cookie cross-origin.
+	AllowCredentials bool
+	// MaxAge is how long in seconds browsers may cache a preflight response.
+	MaxAge int
+}
+
+// CORS adds CORS headers for allowed origins and answers preflight requests.
+// Requests from other origins pass through without CORS headers, except
+// preflights, which are rejected with 403 Forbidden.
+func CORS(cfg CORSConfig) func(http.Handler) http.Handler {
+	allowMethods := strings.Join(cfg.AllowedMethods, ", ")
+	allowHeaders := strings.Join(cfg.AllowedHeaders, ", ")
+	anyOrigin := !cfg.AllowCredentials && slices.Contains(cfg.AllowedOrigins, "*")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+			w.Header().Add("Vary", "Origin")
+			if preflight {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+			}
+
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !anyOrigin && !slices.Contains(cfg.AllowedOrigins, origin) {
+				if preflight {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if anyOrigin {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if cfg.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if allowMethods != "" {
+				w.Header().Set("Access-Control-Allow-Methods", allowMethods)
+			}
+			if allowHeaders != "" {
+				w.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+			}
+			if cfg.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(cfg.MaxAge))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	cfg := CORSConfig{
+		AllowedOrigins:   []string{"https://app.example"},
+		AllowedMethods:   []string{"GET", "POST", "DELETE"},
+		AllowedHeaders:   []string{"Content-Type"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	}
+
+	tests := []struct {
+		name            string
+		cfg             CORSConfig
+		method          string
+		origin          string
+		preflight       bool
+		wantStatus      int
+		wantAllowOrigin string
+		wantCredentials string
+		wantMethods     string
+		wantNextCalled  bool
+	}{
+		{name: "preflight from allowed origin", cfg: cfg, method: http.MethodOptions, origin: "https://app.example", preflight: true,
+			wantStatus: http.StatusNoContent, wantAllowOrigin: "https://app.example", wantCredentials: "true", wantMethods: "GET, POST, DELETE"},
+		{name: "preflight from disallowed origin", cfg: cfg, method: http.MethodOptions, origin: "https://evil.example", preflight: true,
+			wantStatus: http.StatusForbidden},
+		{name: "request from allowed origin", cfg: cfg, method: http.MethodPost, origin: "https://app.example",
+			wantStatus: http.StatusOK, wantAllowOrigin: "https://app.example", wantCredentials: "true", wantNextCalled: true},
+		{name: "request from disallowed origin", cfg: cfg, method: http.MethodPost, origin: "https://evil.example",
+			wantStatus: http.StatusOK, wantNextCalled: true},
+		{name: "same-origin request", cfg: cfg, method: http.MethodGet,
+			wantStatus: http.StatusOK, wantNextCalled: true},
+		{name: "plain OPTIONS request", cfg: cfg, method: http.MethodOptions, origin: "https://app.example",
+			wantStatus: http.StatusOK, wantAllowOrigin: "https://app.example", wantCredentials: "true", wantNextCalled: true},
+		{name: "wildcard origin", cfg: CORSConfig{AllowedOrigins: []string{"*"}}, method: http.MethodGet, origin: "https://any.example",
+			wantStatus: http.StatusOK, wantAllowOrigin: "*", wantNextCalled: true},
+		{name: "wildcard origin ignored with credentials", cfg: CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}, method: http.MethodGet, origin: "https://any.example",
+			wantStatus: http.StatusOK, wantNextCalled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nextCalled := false
+			handler := CORS(tt.cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				nextCalled = true
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(tt.method, "/api/shorten", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status code %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllowOrigin {
+				t.Errorf("Expected Access-Control-Allow-Origin %q, got %q", tt.wantAllowOrigin, got)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != tt.wantCredentials {
+				t.Errorf("Expected Access-Control-Allow-Credentials %q, got %q", tt.wantCredentials, got)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Methods"); got != tt.wantMethods {
+				t.Errorf("Expected Access-Control-Allow-Methods %q, got %q", tt.wantMethods, got)
+			}
+			if nextCalled != tt.wantNextCalled {
+				t.Errorf("Expected next handler called: %v, got %v", tt.wantNextCalled, nextCalled)
+			}
+		})
+	}
+}
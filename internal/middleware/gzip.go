@@ -1,10 +1,16 @@
 package middleware
 
 import (
+	"compress/flate"
 	"compress/gzip"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
+
+	"github.com/andybalholm/brotli"
 )
 
 // GzipWriter wraps a ResponseWriter to support gzip encoding.
@@ -28,79 +34,228 @@ func (w GzipWriter) WriteHeader(statusCode int) {
 	w.ResponseWriter.WriteHeader(statusCode)
 }
 
-// GzipMiddleware compresses eligible responses with gzip when accepted by the client.
+// GzipMiddleware compresses eligible responses with brotli or gzip, whichever
+// the client prefers, at gzip.BestSpeed or the matching brotli quality.
 func GzipMiddleware(next http.Handler) http.Handler {
+	return GzipLevel(gzip.BestSpeed)(next)
+}
+
+// ValidGzipLevel reports whether level is a compression level gzip accepts:
+// gzip.HuffmanOnly, gzip.DefaultCompression or gzip.NoCompression through
+// gzip.BestCompression.
+func ValidGzipLevel(level int) bool {
+	return level >= gzip.HuffmanOnly && level <= gzip.BestCompression
+}
+
+// GzipLevel returns middleware that compresses eligible responses when the
+// client accepts it, with brotli when the client prefers it and gzip otherwise.
+// level is the gzip level; brotli uses the quality of the same number. Invalid
+// levels fall back to gzip.BestSpeed.
+func GzipLevel(level int) func(http.Handler) http.Handler {
+	if !ValidGzipLevel(level) {
+		level = gzip.BestSpeed
+	}
+
+	return func(next http.Handler) http.Handler {
+		return compressHandler(next, level)
+	}
+}
+
+func compressHandler(next http.Handler, level int) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		// Whether the response is compressed depends on Accept-Encoding,
+		// even when this request gets it uncompressed.
+		addVary(w.Header(), "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
 			next.ServeHTTP(w, r)
 			return
 		}
 
 		wrapper := &responseWriterWrapper{
 			ResponseWriter: w,
-			statusCode:     http.StatusOK,
+			encoding:       encoding,
+			level:          level,
 		}
+		defer wrapper.Close()
 
 		next.ServeHTTP(wrapper, r)
+	})
+}
 
-		contentType := wrapper.Header().Get("Content-Type")
-
-		if strings.Contains(contentType, "application/json") ||
-			strings.Contains(contentType, "text/html") ||
-			strings.Contains(contentType, "text/plain") {
+// addVary adds field to the Vary header unless it is already listed, so
+// layers that each depend on the same request header don't repeat it.
+func addVary(header http.Header, field string) {
+	for _, value := range header.Values("Vary") {
+		for _, listed := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(listed), field) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", field)
+}
 
-			if !wrapper.headersSent {
-				gz, err := gzip.NewWriterLevel(w, gzip.BestSpeed)
-				if err != nil {
-					w.WriteHeader(wrapper.statusCode)
-					w.Write(wrapper.body)
-					return
-				}
-				defer gz.Close()
+// compressible reports whether responses of contentType are worth compressing.
+func compressible(contentType string) bool {
+	return strings.Contains(contentType, "application/json") ||
+		strings.Contains(contentType, "text/html") ||
+		strings.Contains(contentType, "text/plain")
+}
 
-				w.Header().Set("Content-Encoding", "gzip")
+// newEncoder returns a writer compressing to w with the given encoding, "br"
+// or "gzip", at the given gzip level.
+func newEncoder(w io.Writer, encoding string, level int) (io.WriteCloser, error) {
+	if encoding == "br" {
+		return brotli.NewWriterLevel(w, brotliQuality(level)), nil
+	}
+	return gzip.NewWriterLevel(w, level)
+}
 
-				for k, v := range wrapper.Header() {
-					for _, vv := range v {
-						w.Header().Add(k, vv)
-					}
-				}
+// brotliQuality maps a gzip level to the brotli quality of the same number,
+// gzip.DefaultCompression to brotli's default and gzip.HuffmanOnly to the
+// fastest quality.
+func brotliQuality(level int) int {
+	switch level {
+	case gzip.DefaultCompression:
+		return brotli.DefaultCompression
+	case gzip.HuffmanOnly:
+		return brotli.BestSpeed
+	default:
+		return level
+	}
+}
 
-				w.WriteHeader(wrapper.statusCode)
+// negotiateEncoding picks the response encoding for an Accept-Encoding
+// header: "br" or "gzip", whichever has the higher q-value with brotli
+// winning ties, or "" for identity. A "*" entry stands for every encoding not
+// listed explicitly.
+func negotiateEncoding(acceptEncoding string) string {
+	weights := make(map[string]float64)
+	wildcard := 0.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
 
-				gz.Write(wrapper.body)
-			}
-		} else {
-			for k, v := range wrapper.Header() {
-				for _, vv := range v {
-					w.Header().Add(k, vv)
-				}
+		weight := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				parsed = 0
 			}
-			w.WriteHeader(wrapper.statusCode)
-			w.Write(wrapper.body)
+			weight = parsed
 		}
-	})
+
+		if name == "*" {
+			wildcard = weight
+			continue
+		}
+		weights[name] = weight
+	}
+
+	weight := func(encoding string) float64 {
+		if w, ok := weights[encoding]; ok {
+			return w
+		}
+		return wildcard
+	}
+
+	br, gz := weight("br"), weight("gzip")
+	switch {
+	case br > 0 && br >= gz:
+		return "br"
+	case gz > 0:
+		return "gzip"
+	default:
+		return ""
+	}
 }
 
+// responseWriterWrapper compresses the response as it is written, so that
+// streamed responses are not held in memory. Whether to compress is decided
+// from the headers when the status is written: responses that are already
+// encoded, not compressible or not allowed a body are passed through as is.
 type responseWriterWrapper struct {
 	http.ResponseWriter
-	statusCode  int
-	body        []byte
+	encoding    string
+	level       int
+	encoder     io.WriteCloser
 	headersSent bool
 }
 
-// WriteHeader captures the status code without immediately writing it.
+// WriteHeader decides whether to compress the response and writes the status code.
 func (w *responseWriterWrapper) WriteHeader(statusCode int) {
-	w.statusCode = statusCode
+	if w.headersSent {
+		return
+	}
+	w.headersSent = true
+
+	header := w.Header()
+	if bodyAllowed(statusCode) && header.Get("Content-Encoding") == "" && compressible(header.Get("Content-Type")) {
+		if encoder, err := newEncoder(w.ResponseWriter, w.encoding, w.level); err == nil {
+			w.encoder = encoder
+			header.Set("Content-Encoding", w.encoding)
+			header.Del("Content-Length")
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(statusCode)
 }
 
-// Write appends the byte slice to the body buffer.
+// Write writes b, compressed if the response is.
 func (w *responseWriterWrapper) Write(b []byte) (int, error) {
-	w.body = append(w.body, b...)
-	return len(b), nil
+	if !w.headersSent {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.encoder == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.encoder.Write(b)
+}
+
+// Flush sends the data compressed so far to the client.
+func (w *responseWriterWrapper) Flush() {
+	if flusher, ok := w.encoder.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController.
+func (w *responseWriterWrapper) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Close finishes the compressed stream, if the response is compressed.
+func (w *responseWriterWrapper) Close() error {
+	if w.encoder == nil {
+		return nil
+	}
+	return w.encoder.Close()
 }
 
-// GzipReader transparently decompresses gzipped request bodies.
+// bodyAllowed reports whether a response with statusCode may have a body.
+func bodyAllowed(statusCode int) bool {
+	return statusCode >= http.StatusOK &&
+		statusCode != http.StatusNoContent &&
+		statusCode != http.StatusNotModified
+}
+
+// ErrInvalidGzip marks request body read errors caused by a corrupt or
+// truncated gzip stream, so handlers can answer 400 instead of treating them
+// as I/O failures.
+var ErrInvalidGzip = errors.New("invalid gzip request body")
+
+// GzipReader transparently decompresses gzipped request bodies. A body
+// without a valid gzip header is rejected with 400 here; corruption further
+// into the stream only shows up while the handler reads the body, as an
+// error wrapping ErrInvalidGzip.
 func GzipReader(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Header.Get("Content-Encoding") != "gzip" {
@@ -115,10 +270,40 @@ func GzipReader(next http.Handler) http.Handler {
 		}
 		defer gzReader.Close()
 
-		bodyReader := io.NopCloser(gzReader)
-		r.Body = bodyReader
+		r.Body = gzipBody{reader: gzReader, body: r.Body}
 		r.ContentLength = -1
 
 		next.ServeHTTP(w, r)
 	})
 }
+
+// gzipBody is a decompressed request body. Closing it closes the original body.
+type gzipBody struct {
+	reader *gzip.Reader
+	body   io.Closer
+}
+
+// Read decompresses into p, wrapping errors caused by corrupt or truncated
+// gzip data in ErrInvalidGzip.
+func (b gzipBody) Read(p []byte) (int, error) {
+	n, err := b.reader.Read(p)
+	if corruptGzip(err) {
+		err = fmt.Errorf("%w: %w", ErrInvalidGzip, err)
+	}
+	return n, err
+}
+
+// Close closes the original request body.
+func (b gzipBody) Close() error {
+	return b.body.Close()
+}
+
+// corruptGzip reports whether err comes from invalid gzip data rather than
+// from reading the underlying body.
+func corruptGzip(err error) bool {
+	var corrupt flate.CorruptInputError
+	return errors.Is(err, gzip.ErrChecksum) ||
+		errors.Is(err, gzip.ErrHeader) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &corrupt)
+}
@@ -3,11 +3,14 @@ package middleware
 import (
 	"bytes"
 	"compress/gzip"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/andybalholm/brotli"
 )
 
 func TestGzipMiddleware(t *testing.T) {
@@ -114,6 +117,46 @@ func TestGzipReader(t *testing.T) {
 	}
 }
 
+func TestGzipReader_Corrupt(t *testing.T) {
+	var buf bytes.Buffer
+	gzWriter := gzip.NewWriter(&buf)
+	if _, err := gzWriter.Write([]byte("Hello, Yandex!")); err != nil {
+		t.Fatalf("Failed to write to gzip writer: %v", err)
+	}
+	gzWriter.Close()
+	valid := buf.Bytes()
+
+	tests := []struct {
+		name       string
+		body       []byte
+		wantStatus int
+	}{
+		{name: "bad header", body: []byte("not gzip at all"), wantStatus: http.StatusBadRequest},
+		{name: "truncated", body: valid[:len(valid)-6], wantStatus: http.StatusUnprocessableEntity},
+		{name: "bad checksum", body: append(append([]byte(nil), valid[:len(valid)-8]...), 0, 0, 0, 0, 0, 0, 0, 0), wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := GzipReader(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if _, err := io.ReadAll(r.Body); !errors.Is(err, ErrInvalidGzip) {
+					t.Errorf("Expected ErrInvalidGzip, got %v", err)
+				}
+				w.WriteHeader(http.StatusUnprocessableEntity)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.body))
+			req.Header.Set("Content-Encoding", "gzip")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status code %d, got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
 func TestGzipReader_NoGzip(t *testing.T) {
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		body, err := io.ReadAll(r.Body)
@@ -145,3 +188,234 @@ func TestGzipReader_NoGzip(t *testing.T) {
 		t.Errorf("Expected response body to be %s, got %s", expected, body)
 	}
 }
+
+func TestGzipLevel(t *testing.T) {
+	payload := strings.Repeat(`{"url":"https://example.com/some/long/path"}`, 100)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(payload))
+	})
+
+	tests := []struct {
+		name  string
+		level int
+	}{
+		{"Best speed", gzip.BestSpeed},
+		{"Best compression", gzip.BestCompression},
+		{"Default compression", gzip.DefaultCompression},
+		{"No compression", gzip.NoCompression},
+		{"Huffman only", gzip.HuffmanOnly},
+		{"Too high falls back", 10},
+		{"Too low falls back", -3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			rec := httptest.NewRecorder()
+
+			GzipLevel(tt.level)(handler).ServeHTTP(rec, req)
+
+			if rec.Header().Get("Content-Encoding") != "gzip" {
+				t.Fatalf("Expected Content-Encoding to be gzip, got %s", rec.Header().Get("Content-Encoding"))
+			}
+
+			reader, err := gzip.NewReader(rec.Body)
+			if err != nil {
+				t.Fatalf("Failed to create gzip reader: %v", err)
+			}
+			defer reader.Close()
+
+			body, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("Failed to read gzipped response: %v", err)
+			}
+			if string(body) != payload {
+				t.Errorf("Expected the original payload back, got %d bytes", len(body))
+			}
+		})
+	}
+}
+
+func TestValidGzipLevel(t *testing.T) {
+	for level := -3; level <= 10; level++ {
+		want := level >= gzip.HuffmanOnly && level <= gzip.BestCompression
+		if got := ValidGzipLevel(level); got != want {
+			t.Errorf("ValidGzipLevel(%d) = %v, want %v", level, got, want)
+		}
+	}
+}
+
+func TestGzipMiddleware_Brotli(t *testing.T) {
+	payload := `{"message":"Hello, Yandex!"}`
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(payload))
+	})
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		wantEncoding   string
+	}{
+		{"Brotli preferred", "br;q=1.0, gzip;q=0.8", "br"},
+		{"Brotli wins ties", "gzip, deflate, br", "br"},
+		{"Gzip preferred", "br;q=0.5, gzip", "gzip"},
+		{"Gzip only", "gzip", "gzip"},
+		{"Brotli refused", "br;q=0, *", "gzip"},
+		{"Wildcard", "*", "br"},
+		{"Identity", "identity", ""},
+		{"None", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+
+			GzipMiddleware(handler).ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Fatalf("Expected Content-Encoding %q, got %q", tt.wantEncoding, got)
+			}
+			if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("Expected Vary Accept-Encoding, got %q", got)
+			}
+
+			var reader io.Reader = rec.Body
+			switch tt.wantEncoding {
+			case "br":
+				reader = brotli.NewReader(rec.Body)
+			case "gzip":
+				gz, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatalf("Failed to create gzip reader: %v", err)
+				}
+				defer gz.Close()
+				reader = gz
+			}
+
+			body, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("Failed to read response: %v", err)
+			}
+			if string(body) != payload {
+				t.Errorf("Expected response body to be %s, got %s", payload, string(body))
+			}
+		})
+	}
+}
+
+func TestGzipMiddleware_Vary(t *testing.T) {
+	jsonHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	})
+	imageHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png"))
+	})
+	asset, err := NewStaticAsset(http.StatusOK, "application/json", []byte(`{}`), true)
+	if err != nil {
+		t.Fatalf("NewStaticAsset() error = %v", err)
+	}
+	corsVary := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		asset.ServeHTTP(w, r)
+	})
+
+	tests := []struct {
+		name           string
+		handler        http.Handler
+		acceptEncoding string
+		wantEncoding   string
+		wantVary       []string
+	}{
+		{"Compressed", jsonHandler, "gzip", "gzip", []string{"Accept-Encoding"}},
+		{"Not accepted", jsonHandler, "", "", []string{"Accept-Encoding"}},
+		{"Not compressible", imageHandler, "gzip", "", []string{"Accept-Encoding"}},
+		{"Precompressed asset", asset, "gzip", "gzip", []string{"Accept-Encoding"}},
+		{"Earlier Vary kept", corsVary, "gzip", "gzip", []string{"Accept-Encoding", "Origin"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+
+			GzipMiddleware(tt.handler).ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("Expected Content-Encoding %q, got %q", tt.wantEncoding, got)
+			}
+			if got := rec.Header().Values("Vary"); strings.Join(got, ",") != strings.Join(tt.wantVary, ",") {
+				t.Errorf("Expected Vary %v, got %v", tt.wantVary, got)
+			}
+		})
+	}
+}
+
+func TestGzipMiddleware_Streams(t *testing.T) {
+	tests := []struct {
+		name         string
+		contentType  string
+		wantEncoding string
+	}{
+		{name: "not compressible", contentType: "application/x-ndjson", wantEncoding: ""},
+		{name: "compressible", contentType: "application/json", wantEncoding: "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.Write([]byte("first\n"))
+				w.(http.Flusher).Flush()
+				<-release
+				w.Write([]byte("second\n"))
+			})
+
+			server := httptest.NewServer(GzipMiddleware(handler))
+			defer server.Close()
+			defer close(release)
+
+			req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Failed to send request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if got := resp.Header.Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("Expected Content-Encoding %q, got %q", tt.wantEncoding, got)
+			}
+
+			var body io.Reader = resp.Body
+			if tt.wantEncoding == "gzip" {
+				reader, err := gzip.NewReader(resp.Body)
+				if err != nil {
+					t.Fatalf("Failed to create gzip reader: %v", err)
+				}
+				body = reader
+			}
+
+			// The first line arrives while the handler still holds back the rest.
+			first := make([]byte, len("first\n"))
+			if _, err := io.ReadFull(body, first); err != nil {
+				t.Fatalf("Failed to read the first line: %v", err)
+			}
+			if string(first) != "first\n" {
+				t.Errorf("Expected the first line, got %q", first)
+			}
+		})
+	}
+}
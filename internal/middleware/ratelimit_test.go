@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := NewRateLimiter(RateLimit{Rate: 1, Burst: 2})
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := limiter.Allow("a"); !ok {
+			t.Fatalf("Expected request %d within burst to be allowed", i+1)
+		}
+	}
+
+	ok, wait := limiter.Allow("a")
+	if ok {
+		t.Fatal("Expected request over burst to be rejected")
+	}
+	if wait != time.Second {
+		t.Errorf("Expected wait of 1s, got %v", wait)
+	}
+
+	if ok, _ := limiter.Allow("b"); !ok {
+		t.Error("Expected another key to have its own bucket")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := limiter.Allow("a"); ok {
+		t.Error("Expected request before refill to be rejected")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := limiter.Allow("a"); !ok {
+		t.Error("Expected request after refill to be allowed")
+	}
+}
+
+func TestRateLimiter_Sweep(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := NewRateLimiter(RateLimit{Rate: 1, Burst: 1})
+	limiter.now = func() time.Time { return now }
+
+	limiter.Allow("a")
+	now = now.Add(2 * rateLimitSweepInterval)
+	limiter.Allow("b")
+
+	if _, ok := limiter.buckets["a"]; ok {
+		t.Error("Expected idle bucket to be swept")
+	}
+	if _, ok := limiter.buckets["b"]; !ok {
+		t.Error("Expected active bucket to be kept")
+	}
+}
+
+func TestRateLimiter_Middleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		limiter    *RateLimiter
+		requests   int
+		ctx        func(ctx context.Context, i int) context.Context
+		remoteAddr func(i int) string
+		wantStatus int
+	}{
+		{
+			name:       "same IP over limit",
+			limiter:    NewRateLimiter(RateLimit{Rate: 0.001, Burst: 3}),
+			requests:   4,
+			remoteAddr: func(int) string { return "192.0.2.1:1234" },
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:       "same IP different ports",
+			limiter:    NewRateLimiter(RateLimit{Rate: 0.001, Burst: 3}),
+			requests:   4,
+			remoteAddr: func(i int) string { return "192.0.2.1:" + string(rune('1'+i)) },
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:       "different IPs",
+			limiter:    NewRateLimiter(RateLimit{Rate: 0.001, Burst: 3}),
+			requests:   4,
+			remoteAddr: func(i int) string { return "192.0.2." + string(rune('1'+i)) + ":1234" },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:     "existing users share an IP",
+			limiter:  NewRateLimiter(RateLimit{Rate: 0.001, Burst: 3}),
+			requests: 4,
+			ctx: func(ctx context.Context, i int) context.Context {
+				return context.WithValue(ctx, UserIDKey, "user"+string(rune('1'+i)))
+			},
+			remoteAddr: func(int) string { return "192.0.2.1:1234" },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:     "new users are keyed by IP",
+			limiter:  NewRateLimiter(RateLimit{Rate: 0.001, Burst: 3}),
+			requests: 4,
+			ctx: func(ctx context.Context, i int) context.Context {
+				ctx = context.WithValue(ctx, UserIDKey, "user"+string(rune('1'+i)))
+				return context.WithValue(ctx, newUserKey, true)
+			},
+			remoteAddr: func(int) string { return "192.0.2.1:1234" },
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:       "nil limiter",
+			limiter:    nil,
+			requests:   10,
+			remoteAddr: func(int) string { return "192.0.2.1:1234" },
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := tt.limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			var rec *httptest.ResponseRecorder
+			for i := 0; i < tt.requests; i++ {
+				req := httptest.NewRequest(http.MethodPost, "/api/shorten", nil)
+				req.RemoteAddr = tt.remoteAddr(i)
+				if tt.ctx != nil {
+					req = req.WithContext(tt.ctx(req.Context(), i))
+				}
+				rec = httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+			}
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status code %d on the last request, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantStatus == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+				t.Error("Expected a Retry-After header")
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"runtime/debug"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog/log"
+)
+
+// internalErrorBody is the response to a recovered panic. It matches the JSON
+// error envelope of the API handlers and carries no details of the panic.
+const internalErrorBody = `{"error":{"code":"internal_error","message":"internal server error"}}`
+
+// Recoverer recovers panics in later handlers. The panic value and stack are
+// logged with the request ID, and the client gets a JSON 500 response without
+// them. http.ErrAbortHandler is re-panicked so the server aborts the response
+// as usual.
+func Recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rvr := recover()
+			if rvr == nil {
+				return
+			}
+			if err, ok := rvr.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(rvr)
+			}
+
+			log.Error().
+				Str("request_id", chimiddleware.GetReqID(r.Context())).
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Interface("panic", rvr).
+				Bytes("stack", debug.Stack()).
+				Msg("Recovered from panic")
+
+			if r.Header.Get("Connection") == "Upgrade" {
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			if _, err := w.Write([]byte(internalErrorBody)); err != nil {
+				log.Debug().Err(err).Msg("Failed to write panic response")
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func TestRecoverer(t *testing.T) {
+	var logs bytes.Buffer
+	previous := log.Logger
+	log.Logger = zerolog.New(&logs)
+	t.Cleanup(func() { log.Logger = previous })
+
+	handler := chimiddleware.RequestID(Recoverer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	req.Header.Set("X-Request-Id", "req-42")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, rec.Code)
+	}
+	if contentType := rec.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Expected Content-Type application/json, got %q", contentType)
+	}
+
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Expected JSON body, got %q: %v", rec.Body.String(), err)
+	}
+	if body.Error.Code != "internal_error" {
+		t.Errorf("Expected error code internal_error, got %q", body.Error.Code)
+	}
+	if strings.Contains(rec.Body.String(), "boom") || strings.Contains(rec.Body.String(), "goroutine") {
+		t.Errorf("Expected no panic details in the response, got %q", rec.Body.String())
+	}
+
+	var entry struct {
+		Level     string `json:"level"`
+		RequestID string `json:"request_id"`
+		Panic     string `json:"panic"`
+		Stack     string `json:"stack"`
+	}
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected one JSON log entry, got %q: %v", logs.String(), err)
+	}
+	if entry.Level != "error" {
+		t.Errorf("Expected level error, got %q", entry.Level)
+	}
+	if entry.RequestID != "req-42" {
+		t.Errorf("Expected request ID req-42, got %q", entry.RequestID)
+	}
+	if entry.Panic != "boom" {
+		t.Errorf("Expected panic value boom, got %q", entry.Panic)
+	}
+	if !strings.Contains(entry.Stack, "goroutine") {
+		t.Errorf("Expected a stack trace in the log, got %q", entry.Stack)
+	}
+}
+
+func TestRecoverer_AbortHandler(t *testing.T) {
+	handler := Recoverer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		rvr := recover()
+		err, ok := rvr.(error)
+		if !ok || !errors.Is(err, http.ErrAbortHandler) {
+			t.Errorf("Expected http.ErrAbortHandler to be re-panicked, got %v", rvr)
+		}
+	}()
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
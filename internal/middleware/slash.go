@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// APIPrefix is the path prefix of the JSON API routes.
+const APIPrefix = "/api/"
+
+// TrimAPISlashes routes API paths ending in slashes as if the slashes were
+// absent, so POST /api/shorten/ reaches /api/shorten without a redirect that
+// would turn the POST into a GET. Other paths are left alone: a short URL
+// such as /abc123/ is not rewritten into a redirect for abc123, and routes
+// that need their trailing slash, such as /debug/pprof/, keep it.
+func TrimAPISlashes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rctx := chi.RouteContext(r.Context())
+
+		path := r.URL.Path
+		if rctx != nil && rctx.RoutePath != "" {
+			path = rctx.RoutePath
+		}
+
+		if strings.HasPrefix(path, APIPrefix) && strings.HasSuffix(path, "/") {
+			trimmed := strings.TrimRight(path, "/")
+			if rctx != nil {
+				rctx.RoutePath = trimmed
+			} else {
+				r.URL.Path = trimmed
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
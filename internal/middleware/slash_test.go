@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestTrimAPISlashes(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(TrimAPISlashes)
+	r.Post("/api/shorten", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("shorten")) })
+	r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("id " + chi.URLParam(r, "id"))) })
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "API route", method: http.MethodPost, path: "/api/shorten", wantStatus: http.StatusOK, wantBody: "shorten"},
+		{name: "API route with slash", method: http.MethodPost, path: "/api/shorten/", wantStatus: http.StatusOK, wantBody: "shorten"},
+		{name: "API route with slashes", method: http.MethodPost, path: "/api/shorten//", wantStatus: http.StatusOK, wantBody: "shorten"},
+		{name: "short ID", method: http.MethodGet, path: "/abc123", wantStatus: http.StatusOK, wantBody: "id abc123"},
+		{name: "short ID with slash", method: http.MethodGet, path: "/abc123/", wantStatus: http.StatusNotFound},
+		{name: "short ID named api", method: http.MethodGet, path: "/api", wantStatus: http.StatusOK, wantBody: "id api"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			rec := httptest.NewRecorder()
+
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status code %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("Expected body %q, got %q", tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
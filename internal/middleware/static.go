@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// StaticAsset serves a fixed response whose gzip encoding is computed once.
+// Responses already carry Content-Encoding, so GzipMiddleware passes them through.
+type StaticAsset struct {
+	statusCode  int
+	contentType string
+	body        []byte
+	gzipped     []byte
+}
+
+// NewStaticAsset creates a StaticAsset. When precompress is true the body is
+// gzipped up front and served to clients that accept gzip.
+func NewStaticAsset(statusCode int, contentType string, body []byte, precompress bool) (*StaticAsset, error) {
+	asset := &StaticAsset{
+		statusCode:  statusCode,
+		contentType: contentType,
+		body:        body,
+	}
+
+	if precompress {
+		var buf bytes.Buffer
+		gz, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip writer: %w", err)
+		}
+		if _, err := gz.Write(body); err != nil {
+			return nil, fmt.Errorf("failed to compress static asset: %w", err)
+		}
+		if err := gz.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress static asset: %w", err)
+		}
+		asset.gzipped = buf.Bytes()
+	}
+
+	return asset, nil
+}
+
+// ServeHTTP writes the precompressed body when accepted, otherwise the raw body.
+func (a *StaticAsset) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", a.contentType)
+
+	body := a.body
+	if a.gzipped != nil {
+		addVary(w.Header(), "Accept-Encoding")
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			body = a.gzipped
+		}
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(a.statusCode)
+	w.Write(body)
+}
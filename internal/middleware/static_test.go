@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStaticAsset_Precompressed(t *testing.T) {
+	body := []byte(`{"openapi":"3.0.0","info":{"title":"URL Shortener"}}`)
+
+	asset, err := NewStaticAsset(http.StatusOK, "application/json", body, true)
+	if err != nil {
+		t.Fatalf("NewStaticAsset() error = %v", err)
+	}
+
+	var calls int
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		asset.ServeHTTP(w, r)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+
+	GzipMiddleware(next).ServeHTTP(rec, req)
+
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected Content-Encoding to be gzip, got %s", rec.Header().Get("Content-Encoding"))
+	}
+
+	if !bytes.Equal(rec.Body.Bytes(), asset.gzipped) {
+		t.Errorf("Expected response body to be the precomputed gzip bytes")
+	}
+
+	reader, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("Failed to create gzip reader: %v", err)
+	}
+	defer reader.Close()
+
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to read gzipped response: %v", err)
+	}
+
+	if !bytes.Equal(decoded, body) {
+		t.Errorf("Expected decoded body %s, got %s", body, decoded)
+	}
+
+	if calls != 1 {
+		t.Errorf("Expected asset to be served once, got %d", calls)
+	}
+}
+
+func TestStaticAsset_NoGzip(t *testing.T) {
+	body := []byte("404 page not found\n")
+
+	asset, err := NewStaticAsset(http.StatusNotFound, "text/plain", body, true)
+	if err != nil {
+		t.Fatalf("NewStaticAsset() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/missing/path", nil)
+	rec := httptest.NewRecorder()
+
+	asset.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	if rec.Header().Get("Content-Encoding") != "" {
+		t.Errorf("Expected no Content-Encoding, got %s", rec.Header().Get("Content-Encoding"))
+	}
+
+	if rec.Body.String() != string(body) {
+		t.Errorf("Expected body %q, got %q", body, rec.Body.String())
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+)
+
+// TrustedSubnet allows requests only from clients within subnet.
+// The client IP is the remote address; behind a reverse proxy, chi's RealIP
+// must run first to replace it with the address from X-Real-IP.
+// A nil subnet forbids all requests.
+func TrustedSubnet(subnet *net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !InSubnet(r, subnet) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// InSubnet reports whether the client of r is within subnet, using the same
+// rules as TrustedSubnet. A nil subnet contains no clients.
+func InSubnet(r *http.Request, subnet *net.IPNet) bool {
+	if subnet == nil {
+		return false
+	}
+
+	ip := clientIP(r)
+	return ip != nil && subnet.Contains(ip)
+}
+
+func clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/MikhailRaia/url-shortener/internal/tenant"
+)
+
+// TenantResolver stores the request tenant in context.
+// If header is non-empty, the tenant is taken from that header (e.g. an API key);
+// otherwise it is derived from the request host without port.
+func TenantResolver(header string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var tenantID string
+			if header != "" {
+				tenantID = strings.TrimSpace(r.Header.Get(header))
+			} else {
+				tenantID = hostWithoutPort(r.Host)
+			}
+
+			ctx := tenant.WithID(r.Context(), tenantID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func hostWithoutPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
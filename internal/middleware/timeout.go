@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+)
+
+// Timeout bounds request handling to timeout. The request context is
+// cancelled at the deadline, and if the handler has not finished by then the
+// client gets 503 Service Unavailable while the handler's late output is
+// discarded. A non-positive timeout leaves requests unbounded.
+func Timeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if timeout <= 0 {
+			return next
+		}
+
+		return http.TimeoutHandler(next, timeout, "request timed out")
+	}
+}
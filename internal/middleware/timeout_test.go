@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		name       string
+		timeout    time.Duration
+		delay      time.Duration
+		wantStatus int
+	}{
+		{name: "fast handler", timeout: time.Second, delay: 0, wantStatus: http.StatusOK},
+		{name: "slow handler", timeout: 20 * time.Millisecond, delay: time.Second, wantStatus: http.StatusServiceUnavailable},
+		{name: "disabled", timeout: 0, delay: 30 * time.Millisecond, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Timeout(tt.timeout)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(tt.delay):
+					w.WriteHeader(http.StatusOK)
+				case <-r.Context().Done():
+				}
+			}))
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status code %d, got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
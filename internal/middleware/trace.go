@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/MikhailRaia/url-shortener/internal/trace"
+)
+
+// Trace stores the request trace ID in context and echoes it in the
+// X-Trace-ID response header. The ID is taken from an incoming traceparent
+// header so a request can be followed across services; otherwise a new one
+// is generated.
+func Trace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceID, ok := trace.ParseTraceparent(r.Header.Get(trace.Header))
+		if !ok {
+			traceID = trace.NewID()
+		}
+
+		w.Header().Set(trace.ResponseHeader, traceID)
+		next.ServeHTTP(w, r.WithContext(trace.WithID(r.Context(), traceID)))
+	})
+}
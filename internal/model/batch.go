@@ -11,3 +11,19 @@ type BatchResponseItem struct {
 	CorrelationID string `json:"correlation_id"`
 	ShortURL      string `json:"short_url"`
 }
+
+// BatchSaveResult is the short ID a storage saved a batch item under and
+// whether the item created it or the URL was already stored.
+type BatchSaveResult struct {
+	ID      string
+	Created bool
+}
+
+// ExpandedURL is a short ID resolved by a batch expansion. OriginalURL is
+// empty for unknown IDs and for URLs that were deleted or have expired.
+type ExpandedURL struct {
+	ID          string `json:"id"`
+	OriginalURL string `json:"original_url"`
+	Deleted     bool   `json:"deleted"`
+	Expired     bool   `json:"expired,omitempty"`
+}
@@ -1,14 +1,60 @@
 package model
 
+import "time"
+
 // URL represents a stored shortened URL with owner information.
 type URL struct {
 	ID          string
 	OriginalURL string
 	UserID      string
+	CreatedAt   time.Time
 }
 
 // UserURL is the external representation returned in API responses.
 type UserURL struct {
 	ShortURL    string `json:"short_url"`
 	OriginalURL string `json:"original_url"`
+	// CreatedAt is when the short URL was created, in UTC. It is zero, and
+	// omitted, for URLs stored before creation times were recorded.
+	CreatedAt time.Time `json:"created_at,omitzero"`
+}
+
+// UserURLOrder is the order a user's URLs are listed in.
+type UserURLOrder string
+
+// Orders of user URL listings.
+const (
+	// NewestFirst lists the most recently created URLs first.
+	NewestFirst UserURLOrder = "newest"
+	// OldestFirst lists URLs in the order they were created.
+	OldestFirst UserURLOrder = "oldest"
+)
+
+// ShortenResult describes a URL shortened by the service.
+type ShortenResult struct {
+	ShortURL string
+	// OriginalURL is the URL as stored, after any normalization.
+	OriginalURL string
+	// CreatedAt is when the short URL was created. It is zero when the URL
+	// had already been shortened.
+	CreatedAt time.Time
+}
+
+// URLStats holds redirect statistics for a short URL.
+type URLStats struct {
+	Visits int64
+	// LastVisited is the time of the latest recorded visit, zero if never visited.
+	LastVisited time.Time
+}
+
+// Creator identifies the client that created a short URL, kept for abuse
+// investigations when creator capture is enabled.
+type Creator struct {
+	IP        string `json:"ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+}
+
+// IsZero reports whether nothing is known about the creator.
+func (c Creator) IsZero() bool {
+	return c.IP == "" && c.UserAgent == ""
 }
@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 // URLRecord is a persisted record used by file storage implementation.
 type URLRecord struct {
 	UUID        string `json:"uuid"`
@@ -7,4 +9,20 @@ type URLRecord struct {
 	OriginalURL string `json:"original_url"`
 	UserID      string `json:"user_id"`
 	IsDeleted   bool   `json:"is_deleted"`
+	TenantID    string `json:"tenant_id,omitempty"`
+	// PasswordHash is the bcrypt hash gating the short URL, if any.
+	PasswordHash string `json:"password_hash,omitempty"`
+	// ExpiresAt is when the short URL expires. A zero time clears an earlier
+	// expiry; nil leaves it unchanged.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// ForwardQuery is whether redirects forward their query string to the
+	// original URL; nil leaves an earlier setting unchanged.
+	ForwardQuery *bool `json:"forward_query,omitempty"`
+	// Creator is the client that created the short URL, if it was captured;
+	// nil leaves an earlier value unchanged.
+	Creator *Creator `json:"creator,omitempty"`
+	// CreatedAt is when the short URL was created. It is only set on the
+	// record creating the URL, and is missing from records written before
+	// creation times were recorded.
+	CreatedAt *time.Time `json:"created_at,omitempty"`
 }
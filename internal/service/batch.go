@@ -0,0 +1,70 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/MikhailRaia/url-shortener/internal/model"
+)
+
+// Batch validation errors.
+var (
+	// ErrBatchTooLarge indicates the batch has more items than allowed.
+	ErrBatchTooLarge = errors.New("batch too large")
+	// ErrInvalidBatchItem indicates a batch item has an empty or malformed URL,
+	// or a missing or duplicate correlation ID.
+	ErrInvalidBatchItem = errors.New("invalid batch item")
+)
+
+// ValidateBatch checks a batch before it is shortened. It rejects batches with
+// more than maxSize items (a non-positive maxSize disables the cap), items
+// without a correlation ID, repeated correlation IDs and URLs that are not
+// absolute. Errors wrap ErrBatchTooLarge or ErrInvalidBatchItem and name the
+// offending item.
+func ValidateBatch(items []model.BatchRequestItem, maxSize int) error {
+	if maxSize > 0 && len(items) > maxSize {
+		return fmt.Errorf("%w: %d items, at most %d allowed", ErrBatchTooLarge, len(items), maxSize)
+	}
+
+	seen := make(map[string]struct{}, len(items))
+	for i, item := range items {
+		if item.CorrelationID == "" {
+			return fmt.Errorf("%w: item %d: correlation_id is required", ErrInvalidBatchItem, i)
+		}
+		if _, ok := seen[item.CorrelationID]; ok {
+			return fmt.Errorf("%w: item %d: duplicate correlation_id %q", ErrInvalidBatchItem, i, item.CorrelationID)
+		}
+		seen[item.CorrelationID] = struct{}{}
+
+		if item.OriginalURL == "" {
+			return fmt.Errorf("%w: item %d: original_url is required", ErrInvalidBatchItem, i)
+		}
+		if !wellFormedURL(item.OriginalURL) {
+			return fmt.Errorf("%w: item %d: original_url is not a valid absolute URL", ErrInvalidBatchItem, i)
+		}
+	}
+
+	return nil
+}
+
+// checkCorrelationIDs rejects batches repeating a correlation ID. Batch
+// results are keyed by correlation ID, so a repeat would silently drop an item
+// from the response.
+func checkCorrelationIDs(items []model.BatchRequestItem) error {
+	seen := make(map[string]struct{}, len(items))
+	for i, item := range items {
+		if _, ok := seen[item.CorrelationID]; ok {
+			return fmt.Errorf("%w: item %d: duplicate correlation_id %q", ErrInvalidBatchItem, i, item.CorrelationID)
+		}
+		seen[item.CorrelationID] = struct{}{}
+	}
+
+	return nil
+}
+
+// wellFormedURL reports whether rawURL parses as an absolute URL with a host.
+func wellFormedURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
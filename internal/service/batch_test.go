@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/storage/memory"
+)
+
+func TestValidateBatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		items   []model.BatchRequestItem
+		maxSize int
+		wantErr error
+	}{
+		{
+			name: "valid batch",
+			items: []model.BatchRequestItem{
+				{CorrelationID: "1", OriginalURL: "https://example.com"},
+				{CorrelationID: "2", OriginalURL: "http://example.org/path?q=1"},
+			},
+			maxSize: 2,
+		},
+		{
+			name: "cap disabled",
+			items: []model.BatchRequestItem{
+				{CorrelationID: "1", OriginalURL: "https://example.com"},
+				{CorrelationID: "2", OriginalURL: "https://example.org"},
+			},
+		},
+		{
+			name: "too large",
+			items: []model.BatchRequestItem{
+				{CorrelationID: "1", OriginalURL: "https://example.com"},
+				{CorrelationID: "2", OriginalURL: "https://example.org"},
+			},
+			maxSize: 1,
+			wantErr: ErrBatchTooLarge,
+		},
+		{
+			name:    "empty URL",
+			items:   []model.BatchRequestItem{{CorrelationID: "1"}},
+			wantErr: ErrInvalidBatchItem,
+		},
+		{
+			name:    "missing correlation ID",
+			items:   []model.BatchRequestItem{{OriginalURL: "https://example.com"}},
+			wantErr: ErrInvalidBatchItem,
+		},
+		{
+			name: "duplicate correlation ID",
+			items: []model.BatchRequestItem{
+				{CorrelationID: "1", OriginalURL: "https://example.com"},
+				{CorrelationID: "1", OriginalURL: "https://example.org"},
+			},
+			wantErr: ErrInvalidBatchItem,
+		},
+		{
+			name:    "relative URL",
+			items:   []model.BatchRequestItem{{CorrelationID: "1", OriginalURL: "/path"}},
+			wantErr: ErrInvalidBatchItem,
+		},
+		{
+			name:    "unparsable URL",
+			items:   []model.BatchRequestItem{{CorrelationID: "1", OriginalURL: "http://[::1"}},
+			wantErr: ErrInvalidBatchItem,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateBatch(tt.items, tt.maxSize)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestURLService_ShortenBatchDuplicateCorrelationID(t *testing.T) {
+	items := []model.BatchRequestItem{
+		{CorrelationID: "1", OriginalURL: "https://example.com/a"},
+		{CorrelationID: "2", OriginalURL: "https://example.com/b"},
+		{CorrelationID: "1", OriginalURL: "https://example.com/c"},
+	}
+
+	for _, withUser := range []bool{false, true} {
+		urlStorage := memory.NewStorage()
+		svc := NewURLService(urlStorage, "http://localhost:8080")
+
+		var (
+			result []model.BatchResponseItem
+			err    error
+		)
+		if withUser {
+			result, err = svc.ShortenBatchWithUser(context.Background(), items, "user1")
+		} else {
+			result, err = svc.ShortenBatch(context.Background(), items)
+		}
+
+		if !errors.Is(err, ErrInvalidBatchItem) {
+			t.Errorf("withUser=%v: Expected ErrInvalidBatchItem, got %v", withUser, err)
+		}
+		if result != nil {
+			t.Errorf("withUser=%v: Expected no result, got %v", withUser, result)
+		}
+		if count, _ := urlStorage.Count(); count != 0 {
+			t.Errorf("withUser=%v: Expected nothing stored, got %d URLs", withUser, count)
+		}
+	}
+}
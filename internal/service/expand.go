@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/storage"
+)
+
+// ExpandBatch resolves short IDs in the tenant of ctx, in the order given.
+// Unknown IDs, and URLs that were deleted or have expired, are returned with
+// an empty original URL. Storages that support it are queried once for all
+// IDs; others are queried per ID.
+func (s *URLService) ExpandBatch(ctx context.Context, ids []string) ([]model.ExpandedURL, error) {
+	urlStorage := s.storageFor(ctx)
+
+	found, err := getBatch(urlStorage, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.ExpandedURL, len(ids))
+	for i, id := range ids {
+		url, ok := found[id]
+		if !ok {
+			result[i] = model.ExpandedURL{ID: id}
+			continue
+		}
+		if url.Deleted || url.Expired {
+			url.OriginalURL = ""
+		}
+		result[i] = url
+	}
+
+	return result, nil
+}
+
+// getBatch looks up ids with a single batched query where the storage
+// supports it, and one lookup per ID otherwise.
+func getBatch(urlStorage storage.URLStorage, ids []string) (map[string]model.ExpandedURL, error) {
+	if getter, ok := urlStorage.(storage.BatchGetter); ok {
+		found, err := getter.GetBatch(ids)
+		if !errors.Is(err, storage.ErrUnsupported) {
+			return found, err
+		}
+	}
+
+	found := make(map[string]model.ExpandedURL, len(ids))
+	for _, id := range ids {
+		originalURL, err := urlStorage.GetWithDeletedStatus(id)
+		switch {
+		case errors.Is(err, storage.ErrURLDeleted):
+			found[id] = model.ExpandedURL{ID: id, Deleted: true}
+		case errors.Is(err, storage.ErrURLExpired):
+			found[id] = model.ExpandedURL{ID: id, Expired: true}
+		case err != nil:
+			return nil, err
+		case originalURL != "":
+			found[id] = model.ExpandedURL{ID: id, OriginalURL: originalURL}
+		}
+	}
+
+	return found, nil
+}
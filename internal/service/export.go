@@ -0,0 +1,29 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/storage"
+)
+
+// ErrExportUnsupported indicates the storage cannot export URLs.
+var ErrExportUnsupported = errors.New("storage does not support exporting URLs")
+
+// ExportURLs calls fn with every URL stored in the tenant of ctx, deleted ones
+// included, in a form ImportURLs accepts. It stops at the first error fn
+// returns and returns that error.
+func (s *URLService) ExportURLs(ctx context.Context, fn func(model.URLRecord) error) error {
+	exporter, ok := s.storageFor(ctx).(storage.Exporter)
+	if !ok {
+		return ErrExportUnsupported
+	}
+
+	err := exporter.ExportURLs(fn)
+	if errors.Is(err, storage.ErrUnsupported) {
+		return ErrExportUnsupported
+	}
+
+	return err
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/MikhailRaia/url-shortener/internal/generator"
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/storage"
+)
+
+// Import errors.
+var (
+	// ErrInvalidImportRecord indicates an imported record has a missing,
+	// malformed or repeated short ID, or an empty or malformed URL.
+	ErrInvalidImportRecord = errors.New("invalid import record")
+	// ErrImportUnsupported indicates the storage cannot import URLs.
+	ErrImportUnsupported = errors.New("storage does not support importing URLs")
+)
+
+// ImportURLs stores records under the short IDs they carry, within the tenant
+// of ctx, e.g. when migrating from another shortener. Records are validated
+// as a whole before anything is stored; errors wrap ErrInvalidImportRecord and
+// name the offending record. URLs are stored as given, without normalization,
+// so that imported short IDs keep resolving to exactly the same URLs.
+//
+// It returns the number of records stored, counting records that already were,
+// and the short IDs skipped because their ID or URL is stored with a different
+// counterpart.
+func (s *URLService) ImportURLs(ctx context.Context, records []model.URLRecord) (int, []string, error) {
+	if err := validateImport(records); err != nil {
+		return 0, nil, err
+	}
+
+	st := s.storageFor(ctx)
+	if _, ok := st.(storage.Importer); !ok {
+		return 0, nil, ErrImportUnsupported
+	}
+
+	// Conflicts are kept out of the transaction's error, so that the records
+	// that did not conflict are committed.
+	var conflictErr *storage.ImportConflictError
+	err := inTx(ctx, st, func(st storage.URLStorage) error {
+		err := st.(storage.Importer).ImportURLs(records)
+		if errors.As(err, &conflictErr) {
+			return nil
+		}
+		return err
+	})
+	if errors.Is(err, storage.ErrUnsupported) {
+		return 0, nil, ErrImportUnsupported
+	}
+	if err != nil {
+		return 0, nil, fmt.Errorf("error importing URLs: %w", err)
+	}
+	if conflictErr != nil {
+		return len(records) - len(conflictErr.ShortIDs), conflictErr.ShortIDs, nil
+	}
+
+	return len(records), nil, nil
+}
+
+// validateImport checks that every record has a valid short ID of at most
+// MaxAliasLength characters, unique within records, and an absolute URL.
+func validateImport(records []model.URLRecord) error {
+	seen := make(map[string]struct{}, len(records))
+	for i, record := range records {
+		if len(record.ShortURL) > MaxAliasLength || !generator.ValidID(record.ShortURL) {
+			return fmt.Errorf("%w: record %d: short ID must be 1 to %d letters, digits, '-' or '_'", ErrInvalidImportRecord, i, MaxAliasLength)
+		}
+		if _, ok := seen[record.ShortURL]; ok {
+			return fmt.Errorf("%w: record %d: duplicate short ID %q", ErrInvalidImportRecord, i, record.ShortURL)
+		}
+		seen[record.ShortURL] = struct{}{}
+
+		if !wellFormedURL(record.OriginalURL) {
+			return fmt.Errorf("%w: record %d: original URL is not a valid absolute URL", ErrInvalidImportRecord, i)
+		}
+	}
+
+	return nil
+}
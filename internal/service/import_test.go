@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/storage"
+	"github.com/MikhailRaia/url-shortener/internal/storage/file"
+	"github.com/MikhailRaia/url-shortener/internal/storage/memory"
+)
+
+func TestURLService_ImportURLs(t *testing.T) {
+	urlStorage, err := file.NewStorage(filepath.Join(t.TempDir(), "urls.json"))
+	if err != nil {
+		t.Fatalf("file.NewStorage() error = %v", err)
+	}
+	defer urlStorage.Close()
+
+	service := NewURLService(urlStorage, "http://localhost:8080")
+	ctx := context.Background()
+
+	imported, conflicts, err := service.ImportURLs(ctx, []model.URLRecord{
+		{ShortURL: "abc", OriginalURL: "https://a.example.com"},
+		{ShortURL: "def", OriginalURL: "https://b.example.com"},
+	})
+	if err != nil || imported != 2 || len(conflicts) != 0 {
+		t.Fatalf("URLService.ImportURLs() = %v, %v, %v, want 2, [], nil", imported, conflicts, err)
+	}
+
+	imported, conflicts, err = service.ImportURLs(ctx, []model.URLRecord{
+		{ShortURL: "abc", OriginalURL: "https://other.example.com"},
+		{ShortURL: "ghi", OriginalURL: "https://c.example.com"},
+	})
+	if err != nil || imported != 1 || !reflect.DeepEqual(conflicts, []string{"abc"}) {
+		t.Errorf("URLService.ImportURLs() with conflict = %v, %v, %v, want 1, [abc], nil", imported, conflicts, err)
+	}
+
+	if got, found := service.GetOriginalURL(ctx, "abc"); !found || got != "https://a.example.com" {
+		t.Errorf("URLService.GetOriginalURL(abc) = %v, %v, want %v, true", got, found, "https://a.example.com")
+	}
+}
+
+// txRecordingStorage records the errors its transactions ended with.
+type txRecordingStorage struct {
+	*file.Storage
+	txErrs []error
+}
+
+func (s *txRecordingStorage) WithTx(ctx context.Context, fn func(storage.URLStorage) error) error {
+	err := s.Storage.WithTx(ctx, fn)
+	s.txErrs = append(s.txErrs, err)
+	return err
+}
+
+func TestURLService_ImportURLsInTx(t *testing.T) {
+	urlStorage, err := file.NewStorage(filepath.Join(t.TempDir(), "urls.json"))
+	if err != nil {
+		t.Fatalf("file.NewStorage() error = %v", err)
+	}
+	defer urlStorage.Close()
+
+	st := &txRecordingStorage{Storage: urlStorage}
+	service := NewURLService(st, "http://localhost:8080")
+	ctx := context.Background()
+
+	if _, err := urlStorage.Save("https://taken.example.com"); err != nil {
+		t.Fatalf("Storage.Save() error = %v", err)
+	}
+
+	imported, conflicts, err := service.ImportURLs(ctx, []model.URLRecord{
+		{ShortURL: "abc", OriginalURL: "https://a.example.com"},
+		{ShortURL: "def", OriginalURL: "https://taken.example.com"},
+	})
+	if err != nil || imported != 1 || !reflect.DeepEqual(conflicts, []string{"def"}) {
+		t.Fatalf("URLService.ImportURLs() = %v, %v, %v, want 1, [def], nil", imported, conflicts, err)
+	}
+
+	// A transaction ending with the conflicts would roll back "abc" too.
+	if want := []error{nil}; !reflect.DeepEqual(st.txErrs, want) {
+		t.Errorf("Expected transactions to end with %v, got %v", want, st.txErrs)
+	}
+}
+
+func TestURLService_ImportURLs_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []model.URLRecord
+	}{
+		{name: "missing short ID", records: []model.URLRecord{{OriginalURL: "https://a.example.com"}}},
+		{name: "short ID with slash", records: []model.URLRecord{{ShortURL: "a/b", OriginalURL: "https://a.example.com"}}},
+		{name: "short ID too long", records: []model.URLRecord{{ShortURL: strings.Repeat("a", MaxAliasLength+1), OriginalURL: "https://a.example.com"}}},
+		{name: "relative URL", records: []model.URLRecord{{ShortURL: "abc", OriginalURL: "/path"}}},
+		{name: "duplicate short ID", records: []model.URLRecord{
+			{ShortURL: "abc", OriginalURL: "https://a.example.com"},
+			{ShortURL: "abc", OriginalURL: "https://b.example.com"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewURLService(memory.NewStorage(), "http://localhost:8080")
+			if _, _, err := service.ImportURLs(context.Background(), tt.records); !errors.Is(err, ErrInvalidImportRecord) {
+				t.Errorf("URLService.ImportURLs() error = %v, want %v", err, ErrInvalidImportRecord)
+			}
+		})
+	}
+
+	service := NewURLService(memory.NewStorage(), "http://localhost:8080")
+	records := []model.URLRecord{{ShortURL: "abc", OriginalURL: "https://a.example.com"}}
+	if _, _, err := service.ImportURLs(context.Background(), records); !errors.Is(err, ErrImportUnsupported) {
+		t.Errorf("URLService.ImportURLs() on memory storage error = %v, want %v", err, ErrImportUnsupported)
+	}
+}
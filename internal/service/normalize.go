@@ -0,0 +1,84 @@
+package service
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/MikhailRaia/url-shortener/internal/model"
+)
+
+// NormalizeOptions selects the optional URL normalization rules. Lowercasing
+// the scheme and host and dropping default ports always apply once
+// normalization is enabled.
+type NormalizeOptions struct {
+	StripTrailingSlash bool
+	StripFragment      bool
+}
+
+// defaultPorts maps schemes to the port implied when none is given.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Option configures optional URLService behavior.
+type Option func(*URLService)
+
+// WithNormalization makes the service normalize URLs before storing them, so
+// logically equal URLs share a short URL.
+func WithNormalization(opts NormalizeOptions) Option {
+	return func(s *URLService) {
+		s.normalize = &opts
+	}
+}
+
+// NormalizeURL returns rawURL with the scheme and host lowercased, a default
+// port removed and, depending on opts, the trailing slash and fragment
+// stripped. URLs that are not absolute are returned unchanged.
+func NormalizeURL(rawURL string, opts NormalizeOptions) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return rawURL
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+
+	if port := u.Port(); port != "" && port == defaultPorts[u.Scheme] {
+		u.Host = strings.TrimSuffix(u.Host, ":"+port)
+	}
+
+	if opts.StripTrailingSlash {
+		u.Path = strings.TrimRight(u.Path, "/")
+		u.RawPath = strings.TrimRight(u.RawPath, "/")
+	}
+
+	if opts.StripFragment {
+		u.Fragment = ""
+		u.RawFragment = ""
+	}
+
+	return u.String()
+}
+
+// normalizeURL applies the configured normalization, if any.
+func (s *URLService) normalizeURL(rawURL string) string {
+	if s.normalize == nil {
+		return rawURL
+	}
+	return NormalizeURL(rawURL, *s.normalize)
+}
+
+// normalizeBatch returns a copy of items with normalized original URLs.
+func (s *URLService) normalizeBatch(items []model.BatchRequestItem) []model.BatchRequestItem {
+	if s.normalize == nil {
+		return items
+	}
+
+	normalized := make([]model.BatchRequestItem, len(items))
+	for i, item := range items {
+		item.OriginalURL = NormalizeURL(item.OriginalURL, *s.normalize)
+		normalized[i] = item
+	}
+	return normalized
+}
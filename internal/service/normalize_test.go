@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/storage"
+	"github.com/MikhailRaia/url-shortener/internal/storage/memory"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		opts NormalizeOptions
+		want string
+	}{
+		{name: "lowercase scheme and host", url: "HTTPS://Example.COM/Path", want: "https://example.com/Path"},
+		{name: "strip default http port", url: "http://example.com:80/a", want: "http://example.com/a"},
+		{name: "strip default https port", url: "https://example.com:443/a", want: "https://example.com/a"},
+		{name: "keep non-default port", url: "https://example.com:8443/a", want: "https://example.com:8443/a"},
+		{name: "keep port of other scheme", url: "http://example.com:443/a", want: "http://example.com:443/a"},
+		{name: "strip default port of IPv6 host", url: "http://[::1]:80/a", want: "http://[::1]/a"},
+		{name: "keep trailing slash by default", url: "https://example.com/a/", want: "https://example.com/a/"},
+		{name: "strip trailing slash", url: "https://example.com/a/", opts: NormalizeOptions{StripTrailingSlash: true}, want: "https://example.com/a"},
+		{name: "strip root slash", url: "https://Example.com/", opts: NormalizeOptions{StripTrailingSlash: true}, want: "https://example.com"},
+		{name: "keep fragment by default", url: "https://example.com/a#top", want: "https://example.com/a#top"},
+		{name: "strip fragment", url: "https://example.com/a?q=1#top", opts: NormalizeOptions{StripFragment: true}, want: "https://example.com/a?q=1"},
+		{name: "leave relative URL", url: "example.com/a/", opts: NormalizeOptions{StripTrailingSlash: true}, want: "example.com/a/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeURL(tt.url, tt.opts); got != tt.want {
+				t.Errorf("NormalizeURL(%q) = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestURLService_Normalization(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		wantSame bool
+	}{
+		{name: "enabled", opts: []Option{WithNormalization(NormalizeOptions{StripTrailingSlash: true})}, wantSame: true},
+		{name: "disabled", opts: nil, wantSame: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewURLService(memory.NewStorage(), "http://localhost:8080", tt.opts...)
+
+			result, err := service.ShortenBatch(ctx, []model.BatchRequestItem{
+				{CorrelationID: "1", OriginalURL: "https://Example.com/"},
+				{CorrelationID: "2", OriginalURL: "https://example.com:443"},
+			})
+			if err != nil {
+				t.Fatalf("URLService.ShortenBatch() error = %v", err)
+			}
+			if len(result) != 2 {
+				t.Fatalf("URLService.ShortenBatch() returned %d items, want 2", len(result))
+			}
+
+			if same := result[0].ShortURL == result[1].ShortURL; same != tt.wantSame {
+				t.Errorf("URLService.ShortenBatch() short URLs %v and %v, want same: %v", result[0].ShortURL, result[1].ShortURL, tt.wantSame)
+			}
+
+			// With normalization the URL is already stored by the batch above.
+			shortURL, err := service.ShortenURL(ctx, "HTTPS://EXAMPLE.COM/")
+			if err != nil && !errors.Is(err, storage.ErrURLExists) {
+				t.Fatalf("URLService.ShortenURL() error = %v", err)
+			}
+			id := shortURL[len("http://localhost:8080/"):]
+			got, _ := service.GetOriginalURL(ctx, id)
+
+			want := "HTTPS://EXAMPLE.COM/"
+			if tt.wantSame {
+				want = "https://example.com"
+			}
+			if got != want {
+				t.Errorf("URLService stored %q, want %q", got, want)
+			}
+		})
+	}
+}
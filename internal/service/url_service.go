@@ -2,117 +2,587 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/MikhailRaia/url-shortener/internal/audit"
+	"github.com/MikhailRaia/url-shortener/internal/auth"
+	"github.com/MikhailRaia/url-shortener/internal/baseurl"
+	"github.com/MikhailRaia/url-shortener/internal/generator"
 	"github.com/MikhailRaia/url-shortener/internal/model"
 	"github.com/MikhailRaia/url-shortener/internal/storage"
-	"net/url"
+	"github.com/MikhailRaia/url-shortener/internal/tenant"
+	"golang.org/x/crypto/bcrypt"
+	"slices"
+	"time"
+)
+
+// Alias length bounds accepted by ShortenURLWithAlias.
+const (
+	MinAliasLength = 3
+	MaxAliasLength = 32
+)
+
+// MaxPasswordLength is the longest URL password accepted, the bcrypt input limit.
+const MaxPasswordLength = 72
+
+// Service errors.
+var (
+	// ErrInvalidAlias indicates the alias has disallowed characters or length.
+	ErrInvalidAlias = errors.New("invalid alias")
+	// ErrInvalidPassword indicates the URL password is empty or too long.
+	ErrInvalidPassword = errors.New("invalid password")
+	// ErrWrongPassword indicates a missing or non-matching password for a protected URL.
+	ErrWrongPassword = errors.New("wrong password")
+	// ErrPasswordsUnsupported indicates the storage cannot protect URLs with a password.
+	ErrPasswordsUnsupported = errors.New("storage does not support URL passwords")
+	// ErrStatsUnsupported indicates the storage cannot track visits.
+	ErrStatsUnsupported = errors.New("storage does not support visit statistics")
+	// ErrInvalidExpiry indicates the requested expiry time is not in the future.
+	ErrInvalidExpiry = errors.New("invalid expiry")
+	// ErrExpiryUnsupported indicates the storage cannot expire URLs.
+	ErrExpiryUnsupported = errors.New("storage does not support URL expiry")
+	// ErrForwardQueryUnsupported indicates the storage cannot forward redirect query strings.
+	ErrForwardQueryUnsupported = errors.New("storage does not support query forwarding")
+	// ErrNotURLOwner indicates the short URL belongs to another user.
+	ErrNotURLOwner = errors.New("short URL is owned by another user")
+	// ErrQuotaExceeded indicates the user already owns the maximum number of URLs.
+	ErrQuotaExceeded = errors.New("per-user URL quota exceeded")
 )
 
 // URLService provides business logic for creating and resolving short URLs.
 type URLService struct {
-	storage storage.URLStorage
-	baseURL string
+	storage   storage.URLStorage
+	baseURL   string
+	normalize *NormalizeOptions
+	// maxURLsPerUser caps the live URLs a user may own; 0 means no cap.
+	maxURLsPerUser int
+	// signer, when set, appends a signature to the IDs of issued short URLs.
+	signer *auth.URLSigner
+	// duplicates keeps repeated URLs of a batch apart, for storages that give
+	// every save of a URL its own short ID.
+	duplicates bool
 }
 
 // NewURLService constructs a URLService with the given storage and base URL.
-func NewURLService(storage storage.URLStorage, baseURL string) *URLService {
-	return &URLService{
+// A base URL of baseurl.Auto builds short URLs from the base URL carried by
+// the request context.
+func NewURLService(storage storage.URLStorage, baseURL string, opts ...Option) *URLService {
+	s := &URLService{
 		storage: storage,
 		baseURL: baseURL,
 	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// WithMaxURLsPerUser caps how many live URLs a single user may own. Deleted
+// URLs do not count and a non-positive limit disables the cap.
+func WithMaxURLsPerUser(limit int) Option {
+	return func(s *URLService) {
+		s.maxURLsPerUser = limit
+	}
+}
+
+// WithURLSigner makes the service issue signed short URLs of the form
+// {base}/{id}.{sig}, so only links it handed out resolve when the redirect
+// handler verifies signatures.
+func WithURLSigner(signer *auth.URLSigner) Option {
+	return func(s *URLService) {
+		s.signer = signer
+	}
+}
+
+// WithDuplicateURLs makes batches store every item under its own short URL,
+// even when items repeat a URL. Pair it with the storage's own duplicate URL
+// option so single shortens get fresh short URLs too.
+func WithDuplicateURLs() Option {
+	return func(s *URLService) {
+		s.duplicates = true
+	}
+}
+
+// storageFor returns the storage view for the tenant carried by ctx, bound to
+// ctx when the storage supports it so queries carry the request trace ID.
+// Storages that don't support tenants are shared by all tenants.
+func (s *URLService) storageFor(ctx context.Context) storage.URLStorage {
+	st := s.storageForTenant(tenant.FromContext(ctx))
+	if binder, ok := st.(storage.ContextBinder); ok {
+		return binder.WithContext(ctx)
+	}
+	return st
+}
+
+func (s *URLService) storageForTenant(tenantID string) storage.URLStorage {
+	if tenantID == "" {
+		return s.storage
+	}
+
+	scoper, ok := s.storage.(storage.TenantScoper)
+	if !ok {
+		return s.storage
+	}
+
+	return scoper.ForTenant(tenantID)
+}
+
+// baseURLFor returns the base URL of short URLs created within ctx.
+func (s *URLService) baseURLFor(ctx context.Context) string {
+	if s.baseURL != baseurl.Auto {
+		return s.baseURL
+	}
+
+	return baseurl.FromContext(ctx)
+}
+
+// checkQuota returns ErrQuotaExceeded if storing n more URLs for userID would
+// take the user past the per-user quota. Anonymous URLs are not counted.
+// Callers run the check and the save in one inTx; how far that keeps
+// concurrent requests from overshooting the quota depends on the storage.
+func (s *URLService) checkQuota(st storage.URLStorage, userID string, n int) error {
+	if s.maxURLsPerUser <= 0 || userID == "" {
+		return nil
+	}
+
+	count, err := countUserURLs(st, userID)
+	if err != nil {
+		return fmt.Errorf("error counting user URLs: %w", err)
+	}
+
+	if count+n > s.maxURLsPerUser {
+		return ErrQuotaExceeded
+	}
+
+	return nil
+}
+
+// checkNewURLQuota is checkQuota for saving originalURL for userID. It is
+// skipped when the URL is already stored, as the save then creates nothing
+// and returns the existing short URL, in storages that can tell.
+func (s *URLService) checkNewURLQuota(st storage.URLStorage, originalURL, userID string) error {
+	if s.maxURLsPerUser <= 0 || userID == "" {
+		return nil
+	}
+
+	if finder, ok := st.(storage.URLFinder); ok {
+		_, err := finder.FindID(originalURL)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, storage.ErrURLNotFound) && !errors.Is(err, storage.ErrUnsupported) {
+			return fmt.Errorf("error finding URL: %w", err)
+		}
+	}
+
+	return s.checkQuota(st, userID, 1)
+}
+
+// inTx runs fn with a view of st whose operations take effect together when
+// st implements storage.TxStorage, and with st itself otherwise.
+func inTx(ctx context.Context, st storage.URLStorage, fn func(storage.URLStorage) error) error {
+	if txStorage, ok := st.(storage.TxStorage); ok {
+		return txStorage.WithTx(ctx, fn)
+	}
+	return fn(st)
+}
+
+// saveURL stores originalURL, associated with userID if it is not empty.
+func saveURL(st storage.URLStorage, originalURL, userID string) (string, error) {
+	if userID == "" {
+		return st.Save(originalURL)
+	}
+	return st.SaveWithUser(originalURL, userID)
+}
+
+// countUserURLs returns the number of userID's live URLs, falling back to the
+// total of a one-item page for storages that cannot count them directly.
+func countUserURLs(st storage.URLStorage, userID string) (int, error) {
+	if counter, ok := st.(storage.UserURLCounter); ok {
+		count, err := counter.CountUserURLs(userID)
+		if !errors.Is(err, storage.ErrUnsupported) {
+			return count, err
+		}
+	}
+
+	_, total, err := st.GetUserURLsPaged(userID, 1, 0)
+	return total, err
+}
+
+// ShortURL returns the short URL for id, signed if the service has a signer.
+// It is absolute unless ctx asks for another baseurl.Format.
+func (s *URLService) ShortURL(ctx context.Context, id string) string {
+	if s.signer != nil {
+		id = s.signer.SignedID(id)
+	}
+	return baseurl.ShortURL(s.baseURLFor(ctx), id, baseurl.FormatFromContext(ctx))
 }
 
 // ShortenURL creates a short URL and returns its absolute form.
+// Re-shortening a URL whose short URL has expired revives it without an expiry.
 func (s *URLService) ShortenURL(ctx context.Context, originalURL string) (string, error) {
-	id, err := s.storage.Save(originalURL)
+	result, err := s.ShortenURLDetailed(ctx, originalURL, "")
+	return result.ShortURL, err
+}
+
+// ShortenURLDetailed creates a short URL, associated with userID if it is not
+// empty, and describes what was stored. Like ShortenURL it returns
+// storage.ErrURLExists together with the existing short URL; CreatedAt is
+// zero in that case. It returns ErrQuotaExceeded once the user owns the
+// maximum number of URLs, unless the URL is already stored.
+func (s *URLService) ShortenURLDetailed(ctx context.Context, originalURL, userID string) (model.ShortenResult, error) {
+	originalURL = s.normalizeURL(originalURL)
+	result := model.ShortenResult{OriginalURL: originalURL}
+	now := time.Now()
+
+	st := s.storageFor(ctx)
+	var id string
+	err := inTx(ctx, st, func(st storage.URLStorage) error {
+		if err := s.checkNewURLQuota(st, originalURL, userID); err != nil {
+			return err
+		}
+
+		var err error
+		if id, err = saveURL(st, originalURL, userID); err != nil {
+			return err
+		}
+
+		return recordCreator(ctx, st, id)
+	})
 	if err != nil {
-		if err == storage.ErrURLExists && id != "" {
-			shortenedURL, _ := url.JoinPath(s.baseURL, id)
-			return shortenedURL, err
+		if errors.Is(err, storage.ErrURLExists) && id != "" {
+			result.ShortURL = s.ShortURL(ctx, id)
+			if renewed, renewErr := renewIfExpired(st, id, time.Time{}); renewErr != nil {
+				return model.ShortenResult{}, renewErr
+			} else if renewed {
+				result.CreatedAt = now
+				return result, nil
+			}
+			return result, err
 		}
-		return "", err
+		return model.ShortenResult{}, err
 	}
 
-	shortenedURL, _ := url.JoinPath(s.baseURL, id)
-	return shortenedURL, nil
+	result.ShortURL = s.ShortURL(ctx, id)
+	result.CreatedAt = now
+	return result, nil
 }
 
 // GetOriginalURL resolves an ID to the original URL if it exists and not deleted.
 func (s *URLService) GetOriginalURL(ctx context.Context, id string) (string, bool) {
-	return s.storage.Get(id)
+	return s.storageFor(ctx).Get(id)
 }
 
 // GetOriginalURLWithDeletedStatus resolves an ID and reports deletion via error.
 func (s *URLService) GetOriginalURLWithDeletedStatus(ctx context.Context, id string) (string, error) {
-	return s.storage.GetWithDeletedStatus(id)
+	return s.storageFor(ctx).GetWithDeletedStatus(id)
 }
 
-// ShortenBatch creates short URLs for a batch of items.
+// ShortenBatch creates short URLs for a batch of items. When every URL was
+// already shortened it returns the short URLs together with storage.ErrURLExists.
+// Batches repeating a correlation ID are rejected with ErrInvalidBatchItem.
 func (s *URLService) ShortenBatch(ctx context.Context, items []model.BatchRequestItem) ([]model.BatchResponseItem, error) {
-	idMap, err := s.storage.SaveBatch(items)
+	if err := checkCorrelationIDs(items); err != nil {
+		return nil, err
+	}
+
+	items = s.normalizeBatch(items)
+	unique, canonical := s.dedupBatch(items)
+	st := s.storageFor(ctx)
+	saved, err := st.SaveBatch(unique)
 	if err != nil {
 		return nil, fmt.Errorf("error saving batch: %w", err)
 	}
+	if err := recordBatchCreators(ctx, st, saved); err != nil {
+		return nil, err
+	}
+
+	return s.batchResponse(ctx, items, canonical, saved)
+}
+
+// dedupBatch drops items repeating an earlier item's original URL, so every
+// backend stores it once, unless duplicate URLs are allowed. canonical maps
+// each correlation ID to the one of the item that was kept.
+func (s *URLService) dedupBatch(items []model.BatchRequestItem) (unique []model.BatchRequestItem, canonical map[string]string) {
+	unique = make([]model.BatchRequestItem, 0, len(items))
+	canonical = make(map[string]string, len(items))
+	kept := make(map[string]string, len(items))
+
+	for _, item := range items {
+		if first, ok := kept[item.OriginalURL]; ok && !s.duplicates {
+			canonical[item.CorrelationID] = first
+			continue
+		}
+		kept[item.OriginalURL] = item.CorrelationID
+		canonical[item.CorrelationID] = item.CorrelationID
+		unique = append(unique, item)
+	}
 
+	return unique, canonical
+}
+
+// batchResponse builds the response for items in request order, giving
+// duplicates the short URL stored for their canonical item. It returns
+// storage.ErrURLExists with the response when no item created a short URL.
+func (s *URLService) batchResponse(ctx context.Context, items []model.BatchRequestItem, canonical map[string]string, saved map[string]model.BatchSaveResult) ([]model.BatchResponseItem, error) {
+	created := false
 	result := make([]model.BatchResponseItem, 0, len(items))
 	for _, item := range items {
-		id, ok := idMap[item.CorrelationID]
+		res, ok := saved[canonical[item.CorrelationID]]
 		if !ok {
 			continue
 		}
+		created = created || res.Created
 
-		shortURL := fmt.Sprintf("%s/%s", s.baseURL, id)
 		result = append(result, model.BatchResponseItem{
 			CorrelationID: item.CorrelationID,
-			ShortURL:      shortURL,
+			ShortURL:      s.ShortURL(ctx, res.ID),
 		})
 	}
 
+	if !created && len(result) > 0 {
+		return result, storage.ErrURLExists
+	}
+
 	return result, nil
 }
 
 // ShortenURLWithUser creates a short URL associated with a user.
+// Re-shortening a URL whose short URL has expired revives it without an expiry.
 func (s *URLService) ShortenURLWithUser(ctx context.Context, originalURL, userID string) (string, error) {
-	id, err := s.storage.SaveWithUser(originalURL, userID)
+	result, err := s.ShortenURLDetailed(ctx, originalURL, userID)
+	return result.ShortURL, err
+}
+
+// ShortenURLWithAlias stores a URL under a caller-chosen alias and returns its absolute form.
+// The user ID is optional.
+func (s *URLService) ShortenURLWithAlias(ctx context.Context, originalURL, alias, userID string) (string, error) {
+	if len(alias) < MinAliasLength || len(alias) > MaxAliasLength || !generator.ValidID(alias) {
+		return "", ErrInvalidAlias
+	}
+
+	originalURL = s.normalizeURL(originalURL)
+
+	err := inTx(ctx, s.storageFor(ctx), func(st storage.URLStorage) error {
+		if err := s.checkQuota(st, userID, 1); err != nil {
+			return err
+		}
+
+		if err := st.SaveWithAlias(alias, originalURL, userID); err != nil {
+			return err
+		}
+
+		return recordCreator(ctx, st, alias)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	shortenedURL := s.ShortURL(ctx, alias)
+	return shortenedURL, nil
+}
+
+// ShortenURLWithPassword creates a short URL gated by a password and returns its absolute form.
+// The user ID is optional. Only a bcrypt hash of the password is stored. If the URL
+// was already shortened, its existing short URL is returned with storage.ErrURLExists
+// and its password is left unchanged.
+func (s *URLService) ShortenURLWithPassword(ctx context.Context, originalURL, password, userID string) (string, error) {
+	if password == "" || len(password) > MaxPasswordLength {
+		return "", ErrInvalidPassword
+	}
+
+	originalURL = s.normalizeURL(originalURL)
+
+	st := s.storageFor(ctx)
+	if _, ok := st.(storage.PasswordProtector); !ok {
+		return "", ErrPasswordsUnsupported
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("error hashing password: %w", err)
+	}
+
+	// The URL and its password are stored together where the storage allows,
+	// so a failure cannot leave the URL reachable without its password.
+	var id string
+	err = inTx(ctx, st, func(st storage.URLStorage) error {
+		if err := s.checkNewURLQuota(st, originalURL, userID); err != nil {
+			return err
+		}
+
+		var err error
+		if id, err = saveURL(st, originalURL, userID); err != nil {
+			return err
+		}
+
+		if err := st.(storage.PasswordProtector).SetPasswordHash(id, string(hash)); err != nil {
+			if errors.Is(err, storage.ErrUnsupported) {
+				return ErrPasswordsUnsupported
+			}
+			return fmt.Errorf("error protecting URL: %w", err)
+		}
+
+		return recordCreator(ctx, st, id)
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrURLExists) && id != "" {
+			shortenedURL := s.ShortURL(ctx, id)
+			return shortenedURL, err
+		}
+		return "", err
+	}
+
+	shortenedURL := s.ShortURL(ctx, id)
+	return shortenedURL, nil
+}
+
+// VerifyURLPassword checks password against the short URL's password, if it has one.
+// It returns ErrWrongPassword when the URL is protected and the password does not match.
+func (s *URLService) VerifyURLPassword(ctx context.Context, id, password string) error {
+	protector, ok := s.storageFor(ctx).(storage.PasswordProtector)
+	if !ok {
+		return nil
+	}
+
+	hash, err := protector.PasswordHash(id)
+	if errors.Is(err, storage.ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting password hash: %w", err)
+	}
+
+	if hash == "" {
+		return nil
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return ErrWrongPassword
+	}
+
+	return nil
+}
+
+// ShortenURLWithForwardQuery shortens a URL whose redirects forward their query
+// string to it. If the URL was already shortened, the existing short URL is
+// returned with storage.ErrURLExists and its setting is left unchanged.
+func (s *URLService) ShortenURLWithForwardQuery(ctx context.Context, originalURL, userID string) (string, error) {
+	originalURL = s.normalizeURL(originalURL)
+
+	st := s.storageFor(ctx)
+	if _, ok := st.(storage.QueryForwarder); !ok {
+		return "", ErrForwardQueryUnsupported
+	}
+
+	var id string
+	err := inTx(ctx, st, func(st storage.URLStorage) error {
+		if err := s.checkNewURLQuota(st, originalURL, userID); err != nil {
+			return err
+		}
+
+		var err error
+		if id, err = saveURL(st, originalURL, userID); err != nil {
+			return err
+		}
+
+		if err := st.(storage.QueryForwarder).SetForwardQuery(id, true); err != nil {
+			if errors.Is(err, storage.ErrUnsupported) {
+				return ErrForwardQueryUnsupported
+			}
+			return fmt.Errorf("error enabling query forwarding: %w", err)
+		}
+
+		return recordCreator(ctx, st, id)
+	})
 	if err != nil {
-		if err == storage.ErrURLExists && id != "" {
-			shortenedURL, _ := url.JoinPath(s.baseURL, id)
+		if errors.Is(err, storage.ErrURLExists) && id != "" {
+			shortenedURL := s.ShortURL(ctx, id)
 			return shortenedURL, err
 		}
 		return "", err
 	}
 
-	shortenedURL, _ := url.JoinPath(s.baseURL, id)
+	shortenedURL := s.ShortURL(ctx, id)
 	return shortenedURL, nil
 }
 
+// ForwardsQuery reports whether redirects of the short URL forward their query
+// string. URLs in storages without query forwarding never do.
+func (s *URLService) ForwardsQuery(ctx context.Context, id string) (bool, error) {
+	forwarder, ok := s.storageFor(ctx).(storage.QueryForwarder)
+	if !ok {
+		return false, nil
+	}
+
+	enabled, err := forwarder.ForwardQuery(id)
+	if errors.Is(err, storage.ErrUnsupported) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error getting query forwarding: %w", err)
+	}
+
+	return enabled, nil
+}
+
+// URLExpiry returns when the short URL expires, or the zero time if it never
+// does. URLs in storages without expiry never expire.
+func (s *URLService) URLExpiry(ctx context.Context, id string) (time.Time, error) {
+	expirer, ok := s.storageFor(ctx).(storage.Expirer)
+	if !ok {
+		return time.Time{}, nil
+	}
+
+	expiresAt, err := expirer.Expiry(id)
+	if errors.Is(err, storage.ErrUnsupported) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error getting URL expiry: %w", err)
+	}
+
+	return expiresAt, nil
+}
+
 // ShortenBatchWithUser creates short URLs for a batch and associates them with a user.
+// Like ShortenBatch it returns storage.ErrURLExists when every URL was already
+// shortened and rejects repeated correlation IDs.
+// A batch whose distinct URLs would take the user past the per-user quota is
+// rejected as a whole with ErrQuotaExceeded and nothing is stored; URLs the
+// user already owns count against the quota too.
 func (s *URLService) ShortenBatchWithUser(ctx context.Context, items []model.BatchRequestItem, userID string) ([]model.BatchResponseItem, error) {
-	idMap, err := s.storage.SaveBatchWithUser(items, userID)
-	if err != nil {
-		return nil, fmt.Errorf("error saving batch: %w", err)
+	if err := checkCorrelationIDs(items); err != nil {
+		return nil, err
 	}
 
-	result := make([]model.BatchResponseItem, 0, len(items))
-	for _, item := range items {
-		id, ok := idMap[item.CorrelationID]
-		if !ok {
-			continue
+	items = s.normalizeBatch(items)
+	unique, canonical := s.dedupBatch(items)
+	var saved map[string]model.BatchSaveResult
+	err := inTx(ctx, s.storageFor(ctx), func(st storage.URLStorage) error {
+		if err := s.checkQuota(st, userID, len(unique)); err != nil {
+			return err
 		}
 
-		shortURL := fmt.Sprintf("%s/%s", s.baseURL, id)
-		result = append(result, model.BatchResponseItem{
-			CorrelationID: item.CorrelationID,
-			ShortURL:      shortURL,
-		})
+		var err error
+		if saved, err = st.SaveBatchWithUser(unique, userID); err != nil {
+			return fmt.Errorf("error saving batch: %w", err)
+		}
+
+		return recordBatchCreators(ctx, st, saved)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return result, nil
+	return s.batchResponse(ctx, items, canonical, saved)
 }
 
 // GetUserURLs returns all URLs belonging to a user, excluding deleted ones.
 func (s *URLService) GetUserURLs(ctx context.Context, userID string) ([]model.UserURL, error) {
-	urls, err := s.storage.GetUserURLs(userID)
+	urls, err := s.storageFor(ctx).GetUserURLs(userID)
 	if err != nil {
 		return nil, fmt.Errorf("error getting user URLs: %w", err)
 	}
@@ -120,15 +590,328 @@ func (s *URLService) GetUserURLs(ctx context.Context, userID string) ([]model.Us
 	result := make([]model.UserURL, len(urls))
 	for i, url := range urls {
 		result[i] = model.UserURL{
-			ShortURL:    fmt.Sprintf("%s/%s", s.baseURL, url.ShortURL),
+			ShortURL:    s.ShortURL(ctx, url.ShortURL),
 			OriginalURL: url.OriginalURL,
+			CreatedAt:   url.CreatedAt,
 		}
 	}
 
 	return result, nil
 }
 
+// GetUserURLsPaged returns a page of a user's URLs in the given order,
+// excluding deleted ones, together with the total number of the user's URLs.
+func (s *URLService) GetUserURLsPaged(ctx context.Context, userID string, order model.UserURLOrder, limit, offset int) ([]model.UserURL, int, error) {
+	st := s.storageFor(ctx)
+
+	var urls []model.UserURL
+	var total int
+	var err error
+	if order == model.OldestFirst {
+		urls, total, err = st.GetUserURLsPaged(userID, limit, offset)
+	} else {
+		urls, total, err = newestUserURLsPage(st, userID, limit, offset)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("error getting user URLs page: %w", err)
+	}
+
+	result := make([]model.UserURL, len(urls))
+	for i, url := range urls {
+		result[i] = model.UserURL{
+			ShortURL:    s.ShortURL(ctx, url.ShortURL),
+			OriginalURL: url.OriginalURL,
+			CreatedAt:   url.CreatedAt,
+		}
+	}
+
+	return result, total, nil
+}
+
+// newestUserURLsPage returns a page of userID's URLs newest first. Storages
+// page URLs oldest first, so it reads the mirrored page and reverses it. If
+// the user's URLs change in between, the page is read once more.
+func newestUserURLsPage(st storage.URLStorage, userID string, limit, offset int) ([]model.UserURL, int, error) {
+	total, err := countUserURLs(st, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		end := total - offset
+		if end <= 0 {
+			return []model.UserURL{}, total, nil
+		}
+		start := 0
+		if limit > 0 {
+			start = max(end-limit, 0)
+		}
+
+		urls, current, err := st.GetUserURLsPaged(userID, end-start, start)
+		if err != nil {
+			return nil, 0, err
+		}
+		if current != total && attempt == 0 {
+			total = current
+			continue
+		}
+
+		slices.Reverse(urls)
+		return urls, current, nil
+	}
+}
+
+// GetDeletedUserURLs returns the short IDs of a user's URLs that have been deleted.
+func (s *URLService) GetDeletedUserURLs(ctx context.Context, userID string) ([]string, error) {
+	ids, err := s.storageFor(ctx).GetDeletedUserURLs(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting deleted user URLs: %w", err)
+	}
+
+	return ids, nil
+}
+
 // DeleteUserURLs marks user's URLs as deleted.
 func (s *URLService) DeleteUserURLs(userID string, urlIDs []string) error {
 	return s.storage.DeleteUserURLs(userID, urlIDs)
 }
+
+// OwnsURL reports whether userID owns the short URL, deleted or not. It
+// returns storage.ErrURLNotFound for unknown short IDs.
+func (s *URLService) OwnsURL(ctx context.Context, userID, id string) (bool, error) {
+	st := s.storageFor(ctx)
+	if checker, ok := st.(storage.OwnershipChecker); ok {
+		owns, err := checker.OwnsURL(userID, id)
+		if !errors.Is(err, storage.ErrUnsupported) {
+			return owns, err
+		}
+	}
+
+	return ownsURL(st, userID, id)
+}
+
+// ownsURL checks ownership by listing the user's URLs, for storages that
+// cannot check it directly.
+func ownsURL(st storage.URLStorage, userID, id string) (bool, error) {
+	originalURL, err := st.GetWithDeletedStatus(id)
+	if err != nil && !errors.Is(err, storage.ErrURLDeleted) && !errors.Is(err, storage.ErrURLExpired) {
+		return false, err
+	}
+	if err == nil && originalURL == "" {
+		return false, storage.ErrURLNotFound
+	}
+	if userID == "" {
+		return false, nil
+	}
+
+	urls, err := st.GetUserURLs(userID)
+	if err != nil {
+		return false, err
+	}
+	for _, url := range urls {
+		if url.ShortURL == id {
+			return true, nil
+		}
+	}
+
+	deleted, err := st.GetDeletedUserURLs(userID)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(deleted, id), nil
+}
+
+// DeleteURL marks a single short URL deleted on behalf of its owner, without
+// going through the delete worker. It returns storage.ErrURLNotFound for
+// unknown short IDs and ErrNotURLOwner if another user owns the URL.
+func (s *URLService) DeleteURL(ctx context.Context, userID, id string) error {
+	owns, err := s.OwnsURL(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if !owns {
+		return ErrNotURLOwner
+	}
+
+	return s.storageFor(ctx).DeleteUserURLs(userID, []string{id})
+}
+
+// DeleteTenantUserURLs marks user's URLs within the given tenant as deleted.
+func (s *URLService) DeleteTenantUserURLs(tenantID, userID string, urlIDs []string) error {
+	return s.storageForTenant(tenantID).DeleteUserURLs(userID, urlIDs)
+}
+
+// RecordVisits adds n redirects to the short URL within the given tenant.
+// Storages that do not track visits ignore them.
+func (s *URLService) RecordVisits(tenantID, id string, n int64, at time.Time) error {
+	tracker, ok := s.storageForTenant(tenantID).(storage.VisitTracker)
+	if !ok {
+		return nil
+	}
+
+	return tracker.IncrementVisit(id, n, at)
+}
+
+// GetURLStats returns the original URL and visit statistics of a short URL.
+// It returns an empty URL for unknown IDs and storage.ErrURLDeleted for deleted ones.
+func (s *URLService) GetURLStats(ctx context.Context, id string) (string, model.URLStats, error) {
+	st := s.storageFor(ctx)
+	tracker, ok := st.(storage.VisitTracker)
+	if !ok {
+		return "", model.URLStats{}, ErrStatsUnsupported
+	}
+
+	originalURL, err := st.GetWithDeletedStatus(id)
+	if err != nil || originalURL == "" {
+		return "", model.URLStats{}, err
+	}
+
+	stats, err := tracker.GetStats(id)
+	if errors.Is(err, storage.ErrUnsupported) {
+		return "", model.URLStats{}, ErrStatsUnsupported
+	}
+	if err != nil {
+		return "", model.URLStats{}, fmt.Errorf("error getting URL stats: %w", err)
+	}
+
+	return originalURL, stats, nil
+}
+
+// URLCreator returns the client that created a short URL, if creator capture
+// was on at the time. Storages that cannot record creators report none.
+func (s *URLService) URLCreator(ctx context.Context, id string) (model.Creator, error) {
+	recorder, ok := s.storageFor(ctx).(storage.CreatorRecorder)
+	if !ok {
+		return model.Creator{}, nil
+	}
+
+	creator, err := recorder.Creator(id)
+	if errors.Is(err, storage.ErrUnsupported) {
+		return model.Creator{}, nil
+	}
+	if err != nil {
+		return model.Creator{}, fmt.Errorf("error getting URL creator: %w", err)
+	}
+
+	return creator, nil
+}
+
+// recordCreator stores the client carried by ctx as the creator of the new
+// short URL id. It does nothing when creator capture is off or the storage
+// cannot record creators.
+func recordCreator(ctx context.Context, st storage.URLStorage, id string) error {
+	creator, ok := audit.CreatorFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	recorder, ok := st.(storage.CreatorRecorder)
+	if !ok {
+		return nil
+	}
+
+	if err := recorder.SetCreator(id, creator); err != nil && !errors.Is(err, storage.ErrUnsupported) {
+		return fmt.Errorf("error recording URL creator: %w", err)
+	}
+
+	return nil
+}
+
+// recordBatchCreators records the creator of each short URL a batch created.
+func recordBatchCreators(ctx context.Context, st storage.URLStorage, saved map[string]model.BatchSaveResult) error {
+	for _, res := range saved {
+		if !res.Created {
+			continue
+		}
+		if err := recordCreator(ctx, st, res.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ShortenURLWithExpiry creates a short URL that expires at expiresAt and returns
+// its absolute form. The user ID is optional. If the URL was already shortened,
+// its existing short URL is returned with storage.ErrURLExists and its expiry is
+// left unchanged, unless it has already expired, in which case it is revived
+// with the new expiry.
+func (s *URLService) ShortenURLWithExpiry(ctx context.Context, originalURL string, expiresAt time.Time, userID string) (string, error) {
+	if !expiresAt.After(time.Now()) {
+		return "", ErrInvalidExpiry
+	}
+
+	originalURL = s.normalizeURL(originalURL)
+
+	st := s.storageFor(ctx)
+	if _, ok := st.(storage.Expirer); !ok {
+		return "", ErrExpiryUnsupported
+	}
+
+	// The URL and its expiry are stored together where the storage allows,
+	// so a failure cannot leave the URL behind without an expiry.
+	var id string
+	err := inTx(ctx, st, func(st storage.URLStorage) error {
+		if err := s.checkNewURLQuota(st, originalURL, userID); err != nil {
+			return err
+		}
+
+		var err error
+		if id, err = saveURL(st, originalURL, userID); err != nil {
+			return err
+		}
+
+		if err := st.(storage.Expirer).SetExpiry(id, expiresAt); err != nil {
+			if errors.Is(err, storage.ErrUnsupported) {
+				return ErrExpiryUnsupported
+			}
+			return fmt.Errorf("error setting URL expiry: %w", err)
+		}
+
+		return recordCreator(ctx, st, id)
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrURLExists) && id != "" {
+			shortenedURL := s.ShortURL(ctx, id)
+			if renewed, renewErr := renewIfExpired(st, id, expiresAt); renewErr != nil {
+				return "", renewErr
+			} else if renewed {
+				return shortenedURL, nil
+			}
+			return shortenedURL, err
+		}
+		return "", err
+	}
+
+	shortenedURL := s.ShortURL(ctx, id)
+	return shortenedURL, nil
+}
+
+// renewIfExpired gives the short URL id the new expiry if it has expired,
+// a zero expiresAt meaning none, and reports whether it did.
+func renewIfExpired(st storage.URLStorage, id string, expiresAt time.Time) (bool, error) {
+	expirer, ok := st.(storage.Expirer)
+	if !ok {
+		return false, nil
+	}
+
+	if _, err := st.GetWithDeletedStatus(id); !errors.Is(err, storage.ErrURLExpired) {
+		return false, nil
+	}
+
+	if err := expirer.SetExpiry(id, expiresAt); err != nil {
+		return false, fmt.Errorf("error renewing expired URL: %w", err)
+	}
+
+	return true, nil
+}
+
+// PurgeExpired removes URLs that expired before the given time, if the storage supports expiry.
+func (s *URLService) PurgeExpired(before time.Time) (int, error) {
+	expirer, ok := s.storage.(storage.Expirer)
+	if !ok {
+		return 0, nil
+	}
+
+	return expirer.PurgeExpired(before)
+}
@@ -3,18 +3,29 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/MikhailRaia/url-shortener/internal/baseurl"
 	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/storage"
+	"github.com/MikhailRaia/url-shortener/internal/storage/memory"
+	"github.com/MikhailRaia/url-shortener/internal/storage/sqlite"
+	"github.com/MikhailRaia/url-shortener/internal/storage/storagetest"
 )
 
 type mockStorage struct {
 	saveFunc                 func(originalURL string) (string, error)
 	saveWithUserFunc         func(originalURL, userID string) (string, error)
+	saveWithAliasFunc        func(alias, originalURL, userID string) error
 	getFunc                  func(id string) (string, bool)
 	getWithDeletedStatusFunc func(id string) (string, bool, error)
-	saveBatchFunc            func(items []model.BatchRequestItem) (map[string]string, error)
-	saveBatchWithUserFunc    func(items []model.BatchRequestItem, userID string) (map[string]string, error)
+	saveBatchFunc            func(items []model.BatchRequestItem) (map[string]model.BatchSaveResult, error)
+	saveBatchWithUserFunc    func(items []model.BatchRequestItem, userID string) (map[string]model.BatchSaveResult, error)
 	getUserURLsFunc          func(userID string) ([]model.UserURL, error)
 	deleteUserURLsFunc       func(userID string, urlIDs []string) error
 }
@@ -30,22 +41,29 @@ func (m *mockStorage) SaveWithUser(originalURL, userID string) (string, error) {
 	return "", nil
 }
 
+func (m *mockStorage) SaveWithAlias(alias, originalURL, userID string) error {
+	if m.saveWithAliasFunc != nil {
+		return m.saveWithAliasFunc(alias, originalURL, userID)
+	}
+	return nil
+}
+
 func (m *mockStorage) Get(id string) (string, bool) {
 	return m.getFunc(id)
 }
 
-func (m *mockStorage) SaveBatch(items []model.BatchRequestItem) (map[string]string, error) {
+func (m *mockStorage) SaveBatch(items []model.BatchRequestItem) (map[string]model.BatchSaveResult, error) {
 	if m.saveBatchFunc != nil {
 		return m.saveBatchFunc(items)
 	}
-	return make(map[string]string), nil
+	return make(map[string]model.BatchSaveResult), nil
 }
 
-func (m *mockStorage) SaveBatchWithUser(items []model.BatchRequestItem, userID string) (map[string]string, error) {
+func (m *mockStorage) SaveBatchWithUser(items []model.BatchRequestItem, userID string) (map[string]model.BatchSaveResult, error) {
 	if m.saveBatchWithUserFunc != nil {
 		return m.saveBatchWithUserFunc(items, userID)
 	}
-	return make(map[string]string), nil
+	return make(map[string]model.BatchSaveResult), nil
 }
 
 func (m *mockStorage) GetUserURLs(userID string) ([]model.UserURL, error) {
@@ -55,6 +73,14 @@ func (m *mockStorage) GetUserURLs(userID string) ([]model.UserURL, error) {
 	return []model.UserURL{}, nil
 }
 
+func (m *mockStorage) GetUserURLsPaged(userID string, limit, offset int) ([]model.UserURL, int, error) {
+	return []model.UserURL{}, 0, nil
+}
+
+func (m *mockStorage) GetDeletedUserURLs(userID string) ([]string, error) {
+	return []string{}, nil
+}
+
 func (m *mockStorage) GetWithDeletedStatus(id string) (string, error) {
 	if m.getWithDeletedStatusFunc != nil {
 		str, _, err := m.getWithDeletedStatusFunc(id)
@@ -76,24 +102,21 @@ func TestURLService_ShortenURL(t *testing.T) {
 	tests := []struct {
 		name        string
 		originalURL string
-		mockID      string
-		mockErr     error
+		storageErr  error
 		want        string
 		wantErr     bool
 	}{
 		{
 			name:        "Successful shortening",
 			originalURL: "https://example.com",
-			mockID:      "abc123",
-			mockErr:     nil,
-			want:        "http://localhost:8080/abc123",
+			storageErr:  nil,
+			want:        "http://localhost:8080/id1",
 			wantErr:     false,
 		},
 		{
 			name:        "Storage error",
 			originalURL: "https://example.com",
-			mockID:      "",
-			mockErr:     errors.New("storage error"),
+			storageErr:  errors.New("storage error"),
 			want:        "",
 			wantErr:     true,
 		},
@@ -101,13 +124,10 @@ func TestURLService_ShortenURL(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockStorage := &mockStorage{
-				saveFunc: func(originalURL string) (string, error) {
-					return tt.mockID, tt.mockErr
-				},
-			}
+			fakeStorage := storagetest.New()
+			fakeStorage.FailOn(storagetest.MethodSave, tt.storageErr)
 
-			service := NewURLService(mockStorage, baseURL)
+			service := NewURLService(fakeStorage, baseURL)
 			got, err := service.ShortenURL(context.Background(), tt.originalURL)
 
 			if (err != nil) != tt.wantErr {
@@ -125,27 +145,32 @@ func TestURLService_ShortenURL(t *testing.T) {
 func TestURLService_GetOriginalURL(t *testing.T) {
 	baseURL := "http://localhost:8080"
 
+	fakeStorage := storagetest.New()
+	fakeStorage.Put("abc123", "https://example.com", "user1")
+	fakeStorage.Put("gone", "https://gone.example.com", "user1")
+	fakeStorage.DeleteUserURLs("user1", []string{"gone"})
+
 	tests := []struct {
 		name      string
 		id        string
-		mockURL   string
-		mockFound bool
 		wantURL   string
 		wantFound bool
 	}{
 		{
 			name:      "URL found",
 			id:        "abc123",
-			mockURL:   "https://example.com",
-			mockFound: true,
 			wantURL:   "https://example.com",
 			wantFound: true,
 		},
 		{
 			name:      "URL not found",
 			id:        "nonexistent",
-			mockURL:   "",
-			mockFound: false,
+			wantURL:   "",
+			wantFound: false,
+		},
+		{
+			name:      "URL deleted",
+			id:        "gone",
 			wantURL:   "",
 			wantFound: false,
 		},
@@ -153,16 +178,7 @@ func TestURLService_GetOriginalURL(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockStorage := &mockStorage{
-				getFunc: func(id string) (string, bool) {
-					if id == tt.id {
-						return tt.mockURL, tt.mockFound
-					}
-					return "", false
-				},
-			}
-
-			service := NewURLService(mockStorage, baseURL)
+			service := NewURLService(fakeStorage, baseURL)
 			gotURL, gotFound := service.GetOriginalURL(context.Background(), tt.id)
 
 			if gotFound != tt.wantFound {
@@ -209,10 +225,10 @@ func BenchmarkURLService_GetOriginalURL(b *testing.B) {
 func BenchmarkURLService_ShortenBatch(b *testing.B) {
 	baseURL := "http://localhost:8080"
 	mockStorage := &mockStorage{
-		saveBatchFunc: func(items []model.BatchRequestItem) (map[string]string, error) {
-			result := make(map[string]string)
+		saveBatchFunc: func(items []model.BatchRequestItem) (map[string]model.BatchSaveResult, error) {
+			result := make(map[string]model.BatchSaveResult)
 			for i := range items {
-				result[items[i].CorrelationID] = "abc" + string(rune(i))
+				result[items[i].CorrelationID] = model.BatchSaveResult{ID: "abc" + string(rune(i)), Created: true}
 			}
 			return result, nil
 		},
@@ -230,3 +246,749 @@ func BenchmarkURLService_ShortenBatch(b *testing.B) {
 		service.ShortenBatch(context.Background(), items)
 	}
 }
+
+func TestURLService_ShortenURLWithExpiry(t *testing.T) {
+	urlStorage, err := sqlite.NewStorage(sqlite.DSNPrefix + filepath.Join(t.TempDir(), "urls.db"))
+	if err != nil {
+		t.Fatalf("sqlite.NewStorage() error = %v", err)
+	}
+	defer urlStorage.Close()
+
+	service := NewURLService(urlStorage, "http://localhost:8080")
+	ctx := context.Background()
+
+	if _, err := service.ShortenURLWithExpiry(ctx, "https://example.com", time.Now().Add(-time.Minute), "user1"); !errors.Is(err, ErrInvalidExpiry) {
+		t.Errorf("URLService.ShortenURLWithExpiry() past expiry error = %v, want %v", err, ErrInvalidExpiry)
+	}
+
+	first, err := service.ShortenURLWithExpiry(ctx, "https://example.com", time.Now().Add(time.Hour), "user1")
+	if err != nil {
+		t.Fatalf("URLService.ShortenURLWithExpiry() error = %v", err)
+	}
+
+	again, err := service.ShortenURLWithExpiry(ctx, "https://example.com", time.Now().Add(time.Hour), "user1")
+	if !errors.Is(err, storage.ErrURLExists) || again != first {
+		t.Errorf("URLService.ShortenURLWithExpiry() live duplicate = %v, %v, want %v, %v", again, err, first, storage.ErrURLExists)
+	}
+
+	id := strings.TrimPrefix(first, "http://localhost:8080/")
+	urlStorage.SetExpiry(id, time.Now().Add(-time.Minute))
+
+	revived, err := service.ShortenURL(ctx, "https://example.com")
+	if err != nil || revived != first {
+		t.Errorf("URLService.ShortenURL() expired duplicate = %v, %v, want %v, nil", revived, err, first)
+	}
+	if _, err := service.GetOriginalURLWithDeletedStatus(ctx, id); err != nil {
+		t.Errorf("URLService.GetOriginalURLWithDeletedStatus() after revival error = %v, want nil", err)
+	}
+}
+
+func TestURLService_ShortenBatchDuplicates(t *testing.T) {
+	items := []model.BatchRequestItem{
+		{CorrelationID: "1", OriginalURL: "https://a.example.com"},
+		{CorrelationID: "2", OriginalURL: "https://b.example.com"},
+		{CorrelationID: "3", OriginalURL: "https://a.example.com"},
+		{CorrelationID: "4", OriginalURL: "https://b.example.com"},
+		{CorrelationID: "5", OriginalURL: "https://a.example.com"},
+	}
+	groups := [][]string{{"1", "3", "5"}, {"2", "4"}}
+
+	tests := []struct {
+		name    string
+		shorten func(s *URLService) ([]model.BatchResponseItem, error)
+	}{
+		{
+			name: "anonymous",
+			shorten: func(s *URLService) ([]model.BatchResponseItem, error) {
+				return s.ShortenBatch(context.Background(), items)
+			},
+		},
+		{
+			name: "with user",
+			shorten: func(s *URLService) ([]model.BatchResponseItem, error) {
+				return s.ShortenBatchWithUser(context.Background(), items, "user1")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlStorage := memory.NewStorage()
+			result, err := tt.shorten(NewURLService(urlStorage, "http://localhost:8080"))
+			if err != nil {
+				t.Fatalf("URLService batch error = %v", err)
+			}
+			if len(result) != len(items) {
+				t.Fatalf("URLService batch returned %d items, want %d", len(result), len(items))
+			}
+
+			shortURLs := make(map[string]string, len(result))
+			for i, item := range result {
+				if item.CorrelationID != items[i].CorrelationID {
+					t.Errorf("URLService batch item %d correlation ID = %v, want %v", i, item.CorrelationID, items[i].CorrelationID)
+				}
+				shortURLs[item.CorrelationID] = item.ShortURL
+			}
+
+			for _, group := range groups {
+				for _, id := range group[1:] {
+					if shortURLs[id] != shortURLs[group[0]] {
+						t.Errorf("URLService batch short URL for %v = %v, want %v", id, shortURLs[id], shortURLs[group[0]])
+					}
+				}
+			}
+			if shortURLs["1"] == shortURLs["2"] {
+				t.Errorf("URLService batch gave different URLs the same short URL %v", shortURLs["1"])
+			}
+
+			if count, _ := urlStorage.Count(); count != len(groups) {
+				t.Errorf("storage holds %d URLs, want %d", count, len(groups))
+			}
+		})
+	}
+}
+
+func TestURLService_DuplicateURLs(t *testing.T) {
+	items := []model.BatchRequestItem{
+		{CorrelationID: "1", OriginalURL: "https://a.example.com"},
+		{CorrelationID: "2", OriginalURL: "https://a.example.com"},
+	}
+
+	tests := []struct {
+		name       string
+		allow      bool
+		wantShared bool
+		wantCount  int
+	}{
+		{"dedup", false, true, 1},
+		{"duplicates allowed", true, false, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlStorage := memory.NewStorage(memory.WithDuplicateURLs(tt.allow))
+			var opts []Option
+			if tt.allow {
+				opts = append(opts, WithDuplicateURLs())
+			}
+			s := NewURLService(urlStorage, "http://localhost:8080", opts...)
+
+			first, err := s.ShortenURLWithUser(context.Background(), "https://a.example.com", "user1")
+			if err != nil {
+				t.Fatalf("ShortenURLWithUser() error = %v", err)
+			}
+			second, err := s.ShortenURLWithUser(context.Background(), "https://a.example.com", "user1")
+			if tt.allow && err != nil {
+				t.Fatalf("ShortenURLWithUser() of a stored URL error = %v, want nil", err)
+			}
+			if !tt.allow && !errors.Is(err, storage.ErrURLExists) {
+				t.Fatalf("ShortenURLWithUser() of a stored URL error = %v, want ErrURLExists", err)
+			}
+			if shared := first == second; shared != tt.wantShared {
+				t.Errorf("ShortenURLWithUser() twice gave %v and %v, want shared = %v", first, second, tt.wantShared)
+			}
+
+			result, err := s.ShortenBatchWithUser(context.Background(), items, "user1")
+			if tt.allow && err != nil {
+				t.Fatalf("ShortenBatchWithUser() error = %v", err)
+			}
+			if len(result) != len(items) {
+				t.Fatalf("ShortenBatchWithUser() returned %d items, want %d", len(result), len(items))
+			}
+			if shared := result[0].ShortURL == result[1].ShortURL; shared != tt.wantShared {
+				t.Errorf("ShortenBatchWithUser() gave %v and %v for a repeated URL, want shared = %v", result[0].ShortURL, result[1].ShortURL, tt.wantShared)
+			}
+
+			if count, _ := urlStorage.Count(); count != tt.wantCount {
+				t.Errorf("storage holds %d URLs, want %d", count, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestURLService_ShortenURLWithUserQuota(t *testing.T) {
+	const quota = 3
+
+	tests := []struct {
+		name      string
+		existing  int
+		deleted   int
+		anonymous bool
+		wantErr   error
+	}{
+		{name: "just below quota", existing: quota - 1},
+		{name: "at quota", existing: quota, wantErr: ErrQuotaExceeded},
+		{name: "deleted URLs do not count", existing: quota, deleted: 1},
+		{name: "anonymous URLs are not capped", existing: quota, anonymous: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlStorage := memory.NewStorage()
+			var ids []string
+			for i := 0; i < tt.existing; i++ {
+				id, err := urlStorage.SaveWithUser(fmt.Sprintf("https://example.com/%d", i), "user1")
+				if err != nil {
+					t.Fatalf("Storage.SaveWithUser() error = %v", err)
+				}
+				ids = append(ids, id)
+			}
+			urlStorage.DeleteUserURLs("user1", ids[:tt.deleted])
+
+			service := NewURLService(urlStorage, "http://localhost:8080", WithMaxURLsPerUser(quota))
+			userID := "user1"
+			if tt.anonymous {
+				userID = ""
+			}
+
+			_, err := service.ShortenURLWithUser(context.Background(), "https://example.com/new", userID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("URLService.ShortenURLWithUser() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestURLService_ShortenStoredURLAtQuota(t *testing.T) {
+	tests := []struct {
+		name    string
+		shorten func(s *URLService, originalURL string) (string, error)
+	}{
+		{
+			name: "user",
+			shorten: func(s *URLService, originalURL string) (string, error) {
+				return s.ShortenURLWithUser(context.Background(), originalURL, "user1")
+			},
+		},
+		{
+			name: "password",
+			shorten: func(s *URLService, originalURL string) (string, error) {
+				return s.ShortenURLWithPassword(context.Background(), originalURL, "secret", "user1")
+			},
+		},
+		{
+			name: "forward query",
+			shorten: func(s *URLService, originalURL string) (string, error) {
+				return s.ShortenURLWithForwardQuery(context.Background(), originalURL, "user1")
+			},
+		},
+		{
+			name: "expiry",
+			shorten: func(s *URLService, originalURL string) (string, error) {
+				return s.ShortenURLWithExpiry(context.Background(), originalURL, time.Now().Add(time.Hour), "user1")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlStorage := memory.NewStorage()
+			owned, _ := urlStorage.SaveWithUser("https://example.com/owned", "user1")
+			anonymous, _ := urlStorage.Save("https://example.com/anonymous")
+			service := NewURLService(urlStorage, "http://localhost:8080", WithMaxURLsPerUser(1))
+
+			for originalURL, id := range map[string]string{
+				"https://example.com/owned":     owned,
+				"https://example.com/anonymous": anonymous,
+			} {
+				shortURL, err := tt.shorten(service, originalURL)
+				if !errors.Is(err, storage.ErrURLExists) {
+					t.Errorf("%s: expected ErrURLExists at the quota, got %v", originalURL, err)
+				}
+				if want := "http://localhost:8080/" + id; shortURL != want {
+					t.Errorf("%s: expected %q, got %q", originalURL, want, shortURL)
+				}
+			}
+
+			if _, err := tt.shorten(service, "https://example.com/new"); !errors.Is(err, ErrQuotaExceeded) {
+				t.Errorf("Expected ErrQuotaExceeded for a new URL, got %v", err)
+			}
+		})
+	}
+}
+
+func TestURLService_ShortenBatchWithUserQuota(t *testing.T) {
+	const quota = 3
+
+	tests := []struct {
+		name      string
+		items     []model.BatchRequestItem
+		wantErr   error
+		wantCount int
+	}{
+		{
+			name: "reaches quota",
+			items: []model.BatchRequestItem{
+				{CorrelationID: "1", OriginalURL: "https://a.example.com"},
+				{CorrelationID: "2", OriginalURL: "https://b.example.com"},
+				{CorrelationID: "3", OriginalURL: "https://a.example.com"},
+			},
+			wantCount: quota,
+		},
+		{
+			name: "crosses quota",
+			items: []model.BatchRequestItem{
+				{CorrelationID: "1", OriginalURL: "https://a.example.com"},
+				{CorrelationID: "2", OriginalURL: "https://b.example.com"},
+				{CorrelationID: "3", OriginalURL: "https://c.example.com"},
+			},
+			wantErr:   ErrQuotaExceeded,
+			wantCount: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urlStorage := memory.NewStorage()
+			if _, err := urlStorage.SaveWithUser("https://example.com", "user1"); err != nil {
+				t.Fatalf("Storage.SaveWithUser() error = %v", err)
+			}
+
+			service := NewURLService(urlStorage, "http://localhost:8080", WithMaxURLsPerUser(quota))
+			result, err := service.ShortenBatchWithUser(context.Background(), tt.items, "user1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("URLService.ShortenBatchWithUser() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && len(result) != len(tt.items) {
+				t.Errorf("URLService.ShortenBatchWithUser() returned %d items, want %d", len(result), len(tt.items))
+			}
+
+			if count, _ := urlStorage.CountUserURLs("user1"); count != tt.wantCount {
+				t.Errorf("user owns %d URLs, want %d", count, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestURLService_AutoBaseURL(t *testing.T) {
+	s := NewURLService(memory.NewStorage(), baseurl.Auto)
+
+	first := baseurl.WithURL(context.Background(), "http://short.example")
+	second := baseurl.WithURL(context.Background(), "https://go.example")
+
+	shortURL, err := s.ShortenURLWithUser(first, "https://example.com/a", "user1")
+	if err != nil {
+		t.Fatalf("ShortenURLWithUser() error = %v", err)
+	}
+	if !strings.HasPrefix(shortURL, "http://short.example/") {
+		t.Errorf("Expected short URL on http://short.example/, got %s", shortURL)
+	}
+
+	urls, err := s.GetUserURLs(second, "user1")
+	if err != nil {
+		t.Fatalf("GetUserURLs() error = %v", err)
+	}
+	id := strings.TrimPrefix(shortURL, "http://short.example/")
+	if len(urls) != 1 || urls[0].ShortURL != "https://go.example/"+id {
+		t.Errorf("Expected user URL https://go.example/%s, got %+v", id, urls)
+	}
+	if got := s.ShortURL(second, id); got != "https://go.example/"+id {
+		t.Errorf("Expected ShortURL https://go.example/%s, got %s", id, got)
+	}
+}
+
+func TestURLService_ShortenBatchExisting(t *testing.T) {
+	st, err := sqlite.NewStorage(sqlite.DSNPrefix + filepath.Join(t.TempDir(), "urls.db"))
+	if err != nil {
+		t.Fatalf("sqlite.NewStorage() error = %v", err)
+	}
+	defer st.Close()
+	s := NewURLService(st, "http://localhost:8080")
+
+	items := []model.BatchRequestItem{
+		{CorrelationID: "1", OriginalURL: "https://example.com/1"},
+		{CorrelationID: "2", OriginalURL: "https://example.com/2"},
+	}
+
+	first, err := s.ShortenBatch(context.Background(), items)
+	if err != nil {
+		t.Fatalf("ShortenBatch() error = %v", err)
+	}
+
+	second, err := s.ShortenBatch(context.Background(), items)
+	if !errors.Is(err, storage.ErrURLExists) {
+		t.Errorf("ShortenBatch() repeated error = %v, want %v", err, storage.ErrURLExists)
+	}
+	if len(second) != len(first) || second[0] != first[0] || second[1] != first[1] {
+		t.Errorf("ShortenBatch() repeated = %v, want %v", second, first)
+	}
+
+	items = append(items, model.BatchRequestItem{CorrelationID: "3", OriginalURL: "https://example.com/3"})
+	if _, err := s.ShortenBatch(context.Background(), items); err != nil {
+		t.Errorf("ShortenBatch() mixed error = %v, want nil", err)
+	}
+}
+
+func TestURLService_ExpandBatch(t *testing.T) {
+	batched := memory.NewStorage()
+	kept, _ := batched.SaveWithUser("https://a.example.com", "user1")
+	gone, _ := batched.SaveWithUser("https://b.example.com", "user1")
+	expired, _ := batched.SaveWithUser("https://c.example.com", "user1")
+	batched.DeleteUserURLs("user1", []string{gone})
+	batched.SetExpiry(expired, time.Now().Add(-time.Minute))
+
+	perID := &mockStorage{
+		getWithDeletedStatusFunc: func(id string) (string, bool, error) {
+			switch id {
+			case kept:
+				return "https://a.example.com", true, nil
+			case gone:
+				return "", true, storage.ErrURLDeleted
+			case expired:
+				return "", true, storage.ErrURLExpired
+			}
+			return "", false, nil
+		},
+	}
+
+	ids := []string{"missing", kept, gone, expired, kept}
+	want := []model.ExpandedURL{
+		{ID: "missing"},
+		{ID: kept, OriginalURL: "https://a.example.com"},
+		{ID: gone, Deleted: true},
+		{ID: expired, Expired: true},
+		{ID: kept, OriginalURL: "https://a.example.com"},
+	}
+
+	for name, st := range map[string]storage.URLStorage{"batched": batched, "per ID": perID} {
+		t.Run(name, func(t *testing.T) {
+			s := NewURLService(st, "http://localhost:8080")
+
+			got, err := s.ExpandBatch(context.Background(), ids)
+			if err != nil {
+				t.Fatalf("ExpandBatch() error = %v", err)
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("ExpandBatch() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestURLService_DeleteURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		storage func() storage.URLStorage
+	}{
+		// The fake cannot check ownership, so the service lists the user's URLs.
+		{name: "listing fallback", storage: func() storage.URLStorage { return storagetest.New() }},
+		{name: "ownership checker", storage: func() storage.URLStorage { return memory.NewStorage() }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := tt.storage()
+			s := NewURLService(st, "http://localhost:8080")
+			ctx := context.Background()
+
+			owned, _ := st.SaveWithUser("https://example.com/owned", "owner")
+			deleted, _ := st.SaveWithUser("https://example.com/deleted", "owner")
+			if err := st.DeleteUserURLs("owner", []string{deleted}); err != nil {
+				t.Fatalf("DeleteUserURLs() error = %v", err)
+			}
+
+			if err := s.DeleteURL(ctx, "other", owned); !errors.Is(err, ErrNotURLOwner) {
+				t.Errorf("Expected ErrNotURLOwner for another user, got %v", err)
+			}
+			if err := s.DeleteURL(ctx, "owner", "missing"); !errors.Is(err, storage.ErrURLNotFound) {
+				t.Errorf("Expected ErrURLNotFound for an unknown ID, got %v", err)
+			}
+			if owns, err := s.OwnsURL(ctx, "owner", deleted); err != nil || !owns {
+				t.Errorf("Expected the owner to own a deleted URL, got %v, %v", owns, err)
+			}
+
+			if err := s.DeleteURL(ctx, "owner", owned); err != nil {
+				t.Fatalf("DeleteURL() error = %v", err)
+			}
+			if _, err := st.GetWithDeletedStatus(owned); !errors.Is(err, storage.ErrURLDeleted) {
+				t.Errorf("Expected the URL to be deleted, got %v", err)
+			}
+		})
+	}
+}
+
+func TestURLService_ShortURLFormat(t *testing.T) {
+	s := NewURLService(memory.NewStorage(), "http://localhost:8080/s")
+
+	tests := []struct {
+		format baseurl.Format
+		want   string
+	}{
+		{format: "", want: "http://localhost:8080/s/abc123"},
+		{format: baseurl.FormatAbsolute, want: "http://localhost:8080/s/abc123"},
+		{format: baseurl.FormatRelative, want: "/abc123"},
+		{format: baseurl.FormatID, want: "abc123"},
+	}
+
+	for _, tt := range tests {
+		ctx := baseurl.WithFormat(context.Background(), tt.format)
+		if got := s.ShortURL(ctx, "abc123"); got != tt.want {
+			t.Errorf("format %q: expected %q, got %q", tt.format, tt.want, got)
+		}
+	}
+}
+
+// txCountingStorage counts the transactions the service opens.
+type txCountingStorage struct {
+	*memory.Storage
+	txs int
+}
+
+func (s *txCountingStorage) WithTx(ctx context.Context, fn func(storage.URLStorage) error) error {
+	s.txs++
+	return s.Storage.WithTx(ctx, fn)
+}
+
+// failingExpiryStorage fails to set expiries and records the errors its
+// transactions ended with, which a transactional storage would roll back.
+type failingExpiryStorage struct {
+	*memory.Storage
+	txErrs []error
+}
+
+func (s *failingExpiryStorage) WithTx(ctx context.Context, fn func(storage.URLStorage) error) error {
+	err := s.Storage.WithTx(ctx, func(storage.URLStorage) error { return fn(s) })
+	s.txErrs = append(s.txErrs, err)
+	return err
+}
+
+func (s *failingExpiryStorage) SetExpiry(id string, expiresAt time.Time) error {
+	return errors.New("storage error")
+}
+
+func TestURLService_ShortenInTx(t *testing.T) {
+	tests := []struct {
+		name    string
+		shorten func(s *URLService, originalURL string) (string, error)
+		check   func(st *memory.Storage, id string) bool
+	}{
+		{
+			name: "password",
+			shorten: func(s *URLService, originalURL string) (string, error) {
+				return s.ShortenURLWithPassword(context.Background(), originalURL, "secret", "user1")
+			},
+			check: func(st *memory.Storage, id string) bool {
+				hash, err := st.PasswordHash(id)
+				return err == nil && hash != ""
+			},
+		},
+		{
+			name: "forward query",
+			shorten: func(s *URLService, originalURL string) (string, error) {
+				return s.ShortenURLWithForwardQuery(context.Background(), originalURL, "user1")
+			},
+			check: func(st *memory.Storage, id string) bool {
+				enabled, err := st.ForwardQuery(id)
+				return err == nil && enabled
+			},
+		},
+		{
+			name: "expiry",
+			shorten: func(s *URLService, originalURL string) (string, error) {
+				return s.ShortenURLWithExpiry(context.Background(), originalURL, time.Now().Add(time.Hour), "user1")
+			},
+			check: func(st *memory.Storage, id string) bool {
+				_, err := st.GetWithDeletedStatus(id)
+				return err == nil
+			},
+		},
+		{
+			name: "user",
+			shorten: func(s *URLService, originalURL string) (string, error) {
+				return s.ShortenURLWithUser(context.Background(), originalURL, "user1")
+			},
+			check: func(st *memory.Storage, id string) bool {
+				_, found := st.Get(id)
+				return found
+			},
+		},
+		{
+			name: "alias",
+			shorten: func(s *URLService, originalURL string) (string, error) {
+				alias := "alias-" + strings.ReplaceAll(strings.TrimPrefix(originalURL, "https://example.com/"), "/", "-")
+				return s.ShortenURLWithAlias(context.Background(), originalURL, alias, "user1")
+			},
+			check: func(st *memory.Storage, id string) bool {
+				_, found := st.Get(id)
+				return found
+			},
+		},
+		{
+			name: "batch",
+			shorten: func(s *URLService, originalURL string) (string, error) {
+				items, err := s.ShortenBatchWithUser(context.Background(), []model.BatchRequestItem{
+					{CorrelationID: "1", OriginalURL: originalURL},
+				}, "user1")
+				if len(items) == 0 {
+					return "", err
+				}
+				return items[0].ShortURL, err
+			},
+			check: func(st *memory.Storage, id string) bool {
+				_, found := st.Get(id)
+				return found
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := &txCountingStorage{Storage: memory.NewStorage()}
+			s := NewURLService(st, "http://localhost:8080", WithMaxURLsPerUser(1))
+
+			shortURL, err := tt.shorten(s, "https://example.com/tx")
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if st.txs != 1 {
+				t.Errorf("Expected 1 transaction, got %d", st.txs)
+			}
+			if id := strings.TrimPrefix(shortURL, "http://localhost:8080/"); !tt.check(st.Storage, id) {
+				t.Errorf("Expected %s to be set on %q", tt.name, id)
+			}
+
+			if _, err := tt.shorten(s, "https://example.com/tx/over-quota"); !errors.Is(err, ErrQuotaExceeded) {
+				t.Errorf("Expected ErrQuotaExceeded from within the transaction, got %v", err)
+			}
+		})
+	}
+}
+
+func TestURLService_ShortenWithExpiryFailsTx(t *testing.T) {
+	st := &failingExpiryStorage{Storage: memory.NewStorage()}
+	s := NewURLService(st, "http://localhost:8080")
+
+	_, err := s.ShortenURLWithExpiry(context.Background(), "https://example.com/expiry", time.Now().Add(time.Hour), "user1")
+	if err == nil {
+		t.Fatal("Expected an error, got nil")
+	}
+	if len(st.txErrs) != 1 || !errors.Is(st.txErrs[0], err) {
+		t.Errorf("Expected the transaction to end with %v, got %v", err, st.txErrs)
+	}
+}
+
+func TestURLService_CachedUnsupported(t *testing.T) {
+	tests := []struct {
+		name    string
+		call    func(s *URLService, id string) error
+		wantErr error
+	}{
+		{
+			name: "stats",
+			call: func(s *URLService, id string) error {
+				_, _, err := s.GetURLStats(context.Background(), id)
+				return err
+			},
+			wantErr: ErrStatsUnsupported,
+		},
+		{
+			name: "password",
+			call: func(s *URLService, id string) error {
+				_, err := s.ShortenURLWithPassword(context.Background(), "https://example.com/password", "secret", "user1")
+				return err
+			},
+			wantErr: ErrPasswordsUnsupported,
+		},
+		{
+			name: "password check",
+			call: func(s *URLService, id string) error {
+				return s.VerifyURLPassword(context.Background(), id, "secret")
+			},
+		},
+		{
+			name: "expiry",
+			call: func(s *URLService, id string) error {
+				_, err := s.ShortenURLWithExpiry(context.Background(), "https://example.com/expiry", time.Now().Add(time.Hour), "user1")
+				return err
+			},
+			wantErr: ErrExpiryUnsupported,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The fake lacks every optional capability the cache forwards.
+			st := storage.NewCachingStorage(storagetest.New(), 10, time.Minute)
+			s := NewURLService(st, "http://localhost:8080")
+
+			id, err := st.Save("https://example.com")
+			if err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+
+			if err := tt.call(s, id); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestURLService_GetUserURLsPagedOrder(t *testing.T) {
+	s := NewURLService(memory.NewStorage(), "http://localhost:8080")
+	originals := []string{"https://a.example.com", "https://b.example.com", "https://c.example.com", "https://d.example.com", "https://e.example.com"}
+	for _, original := range originals {
+		if _, err := s.ShortenURLWithUser(context.Background(), original, "user1"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		order  model.UserURLOrder
+		limit  int
+		offset int
+		want   []string
+	}{
+		{"newest first page", model.NewestFirst, 2, 0, []string{originals[4], originals[3]}},
+		{"newest first middle page", model.NewestFirst, 2, 2, []string{originals[2], originals[1]}},
+		{"newest first last partial page", model.NewestFirst, 2, 4, []string{originals[0]}},
+		{"newest first past end", model.NewestFirst, 2, 5, nil},
+		{"newest first all", model.NewestFirst, 10, 0, []string{originals[4], originals[3], originals[2], originals[1], originals[0]}},
+		{"oldest first page", model.OldestFirst, 2, 1, []string{originals[1], originals[2]}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urls, total, err := s.GetUserURLsPaged(context.Background(), "user1", tt.order, tt.limit, tt.offset)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if total != len(originals) {
+				t.Errorf("Expected total %d, got %d", len(originals), total)
+			}
+
+			var got []string
+			for _, url := range urls {
+				got = append(got, url.OriginalURL)
+				if url.CreatedAt.IsZero() {
+					t.Errorf("Expected CreatedAt to be set for %s", url.OriginalURL)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestURLService_URLExpiry(t *testing.T) {
+	s := NewURLService(memory.NewStorage(), "http://localhost:8080")
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour).UTC()
+
+	expiring, err := s.ShortenURLWithExpiry(ctx, "https://example.com/expiring", expiresAt, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	permanent, err := s.ShortenURL(ctx, "https://example.com/permanent")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if got, err := s.URLExpiry(ctx, strings.TrimPrefix(expiring, "http://localhost:8080/")); err != nil || !got.Equal(expiresAt) {
+		t.Errorf("Expected expiry %v, got %v, %v", expiresAt, got, err)
+	}
+	if got, err := s.URLExpiry(ctx, strings.TrimPrefix(permanent, "http://localhost:8080/")); err != nil || !got.IsZero() {
+		t.Errorf("Expected no expiry, got %v, %v", got, err)
+	}
+}
@@ -0,0 +1,329 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/MikhailRaia/url-shortener/internal/model"
+)
+
+// ErrUnsupported indicates the storage wrapped by CachingStorage lacks an optional capability.
+var ErrUnsupported = errors.New("operation not supported by underlying storage")
+
+// CachingStorage is a read-through cache in front of any URLStorage.
+// Lookups by short ID are served from an in-process LRU whose entries expire
+// after a TTL; deletions evict the affected IDs so a deleted URL is reported
+// as such on the next lookup. Writes go straight to the wrapped storage.
+type CachingStorage struct {
+	URLStorage
+
+	cache  *TTLCache[cacheEntry]
+	prefix string
+}
+
+// NewCachingStorage wraps inner with a cache of at most size entries, each kept for ttl.
+// A non-positive ttl keeps entries until they are evicted or invalidated.
+func NewCachingStorage(inner URLStorage, size int, ttl time.Duration) *CachingStorage {
+	return &CachingStorage{
+		URLStorage: inner,
+		cache:      NewTTLCache[cacheEntry](size, ttl),
+	}
+}
+
+// ForTenant returns a cached view of the wrapped storage scoped to the given tenant.
+// The view shares the cache, keyed by tenant, with the parent.
+func (c *CachingStorage) ForTenant(tenantID string) URLStorage {
+	scoper, ok := c.URLStorage.(TenantScoper)
+	if !ok {
+		return c
+	}
+
+	return &CachingStorage{
+		URLStorage: scoper.ForTenant(tenantID),
+		cache:      c.cache,
+		prefix:     tenantID + "\x00",
+	}
+}
+
+// WithContext returns a cached view of the wrapped storage bound to ctx.
+// The view shares the cache with the parent.
+func (c *CachingStorage) WithContext(ctx context.Context) URLStorage {
+	binder, ok := c.URLStorage.(ContextBinder)
+	if !ok {
+		return c
+	}
+
+	return &CachingStorage{
+		URLStorage: binder.WithContext(ctx),
+		cache:      c.cache,
+		prefix:     c.prefix,
+	}
+}
+
+// WithTx runs fn in a transaction of the wrapped storage, with a cached view
+// of the transaction sharing the cache with c. Storages without transactions
+// run fn with c itself.
+func (c *CachingStorage) WithTx(ctx context.Context, fn func(URLStorage) error) error {
+	txStorage, ok := c.URLStorage.(TxStorage)
+	if !ok {
+		return fn(c)
+	}
+
+	return txStorage.WithTx(ctx, func(tx URLStorage) error {
+		return fn(&CachingStorage{
+			URLStorage: tx,
+			cache:      c.cache,
+			prefix:     c.prefix,
+		})
+	})
+}
+
+// Get retrieves the original URL for a given short ID.
+func (c *CachingStorage) Get(id string) (string, bool) {
+	originalURL, err := c.GetWithDeletedStatus(id)
+	if err != nil || originalURL == "" {
+		return "", false
+	}
+
+	return originalURL, true
+}
+
+// GetWithDeletedStatus retrieves the original URL and checks if it has been deleted.
+// Unknown IDs are not cached, so a URL saved later under the same ID is found.
+func (c *CachingStorage) GetWithDeletedStatus(id string) (string, error) {
+	if entry, ok := c.cache.Get(c.prefix + id); ok {
+		if entry.deleted {
+			return "", ErrURLDeleted
+		}
+		if !entry.expiresAt.IsZero() && !time.Now().Before(entry.expiresAt) {
+			return "", ErrURLExpired
+		}
+		return entry.url, nil
+	}
+
+	originalURL, err := c.URLStorage.GetWithDeletedStatus(id)
+	switch {
+	case errors.Is(err, ErrURLDeleted):
+		c.cache.Add(c.prefix+id, cacheEntry{deleted: true})
+	case err == nil && originalURL != "":
+		expiresAt, expiryErr := c.Expiry(id)
+		if expiryErr != nil {
+			// Without the expiry the entry could outlive it, so skip caching.
+			break
+		}
+		c.cache.Add(c.prefix+id, cacheEntry{url: originalURL, expiresAt: expiresAt})
+	}
+
+	return originalURL, err
+}
+
+// DeleteUserURLs marks specified URLs as deleted for a user and evicts them from the cache.
+func (c *CachingStorage) DeleteUserURLs(userID string, urlIDs []string) error {
+	err := c.URLStorage.DeleteUserURLs(userID, urlIDs)
+
+	// Evict even on error: the backend may have applied part of the deletion.
+	for _, id := range urlIDs {
+		c.cache.Remove(c.prefix + id)
+	}
+
+	return err
+}
+
+// Count reports the wrapped storage's URL count if it supports counting.
+func (c *CachingStorage) Count() (int, error) {
+	counter, ok := c.URLStorage.(URLCounter)
+	if !ok {
+		return 0, ErrUnsupported
+	}
+
+	return counter.Count()
+}
+
+// CountUserURLs reports the wrapped storage's count of a user's URLs if it supports counting them.
+func (c *CachingStorage) CountUserURLs(userID string) (int, error) {
+	counter, ok := c.URLStorage.(UserURLCounter)
+	if !ok {
+		return 0, ErrUnsupported
+	}
+
+	return counter.CountUserURLs(userID)
+}
+
+// ImportURLs forwards to the wrapped storage if it supports importing URLs.
+// Imported IDs were not stored before, so no cached lookup is stale.
+func (c *CachingStorage) ImportURLs(records []model.URLRecord) error {
+	importer, ok := c.URLStorage.(Importer)
+	if !ok {
+		return ErrUnsupported
+	}
+
+	return importer.ImportURLs(records)
+}
+
+// GetBatch forwards to the wrapped storage if it supports batched lookups.
+func (c *CachingStorage) GetBatch(ids []string) (map[string]model.ExpandedURL, error) {
+	getter, ok := c.URLStorage.(BatchGetter)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	return getter.GetBatch(ids)
+}
+
+// ExportURLs forwards to the wrapped storage if it supports exporting URLs.
+func (c *CachingStorage) ExportURLs(fn func(model.URLRecord) error) error {
+	exporter, ok := c.URLStorage.(Exporter)
+	if !ok {
+		return ErrUnsupported
+	}
+
+	return exporter.ExportURLs(fn)
+}
+
+// SetPasswordHash forwards to the wrapped storage if it supports password protection.
+func (c *CachingStorage) SetPasswordHash(id, hash string) error {
+	protector, ok := c.URLStorage.(PasswordProtector)
+	if !ok {
+		return ErrUnsupported
+	}
+
+	return protector.SetPasswordHash(id, hash)
+}
+
+// PasswordHash forwards to the wrapped storage if it supports password protection.
+func (c *CachingStorage) PasswordHash(id string) (string, error) {
+	protector, ok := c.URLStorage.(PasswordProtector)
+	if !ok {
+		return "", ErrUnsupported
+	}
+
+	return protector.PasswordHash(id)
+}
+
+// SetForwardQuery forwards to the wrapped storage if it supports query forwarding.
+func (c *CachingStorage) SetForwardQuery(id string, enabled bool) error {
+	forwarder, ok := c.URLStorage.(QueryForwarder)
+	if !ok {
+		return ErrUnsupported
+	}
+
+	return forwarder.SetForwardQuery(id, enabled)
+}
+
+// ForwardQuery forwards to the wrapped storage if it supports query forwarding.
+func (c *CachingStorage) ForwardQuery(id string) (bool, error) {
+	forwarder, ok := c.URLStorage.(QueryForwarder)
+	if !ok {
+		return false, ErrUnsupported
+	}
+
+	return forwarder.ForwardQuery(id)
+}
+
+// OwnsURL forwards to the wrapped storage if it can check ownership.
+func (c *CachingStorage) OwnsURL(userID, id string) (bool, error) {
+	checker, ok := c.URLStorage.(OwnershipChecker)
+	if !ok {
+		return false, ErrUnsupported
+	}
+
+	return checker.OwnsURL(userID, id)
+}
+
+// FindID forwards to the wrapped storage if it can find stored URLs.
+func (c *CachingStorage) FindID(originalURL string) (string, error) {
+	finder, ok := c.URLStorage.(URLFinder)
+	if !ok {
+		return "", ErrUnsupported
+	}
+
+	return finder.FindID(originalURL)
+}
+
+// SetCreator forwards to the wrapped storage if it records creators.
+func (c *CachingStorage) SetCreator(id string, creator model.Creator) error {
+	recorder, ok := c.URLStorage.(CreatorRecorder)
+	if !ok {
+		return ErrUnsupported
+	}
+
+	return recorder.SetCreator(id, creator)
+}
+
+// Creator forwards to the wrapped storage if it records creators.
+func (c *CachingStorage) Creator(id string) (model.Creator, error) {
+	recorder, ok := c.URLStorage.(CreatorRecorder)
+	if !ok {
+		return model.Creator{}, ErrUnsupported
+	}
+
+	return recorder.Creator(id)
+}
+
+// IncrementVisit forwards to the wrapped storage if it tracks visits.
+func (c *CachingStorage) IncrementVisit(id string, n int64, at time.Time) error {
+	tracker, ok := c.URLStorage.(VisitTracker)
+	if !ok {
+		return ErrUnsupported
+	}
+
+	return tracker.IncrementVisit(id, n, at)
+}
+
+// GetStats forwards to the wrapped storage if it tracks visits.
+func (c *CachingStorage) GetStats(id string) (model.URLStats, error) {
+	tracker, ok := c.URLStorage.(VisitTracker)
+	if !ok {
+		return model.URLStats{}, ErrUnsupported
+	}
+
+	return tracker.GetStats(id)
+}
+
+// SetExpiry forwards to the wrapped storage if it supports expiry and evicts the URL.
+func (c *CachingStorage) SetExpiry(id string, expiresAt time.Time) error {
+	expirer, ok := c.URLStorage.(Expirer)
+	if !ok {
+		return ErrUnsupported
+	}
+
+	err := expirer.SetExpiry(id, expiresAt)
+	c.cache.Remove(c.prefix + id)
+	return err
+}
+
+// Expiry forwards to the wrapped storage if it supports expiry.
+func (c *CachingStorage) Expiry(id string) (time.Time, error) {
+	expirer, ok := c.URLStorage.(Expirer)
+	if !ok {
+		return time.Time{}, nil
+	}
+
+	return expirer.Expiry(id)
+}
+
+// PurgeExpired forwards to the wrapped storage if it supports expiry. Purged
+// IDs are not reported, so the cached URLs of this view are evicted whenever
+// anything may have been purged.
+func (c *CachingStorage) PurgeExpired(before time.Time) (int, error) {
+	expirer, ok := c.URLStorage.(Expirer)
+	if !ok {
+		return 0, nil
+	}
+
+	n, err := expirer.PurgeExpired(before)
+
+	// Evict even on error: the backend may have purged some URLs.
+	if n > 0 || err != nil {
+		c.cache.RemovePrefix(c.prefix)
+	}
+
+	return n, err
+}
+
+type cacheEntry struct {
+	url       string
+	deleted   bool
+	expiresAt time.Time
+}
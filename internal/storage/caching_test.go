@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// countingStorage is a URLStorage stub that records how often lookups reach it.
+type countingStorage struct {
+	URLStorage
+
+	urls    map[string]string
+	deleted map[string]bool
+	lookups int
+}
+
+func newCountingStorage() *countingStorage {
+	return &countingStorage{
+		urls:    map[string]string{"abc": "https://example.com"},
+		deleted: make(map[string]bool),
+	}
+}
+
+func (s *countingStorage) GetWithDeletedStatus(id string) (string, error) {
+	s.lookups++
+	if s.deleted[id] {
+		return "", ErrURLDeleted
+	}
+	return s.urls[id], nil
+}
+
+func (s *countingStorage) DeleteUserURLs(userID string, urlIDs []string) error {
+	for _, id := range urlIDs {
+		s.deleted[id] = true
+	}
+	return nil
+}
+
+func TestCachingStorage_HitAvoidsBackend(t *testing.T) {
+	backend := newCountingStorage()
+	c := NewCachingStorage(backend, 10, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		got, found := c.Get("abc")
+		if !found || got != "https://example.com" {
+			t.Fatalf("CachingStorage.Get() = %v, %v, want %v, true", got, found, "https://example.com")
+		}
+	}
+
+	if backend.lookups != 1 {
+		t.Errorf("backend lookups = %d, want 1", backend.lookups)
+	}
+}
+
+func TestCachingStorage_MissesAreNotCached(t *testing.T) {
+	backend := newCountingStorage()
+	c := NewCachingStorage(backend, 10, time.Minute)
+
+	if _, found := c.Get("new"); found {
+		t.Fatalf("CachingStorage.Get() found an unknown ID")
+	}
+
+	backend.urls["new"] = "https://new.example.com"
+
+	if got, found := c.Get("new"); !found || got != "https://new.example.com" {
+		t.Errorf("CachingStorage.Get() = %v, %v, want the newly saved URL", got, found)
+	}
+}
+
+func TestCachingStorage_DeleteInvalidates(t *testing.T) {
+	backend := newCountingStorage()
+	c := NewCachingStorage(backend, 10, time.Minute)
+
+	if _, err := c.GetWithDeletedStatus("abc"); err != nil {
+		t.Fatalf("CachingStorage.GetWithDeletedStatus() error = %v", err)
+	}
+
+	if err := c.DeleteUserURLs("user1", []string{"abc"}); err != nil {
+		t.Fatalf("CachingStorage.DeleteUserURLs() error = %v", err)
+	}
+
+	if _, err := c.GetWithDeletedStatus("abc"); err != ErrURLDeleted {
+		t.Errorf("CachingStorage.GetWithDeletedStatus() error = %v, want %v", err, ErrURLDeleted)
+	}
+
+	// The deleted status is itself cached.
+	lookups := backend.lookups
+	if _, err := c.GetWithDeletedStatus("abc"); err != ErrURLDeleted {
+		t.Errorf("CachingStorage.GetWithDeletedStatus() error = %v, want %v", err, ErrURLDeleted)
+	}
+	if backend.lookups != lookups {
+		t.Errorf("backend lookups = %d, want %d", backend.lookups, lookups)
+	}
+}
+
+func TestCachingStorage_TTLExpiry(t *testing.T) {
+	backend := newCountingStorage()
+	c := NewCachingStorage(backend, 10, time.Minute)
+
+	now := time.Now()
+	c.cache.now = func() time.Time { return now }
+
+	c.Get("abc")
+	c.Get("abc")
+	if backend.lookups != 1 {
+		t.Fatalf("backend lookups = %d, want 1", backend.lookups)
+	}
+
+	now = now.Add(time.Minute)
+
+	c.Get("abc")
+	if backend.lookups != 2 {
+		t.Errorf("backend lookups after expiry = %d, want 2", backend.lookups)
+	}
+}
+
+func TestCachingStorage_EvictsLeastRecentlyUsed(t *testing.T) {
+	backend := newCountingStorage()
+	backend.urls["def"] = "https://def.example.com"
+	c := NewCachingStorage(backend, 1, time.Minute)
+
+	c.Get("abc")
+	c.Get("def")
+	c.Get("abc")
+
+	if backend.lookups != 3 {
+		t.Errorf("backend lookups = %d, want 3", backend.lookups)
+	}
+}
+
+// txStorage is a countingStorage that supports WithTx.
+type txStorage struct {
+	*countingStorage
+	txs int
+}
+
+func (s *txStorage) WithTx(ctx context.Context, fn func(URLStorage) error) error {
+	s.txs++
+	return fn(s)
+}
+
+func TestCachingStorage_WithTx(t *testing.T) {
+	backend := &txStorage{countingStorage: newCountingStorage()}
+	c := NewCachingStorage(backend, 10, time.Minute)
+
+	err := c.WithTx(context.Background(), func(tx URLStorage) error {
+		if _, ok := tx.(*CachingStorage); !ok {
+			t.Errorf("WithTx() view is %T, want *CachingStorage", tx)
+		}
+		tx.Get("abc")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("CachingStorage.WithTx() error = %v", err)
+	}
+	if backend.txs != 1 {
+		t.Errorf("backend transactions = %d, want 1", backend.txs)
+	}
+
+	c.Get("abc")
+	if backend.lookups != 1 {
+		t.Errorf("backend lookups = %d, want 1: the transaction view should share the cache", backend.lookups)
+	}
+
+	plain := NewCachingStorage(newCountingStorage(), 10, time.Minute)
+	if err := plain.WithTx(context.Background(), func(tx URLStorage) error {
+		if tx != plain {
+			t.Errorf("WithTx() without backend transactions got %v, want the caching storage itself", tx)
+		}
+		return nil
+	}); err != nil {
+		t.Errorf("CachingStorage.WithTx() without backend transactions error = %v", err)
+	}
+}
+
+// expiringStorage is a countingStorage that supports expiry; PurgeExpired
+// removes every URL.
+type expiringStorage struct {
+	*countingStorage
+}
+
+func (s *expiringStorage) SetExpiry(id string, expiresAt time.Time) error { return nil }
+
+func (s *expiringStorage) Expiry(id string) (time.Time, error) { return time.Time{}, nil }
+
+func (s *expiringStorage) PurgeExpired(before time.Time) (int, error) {
+	n := len(s.urls)
+	s.urls = make(map[string]string)
+	return n, nil
+}
+
+func TestCachingStorage_PurgeInvalidates(t *testing.T) {
+	backend := &expiringStorage{countingStorage: newCountingStorage()}
+	c := NewCachingStorage(backend, 10, time.Minute)
+
+	if _, found := c.Get("abc"); !found {
+		t.Fatalf("CachingStorage.Get() did not find %q", "abc")
+	}
+
+	if n, err := c.PurgeExpired(time.Now()); err != nil || n != 1 {
+		t.Fatalf("CachingStorage.PurgeExpired() = %v, %v, want 1, nil", n, err)
+	}
+
+	if got, found := c.Get("abc"); found {
+		t.Errorf("CachingStorage.Get() after purge = %v, want not found", got)
+	}
+}
@@ -2,164 +2,475 @@ package file
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/MikhailRaia/url-shortener/internal/generator"
 	"github.com/MikhailRaia/url-shortener/internal/model"
 	"github.com/MikhailRaia/url-shortener/internal/storage"
+	"github.com/rs/zerolog/log"
 )
 
+// FormatVersion is the version of the file format written by this package.
+// Files start with a {"version":N} header line followed by one URLRecord per
+// line; version 0 files have no header and are upgraded when loaded.
+const FormatVersion = 1
+
+// ErrUnsupportedVersion is returned for a file written in a newer format.
+var ErrUnsupportedVersion = errors.New("unsupported storage file version")
+
+// maxIDAttempts bounds retries when a generated short ID collides with an existing one.
+const maxIDAttempts = 10
+
+// ErrNotLoaded is returned by every call once a background load failed, so
+// partly loaded data is neither served nor written back to the file.
+var ErrNotLoaded = errors.New("file storage is not loaded")
+
+// fileHeader is the first line of a versioned storage file.
+type fileHeader struct {
+	Version *int `json:"version"`
+	// LastUUID is the last URL UUID handed out when the file was rewritten,
+	// so that the sequence survives records dropped by compaction.
+	LastUUID int64 `json:"last_uuid,omitempty"`
+}
+
 // Storage implements URLStorage backed by an append-only JSONL file.
 type Storage struct {
+	*data
+	tenantID string
+}
+
+// data is the state shared by all tenant views of a Storage.
+type data struct {
 	filePath      string
+	file          *os.File
 	urlMap        map[string]string
+	cache         *lru
 	reverseURLMap map[string]string
 	userURLs      map[string][]model.URL
-	deletedMap    map[string]bool
-	idCounter     int
-	mu            sync.RWMutex
-	fileWriteMu   sync.Mutex
+	// deletedMap holds every known short ID key, mapped to whether it is deleted.
+	deletedMap map[string]bool
+	passwords  map[string]string
+	forwarding map[string]bool
+	creators   map[string]model.Creator
+	stats      map[string]model.URLStats
+	expiries   map[string]time.Time
+	// lastUUID is the UUID of the last URL record. It only grows, and is
+	// advanced while appending under fileWriteMu so that UUIDs increase
+	// through the file.
+	lastUUID    atomic.Int64
+	ids         generator.IDGenerator
+	duplicates  bool
+	mu          sync.RWMutex
+	fileWriteMu sync.Mutex
+	// txMu serializes WithTx calls.
+	txMu sync.Mutex
+	// loadErr is the error a background load failed with, wrapping
+	// ErrNotLoaded. It is set before the load releases mu.
+	loadErr error
+
+	backgroundLoad bool
+	// loaded is closed once the file has been loaded and opened for appending.
+	loaded chan struct{}
+	// loadedBytes and loadSize track the progress of loading the file.
+	loadedBytes atomic.Int64
+	loadSize    atomic.Int64
+}
+
+// Option configures optional file storage features.
+type Option func(*data)
+
+// WithCacheSize bounds the in-memory forward map to an LRU cache of the given
+// number of URLs. Cache misses are served by scanning the file. A non-positive
+// size keeps every URL resident, which is the default.
+func WithCacheSize(size int) Option {
+	return func(d *data) {
+		if size > 0 {
+			d.cache = newLRU(size)
+		}
+	}
+}
+
+// WithIDGenerator makes the storage generate short IDs with ids instead of
+// generator.Default.
+func WithIDGenerator(ids generator.IDGenerator) Option {
+	return func(d *data) {
+		d.ids = ids
+	}
+}
+
+// WithDuplicateURLs makes every save of a URL store it under a fresh short ID
+// instead of returning the ID it was first saved under.
+func WithDuplicateURLs(allow bool) Option {
+	return func(d *data) {
+		d.duplicates = allow
+	}
+}
+
+// WithBackgroundLoad makes NewStorage return before the file has been read,
+// so a large file does not delay startup. Calls wait until loading finishes,
+// and Ready reports when it has so health checks can hold traffic back
+// meanwhile. A failed load is logged and leaves the storage never ready.
+func WithBackgroundLoad(enabled bool) Option {
+	return func(d *data) {
+		d.backgroundLoad = enabled
+	}
 }
 
 // NewStorage creates a file-backed storage at the provided path.
-func NewStorage(filePath string) (*Storage, error) {
+func NewStorage(filePath string, opts ...Option) (*Storage, error) {
 	dir := filepath.Dir(filePath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	storage := &Storage{
-		filePath:      filePath,
-		urlMap:        make(map[string]string),
-		reverseURLMap: make(map[string]string),
-		userURLs:      make(map[string][]model.URL),
-		deletedMap:    make(map[string]bool),
-		idCounter:     0,
+	s := &Storage{
+		data: &data{
+			filePath:      filePath,
+			urlMap:        make(map[string]string),
+			reverseURLMap: make(map[string]string),
+			userURLs:      make(map[string][]model.URL),
+			deletedMap:    make(map[string]bool),
+			passwords:     make(map[string]string),
+			forwarding:    make(map[string]bool),
+			creators:      make(map[string]model.Creator),
+			stats:         make(map[string]model.URLStats),
+			expiries:      make(map[string]time.Time),
+			loaded:        make(chan struct{}),
+			ids:           generator.Default(),
+		},
 	}
 
-	if err := storage.loadFromFile(); err != nil {
-		return nil, err
+	for _, opt := range opts {
+		opt(s.data)
+	}
+
+	if !s.backgroundLoad {
+		if err := s.load(); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+
+	// Take the locks before returning so that every call waits for the load.
+	s.mu.Lock()
+	s.fileWriteMu.Lock()
+	go func() {
+		defer s.mu.Unlock()
+		defer s.fileWriteMu.Unlock()
+
+		start := time.Now()
+		if err := s.load(); err != nil {
+			log.Error().Err(err).Str("path", filePath).Msg("Failed to load file storage")
+			s.loadErr = fmt.Errorf("%w: %w", ErrNotLoaded, err)
+			return
+		}
+		log.Info().Str("path", filePath).Dur("elapsed", time.Since(start)).Msg("File storage loaded")
+	}()
+
+	return s, nil
+}
+
+// load reads the file into memory and opens it for appending. Callers must
+// hold mu and fileWriteMu, or have the storage to themselves.
+func (s *Storage) load() error {
+	if err := s.loadFromFile(); err != nil {
+		return err
+	}
+
+	if err := s.openForAppend(); err != nil {
+		return err
+	}
+
+	close(s.loaded)
+	return nil
+}
+
+// loadError returns the error a background load failed with, so that calls
+// fail instead of serving the partly loaded maps. It waits for a running load.
+func (s *Storage) loadError() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.loadErr
+}
+
+// Ready reports whether the file has been loaded. It is only false while a
+// background load is running or after it failed.
+func (s *Storage) Ready() bool {
+	select {
+	case <-s.loaded:
+		return true
+	default:
+		return false
+	}
+}
+
+// Loaded returns a channel closed once the file has been loaded.
+func (s *Storage) Loaded() <-chan struct{} {
+	return s.loaded
+}
+
+// LoadProgress returns the fraction of the file loaded so far, from 0 to 1.
+func (s *Storage) LoadProgress() float64 {
+	if s.Ready() {
+		return 1
+	}
+
+	size := s.loadSize.Load()
+	if size == 0 {
+		return 0
+	}
+	return min(float64(s.loadedBytes.Load())/float64(size), 1)
+}
+
+// ForTenant returns a view of the storage scoped to the given tenant.
+func (s *Storage) ForTenant(tenantID string) storage.URLStorage {
+	return &Storage{
+		data:     s.data,
+		tenantID: tenantID,
+	}
+}
+
+// WithTx runs fn with the storage, serialized against other WithTx calls on
+// the same data so that checks made in fn still hold when it writes.
+// Operations outside WithTx are not held back, and changes fn made before
+// failing are kept: there is no rollback.
+func (s *Storage) WithTx(ctx context.Context, fn func(storage.URLStorage) error) error {
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+
+	return fn(s)
+}
+
+// key namespaces an ID, user ID or original URL by the given tenant.
+func key(tenantID, id string) string {
+	if tenantID == "" {
+		return id
+	}
+	return tenantID + "\x00" + id
+}
+
+// splitKey is the inverse of key.
+func splitKey(k string) (tenantID, id string) {
+	if tenantID, id, found := strings.Cut(k, "\x00"); found {
+		return tenantID, id
 	}
+	return "", k
+}
+
+func (s *Storage) key(id string) string {
+	return key(s.tenantID, id)
+}
 
-	return storage, nil
+// existingID returns the ID originalURL is already stored under in the view's
+// tenant, unless duplicate URLs are allowed. Callers must hold mu.
+func (s *Storage) existingID(originalURL string) (string, bool) {
+	if s.duplicates {
+		return "", false
+	}
+	id, exists := s.reverseURLMap[s.key(originalURL)]
+	return id, exists
 }
 
 // Save stores a new URL and returns its generated short ID.
 func (s *Storage) Save(originalURL string) (string, error) {
+	if err := s.loadError(); err != nil {
+		return "", err
+	}
 	s.mu.Lock()
-	if existingID, exists := s.reverseURLMap[originalURL]; exists {
+	if existingID, exists := s.existingID(originalURL); exists {
 		s.mu.Unlock()
 		return existingID, storage.ErrURLExists
 	}
 
-	id, err := generator.GenerateID(8)
+	id, err := s.newID()
 	if err != nil {
 		s.mu.Unlock()
 		return "", err
 	}
 
-	s.idCounter++
-	uuid := strconv.Itoa(s.idCounter)
-	s.urlMap[id] = originalURL
-	s.reverseURLMap[originalURL] = id
+	if err := s.storeNewURL(id, originalURL, ""); err != nil {
+		s.mu.Unlock()
+		return "", err
+	}
+	s.reverseURLMap[s.key(originalURL)] = id
 	s.mu.Unlock()
 
+	return id, nil
+}
+
+// storeNewURL appends the record of a new URL to the file and only then adds
+// the URL to memory, so a lookup never finds a URL the file lacks. Callers
+// must hold mu for writing and update reverseURLMap themselves.
+func (s *Storage) storeNewURL(id, originalURL, userID string) error {
+	createdAt := time.Now().UTC()
 	record := model.URLRecord{
-		UUID:        uuid,
 		ShortURL:    id,
 		OriginalURL: originalURL,
-		UserID:      "",
+		UserID:      userID,
 		IsDeleted:   false,
+		TenantID:    s.tenantID,
+		CreatedAt:   &createdAt,
 	}
 
-	if err := s.saveRecordToFile(record); err != nil {
-		return "", err
+	if err := s.saveNewRecordToFile(record); err != nil {
+		return err
 	}
 
-	return id, nil
+	s.putURL(s.key(id), originalURL)
+	if userID != "" {
+		url := model.URL{
+			ID:          id,
+			OriginalURL: originalURL,
+			UserID:      userID,
+			CreatedAt:   createdAt,
+		}
+		s.userURLs[s.key(userID)] = append(s.userURLs[s.key(userID)], url)
+	}
+
+	return nil
+}
+
+// newID generates a short ID not yet used in the view's tenant. Callers must
+// hold mu.
+func (s *Storage) newID() (string, error) {
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		id, err := s.ids.Generate()
+		if err != nil {
+			return "", err
+		}
+		if _, taken := s.deletedMap[s.key(id)]; !taken {
+			return id, nil
+		}
+	}
+
+	return "", errors.New("failed to generate a unique ID")
 }
 
 // Get retrieves the original URL for a given short ID.
 func (s *Storage) Get(id string) (string, bool) {
+	if s.loadError() != nil {
+		return "", false
+	}
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	originalURL, found := s.urlMap[id]
+	originalURL, found := s.lookupURL(s.key(id))
 	if !found {
 		return "", false
 	}
 
-	if s.deletedMap[id] {
+	if s.deletedMap[s.key(id)] || s.expired(s.key(id)) {
 		return "", false
 	}
 
 	return originalURL, true
 }
 
+// GetBatch looks up the given short IDs at once, leaving out unknown ones.
+func (s *Storage) GetBatch(ids []string) (map[string]model.ExpandedURL, error) {
+	if err := s.loadError(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]model.ExpandedURL, len(ids))
+	for _, id := range ids {
+		originalURL, found := s.lookupURL(s.key(id))
+		if !found {
+			continue
+		}
+
+		result[id] = model.ExpandedURL{
+			ID:          id,
+			OriginalURL: originalURL,
+			Deleted:     s.deletedMap[s.key(id)],
+			Expired:     s.expired(s.key(id)),
+		}
+	}
+
+	return result, nil
+}
+
 // GetWithDeletedStatus retrieves the original URL and checks if it has been deleted.
 func (s *Storage) GetWithDeletedStatus(id string) (string, error) {
+	if err := s.loadError(); err != nil {
+		return "", err
+	}
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	originalURL, found := s.urlMap[id]
+	originalURL, found := s.lookupURL(s.key(id))
 	if !found {
 		return "", nil
 	}
 
-	if s.deletedMap[id] {
+	if s.deletedMap[s.key(id)] {
 		return "", storage.ErrURLDeleted
 	}
 
+	if s.expired(s.key(id)) {
+		return "", storage.ErrURLExpired
+	}
+
 	return originalURL, nil
 }
 
-// SaveBatch stores multiple URLs and returns a map of correlation IDs to short IDs.
-func (s *Storage) SaveBatch(items []model.BatchRequestItem) (map[string]string, error) {
-	result := make(map[string]string)
+// expired reports whether the URL stored under k has passed its expiry.
+// Callers must hold mu.
+func (s *Storage) expired(k string) bool {
+	expiresAt, ok := s.expiries[k]
+	return ok && !time.Now().Before(expiresAt)
+}
+
+// SaveBatch stores multiple URLs and returns the short ID saved for each correlation ID.
+func (s *Storage) SaveBatch(items []model.BatchRequestItem) (map[string]model.BatchSaveResult, error) {
+	if err := s.loadError(); err != nil {
+		return nil, err
+	}
+	result := make(map[string]model.BatchSaveResult)
 
 	for _, item := range items {
 		s.mu.Lock()
-		if existingID, exists := s.reverseURLMap[item.OriginalURL]; exists {
+		if existingID, exists := s.existingID(item.OriginalURL); exists {
 			s.mu.Unlock()
-			result[item.CorrelationID] = existingID
+			result[item.CorrelationID] = model.BatchSaveResult{ID: existingID}
 			continue
 		}
 
-		id, err := generator.GenerateID(8)
+		id, err := s.newID()
 		if err != nil {
 			s.mu.Unlock()
 			return nil, fmt.Errorf("failed to generate ID: %w", err)
 		}
 
-		s.idCounter++
-		uuid := strconv.Itoa(s.idCounter)
-		s.urlMap[id] = item.OriginalURL
-		s.reverseURLMap[item.OriginalURL] = id
-		s.mu.Unlock()
-
-		record := model.URLRecord{
-			UUID:        uuid,
-			ShortURL:    id,
-			OriginalURL: item.OriginalURL,
-			UserID:      "",
-			IsDeleted:   false,
-		}
-
-		if err := s.saveRecordToFile(record); err != nil {
+		if err := s.storeNewURL(id, item.OriginalURL, ""); err != nil {
+			s.mu.Unlock()
 			return nil, fmt.Errorf("failed to save record to file: %w", err)
 		}
+		s.reverseURLMap[s.key(item.OriginalURL)] = id
+		s.mu.Unlock()
 
-		result[item.CorrelationID] = id
+		result[item.CorrelationID] = model.BatchSaveResult{ID: id, Created: true}
 	}
 
 	return result, nil
 }
 
+// loadFromFile replays the file into memory, upgrading it to FormatVersion
+// first if it was written in an older format.
 func (s *Storage) loadFromFile() error {
 	file, err := os.OpenFile(s.filePath, os.O_RDONLY|os.O_CREATE, 0644)
 	if err != nil {
@@ -167,23 +478,47 @@ func (s *Storage) loadFromFile() error {
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	maxID := 0
+	if info, err := file.Stat(); err == nil {
+		s.loadSize.Store(info.Size())
+	}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "" {
-			continue
+	var maxID int64
+
+	header, err := readRecords(s.progressReader(file), func(record model.URLRecord) error {
+		if record.UUID != "" {
+			// The ID is stored anew, so nothing of an earlier URL under it
+			// carries over.
+			k := key(record.TenantID, record.ShortURL)
+			delete(s.passwords, k)
+			delete(s.expiries, k)
+			delete(s.forwarding, k)
+			delete(s.creators, k)
 		}
-
-		var record model.URLRecord
-		if err := json.Unmarshal([]byte(line), &record); err != nil {
-			return fmt.Errorf("failed to unmarshal record: %w", err)
+		s.putURL(key(record.TenantID, record.ShortURL), record.OriginalURL)
+		if _, exists := s.reverseURLMap[key(record.TenantID, record.OriginalURL)]; !exists {
+			s.reverseURLMap[key(record.TenantID, record.OriginalURL)] = record.ShortURL
+		}
+		s.deletedMap[key(record.TenantID, record.ShortURL)] = record.IsDeleted
+		if record.PasswordHash != "" {
+			s.passwords[key(record.TenantID, record.ShortURL)] = record.PasswordHash
+		}
+		if record.ExpiresAt != nil {
+			if record.ExpiresAt.IsZero() {
+				delete(s.expiries, key(record.TenantID, record.ShortURL))
+			} else {
+				s.expiries[key(record.TenantID, record.ShortURL)] = *record.ExpiresAt
+			}
+		}
+		if record.ForwardQuery != nil {
+			if *record.ForwardQuery {
+				s.forwarding[key(record.TenantID, record.ShortURL)] = true
+			} else {
+				delete(s.forwarding, key(record.TenantID, record.ShortURL))
+			}
+		}
+		if record.Creator != nil {
+			s.creators[key(record.TenantID, record.ShortURL)] = *record.Creator
 		}
-
-		s.urlMap[record.ShortURL] = record.OriginalURL
-		s.reverseURLMap[record.OriginalURL] = record.ShortURL
-		s.deletedMap[record.ShortURL] = record.IsDeleted
 
 		if record.UserID != "" {
 			url := model.URL{
@@ -191,185 +526,811 @@ func (s *Storage) loadFromFile() error {
 				OriginalURL: record.OriginalURL,
 				UserID:      record.UserID,
 			}
-			s.userURLs[record.UserID] = append(s.userURLs[record.UserID], url)
+			if record.CreatedAt != nil {
+				url.CreatedAt = *record.CreatedAt
+			}
+			userKey := key(record.TenantID, record.UserID)
+			s.userURLs[userKey] = append(s.userURLs[userKey], url)
 		}
 
-		if id, err := strconv.Atoi(record.UUID); err == nil && id > maxID {
+		if id, err := strconv.ParseInt(record.UUID, 10, 64); err == nil && id > maxID {
 			maxID = id
 		}
-	}
-
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading file: %w", err)
-	}
-
-	s.idCounter = maxID
-	return nil
-}
-
-func (s *Storage) saveRecordToFile(record model.URLRecord) error {
-	s.fileWriteMu.Lock()
-	defer s.fileWriteMu.Unlock()
-
-	file, err := os.OpenFile(s.filePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to open file for writing: %w", err)
+		return err
 	}
-	defer file.Close()
 
-	data, err := json.Marshal(record)
-	if err != nil {
-		return fmt.Errorf("failed to marshal record: %w", err)
-	}
+	s.lastUUID.Store(max(maxID, header.LastUUID))
 
-	if _, err := file.Write(append(data, '\n')); err != nil {
-		return fmt.Errorf("failed to write to file: %w", err)
+	if version := header.version(); version < FormatVersion {
+		// The file is replaced, so stop reading it first.
+		file.Close()
+		if err := s.upgradeFile(version); err != nil {
+			return err
+		}
 	}
-
 	return nil
 }
 
-// SaveWithUser stores a new URL associated with a user and returns its generated short ID.
-func (s *Storage) SaveWithUser(originalURL, userID string) (string, error) {
-	s.mu.Lock()
-	if existingID, exists := s.reverseURLMap[originalURL]; exists {
-		s.mu.Unlock()
-		return existingID, storage.ErrURLExists
-	}
-
-	id, err := generator.GenerateID(8)
-	if err != nil {
-		s.mu.Unlock()
-		return "", err
-	}
-
-	s.idCounter++
-	uuid := strconv.Itoa(s.idCounter)
-	s.urlMap[id] = originalURL
-	s.reverseURLMap[originalURL] = id
+// loadProgressStep is the fraction of the file between progress log lines of
+// a background load.
+const loadProgressStep = 0.1
+
+// progressReader counts the bytes read from r towards the load progress and,
+// for background loads, logs every loadProgressStep of the file.
+func (s *Storage) progressReader(r io.Reader) io.Reader {
+	return &countingReader{r: r, onRead: func(n int) {
+		before := s.loadedBytes.Load()
+		after := s.loadedBytes.Add(int64(n))
+		size := s.loadSize.Load()
+		if !s.backgroundLoad || size == 0 {
+			return
+		}
+		step := int64(loadProgressStep * float64(size))
+		if step > 0 && before/step != after/step && after < size {
+			log.Info().Str("path", s.filePath).Int64("percent", 100*after/size).Msg("Loading file storage")
+		}
+	}}
+}
 
-	url := model.URL{
-		ID:          id,
-		OriginalURL: originalURL,
-		UserID:      userID,
-	}
-	s.userURLs[userID] = append(s.userURLs[userID], url)
-	s.mu.Unlock()
+// countingReader calls onRead with the size of every read.
+type countingReader struct {
+	r      io.Reader
+	onRead func(n int)
+}
 
-	record := model.URLRecord{
-		UUID:        uuid,
-		ShortURL:    id,
-		OriginalURL: originalURL,
-		UserID:      userID,
-		IsDeleted:   false,
-	}
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.onRead(n)
+	return n, err
+}
 
-	if err := s.saveRecordToFile(record); err != nil {
-		return "", err
+// version returns the format version of a file with this header.
+func (h fileHeader) version() int {
+	if h.Version == nil {
+		return 0
 	}
-
-	return id, nil
+	return *h.Version
 }
 
-// SaveBatchWithUser stores multiple URLs associated with a user and returns a map of correlation IDs to short IDs.
-func (s *Storage) SaveBatchWithUser(items []model.BatchRequestItem, userID string) (map[string]string, error) {
-	result := make(map[string]string)
+// readRecords calls fn for each record of a storage file, migrating records
+// from older format versions, and returns the file header, which is empty for
+// version 0 files.
+func readRecords(r io.Reader, fn func(model.URLRecord) error) (fileHeader, error) {
+	var header fileHeader
+	first := true
 
-	for _, item := range items {
-		s.mu.Lock()
-		if existingID, exists := s.reverseURLMap[item.OriginalURL]; exists {
-			s.mu.Unlock()
-			result[item.CorrelationID] = existingID
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
 			continue
 		}
 
-		id, err := generator.GenerateID(8)
-		if err != nil {
-			s.mu.Unlock()
-			return nil, fmt.Errorf("failed to generate ID: %w", err)
-		}
-
-		s.idCounter++
-		uuid := strconv.Itoa(s.idCounter)
-		s.urlMap[id] = item.OriginalURL
-		s.reverseURLMap[item.OriginalURL] = id
+		if first {
+			first = false
 
-		url := model.URL{
-			ID:          id,
-			OriginalURL: item.OriginalURL,
-			UserID:      userID,
+			if err := json.Unmarshal(line, &header); err != nil {
+				return fileHeader{}, fmt.Errorf("failed to unmarshal record: %w", err)
+			}
+			if header.Version != nil {
+				if version := *header.Version; version < 1 || version > FormatVersion {
+					return fileHeader{}, fmt.Errorf("%w %d: supported versions are 0 to %d", ErrUnsupportedVersion, version, FormatVersion)
+				}
+				continue
+			}
+			header = fileHeader{}
 		}
-		s.userURLs[userID] = append(s.userURLs[userID], url)
-		s.mu.Unlock()
 
-		record := model.URLRecord{
-			UUID:        uuid,
-			ShortURL:    id,
-			OriginalURL: item.OriginalURL,
-			UserID:      userID,
-			IsDeleted:   false,
+		var record model.URLRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fileHeader{}, fmt.Errorf("failed to unmarshal record: %w", err)
 		}
-
-		if err := s.saveRecordToFile(record); err != nil {
-			return nil, fmt.Errorf("failed to save record to file: %w", err)
+		// Version 1 only added the header: version 0 records already have
+		// the current shape. Later versions migrate older records here.
+		if err := fn(record); err != nil {
+			return fileHeader{}, err
 		}
+	}
 
-		result[item.CorrelationID] = id
+	if err := scanner.Err(); err != nil {
+		return fileHeader{}, fmt.Errorf("error reading file: %w", err)
 	}
 
-	return result, nil
+	return header, nil
 }
 
-// GetUserURLs retrieves all non-deleted URLs associated with a user.
-func (s *Storage) GetUserURLs(userID string) ([]model.UserURL, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// upgradeFile rewrites a file written in an older format version as
+// FormatVersion. It runs before the file is opened for appending.
+func (s *Storage) upgradeFile(from int) error {
+	err := s.rewriteFile(func(write func(model.URLRecord) error) error {
+		file, err := os.Open(s.filePath)
+		if err != nil {
+			return fmt.Errorf("failed to open file: %w", err)
+		}
+		defer file.Close()
 
-	urls, exists := s.userURLs[userID]
-	if !exists {
-		return []model.UserURL{}, nil
+		_, err = readRecords(file, write)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upgrade storage file from version %d: %w", from, err)
 	}
 
-	var result []model.UserURL
-	for _, url := range urls {
-		if !s.deletedMap[url.ID] {
-			result = append(result, model.UserURL{
-				ShortURL:    url.ID,
-				OriginalURL: url.OriginalURL,
-			})
+	log.Info().Str("path", s.filePath).Int("from", from).Int("to", FormatVersion).Msg("Upgraded file storage format")
+	return nil
+}
+
+// rewriteFile atomically replaces the file with a FormatVersion header and
+// the records passed to write by fill. The new file is written to a temporary
+// file and renamed over the old one, so a crash leaves either intact.
+func (s *Storage) rewriteFile(fill func(write func(model.URLRecord) error) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.filePath), filepath.Base(s.filePath)+".rewrite-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	writeLine := func(v any) error {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("failed to marshal record: %w", err)
 		}
+		if _, err := writer.Write(append(data, '\n')); err != nil {
+			return fmt.Errorf("failed to write temporary file: %w", err)
+		}
+		return nil
 	}
 
-	return result, nil
-}
+	version := FormatVersion
+	if err := writeLine(fileHeader{Version: &version, LastUUID: s.lastUUID.Load()}); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := fill(func(record model.URLRecord) error { return writeLine(record) }); err != nil {
+		tmp.Close()
+		return err
+	}
 
-// DeleteUserURLs marks specified URLs as deleted for a user.
-func (s *Storage) DeleteUserURLs(userID string, urlIDs []string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
 
-	userURLs, exists := s.userURLs[userID]
-	if !exists {
-		return nil
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync temporary file: %w", err)
 	}
 
-	userURLSet := make(map[string]bool)
-	for _, url := range userURLs {
-		userURLSet[url.ID] = true
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temporary file: %w", err)
 	}
 
-	for _, urlID := range urlIDs {
-		if userURLSet[urlID] && !s.deletedMap[urlID] {
-			s.deletedMap[urlID] = true
+	if err := os.Rename(tmp.Name(), s.filePath); err != nil {
+		return fmt.Errorf("failed to replace storage file: %w", err)
+	}
+
+	return nil
+}
+
+// openForAppend opens the handle used for all writes for the storage's lifetime.
+func (s *Storage) openForAppend() error {
+	file, err := os.OpenFile(s.filePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open file for writing: %w", err)
+	}
+
+	s.file = file
+	return nil
+}
+
+// saveNewRecordToFile appends the record of a new URL to the file, giving it
+// the next UUID.
+func (s *Storage) saveNewRecordToFile(record model.URLRecord) error {
+	s.fileWriteMu.Lock()
+	defer s.fileWriteMu.Unlock()
+
+	record.UUID = strconv.FormatInt(s.lastUUID.Add(1), 10)
+	return s.writeRecord(record)
+}
+
+// saveRecordToFile appends a record updating an existing URL to the file.
+// Update records have no UUID of their own.
+func (s *Storage) saveRecordToFile(record model.URLRecord) error {
+	s.fileWriteMu.Lock()
+	defer s.fileWriteMu.Unlock()
+
+	return s.writeRecord(record)
+}
+
+// writeRecord appends record to the file and fsyncs it, so a record is
+// durable once the save that wrote it has returned. Callers must hold
+// fileWriteMu.
+func (s *Storage) writeRecord(record model.URLRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write to file: %w", err)
+	}
+
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the underlying file. The storage must not be used afterwards.
+func (s *Storage) Close() error {
+	s.fileWriteMu.Lock()
+	defer s.fileWriteMu.Unlock()
+
+	return s.file.Close()
+}
+
+// Compact rewrites the file with one current record per short ID, dropping
+// superseded records and URLs that have been deleted or have expired. Dropped
+// URLs are also purged from memory, so after compaction they resolve as unknown
+// rather than gone. The file is replaced atomically, so a crash leaves either
+// the old or the new file intact.
+func (s *Storage) Compact() error {
+	if err := s.loadError(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fileWriteMu.Lock()
+	defer s.fileWriteMu.Unlock()
+
+	if !s.Ready() {
+		return ErrNotLoaded
+	}
+
+	now := time.Now()
+	dropped, err := s.rewriteDropping(func(record model.URLRecord) bool {
+		return record.IsDeleted || (record.ExpiresAt != nil && !record.ExpiresAt.IsZero() && !now.Before(*record.ExpiresAt))
+	})
+	if err != nil {
+		return err
+	}
+
+	s.forget(dropped)
+	return nil
+}
+
+// rewriteDropping rewrites the file with the current record of each short ID,
+// leaving out and returning the records drop reports true for, and reopens
+// the append handle on the new file. Callers must hold mu and fileWriteMu.
+func (s *Storage) rewriteDropping(drop func(model.URLRecord) bool) ([]model.URLRecord, error) {
+	records, err := s.currentRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	var dropped []model.URLRecord
+	err = s.rewriteFile(func(write func(model.URLRecord) error) error {
+		for _, record := range records {
+			if drop(record) {
+				dropped = append(dropped, record)
+				continue
+			}
+			if err := write(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The append handle still points at the replaced file.
+	if err := s.file.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close replaced storage file: %w", err)
+	}
+	if err := s.openForAppend(); err != nil {
+		return nil, err
+	}
+
+	return dropped, nil
+}
+
+// currentRecords replays the file and returns the latest state of each short ID
+// in order of first appearance. Callers must hold fileWriteMu.
+func (s *Storage) currentRecords() ([]model.URLRecord, error) {
+	file, err := os.Open(s.filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	var order []string
+	current := make(map[string]model.URLRecord)
+
+	_, err = readRecords(file, func(record model.URLRecord) error {
+		k := key(record.TenantID, record.ShortURL)
+		prev, seen := current[k]
+		if !seen {
+			order = append(order, k)
+		} else if record.UUID == "" {
+			// Update records carry only what they change. A record with a
+			// UUID stores the ID anew, so it inherits nothing.
+			record.UUID = prev.UUID
+			if record.UserID == "" {
+				record.UserID = prev.UserID
+			}
+			if record.PasswordHash == "" {
+				record.PasswordHash = prev.PasswordHash
+			}
+			if record.ExpiresAt == nil {
+				record.ExpiresAt = prev.ExpiresAt
+			}
+			if record.ForwardQuery == nil {
+				record.ForwardQuery = prev.ForwardQuery
+			}
+			if record.Creator == nil {
+				record.Creator = prev.Creator
+			}
+			if record.CreatedAt == nil {
+				record.CreatedAt = prev.CreatedAt
+			}
+		}
+		current[k] = record
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]model.URLRecord, 0, len(order))
+	for _, k := range order {
+		records = append(records, current[k])
+	}
+
+	return records, nil
+}
+
+// forget removes the URLs of records from the in-memory state.
+// Callers must hold mu.
+func (s *Storage) forget(records []model.URLRecord) {
+	for _, record := range records {
+		k := key(record.TenantID, record.ShortURL)
+		reverseKey := key(record.TenantID, record.OriginalURL)
+		if s.reverseURLMap[reverseKey] == record.ShortURL {
+			delete(s.reverseURLMap, reverseKey)
+		}
+		delete(s.urlMap, k)
+		if s.cache != nil {
+			s.cache.Remove(k)
+		}
+		delete(s.passwords, k)
+		delete(s.forwarding, k)
+		delete(s.creators, k)
+		delete(s.stats, k)
+		delete(s.expiries, k)
+		delete(s.deletedMap, k)
+	}
+
+	for userKey, urls := range s.userURLs {
+		tenantID, _ := splitKey(userKey)
+
+		kept := urls[:0]
+		seen := make(map[string]bool)
+		for _, url := range urls {
+			if _, exists := s.deletedMap[key(tenantID, url.ID)]; exists && !seen[url.ID] {
+				seen[url.ID] = true
+				kept = append(kept, url)
+			}
+		}
+
+		if len(kept) == 0 {
+			delete(s.userURLs, userKey)
+		} else {
+			s.userURLs[userKey] = kept
+		}
+	}
+}
+
+// putURL records the original URL of a short ID key. Callers must hold mu for writing.
+func (s *Storage) putURL(k, originalURL string) {
+	if _, known := s.deletedMap[k]; !known {
+		s.deletedMap[k] = false
+	}
+
+	if s.cache != nil {
+		s.cache.Add(k, originalURL)
+		return
+	}
+	s.urlMap[k] = originalURL
+}
+
+// lookupURL returns the original URL of a short ID key, reading it from the
+// file on a cache miss. Callers must hold mu.
+func (s *Storage) lookupURL(k string) (string, bool) {
+	if _, known := s.deletedMap[k]; !known {
+		return "", false
+	}
+
+	if s.cache == nil {
+		originalURL, found := s.urlMap[k]
+		return originalURL, found
+	}
+
+	if originalURL, found := s.cache.Get(k); found {
+		return originalURL, true
+	}
+
+	originalURL, found, err := s.scanForURL(k)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read URL from file storage")
+		return "", false
+	}
+	if found {
+		s.cache.Add(k, originalURL)
+	}
+
+	return originalURL, found
+}
+
+// scanForURL reads the file for the original URL of a short ID key. It reads
+// through its own read-only handle without fileWriteMu, so it does not hold up
+// appends; a record still being appended is skipped. New URLs are appended
+// before they become known, and callers hold mu, which keeps Compact from
+// replacing the file mid-scan.
+func (s *Storage) scanForURL(k string) (string, bool, error) {
+	file, err := os.Open(s.filePath)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	var originalURL string
+	found := false
+
+	_, err = readRecords(&completeLinesReader{r: bufio.NewReader(file)}, func(record model.URLRecord) error {
+		if key(record.TenantID, record.ShortURL) == k {
+			originalURL = record.OriginalURL
+			found = true
+		}
+		return nil
+	})
+	if err != nil {
+		return "", false, err
+	}
+
+	return originalURL, found, nil
+}
+
+// completeLinesReader reads r up to its last newline, leaving out a trailing
+// line that is still being appended.
+type completeLinesReader struct {
+	r       *bufio.Reader
+	pending []byte
+}
+
+func (c *completeLinesReader) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		line, err := c.r.ReadBytes('\n')
+		if err != nil {
+			return 0, err
+		}
+		c.pending = line
+	}
+
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// SaveWithUser stores a new URL associated with a user and returns its generated short ID.
+func (s *Storage) SaveWithUser(originalURL, userID string) (string, error) {
+	if err := s.loadError(); err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	if existingID, exists := s.existingID(originalURL); exists {
+		s.mu.Unlock()
+		return existingID, storage.ErrURLExists
+	}
+
+	id, err := s.newID()
+	if err != nil {
+		s.mu.Unlock()
+		return "", err
+	}
+
+	if err := s.storeNewURL(id, originalURL, userID); err != nil {
+		s.mu.Unlock()
+		return "", err
+	}
+	s.reverseURLMap[s.key(originalURL)] = id
+	s.mu.Unlock()
+
+	return id, nil
+}
+
+// SaveWithAlias stores a URL under the given alias, optionally associated with a user.
+// Saving the same URL under the same alias again is a no-op.
+func (s *Storage) SaveWithAlias(alias, originalURL, userID string) error {
+	if err := s.loadError(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	if existing, found := s.lookupURL(s.key(alias)); found {
+		deleted := s.deletedMap[s.key(alias)]
+		s.mu.Unlock()
+		if existing == originalURL && !deleted {
+			return nil
+		}
+		return storage.ErrAliasTaken
+	}
+
+	if err := s.storeNewURL(alias, originalURL, userID); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	if _, exists := s.reverseURLMap[s.key(originalURL)]; !exists {
+		s.reverseURLMap[s.key(originalURL)] = alias
+	}
+	s.mu.Unlock()
+
+	return nil
+}
+
+// ImportURLs stores records under their own short IDs, together with their
+// deletion state, password hash, expiry, query forwarding, creator and
+// creation time, skipping records that conflict
+// with stored URLs. The records' tenants are ignored in favor of the storage's.
+func (s *Storage) ImportURLs(records []model.URLRecord) error {
+	if err := s.loadError(); err != nil {
+		return err
+	}
+	var conflicts []string
+
+	for _, record := range records {
+		s.mu.Lock()
+		if existing, found := s.lookupURL(s.key(record.ShortURL)); found {
+			s.mu.Unlock()
+			if existing != record.OriginalURL {
+				conflicts = append(conflicts, record.ShortURL)
+			}
+			continue
+		}
+		if _, exists := s.existingID(record.OriginalURL); exists {
+			s.mu.Unlock()
+			conflicts = append(conflicts, record.ShortURL)
+			continue
+		}
+
+		createdAt := time.Now().UTC()
+		if record.CreatedAt != nil && !record.CreatedAt.IsZero() {
+			createdAt = record.CreatedAt.UTC()
+		}
+		expiresAt := record.ExpiresAt
+		if expiresAt != nil && expiresAt.IsZero() {
+			expiresAt = nil
+		}
+		forwardQuery := record.ForwardQuery
+		if forwardQuery != nil && !*forwardQuery {
+			forwardQuery = nil
+		}
+
+		// The record is appended before memory is updated, so a failed write
+		// leaves nothing behind that the file lacks.
+		err := s.saveNewRecordToFile(model.URLRecord{
+			ShortURL:     record.ShortURL,
+			OriginalURL:  record.OriginalURL,
+			UserID:       record.UserID,
+			IsDeleted:    record.IsDeleted,
+			TenantID:     s.tenantID,
+			PasswordHash: record.PasswordHash,
+			ExpiresAt:    expiresAt,
+			ForwardQuery: forwardQuery,
+			Creator:      record.Creator,
+			CreatedAt:    &createdAt,
+		})
+		if err != nil {
+			s.mu.Unlock()
+			return fmt.Errorf("failed to save record to file: %w", err)
+		}
+
+		s.putURL(s.key(record.ShortURL), record.OriginalURL)
+		if _, exists := s.reverseURLMap[s.key(record.OriginalURL)]; !exists {
+			s.reverseURLMap[s.key(record.OriginalURL)] = record.ShortURL
+		}
+		s.deletedMap[s.key(record.ShortURL)] = record.IsDeleted
+		if record.PasswordHash != "" {
+			s.passwords[s.key(record.ShortURL)] = record.PasswordHash
+		}
+		if expiresAt != nil {
+			s.expiries[s.key(record.ShortURL)] = *expiresAt
+		}
+		if forwardQuery != nil {
+			s.forwarding[s.key(record.ShortURL)] = true
+		}
+		if record.Creator != nil {
+			s.creators[s.key(record.ShortURL)] = *record.Creator
+		}
+		if record.UserID != "" {
+			url := model.URL{
+				ID:          record.ShortURL,
+				OriginalURL: record.OriginalURL,
+				UserID:      record.UserID,
+				CreatedAt:   createdAt,
+			}
+			s.userURLs[s.key(record.UserID)] = append(s.userURLs[s.key(record.UserID)], url)
+		}
+		s.mu.Unlock()
+	}
+
+	if len(conflicts) > 0 {
+		return &storage.ImportConflictError{ShortIDs: conflicts}
+	}
+
+	return nil
+}
+
+// ExportURLs calls fn with the latest state of each of the tenant's URLs, as
+// of the compacted log, in the order they were first stored.
+func (s *Storage) ExportURLs(fn func(model.URLRecord) error) error {
+	if err := s.loadError(); err != nil {
+		return err
+	}
+	s.fileWriteMu.Lock()
+	records, err := s.currentRecords()
+	s.fileWriteMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if record.TenantID != s.tenantID {
+			continue
+		}
+
+		record.UUID = ""
+		if record.ExpiresAt != nil && record.ExpiresAt.IsZero() {
+			record.ExpiresAt = nil
+		}
+		if record.ForwardQuery != nil && !*record.ForwardQuery {
+			record.ForwardQuery = nil
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SaveBatchWithUser stores multiple URLs associated with a user and returns the short ID saved for each correlation ID.
+func (s *Storage) SaveBatchWithUser(items []model.BatchRequestItem, userID string) (map[string]model.BatchSaveResult, error) {
+	if err := s.loadError(); err != nil {
+		return nil, err
+	}
+	result := make(map[string]model.BatchSaveResult)
+
+	for _, item := range items {
+		s.mu.Lock()
+		if existingID, exists := s.existingID(item.OriginalURL); exists {
+			s.mu.Unlock()
+			result[item.CorrelationID] = model.BatchSaveResult{ID: existingID}
+			continue
+		}
+
+		id, err := s.newID()
+		if err != nil {
+			s.mu.Unlock()
+			return nil, fmt.Errorf("failed to generate ID: %w", err)
+		}
+
+		if err := s.storeNewURL(id, item.OriginalURL, userID); err != nil {
+			s.mu.Unlock()
+			return nil, fmt.Errorf("failed to save record to file: %w", err)
+		}
+		s.reverseURLMap[s.key(item.OriginalURL)] = id
+		s.mu.Unlock()
+
+		result[item.CorrelationID] = model.BatchSaveResult{ID: id, Created: true}
+	}
+
+	return result, nil
+}
+
+// GetUserURLs retrieves all non-deleted URLs associated with a user.
+func (s *Storage) GetUserURLs(userID string) ([]model.UserURL, error) {
+	if err := s.loadError(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	urls, exists := s.userURLs[s.key(userID)]
+	if !exists {
+		return []model.UserURL{}, nil
+	}
+
+	var result []model.UserURL
+	for _, url := range urls {
+		if !s.deletedMap[s.key(url.ID)] {
+			result = append(result, model.UserURL{
+				ShortURL:    url.ID,
+				OriginalURL: url.OriginalURL,
+				CreatedAt:   url.CreatedAt,
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// GetUserURLsPaged retrieves a window of a user's non-deleted URLs in creation order
+// along with the total number of such URLs.
+func (s *Storage) GetUserURLsPaged(userID string, limit, offset int) ([]model.UserURL, int, error) {
+	if err := s.loadError(); err != nil {
+		return nil, 0, err
+	}
+	urls, err := s.GetUserURLs(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total := len(urls)
+	if offset >= total {
+		return []model.UserURL{}, total, nil
+	}
+
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+
+	return urls[offset:end], total, nil
+}
+
+// CountUserURLs returns the number of a user's non-deleted URLs.
+func (s *Storage) CountUserURLs(userID string) (int, error) {
+	if err := s.loadError(); err != nil {
+		return 0, err
+	}
+	_, total, err := s.GetUserURLsPaged(userID, 1, 0)
+	return total, err
+}
+
+// DeleteUserURLs marks specified URLs as deleted for a user.
+func (s *Storage) DeleteUserURLs(userID string, urlIDs []string) error {
+	if err := s.loadError(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	userURLs, exists := s.userURLs[s.key(userID)]
+	if !exists {
+		return nil
+	}
+
+	userURLSet := make(map[string]string)
+	for _, url := range userURLs {
+		userURLSet[url.ID] = url.OriginalURL
+	}
+
+	for _, urlID := range urlIDs {
+		if _, owned := userURLSet[urlID]; owned && !s.deletedMap[s.key(urlID)] {
+			s.deletedMap[s.key(urlID)] = true
 
-			s.idCounter++
-			uuid := strconv.Itoa(s.idCounter)
 			record := model.URLRecord{
-				UUID:        uuid,
 				ShortURL:    urlID,
-				OriginalURL: s.urlMap[urlID],
+				OriginalURL: userURLSet[urlID],
 				UserID:      userID,
 				IsDeleted:   true,
+				TenantID:    s.tenantID,
 			}
 
 			if err := s.saveRecordToFile(record); err != nil {
@@ -380,3 +1341,334 @@ func (s *Storage) DeleteUserURLs(userID string, urlIDs []string) error {
 
 	return nil
 }
+
+// OwnsURL reports whether the user owns the short URL, deleted or not.
+// It returns storage.ErrURLNotFound for unknown short IDs.
+func (s *Storage) OwnsURL(userID, id string) (bool, error) {
+	if err := s.loadError(); err != nil {
+		return false, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, known := s.deletedMap[s.key(id)]; !known {
+		return false, storage.ErrURLNotFound
+	}
+	if userID == "" {
+		return false, nil
+	}
+
+	for _, url := range s.userURLs[s.key(userID)] {
+		if url.ID == id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// FindID returns the short ID originalURL is stored under, or
+// storage.ErrURLNotFound if it is not stored or duplicate URLs are allowed.
+func (s *Storage) FindID(originalURL string) (string, error) {
+	if err := s.loadError(); err != nil {
+		return "", err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, exists := s.existingID(originalURL)
+	if !exists {
+		return "", storage.ErrURLNotFound
+	}
+	return id, nil
+}
+
+// GetDeletedUserURLs returns the short IDs of a user's URLs that are marked deleted.
+func (s *Storage) GetDeletedUserURLs(userID string) ([]string, error) {
+	if err := s.loadError(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := []string{}
+	seen := make(map[string]bool)
+	for _, url := range s.userURLs[s.key(userID)] {
+		if s.deletedMap[s.key(url.ID)] && !seen[url.ID] {
+			seen[url.ID] = true
+			result = append(result, url.ID)
+		}
+	}
+
+	return result, nil
+}
+
+// SetPasswordHash gates the short URL behind the given password hash and
+// appends a record carrying the hash to the file. The record is written under
+// the lock before memory is updated, so a failed write changes nothing and
+// the record's deletion state cannot be overtaken by a concurrent deletion.
+func (s *Storage) SetPasswordHash(id, hash string) error {
+	if err := s.loadError(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	originalURL, found := s.lookupURL(s.key(id))
+	if !found {
+		return fmt.Errorf("short URL %s not found", id)
+	}
+
+	record := model.URLRecord{
+		ShortURL:     id,
+		OriginalURL:  originalURL,
+		IsDeleted:    s.deletedMap[s.key(id)],
+		TenantID:     s.tenantID,
+		PasswordHash: hash,
+	}
+
+	if err := s.saveRecordToFile(record); err != nil {
+		return fmt.Errorf("failed to save password record: %w", err)
+	}
+
+	s.passwords[s.key(id)] = hash
+	return nil
+}
+
+// PasswordHash returns the password hash gating the short URL, or "" if it is not protected.
+func (s *Storage) PasswordHash(id string) (string, error) {
+	if err := s.loadError(); err != nil {
+		return "", err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.passwords[s.key(id)], nil
+}
+
+// SetForwardQuery sets whether redirects of the short URL forward their query
+// string and appends a record carrying the setting to the file. Like
+// SetPasswordHash, it writes the record before updating memory.
+func (s *Storage) SetForwardQuery(id string, enabled bool) error {
+	if err := s.loadError(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	originalURL, found := s.lookupURL(s.key(id))
+	if !found {
+		return fmt.Errorf("short URL %s not found", id)
+	}
+
+	record := model.URLRecord{
+		ShortURL:     id,
+		OriginalURL:  originalURL,
+		IsDeleted:    s.deletedMap[s.key(id)],
+		TenantID:     s.tenantID,
+		ForwardQuery: &enabled,
+	}
+
+	if err := s.saveRecordToFile(record); err != nil {
+		return fmt.Errorf("failed to save query forwarding record: %w", err)
+	}
+
+	if enabled {
+		s.forwarding[s.key(id)] = true
+	} else {
+		delete(s.forwarding, s.key(id))
+	}
+	return nil
+}
+
+// ForwardQuery reports whether redirects of the short URL forward their query string.
+func (s *Storage) ForwardQuery(id string) (bool, error) {
+	if err := s.loadError(); err != nil {
+		return false, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.forwarding[s.key(id)], nil
+}
+
+// SetCreator records the client that created the short URL and appends a
+// record carrying it to the file. Like SetPasswordHash, it writes the record
+// before updating memory.
+func (s *Storage) SetCreator(id string, creator model.Creator) error {
+	if err := s.loadError(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	originalURL, found := s.lookupURL(s.key(id))
+	if !found {
+		return fmt.Errorf("short URL %s not found", id)
+	}
+
+	record := model.URLRecord{
+		ShortURL:    id,
+		OriginalURL: originalURL,
+		IsDeleted:   s.deletedMap[s.key(id)],
+		TenantID:    s.tenantID,
+		Creator:     &creator,
+	}
+
+	if err := s.saveRecordToFile(record); err != nil {
+		return fmt.Errorf("failed to save creator record: %w", err)
+	}
+
+	s.creators[s.key(id)] = creator
+	return nil
+}
+
+// Creator returns the client that created the short URL, if it was recorded.
+func (s *Storage) Creator(id string) (model.Creator, error) {
+	if err := s.loadError(); err != nil {
+		return model.Creator{}, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.creators[s.key(id)], nil
+}
+
+// Count returns the number of stored, non-deleted URLs across all tenants.
+func (s *Storage) Count() (int, error) {
+	if err := s.loadError(); err != nil {
+		return 0, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, deleted := range s.deletedMap {
+		if !deleted {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// IncrementVisit adds n visits to the short URL, the latest at the given time.
+// Visit statistics are kept in memory only and reset when the storage is reopened.
+func (s *Storage) IncrementVisit(id string, n int64, at time.Time) error {
+	if err := s.loadError(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := s.stats[s.key(id)]
+	stats.Visits += n
+	if at.After(stats.LastVisited) {
+		stats.LastVisited = at
+	}
+	s.stats[s.key(id)] = stats
+
+	return nil
+}
+
+// GetStats returns the visit statistics of the short URL.
+func (s *Storage) GetStats(id string) (model.URLStats, error) {
+	if err := s.loadError(); err != nil {
+		return model.URLStats{}, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.stats[s.key(id)], nil
+}
+
+// SetExpiry makes the short URL expire at the given time and appends a record
+// carrying the expiry to the file. A zero time removes the expiry. Like
+// SetPasswordHash, it writes the record before updating memory.
+func (s *Storage) SetExpiry(id string, expiresAt time.Time) error {
+	if err := s.loadError(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	originalURL, found := s.lookupURL(s.key(id))
+	if !found {
+		return fmt.Errorf("short URL %s not found", id)
+	}
+
+	record := model.URLRecord{
+		ShortURL:    id,
+		OriginalURL: originalURL,
+		IsDeleted:   s.deletedMap[s.key(id)],
+		TenantID:    s.tenantID,
+		ExpiresAt:   &expiresAt,
+	}
+
+	if err := s.saveRecordToFile(record); err != nil {
+		return fmt.Errorf("failed to save expiry record: %w", err)
+	}
+
+	if expiresAt.IsZero() {
+		delete(s.expiries, s.key(id))
+	} else {
+		s.expiries[s.key(id)] = expiresAt
+	}
+	return nil
+}
+
+// Expiry returns when the short URL expires, or the zero time if it never does.
+func (s *Storage) Expiry(id string) (time.Time, error) {
+	if err := s.loadError(); err != nil {
+		return time.Time{}, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.expiries[s.key(id)], nil
+}
+
+// PurgeExpired removes URLs of all tenants that expired before the given time
+// from memory and from the file, which is rewritten without their records,
+// and returns how many were removed.
+func (s *Storage) PurgeExpired(before time.Time) (int, error) {
+	if err := s.loadError(); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := make(map[string]bool)
+	var expired []model.URLRecord
+	for k, expiresAt := range s.expiries {
+		if !expiresAt.Before(before) {
+			continue
+		}
+
+		tenantID, id := splitKey(k)
+		originalURL, _ := s.lookupURL(k)
+		expired = append(expired, model.URLRecord{
+			ShortURL:    id,
+			OriginalURL: originalURL,
+			TenantID:    tenantID,
+		})
+		purged[k] = true
+	}
+
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	// Drop the purged records from the file too, so they do not come back
+	// on the next load, nor merge into a URL later stored under the same ID.
+	s.fileWriteMu.Lock()
+	defer s.fileWriteMu.Unlock()
+
+	if _, err := s.rewriteDropping(func(record model.URLRecord) bool {
+		return purged[key(record.TenantID, record.ShortURL)]
+	}); err != nil {
+		return 0, err
+	}
+
+	s.forget(expired)
+	return len(expired), nil
+}
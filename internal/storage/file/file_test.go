@@ -0,0 +1,1064 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MikhailRaia/url-shortener/internal/generator"
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/storage"
+	"github.com/MikhailRaia/url-shortener/internal/storage/storagetest"
+)
+
+func TestStorage_GetDeletedUserURLs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+
+	storage, err := NewStorage(path)
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	defer storage.Close()
+
+	keep, _ := storage.SaveWithUser("https://a.example.com", "user1")
+	gone, _ := storage.SaveWithUser("https://b.example.com", "user1")
+	other, _ := storage.SaveWithUser("https://c.example.com", "user2")
+
+	if err := storage.DeleteUserURLs("user1", []string{gone, other}); err != nil {
+		t.Fatalf("Storage.DeleteUserURLs() error = %v", err)
+	}
+
+	// Reload to check that deletions survive a restart without duplicating IDs.
+	reloaded, err := NewStorage(path)
+	if err != nil {
+		t.Fatalf("NewStorage() reload error = %v", err)
+	}
+	defer reloaded.Close()
+
+	for name, s := range map[string]*Storage{"live": storage, "reloaded": reloaded} {
+		deleted, err := s.GetDeletedUserURLs("user1")
+		if err != nil {
+			t.Fatalf("%s: Storage.GetDeletedUserURLs() error = %v", name, err)
+		}
+		if len(deleted) != 1 || deleted[0] != gone {
+			t.Errorf("%s: Storage.GetDeletedUserURLs() = %v, want [%v] (kept %v)", name, deleted, gone, keep)
+		}
+
+		deleted, err = s.GetDeletedUserURLs("user2")
+		if err != nil {
+			t.Fatalf("%s: Storage.GetDeletedUserURLs() error = %v", name, err)
+		}
+		if len(deleted) != 0 {
+			t.Errorf("%s: Storage.GetDeletedUserURLs() for user2 = %v, want none", name, deleted)
+		}
+	}
+}
+
+func TestStorage_Compact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+
+	storage, err := NewStorage(path)
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	defer storage.Close()
+
+	kept, _ := storage.SaveWithUser("https://a.example.com", "user1")
+	protected, _ := storage.Save("https://b.example.com")
+	if err := storage.SetPasswordHash(protected, "hash"); err != nil {
+		t.Fatalf("Storage.SetPasswordHash() error = %v", err)
+	}
+	var deleted []string
+	for _, u := range []string{"https://c.example.com", "https://d.example.com"} {
+		id, _ := storage.SaveWithUser(u, "user1")
+		deleted = append(deleted, id)
+	}
+	if err := storage.DeleteUserURLs("user1", deleted); err != nil {
+		t.Fatalf("Storage.DeleteUserURLs() error = %v", err)
+	}
+
+	before := countLines(t, path)
+
+	if err := storage.Compact(); err != nil {
+		t.Fatalf("Storage.Compact() error = %v", err)
+	}
+
+	if after := countLines(t, path); after != 2 || after >= before {
+		t.Errorf("compacted file has %d records (was %d), want 2", after, before)
+	}
+
+	reloaded, err := NewStorage(path)
+	if err != nil {
+		t.Fatalf("NewStorage() reload error = %v", err)
+	}
+	defer reloaded.Close()
+
+	for name, s := range map[string]*Storage{"live": storage, "reloaded": reloaded} {
+		if got, found := s.Get(kept); !found || got != "https://a.example.com" {
+			t.Errorf("%s: Get(%s) = %v, %v, want https://a.example.com, true", name, kept, got, found)
+		}
+		if got, found := s.Get(protected); !found || got != "https://b.example.com" {
+			t.Errorf("%s: Get(%s) = %v, %v, want https://b.example.com, true", name, protected, got, found)
+		}
+		if hash, _ := s.PasswordHash(protected); hash != "hash" {
+			t.Errorf("%s: PasswordHash(%s) = %q, want %q", name, protected, hash, "hash")
+		}
+		for _, id := range deleted {
+			if _, found := s.Get(id); found {
+				t.Errorf("%s: Get(%s) found a deleted URL", name, id)
+			}
+		}
+
+		urls, err := s.GetUserURLs("user1")
+		if err != nil {
+			t.Fatalf("%s: GetUserURLs() error = %v", name, err)
+		}
+		if len(urls) != 1 || urls[0].ShortURL != kept {
+			t.Errorf("%s: GetUserURLs() = %v, want only %s", name, urls, kept)
+		}
+
+		count, _ := s.Count()
+		if count != 2 {
+			t.Errorf("%s: Count() = %d, want 2", name, count)
+		}
+	}
+
+	// A deleted URL can be shortened again after compaction.
+	if _, err := storage.Save("https://c.example.com"); err != nil {
+		t.Errorf("Save() of a compacted-away URL error = %v", err)
+	}
+}
+
+// countLines returns the number of records in the file at path, not
+// counting the version header.
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if !strings.HasPrefix(string(data), `{"version":1`) {
+		t.Fatalf("file %s has no version header", path)
+	}
+	return strings.Count(string(data), "\n") - 1
+}
+
+func TestStorage_FormatVersions(t *testing.T) {
+	const records = `{"uuid":"1","short_url":"abc","original_url":"https://a.example.com","user_id":"user1","is_deleted":false}
+{"uuid":"2","short_url":"def","original_url":"https://b.example.com","user_id":"","is_deleted":true}
+`
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr error
+	}{
+		{name: "new file"},
+		{name: "version 0 without header", content: records},
+		{name: "version 1", content: `{"version":1}` + "\n" + records},
+		{name: "future version", content: `{"version":2}` + "\n" + records, wantErr: ErrUnsupportedVersion},
+		{name: "invalid version", content: `{"version":0}` + "\n" + records, wantErr: ErrUnsupportedVersion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "storage.json")
+			if tt.content != "" {
+				if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			fileStorage, err := NewStorage(path)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("NewStorage() error = %v, want %v", err, tt.wantErr)
+				}
+				data, _ := os.ReadFile(path)
+				if string(data) != tt.content {
+					t.Errorf("NewStorage() modified a file it cannot read: %q", data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewStorage() error = %v", err)
+			}
+			defer fileStorage.Close()
+
+			id, err := fileStorage.Save("https://c.example.com")
+			if err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+
+			wantRecords := 1
+			if tt.content != "" {
+				wantRecords = 3
+			}
+			if got := countLines(t, path); got != wantRecords {
+				t.Errorf("file has %d records, want %d", got, wantRecords)
+			}
+
+			reloaded, err := NewStorage(path)
+			if err != nil {
+				t.Fatalf("NewStorage() reload error = %v", err)
+			}
+			defer reloaded.Close()
+
+			if got, found := reloaded.Get(id); !found || got != "https://c.example.com" {
+				t.Errorf("Get(%s) = %v, %v, want https://c.example.com, true", id, got, found)
+			}
+			if tt.content == "" {
+				return
+			}
+			if got, found := reloaded.Get("abc"); !found || got != "https://a.example.com" {
+				t.Errorf("Get(abc) = %v, %v, want https://a.example.com, true", got, found)
+			}
+			if _, err := reloaded.GetWithDeletedStatus("def"); !errors.Is(err, storage.ErrURLDeleted) {
+				t.Errorf("GetWithDeletedStatus(def) error = %v, want %v", err, storage.ErrURLDeleted)
+			}
+			if urls, _ := reloaded.GetUserURLs("user1"); len(urls) != 1 || urls[0].ShortURL != "abc" {
+				t.Errorf("GetUserURLs(user1) = %v, want only abc", urls)
+			}
+		})
+	}
+}
+
+func TestStorage_RecordsSurviveReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+
+	storage, err := NewStorage(path)
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	defer storage.Close()
+
+	id, err := storage.SaveWithUser("https://example.com", "user1")
+	if err != nil {
+		t.Fatalf("Storage.SaveWithUser() error = %v", err)
+	}
+
+	// Reopen without closing the first instance, as after a crash.
+	reopened, err := NewStorage(path)
+	if err != nil {
+		t.Fatalf("NewStorage() reopen error = %v", err)
+	}
+	defer reopened.Close()
+
+	if got, found := reopened.Get(id); !found || got != "https://example.com" {
+		t.Errorf("reopened Get(%s) = %v, %v, want https://example.com, true", id, got, found)
+	}
+
+	// Writes after compaction must go to the new file, not the replaced one.
+	if err := storage.Compact(); err != nil {
+		t.Fatalf("Storage.Compact() error = %v", err)
+	}
+	after, err := storage.Save("https://example.com/after-compact")
+	if err != nil {
+		t.Fatalf("Storage.Save() error = %v", err)
+	}
+
+	reopened, err = NewStorage(path)
+	if err != nil {
+		t.Fatalf("NewStorage() reopen error = %v", err)
+	}
+	defer reopened.Close()
+
+	if _, found := reopened.Get(after); !found {
+		t.Errorf("record saved after compaction was lost on reopen")
+	}
+}
+
+// writeLargeFile writes a storage file with n URLs, idN -> https://example.com/N.
+func writeLargeFile(t *testing.T, path string, n int) {
+	t.Helper()
+
+	var b strings.Builder
+	b.WriteString(`{"version":1}` + "\n")
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, `{"uuid":"%d","short_url":"id%d","original_url":"https://example.com/%d","user_id":"","is_deleted":false}`+"\n", i, i, i)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+}
+
+func TestStorage_BackgroundLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+	const n = 200000
+	writeLargeFile(t, path, n)
+
+	storage, err := NewStorage(path, WithBackgroundLoad(true))
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	defer storage.Close()
+
+	if storage.Ready() {
+		t.Errorf("Ready() = true right after NewStorage(), want false while loading")
+	}
+	if progress := storage.LoadProgress(); progress >= 1 {
+		t.Errorf("LoadProgress() = %v right after NewStorage(), want below 1", progress)
+	}
+
+	// Calls made while loading wait for it instead of missing URLs.
+	if got, found := storage.Get("id" + strconv.Itoa(n)); !found || got != "https://example.com/"+strconv.Itoa(n) {
+		t.Errorf("Get() during load = %q, %v, want the last URL of the file", got, found)
+	}
+
+	select {
+	case <-storage.Loaded():
+	case <-time.After(30 * time.Second):
+		t.Fatal("storage did not finish loading")
+	}
+
+	if !storage.Ready() {
+		t.Errorf("Ready() = false after load, want true")
+	}
+	if progress := storage.LoadProgress(); progress != 1 {
+		t.Errorf("LoadProgress() = %v after load, want 1", progress)
+	}
+	if count, err := storage.Count(); err != nil || count != n {
+		t.Errorf("Count() = %d, %v, want %d", count, err, n)
+	}
+	if _, err := storage.Save("https://example.com/new"); err != nil {
+		t.Errorf("Save() after load error = %v", err)
+	}
+}
+
+func TestStorage_BackgroundLoadFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+	corrupt := `{"version":1}` + "\n" + `{"uuid":"1","short_url":"a","original_url":"https://example.com"}` + "\n" + "not json\n"
+	if err := os.WriteFile(path, []byte(corrupt), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	storage, err := NewStorage(path, WithBackgroundLoad(true))
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v, want load errors to be reported by Ready", err)
+	}
+
+	// Count waits for the load to finish.
+	if _, err := storage.Count(); !errors.Is(err, ErrNotLoaded) {
+		t.Errorf("Count() after a failed load error = %v, want ErrNotLoaded", err)
+	}
+	if storage.Ready() {
+		t.Errorf("Ready() = true after a failed load, want false")
+	}
+	if err := storage.Compact(); !errors.Is(err, ErrNotLoaded) {
+		t.Errorf("Compact() after a failed load error = %v, want ErrNotLoaded", err)
+	}
+
+	if _, err := storage.Save("https://example.com/new"); !errors.Is(err, ErrNotLoaded) {
+		t.Errorf("Save() after a failed load error = %v, want ErrNotLoaded", err)
+	}
+	if _, err := storage.SaveBatchWithUser([]model.BatchRequestItem{{CorrelationID: "1", OriginalURL: "https://example.com/batch"}}, "user1"); !errors.Is(err, ErrNotLoaded) {
+		t.Errorf("SaveBatchWithUser() after a failed load error = %v, want ErrNotLoaded", err)
+	}
+	if err := storage.DeleteUserURLs("user1", []string{"a"}); !errors.Is(err, ErrNotLoaded) {
+		t.Errorf("DeleteUserURLs() after a failed load error = %v, want ErrNotLoaded", err)
+	}
+	if got, found := storage.Get("a"); found {
+		t.Errorf("Get() after a failed load = %q, want not found", got)
+	}
+	if _, err := storage.GetWithDeletedStatus("a"); !errors.Is(err, ErrNotLoaded) {
+		t.Errorf("GetWithDeletedStatus() after a failed load error = %v, want ErrNotLoaded", err)
+	}
+	if _, err := storage.GetUserURLs("user1"); !errors.Is(err, ErrNotLoaded) {
+		t.Errorf("GetUserURLs() after a failed load error = %v, want ErrNotLoaded", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if string(data) != corrupt {
+		t.Errorf("file changed after a failed load")
+	}
+}
+
+func TestStorage_IDCollision(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+
+	storage, err := NewStorage(path, WithIDGenerator(generator.NewSequence("aaaaaaaa", "aaaaaaaa", "bbbbbbbb")))
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	defer storage.Close()
+
+	first, err := storage.Save("https://example.com/first")
+	if err != nil || first != "aaaaaaaa" {
+		t.Fatalf("Save() = %q, %v, want aaaaaaaa", first, err)
+	}
+
+	saved, err := storage.SaveBatch([]model.BatchRequestItem{{CorrelationID: "1", OriginalURL: "https://example.com/second"}})
+	if err != nil || saved["1"].ID != "bbbbbbbb" {
+		t.Fatalf("SaveBatch() after a collision = %v, %v, want bbbbbbbb", saved, err)
+	}
+
+	reopened, err := NewStorage(path)
+	if err != nil {
+		t.Fatalf("NewStorage() reopen error = %v", err)
+	}
+	defer reopened.Close()
+
+	if got, _ := reopened.Get(first); got != "https://example.com/first" {
+		t.Errorf("Get(%q) = %q, want the first URL to be kept", first, got)
+	}
+}
+
+func TestStorage_ForwardQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+
+	storage, err := NewStorage(path)
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	defer storage.Close()
+
+	on, _ := storage.Save("https://a.example.com")
+	off, _ := storage.Save("https://b.example.com")
+	if err := storage.SetForwardQuery(on, true); err != nil {
+		t.Fatalf("Storage.SetForwardQuery() error = %v", err)
+	}
+	if err := storage.SetForwardQuery(off, true); err != nil {
+		t.Fatalf("Storage.SetForwardQuery() error = %v", err)
+	}
+	if err := storage.SetForwardQuery(off, false); err != nil {
+		t.Fatalf("Storage.SetForwardQuery() error = %v", err)
+	}
+	// A later update must not clear the setting.
+	if err := storage.DeleteUserURLs("nobody", []string{on}); err != nil {
+		t.Fatalf("Storage.DeleteUserURLs() error = %v", err)
+	}
+	if err := storage.SetForwardQuery("missing", true); err == nil {
+		t.Errorf("Storage.SetForwardQuery() of an unknown ID error = nil, want an error")
+	}
+
+	for _, compact := range []bool{false, true} {
+		if compact {
+			if err := storage.Compact(); err != nil {
+				t.Fatalf("Storage.Compact() error = %v", err)
+			}
+		}
+
+		reopened, err := NewStorage(path)
+		if err != nil {
+			t.Fatalf("NewStorage() reopen error = %v", err)
+		}
+
+		if forward, _ := reopened.ForwardQuery(on); !forward {
+			t.Errorf("compact=%v: ForwardQuery(%s) = false, want true", compact, on)
+		}
+		if forward, _ := reopened.ForwardQuery(off); forward {
+			t.Errorf("compact=%v: ForwardQuery(%s) = true, want false", compact, off)
+		}
+		reopened.Close()
+	}
+}
+
+func TestStorage_Creator(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+
+	storage, err := NewStorage(path)
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	defer storage.Close()
+
+	want := model.Creator{IP: "203.0.113.7", UserAgent: "curl/8.5.0"}
+	recorded, _ := storage.Save("https://a.example.com")
+	anonymous, _ := storage.Save("https://b.example.com")
+	if err := storage.SetCreator(recorded, want); err != nil {
+		t.Fatalf("Storage.SetCreator() error = %v", err)
+	}
+	// A later update must not clear the creator.
+	if err := storage.DeleteUserURLs("nobody", []string{recorded}); err != nil {
+		t.Fatalf("Storage.DeleteUserURLs() error = %v", err)
+	}
+	if err := storage.SetCreator("missing", want); err == nil {
+		t.Errorf("Storage.SetCreator() of an unknown ID error = nil, want an error")
+	}
+
+	for _, compact := range []bool{false, true} {
+		if compact {
+			if err := storage.Compact(); err != nil {
+				t.Fatalf("Storage.Compact() error = %v", err)
+			}
+		}
+
+		reopened, err := NewStorage(path)
+		if err != nil {
+			t.Fatalf("NewStorage() reopen error = %v", err)
+		}
+
+		if got, _ := reopened.Creator(recorded); got != want {
+			t.Errorf("compact=%v: Creator(%s) = %+v, want %+v", compact, recorded, got, want)
+		}
+		if got, _ := reopened.Creator(anonymous); !got.IsZero() {
+			t.Errorf("compact=%v: Creator(%s) = %+v, want none", compact, anonymous, got)
+		}
+		reopened.Close()
+	}
+}
+
+func TestStorage_CacheMissThenHit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+
+	storage, err := NewStorage(path, WithCacheSize(2))
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	defer storage.Close()
+
+	urls := []string{"https://a.example.com", "https://b.example.com", "https://c.example.com"}
+	ids := make([]string, len(urls))
+	for i, u := range urls {
+		if ids[i], err = storage.Save(u); err != nil {
+			t.Fatalf("Storage.Save() error = %v", err)
+		}
+	}
+
+	if len(storage.urlMap) != 0 {
+		t.Errorf("forward map holds %d URLs, want none with a cache", len(storage.urlMap))
+	}
+	if storage.cache.Len() != 2 {
+		t.Fatalf("cache holds %d URLs, want 2", storage.cache.Len())
+	}
+	if _, cached := storage.cache.Get(storage.key(ids[0])); cached {
+		t.Fatalf("oldest URL is cached, want evicted")
+	}
+
+	// Miss: served from the file and cached.
+	if got, found := storage.Get(ids[0]); !found || got != urls[0] {
+		t.Errorf("Get(%s) = %v, %v, want %v, true", ids[0], got, found, urls[0])
+	}
+	if got, cached := storage.cache.Get(storage.key(ids[0])); !cached || got != urls[0] {
+		t.Errorf("URL not cached after miss")
+	}
+
+	// Hit: served from the cache even if the file is gone.
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if got, found := storage.Get(ids[0]); !found || got != urls[0] {
+		t.Errorf("cached Get(%s) = %v, %v, want %v, true", ids[0], got, found, urls[0])
+	}
+
+	if _, found := storage.Get("unknown"); found {
+		t.Errorf("Get(unknown) found a URL")
+	}
+
+	if count, _ := storage.Count(); count != 3 {
+		t.Errorf("Count() = %d, want 3", count)
+	}
+}
+
+func TestStorage_CacheMissScan(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+
+	storage, err := NewStorage(path, WithCacheSize(1))
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	defer storage.Close()
+
+	id, err := storage.Save("https://a.example.com")
+	if err != nil {
+		t.Fatalf("Storage.Save() error = %v", err)
+	}
+	if _, err := storage.Save("https://b.example.com"); err != nil {
+		t.Fatalf("Storage.Save() error = %v", err)
+	}
+
+	// A record still being appended has no trailing newline yet.
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	file.WriteString(`{"uuid":"3","short_url":"par`)
+	file.Close()
+
+	// Scans do not wait for writers.
+	storage.fileWriteMu.Lock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if got, found := storage.Get(id); !found || got != "https://a.example.com" {
+			t.Errorf("Get(%s) = %v, %v, want %v, true", id, got, found, "https://a.example.com")
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("Get() on a cache miss waited for fileWriteMu")
+	}
+	storage.fileWriteMu.Unlock()
+	<-done
+}
+
+func TestStorage_FailedAppendNotStored(t *testing.T) {
+	storage, err := NewStorage(filepath.Join(t.TempDir(), "storage.json"))
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	defer storage.Close()
+
+	storage.file.Close()
+	if _, err := storage.SaveWithUser("https://example.com", "user1"); err == nil {
+		t.Fatal("Storage.SaveWithUser() with a closed file error = nil, want an error")
+	}
+	if err := storage.SaveWithAlias("alias", "https://example.com/alias", "user1"); err == nil {
+		t.Fatal("Storage.SaveWithAlias() with a closed file error = nil, want an error")
+	}
+	imported := []model.URLRecord{{ShortURL: "imported", OriginalURL: "https://example.com/imported", UserID: "user1"}}
+	if err := storage.ImportURLs(imported); err == nil {
+		t.Fatal("Storage.ImportURLs() with a closed file error = nil, want an error")
+	}
+
+	if count, _ := storage.Count(); count != 0 {
+		t.Errorf("Count() = %d, want 0", count)
+	}
+	if urls, _ := storage.GetUserURLs("user1"); len(urls) != 0 {
+		t.Errorf("GetUserURLs() = %v, want none", urls)
+	}
+
+	if err := storage.openForAppend(); err != nil {
+		t.Fatalf("openForAppend() error = %v", err)
+	}
+	if _, err := storage.SaveWithUser("https://example.com", "user1"); err != nil {
+		t.Errorf("Storage.SaveWithUser() after a failed append error = %v, want nil", err)
+	}
+	if err := storage.SaveWithAlias("alias", "https://example.com/alias", "user1"); err != nil {
+		t.Errorf("Storage.SaveWithAlias() after a failed append error = %v, want nil", err)
+	}
+	if err := storage.ImportURLs(imported); err != nil {
+		t.Errorf("Storage.ImportURLs() after a failed append error = %v, want nil", err)
+	}
+}
+
+func TestStorage_FailedSettingNotApplied(t *testing.T) {
+	tests := []struct {
+		name string
+		set  func(s *Storage, id string) error
+		// applied reports whether the setting took effect in memory.
+		applied func(s *Storage, id string) bool
+	}{
+		{
+			name: "password",
+			set:  func(s *Storage, id string) error { return s.SetPasswordHash(id, "hash") },
+			applied: func(s *Storage, id string) bool {
+				hash, _ := s.PasswordHash(id)
+				return hash != ""
+			},
+		},
+		{
+			name: "expiry",
+			set:  func(s *Storage, id string) error { return s.SetExpiry(id, time.Now().Add(time.Hour)) },
+			applied: func(s *Storage, id string) bool {
+				expiresAt, _ := s.Expiry(id)
+				return !expiresAt.IsZero()
+			},
+		},
+		{
+			name: "forward query",
+			set:  func(s *Storage, id string) error { return s.SetForwardQuery(id, true) },
+			applied: func(s *Storage, id string) bool {
+				enabled, _ := s.ForwardQuery(id)
+				return enabled
+			},
+		},
+		{
+			name: "creator",
+			set:  func(s *Storage, id string) error { return s.SetCreator(id, model.Creator{IP: "192.0.2.1"}) },
+			applied: func(s *Storage, id string) bool {
+				creator, _ := s.Creator(id)
+				return creator.IP != ""
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewStorage(filepath.Join(t.TempDir(), "storage.json"))
+			if err != nil {
+				t.Fatalf("NewStorage() error = %v", err)
+			}
+			defer s.Close()
+
+			id, err := s.Save("https://example.com")
+			if err != nil {
+				t.Fatalf("Storage.Save() error = %v", err)
+			}
+
+			s.file.Close()
+			if err := tt.set(s, id); err == nil {
+				t.Fatal("setting with a closed file error = nil, want an error")
+			}
+			if tt.applied(s, id) {
+				t.Error("setting applied in memory after a failed write")
+			}
+
+			if err := s.openForAppend(); err != nil {
+				t.Fatalf("openForAppend() error = %v", err)
+			}
+			if err := tt.set(s, id); err != nil {
+				t.Errorf("setting after a failed write error = %v, want nil", err)
+			}
+			if !tt.applied(s, id) {
+				t.Error("setting not applied after a successful write")
+			}
+		})
+	}
+}
+
+func TestStorage_PurgedIDReusedAfterReload(t *testing.T) {
+	tests := []struct {
+		name  string
+		purge bool
+		// reuse stores a new URL under the expired alias "promo".
+		reuse func(t *testing.T, s *Storage, path string)
+	}{
+		{
+			name:  "saved again",
+			purge: true,
+			reuse: func(t *testing.T, s *Storage, path string) {
+				if err := s.SaveWithAlias("promo", "https://new.example.com", ""); err != nil {
+					t.Fatalf("Storage.SaveWithAlias() error = %v", err)
+				}
+			},
+		},
+		{
+			// Files written before purges dropped records keep the old ones.
+			name: "left in the log",
+			reuse: func(t *testing.T, s *Storage, path string) {
+				file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+				if err != nil {
+					t.Fatalf("OpenFile() error = %v", err)
+				}
+				defer file.Close()
+				file.WriteString(`{"uuid":"99","short_url":"promo","original_url":"https://new.example.com","user_id":"","is_deleted":false}` + "\n")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "storage.json")
+
+			s, err := NewStorage(path)
+			if err != nil {
+				t.Fatalf("NewStorage() error = %v", err)
+			}
+			if err := s.SaveWithAlias("promo", "https://old.example.com", ""); err != nil {
+				t.Fatalf("Storage.SaveWithAlias() error = %v", err)
+			}
+			if err := s.SetPasswordHash("promo", "hash"); err != nil {
+				t.Fatalf("Storage.SetPasswordHash() error = %v", err)
+			}
+			if err := s.SetExpiry("promo", time.Now().Add(-time.Minute)); err != nil {
+				t.Fatalf("Storage.SetExpiry() error = %v", err)
+			}
+
+			if tt.purge {
+				if n, err := s.PurgeExpired(time.Now()); err != nil || n != 1 {
+					t.Fatalf("Storage.PurgeExpired() = %d, %v, want 1, nil", n, err)
+				}
+			}
+			tt.reuse(t, s, path)
+			s.Close()
+
+			s, err = NewStorage(path)
+			if err != nil {
+				t.Fatalf("NewStorage() after reload error = %v", err)
+			}
+			defer s.Close()
+
+			if got, err := s.GetWithDeletedStatus("promo"); err != nil || got != "https://new.example.com" {
+				t.Errorf("GetWithDeletedStatus(promo) = %v, %v, want %v, nil", got, err, "https://new.example.com")
+			}
+			if hash, _ := s.PasswordHash("promo"); hash != "" {
+				t.Errorf("PasswordHash(promo) = %q, want none", hash)
+			}
+			if expiresAt, _ := s.Expiry("promo"); !expiresAt.IsZero() {
+				t.Errorf("Expiry(promo) = %v, want none", expiresAt)
+			}
+			if tt.purge {
+				if id, err := s.Save("https://old.example.com"); err != nil || id == "promo" {
+					t.Errorf("Save(old URL) = %v, %v, want a new ID", id, err)
+				}
+			}
+		})
+	}
+}
+
+func TestStorage_ImportURLs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+
+	s, err := NewStorage(path)
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	defer s.Close()
+
+	existing, err := s.Save("https://taken.example.com")
+	if err != nil {
+		t.Fatalf("Storage.Save() error = %v", err)
+	}
+
+	records := []model.URLRecord{
+		{ShortURL: "abc", OriginalURL: "https://a.example.com", UserID: "user1"},
+		{ShortURL: existing, OriginalURL: "https://taken.example.com"},
+		{ShortURL: existing, OriginalURL: "https://other.example.com"},
+		{ShortURL: "xyz", OriginalURL: "https://taken.example.com"},
+	}
+
+	var conflictErr *storage.ImportConflictError
+	if err := s.ImportURLs(records); !errors.As(err, &conflictErr) {
+		t.Fatalf("Storage.ImportURLs() error = %v, want *storage.ImportConflictError", err)
+	}
+	if want := []string{existing, "xyz"}; !reflect.DeepEqual(conflictErr.ShortIDs, want) {
+		t.Errorf("Storage.ImportURLs() conflicts = %v, want %v", conflictErr.ShortIDs, want)
+	}
+
+	reloaded, err := NewStorage(path)
+	if err != nil {
+		t.Fatalf("NewStorage() reload error = %v", err)
+	}
+	defer reloaded.Close()
+
+	for name, st := range map[string]*Storage{"live": s, "reloaded": reloaded} {
+		if got, found := st.Get("abc"); !found || got != "https://a.example.com" {
+			t.Errorf("%s: Storage.Get(abc) = %v, %v, want %v, true", name, got, found, "https://a.example.com")
+		}
+		if _, found := st.Get("xyz"); found {
+			t.Errorf("%s: Storage.Get(xyz) found a conflicting record", name)
+		}
+		if urls, _ := st.GetUserURLs("user1"); len(urls) != 1 || urls[0].ShortURL != "abc" {
+			t.Errorf("%s: Storage.GetUserURLs(user1) = %v, want [abc]", name, urls)
+		}
+	}
+
+	if err := s.ImportURLs(records[:1]); err != nil {
+		t.Errorf("Storage.ImportURLs() again error = %v, want nil", err)
+	}
+}
+
+func TestStorage_ImportURLsDuplicates(t *testing.T) {
+	src, err := NewStorage(filepath.Join(t.TempDir(), "storage.json"), WithDuplicateURLs(true))
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	defer src.Close()
+
+	first, _ := src.Save("https://a.example.com")
+	second, _ := src.Save("https://a.example.com")
+	if first == second {
+		t.Fatalf("Storage.Save() returned %v twice, want distinct IDs", first)
+	}
+
+	var exported []model.URLRecord
+	err = src.ExportURLs(func(record model.URLRecord) error {
+		exported = append(exported, record)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Storage.ExportURLs() error = %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "storage.json")
+	dst, err := NewStorage(path, WithDuplicateURLs(true))
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	defer dst.Close()
+
+	if err := dst.ImportURLs(exported); err != nil {
+		t.Fatalf("Storage.ImportURLs() error = %v, want nil", err)
+	}
+
+	reloaded, err := NewStorage(path, WithDuplicateURLs(true))
+	if err != nil {
+		t.Fatalf("NewStorage() reload error = %v", err)
+	}
+	defer reloaded.Close()
+
+	for name, st := range map[string]*Storage{"live": dst, "reloaded": reloaded} {
+		for _, id := range []string{first, second} {
+			if got, found := st.Get(id); !found || got != "https://a.example.com" {
+				t.Errorf("%s: Storage.Get(%s) = %v, %v, want %v, true", name, id, got, found, "https://a.example.com")
+			}
+		}
+	}
+}
+
+func TestStorage_ExportURLs(t *testing.T) {
+	s, err := NewStorage(filepath.Join(t.TempDir(), "storage.json"))
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	defer s.Close()
+
+	kept, _ := s.SaveWithUser("https://a.example.com", "user1")
+	gone, _ := s.SaveWithUser("https://b.example.com", "user1")
+	s.DeleteUserURLs("user1", []string{gone})
+	s.ForTenant("other").Save("https://c.example.com")
+
+	var exported []model.URLRecord
+	err = s.ExportURLs(func(record model.URLRecord) error {
+		exported = append(exported, record)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Storage.ExportURLs() error = %v", err)
+	}
+
+	want := []model.URLRecord{
+		{ShortURL: kept, OriginalURL: "https://a.example.com", UserID: "user1"},
+		{ShortURL: gone, OriginalURL: "https://b.example.com", UserID: "user1", IsDeleted: true},
+	}
+	for i := range exported {
+		if exported[i].CreatedAt == nil || exported[i].CreatedAt.IsZero() {
+			t.Errorf("Storage.ExportURLs() record %d has no creation time", i)
+		}
+		exported[i].CreatedAt = nil
+	}
+	if !reflect.DeepEqual(exported, want) {
+		t.Errorf("Storage.ExportURLs() = %v, want %v", exported, want)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = s.ExportURLs(func(model.URLRecord) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("Storage.ExportURLs() with failing fn = %v after %d calls, want %v after 1", err, calls, stop)
+	}
+}
+
+func TestStorage_UUIDSequence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.json")
+
+	storage, err := NewStorage(path)
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	defer storage.Close()
+
+	const workers, perWorker = 8, 25
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			userID := fmt.Sprintf("user%d", w)
+			for i := 0; i < perWorker; i++ {
+				id, err := storage.SaveWithUser(fmt.Sprintf("https://%d.example.com/%d", w, i), userID)
+				if err != nil {
+					t.Errorf("SaveWithUser() error = %v", err)
+					return
+				}
+				if i%2 == 0 {
+					if err := storage.DeleteUserURLs(userID, []string{id}); err != nil {
+						t.Errorf("DeleteUserURLs() error = %v", err)
+						return
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var last int64
+	created := 0
+	_, err = readRecords(file, func(record model.URLRecord) error {
+		if record.IsDeleted {
+			if record.UUID != "" {
+				t.Errorf("deletion record of %s has UUID %q, want none", record.ShortURL, record.UUID)
+			}
+			return nil
+		}
+
+		uuid, err := strconv.ParseInt(record.UUID, 10, 64)
+		if err != nil {
+			t.Errorf("record of %s has UUID %q, want a number", record.ShortURL, record.UUID)
+			return nil
+		}
+		if uuid <= last {
+			t.Errorf("record of %s has UUID %d after %d, want increasing UUIDs", record.ShortURL, uuid, last)
+		}
+		last = uuid
+		created++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("readRecords() error = %v", err)
+	}
+	if created != workers*perWorker || last != workers*perWorker {
+		t.Errorf("file has %d URL records up to UUID %d, want %d", created, last, workers*perWorker)
+	}
+
+	// Compaction drops the deleted URL with the last UUID, but the sequence
+	// continues after a restart.
+	id, _ := storage.SaveWithUser("https://last.example.com", "user0")
+	if err := storage.DeleteUserURLs("user0", []string{id}); err != nil {
+		t.Fatalf("DeleteUserURLs() error = %v", err)
+	}
+	last++
+	if err := storage.Compact(); err != nil {
+		t.Fatalf("Compact() error = %v", err)
+	}
+	storage.Close()
+
+	reloaded, err := NewStorage(path)
+	if err != nil {
+		t.Fatalf("NewStorage() reload error = %v", err)
+	}
+	defer reloaded.Close()
+
+	if _, err := reloaded.Save("https://after.example.com"); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if got := reloaded.lastUUID.Load(); got != last+1 {
+		t.Errorf("UUID after restart = %d, want %d", got, last+1)
+	}
+}
+
+func TestStorage_Suite(t *testing.T) {
+	storagetest.RunSuite(t, func() storage.URLStorage {
+		s, err := NewStorage(filepath.Join(t.TempDir(), "storage.json"))
+		if err != nil {
+			t.Fatalf("NewStorage() error = %v", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+func TestStorage_DuplicateSuite(t *testing.T) {
+	storagetest.RunDuplicateSuite(t, func() storage.URLStorage {
+		s, err := NewStorage(filepath.Join(t.TempDir(), "storage.json"), WithDuplicateURLs(true))
+		if err != nil {
+			t.Fatalf("NewStorage() error = %v", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
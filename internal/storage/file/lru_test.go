@@ -0,0 +1,29 @@
+package file
+
+import "testing"
+
+func TestLRU_Eviction(t *testing.T) {
+	cache := newLRU(2)
+	cache.Add("a", "https://a.example.com")
+	cache.Add("b", "https://b.example.com")
+
+	// Touch "a" so that "b" becomes the least recently used entry.
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatalf("Get(a) missed before eviction")
+	}
+
+	cache.Add("c", "https://c.example.com")
+
+	if _, ok := cache.Get("b"); ok {
+		t.Errorf("Get(b) hit, want evicted")
+	}
+	if got, ok := cache.Get("a"); !ok || got != "https://a.example.com" {
+		t.Errorf("Get(a) = %v, %v, want https://a.example.com, true", got, ok)
+	}
+	if got, ok := cache.Get("c"); !ok || got != "https://c.example.com" {
+		t.Errorf("Get(c) = %v, %v, want https://c.example.com, true", got, ok)
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", cache.Len())
+	}
+}
@@ -1,142 +1,346 @@
 package memory
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/MikhailRaia/url-shortener/internal/generator"
 	"github.com/MikhailRaia/url-shortener/internal/model"
 	"github.com/MikhailRaia/url-shortener/internal/storage"
+	"strings"
 	"sync"
+	"time"
 )
 
 // Storage implements in-memory URLStorage for testing and development.
 type Storage struct {
+	*data
+	tenantID string
+}
+
+// data is the state shared by all tenant views of a Storage.
+type data struct {
 	urlMap     map[string]string
+	idByURL    map[string]string
 	userURLs   map[string][]model.URL
 	deletedMap map[string]bool
+	passwords  map[string]string
+	forwarding map[string]bool
+	creators   map[string]model.Creator
+	stats      map[string]model.URLStats
+	expiries   map[string]time.Time
+	ids        generator.IDGenerator
+	duplicates bool
 	mutex      sync.RWMutex
+	txMutex    sync.Mutex
+}
+
+// maxIDAttempts bounds retries when a generated short ID collides with an existing one.
+const maxIDAttempts = 10
+
+// Option configures optional memory storage features.
+type Option func(*data)
+
+// WithIDGenerator makes the storage generate short IDs with ids instead of
+// generator.Default.
+func WithIDGenerator(ids generator.IDGenerator) Option {
+	return func(d *data) {
+		d.ids = ids
+	}
+}
+
+// WithDuplicateURLs makes every save of a URL store it under a fresh short ID
+// instead of returning the ID it was first saved under.
+func WithDuplicateURLs(allow bool) Option {
+	return func(d *data) {
+		d.duplicates = allow
+	}
 }
 
 // NewStorage creates a new in-memory storage instance.
-func NewStorage() *Storage {
+func NewStorage(opts ...Option) *Storage {
+	s := &Storage{
+		data: &data{
+			urlMap:     make(map[string]string),
+			idByURL:    make(map[string]string),
+			userURLs:   make(map[string][]model.URL),
+			deletedMap: make(map[string]bool),
+			passwords:  make(map[string]string),
+			forwarding: make(map[string]bool),
+			creators:   make(map[string]model.Creator),
+			stats:      make(map[string]model.URLStats),
+			expiries:   make(map[string]time.Time),
+			ids:        generator.Default(),
+		},
+	}
+
+	for _, opt := range opts {
+		opt(s.data)
+	}
+
+	return s
+}
+
+// newID generates a short ID not yet used in the view's tenant. Callers must
+// hold the mutex.
+func (s *Storage) newID() (string, error) {
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		id, err := s.ids.Generate()
+		if err != nil {
+			return "", err
+		}
+		if _, taken := s.urlMap[s.key(id)]; !taken {
+			return id, nil
+		}
+	}
+
+	return "", errors.New("failed to generate a unique ID")
+}
+
+// ForTenant returns a view of the storage scoped to the given tenant.
+func (s *Storage) ForTenant(tenantID string) storage.URLStorage {
 	return &Storage{
-		urlMap:     make(map[string]string),
-		userURLs:   make(map[string][]model.URL),
-		deletedMap: make(map[string]bool),
+		data:     s.data,
+		tenantID: tenantID,
 	}
 }
 
-// Save stores a new URL and returns its generated short ID.
-func (s *Storage) Save(originalURL string) (string, error) {
-	id, err := generator.GenerateID(8)
-	if err != nil {
-		return "", err
+// WithTx runs fn with the storage, serialized against other WithTx calls on
+// the same data so that checks made in fn still hold when it writes.
+// Operations outside WithTx are not held back, and changes fn made before
+// failing are kept: there is no rollback.
+func (s *Storage) WithTx(ctx context.Context, fn func(storage.URLStorage) error) error {
+	s.txMutex.Lock()
+	defer s.txMutex.Unlock()
+
+	return fn(s)
+}
+
+// existingID returns the ID originalURL is already stored under in the view's
+// tenant, unless duplicate URLs are allowed. Callers must hold the mutex.
+func (s *Storage) existingID(originalURL string) (string, bool) {
+	if s.duplicates {
+		return "", false
 	}
+	id, exists := s.idByURL[s.key(originalURL)]
+	return id, exists
+}
+
+// key namespaces an ID or user ID by the view's tenant.
+func (s *Storage) key(id string) string {
+	if s.tenantID == "" {
+		return id
+	}
+	return s.tenantID + "\x00" + id
+}
 
+// Save stores a new URL and returns its generated short ID. If the URL is
+// already stored, its existing ID is returned with storage.ErrURLExists.
+func (s *Storage) Save(originalURL string) (string, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	s.urlMap[id] = originalURL
+	if existingID, exists := s.existingID(originalURL); exists {
+		return existingID, storage.ErrURLExists
+	}
+
+	id, err := s.newID()
+	if err != nil {
+		return "", err
+	}
+
+	s.storeURL(id, originalURL)
 	return id, nil
 }
 
+// storeURL stores originalURL under id and remembers the ID for deduplication.
+// Callers must hold the mutex.
+func (s *Storage) storeURL(id, originalURL string) {
+	s.urlMap[s.key(id)] = originalURL
+	if _, exists := s.idByURL[s.key(originalURL)]; !exists {
+		s.idByURL[s.key(originalURL)] = id
+	}
+}
+
 // Get retrieves the original URL for a given short ID.
 func (s *Storage) Get(id string) (string, bool) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
-	originalURL, found := s.urlMap[id]
+	originalURL, found := s.urlMap[s.key(id)]
 	if !found {
 		return "", false
 	}
 
-	if s.deletedMap[id] {
+	if s.deletedMap[s.key(id)] || s.expired(s.key(id)) {
 		return "", false
 	}
 
 	return originalURL, true
 }
 
+// GetBatch looks up the given short IDs at once, leaving out unknown ones.
+func (s *Storage) GetBatch(ids []string) (map[string]model.ExpandedURL, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	result := make(map[string]model.ExpandedURL, len(ids))
+	for _, id := range ids {
+		originalURL, found := s.urlMap[s.key(id)]
+		if !found {
+			continue
+		}
+
+		result[id] = model.ExpandedURL{
+			ID:          id,
+			OriginalURL: originalURL,
+			Deleted:     s.deletedMap[s.key(id)],
+			Expired:     s.expired(s.key(id)),
+		}
+	}
+
+	return result, nil
+}
+
 // GetWithDeletedStatus retrieves the original URL and checks if it has been deleted.
 func (s *Storage) GetWithDeletedStatus(id string) (string, error) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
-	originalURL, found := s.urlMap[id]
+	originalURL, found := s.urlMap[s.key(id)]
 	if !found {
 		return "", nil
 	}
 
-	if s.deletedMap[id] {
+	if s.deletedMap[s.key(id)] {
 		return "", storage.ErrURLDeleted
 	}
 
+	if s.expired(s.key(id)) {
+		return "", storage.ErrURLExpired
+	}
+
 	return originalURL, nil
 }
 
-// SaveBatch stores multiple URLs and returns a map of correlation IDs to short IDs.
-func (s *Storage) SaveBatch(items []model.BatchRequestItem) (map[string]string, error) {
-	result := make(map[string]string)
+// expired reports whether the URL stored under k has passed its expiry.
+// Callers must hold the mutex.
+func (s *Storage) expired(k string) bool {
+	expiresAt, ok := s.expiries[k]
+	return ok && !time.Now().Before(expiresAt)
+}
+
+// SaveBatch stores multiple URLs and returns the short ID saved for each correlation ID.
+// URLs that are already stored keep their existing ID.
+func (s *Storage) SaveBatch(items []model.BatchRequestItem) (map[string]model.BatchSaveResult, error) {
+	result := make(map[string]model.BatchSaveResult)
 
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	for _, item := range items {
-		id, err := generator.GenerateID(8)
+		if existingID, exists := s.existingID(item.OriginalURL); exists {
+			result[item.CorrelationID] = model.BatchSaveResult{ID: existingID}
+			continue
+		}
+
+		id, err := s.newID()
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate ID: %w", err)
 		}
 
-		s.urlMap[id] = item.OriginalURL
-		result[item.CorrelationID] = id
+		s.storeURL(id, item.OriginalURL)
+		result[item.CorrelationID] = model.BatchSaveResult{ID: id, Created: true}
 	}
 
 	return result, nil
 }
 
 // SaveWithUser stores a new URL associated with a user and returns its generated short ID.
+// If the URL is already stored, its existing ID is returned with storage.ErrURLExists.
 func (s *Storage) SaveWithUser(originalURL, userID string) (string, error) {
-	id, err := generator.GenerateID(8)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if existingID, exists := s.existingID(originalURL); exists {
+		return existingID, storage.ErrURLExists
+	}
+
+	id, err := s.newID()
 	if err != nil {
 		return "", err
 	}
 
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
-	s.urlMap[id] = originalURL
+	s.storeURL(id, originalURL)
 
 	url := model.URL{
 		ID:          id,
 		OriginalURL: originalURL,
 		UserID:      userID,
+		CreatedAt:   time.Now().UTC(),
 	}
-	s.userURLs[userID] = append(s.userURLs[userID], url)
+	s.userURLs[s.key(userID)] = append(s.userURLs[s.key(userID)], url)
 
 	return id, nil
 }
 
-// SaveBatchWithUser stores multiple URLs associated with a user and returns a map of correlation IDs to short IDs.
-func (s *Storage) SaveBatchWithUser(items []model.BatchRequestItem, userID string) (map[string]string, error) {
-	result := make(map[string]string)
+// SaveWithAlias stores a URL under the given alias, optionally associated with a user.
+// Saving the same URL under the same alias again is a no-op.
+func (s *Storage) SaveWithAlias(alias, originalURL, userID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if existing, found := s.urlMap[s.key(alias)]; found {
+		if existing == originalURL && !s.deletedMap[s.key(alias)] {
+			return nil
+		}
+		return storage.ErrAliasTaken
+	}
+
+	s.storeURL(alias, originalURL)
+
+	if userID != "" {
+		url := model.URL{
+			ID:          alias,
+			OriginalURL: originalURL,
+			UserID:      userID,
+			CreatedAt:   time.Now().UTC(),
+		}
+		s.userURLs[s.key(userID)] = append(s.userURLs[s.key(userID)], url)
+	}
+
+	return nil
+}
+
+// SaveBatchWithUser stores multiple URLs associated with a user and returns the short ID saved for each correlation ID.
+// URLs that are already stored keep their existing ID and owner.
+func (s *Storage) SaveBatchWithUser(items []model.BatchRequestItem, userID string) (map[string]model.BatchSaveResult, error) {
+	result := make(map[string]model.BatchSaveResult)
 
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	for _, item := range items {
-		id, err := generator.GenerateID(8)
+		if existingID, exists := s.existingID(item.OriginalURL); exists {
+			result[item.CorrelationID] = model.BatchSaveResult{ID: existingID}
+			continue
+		}
+
+		id, err := s.newID()
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate ID: %w", err)
 		}
 
-		s.urlMap[id] = item.OriginalURL
-		result[item.CorrelationID] = id
+		s.storeURL(id, item.OriginalURL)
+		result[item.CorrelationID] = model.BatchSaveResult{ID: id, Created: true}
 
 		url := model.URL{
 			ID:          id,
 			OriginalURL: item.OriginalURL,
 			UserID:      userID,
+			CreatedAt:   time.Now().UTC(),
 		}
-		s.userURLs[userID] = append(s.userURLs[userID], url)
+		s.userURLs[s.key(userID)] = append(s.userURLs[s.key(userID)], url)
 	}
 
 	return result, nil
@@ -147,17 +351,18 @@ func (s *Storage) GetUserURLs(userID string) ([]model.UserURL, error) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
-	urls, exists := s.userURLs[userID]
+	urls, exists := s.userURLs[s.key(userID)]
 	if !exists {
 		return []model.UserURL{}, nil
 	}
 
 	var result []model.UserURL
 	for _, url := range urls {
-		if !s.deletedMap[url.ID] {
+		if !s.deletedMap[s.key(url.ID)] {
 			result = append(result, model.UserURL{
 				ShortURL:    url.ID,
 				OriginalURL: url.OriginalURL,
+				CreatedAt:   url.CreatedAt,
 			})
 		}
 	}
@@ -165,12 +370,39 @@ func (s *Storage) GetUserURLs(userID string) ([]model.UserURL, error) {
 	return result, nil
 }
 
+// GetUserURLsPaged retrieves a window of a user's non-deleted URLs in creation order
+// along with the total number of such URLs.
+func (s *Storage) GetUserURLsPaged(userID string, limit, offset int) ([]model.UserURL, int, error) {
+	urls, err := s.GetUserURLs(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total := len(urls)
+	if offset >= total {
+		return []model.UserURL{}, total, nil
+	}
+
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+
+	return urls[offset:end], total, nil
+}
+
+// CountUserURLs returns the number of a user's non-deleted URLs.
+func (s *Storage) CountUserURLs(userID string) (int, error) {
+	_, total, err := s.GetUserURLsPaged(userID, 1, 0)
+	return total, err
+}
+
 // DeleteUserURLs marks specified URLs as deleted for a user.
 func (s *Storage) DeleteUserURLs(userID string, urlIDs []string) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	userURLs, exists := s.userURLs[userID]
+	userURLs, exists := s.userURLs[s.key(userID)]
 	if !exists {
 		return nil
 	}
@@ -184,9 +416,241 @@ func (s *Storage) DeleteUserURLs(userID string, urlIDs []string) error {
 	// Mark URLs as deleted only if they belong to the user
 	for _, urlID := range urlIDs {
 		if userURLSet[urlID] {
-			s.deletedMap[urlID] = true
+			s.deletedMap[s.key(urlID)] = true
+		}
+	}
+
+	return nil
+}
+
+// OwnsURL reports whether the user owns the short URL, deleted or not.
+// It returns storage.ErrURLNotFound for unknown short IDs.
+func (s *Storage) OwnsURL(userID, id string) (bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if _, found := s.urlMap[s.key(id)]; !found {
+		return false, storage.ErrURLNotFound
+	}
+	if userID == "" {
+		return false, nil
+	}
+
+	for _, url := range s.userURLs[s.key(userID)] {
+		if url.ID == id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// FindID returns the short ID originalURL is stored under, or
+// storage.ErrURLNotFound if it is not stored or duplicate URLs are allowed.
+func (s *Storage) FindID(originalURL string) (string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	id, exists := s.existingID(originalURL)
+	if !exists {
+		return "", storage.ErrURLNotFound
+	}
+	return id, nil
+}
+
+// GetDeletedUserURLs returns the short IDs of a user's URLs that are marked deleted.
+func (s *Storage) GetDeletedUserURLs(userID string) ([]string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	result := []string{}
+	seen := make(map[string]bool)
+	for _, url := range s.userURLs[s.key(userID)] {
+		if s.deletedMap[s.key(url.ID)] && !seen[url.ID] {
+			seen[url.ID] = true
+			result = append(result, url.ID)
+		}
+	}
+
+	return result, nil
+}
+
+// SetPasswordHash gates the short URL behind the given password hash.
+func (s *Storage) SetPasswordHash(id, hash string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, found := s.urlMap[s.key(id)]; !found {
+		return fmt.Errorf("short URL %s not found", id)
+	}
+
+	s.passwords[s.key(id)] = hash
+	return nil
+}
+
+// PasswordHash returns the password hash gating the short URL, or "" if it is not protected.
+func (s *Storage) PasswordHash(id string) (string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.passwords[s.key(id)], nil
+}
+
+// SetForwardQuery sets whether redirects of the short URL forward their query string.
+func (s *Storage) SetForwardQuery(id string, enabled bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, found := s.urlMap[s.key(id)]; !found {
+		return fmt.Errorf("short URL %s not found", id)
+	}
+
+	if enabled {
+		s.forwarding[s.key(id)] = true
+	} else {
+		delete(s.forwarding, s.key(id))
+	}
+	return nil
+}
+
+// ForwardQuery reports whether redirects of the short URL forward their query string.
+func (s *Storage) ForwardQuery(id string) (bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.forwarding[s.key(id)], nil
+}
+
+// SetCreator records the client that created the short URL.
+func (s *Storage) SetCreator(id string, creator model.Creator) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, found := s.urlMap[s.key(id)]; !found {
+		return fmt.Errorf("short URL %s not found", id)
+	}
+
+	s.creators[s.key(id)] = creator
+	return nil
+}
+
+// Creator returns the client that created the short URL, if it was recorded.
+func (s *Storage) Creator(id string) (model.Creator, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.creators[s.key(id)], nil
+}
+
+// Count returns the number of stored, non-deleted URLs across all tenants.
+func (s *Storage) Count() (int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	count := 0
+	for id := range s.urlMap {
+		if !s.deletedMap[id] {
+			count++
 		}
 	}
 
+	return count, nil
+}
+
+// IncrementVisit adds n visits to the short URL, the latest at the given time.
+func (s *Storage) IncrementVisit(id string, n int64, at time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stats := s.stats[s.key(id)]
+	stats.Visits += n
+	if at.After(stats.LastVisited) {
+		stats.LastVisited = at
+	}
+	s.stats[s.key(id)] = stats
+
+	return nil
+}
+
+// GetStats returns the visit statistics of the short URL.
+func (s *Storage) GetStats(id string) (model.URLStats, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.stats[s.key(id)], nil
+}
+
+// SetExpiry makes the short URL expire at the given time. A zero time removes the expiry.
+func (s *Storage) SetExpiry(id string, expiresAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, found := s.urlMap[s.key(id)]; !found {
+		return fmt.Errorf("short URL %s not found", id)
+	}
+
+	if expiresAt.IsZero() {
+		delete(s.expiries, s.key(id))
+	} else {
+		s.expiries[s.key(id)] = expiresAt
+	}
+
 	return nil
 }
+
+// Expiry returns when the short URL expires, or the zero time if it never does.
+func (s *Storage) Expiry(id string) (time.Time, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.expiries[s.key(id)], nil
+}
+
+// PurgeExpired removes URLs of all tenants that expired before the given time
+// and returns how many were removed.
+func (s *Storage) PurgeExpired(before time.Time) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	purged := make(map[string]bool)
+	for k, expiresAt := range s.expiries {
+		if expiresAt.Before(before) {
+			purged[k] = true
+			prefix := ""
+			if i := strings.IndexByte(k, 0); i >= 0 {
+				prefix = k[:i+1]
+			}
+			if urlKey := prefix + s.urlMap[k]; s.idByURL[urlKey] == k[len(prefix):] {
+				delete(s.idByURL, urlKey)
+			}
+			delete(s.urlMap, k)
+			delete(s.deletedMap, k)
+			delete(s.passwords, k)
+			delete(s.forwarding, k)
+			delete(s.creators, k)
+			delete(s.stats, k)
+			delete(s.expiries, k)
+		}
+	}
+
+	if len(purged) == 0 {
+		return 0, nil
+	}
+
+	for userKey, urls := range s.userURLs {
+		// User keys carry the same tenant prefix as the URL keys they own.
+		prefix := ""
+		if i := strings.IndexByte(userKey, 0); i >= 0 {
+			prefix = userKey[:i+1]
+		}
+
+		kept := urls[:0]
+		for _, url := range urls {
+			if !purged[prefix+url.ID] {
+				kept = append(kept, url)
+			}
+		}
+		s.userURLs[userKey] = kept
+	}
+
+	return len(purged), nil
+}
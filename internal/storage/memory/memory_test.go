@@ -1,7 +1,15 @@
 package memory
 
 import (
+	"errors"
+	"reflect"
 	"testing"
+	"time"
+
+	"github.com/MikhailRaia/url-shortener/internal/generator"
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	urlstorage "github.com/MikhailRaia/url-shortener/internal/storage"
+	"github.com/MikhailRaia/url-shortener/internal/storage/storagetest"
 )
 
 func TestStorage_Save(t *testing.T) {
@@ -28,6 +36,28 @@ func TestStorage_Save(t *testing.T) {
 	}
 }
 
+func TestStorage_IDCollision(t *testing.T) {
+	storage := NewStorage(WithIDGenerator(generator.NewSequence("aaaaaaaa", "aaaaaaaa", "bbbbbbbb")))
+
+	first, err := storage.Save("https://example.com/first")
+	if err != nil || first != "aaaaaaaa" {
+		t.Fatalf("Save() = %q, %v, want aaaaaaaa", first, err)
+	}
+
+	second, err := storage.SaveWithUser("https://example.com/second", "user1")
+	if err != nil || second != "bbbbbbbb" {
+		t.Fatalf("SaveWithUser() after a collision = %q, %v, want bbbbbbbb", second, err)
+	}
+
+	if got, _ := storage.Get(first); got != "https://example.com/first" {
+		t.Errorf("Get(%q) = %q, want the first URL to be kept", first, got)
+	}
+
+	if _, err := storage.Save("https://example.com/third"); !errors.Is(err, generator.ErrSequenceExhausted) {
+		t.Errorf("Save() with an exhausted generator error = %v, want ErrSequenceExhausted", err)
+	}
+}
+
 func TestStorage_Get(t *testing.T) {
 	storage := NewStorage()
 	originalURL := "https://example.com"
@@ -111,3 +141,231 @@ func BenchmarkStorage_SaveMultiple(b *testing.B) {
 		}
 	}
 }
+
+func TestStorage_ForTenant(t *testing.T) {
+	root := NewStorage()
+	tenantA := root.ForTenant("a.example.com")
+	tenantB := root.ForTenant("b.example.com")
+
+	id, err := tenantA.SaveWithUser("https://example.com", "user1")
+	if err != nil {
+		t.Fatalf("Storage.SaveWithUser() error = %v", err)
+	}
+
+	if got, found := tenantA.Get(id); !found || got != "https://example.com" {
+		t.Errorf("tenant A Get() = %v, %v, want %v, true", got, found, "https://example.com")
+	}
+
+	if _, found := tenantB.Get(id); found {
+		t.Errorf("tenant B Get() found URL saved in tenant A")
+	}
+
+	if _, found := root.Get(id); found {
+		t.Errorf("default tenant Get() found URL saved in tenant A")
+	}
+
+	urls, err := tenantB.GetUserURLs("user1")
+	if err != nil {
+		t.Fatalf("Storage.GetUserURLs() error = %v", err)
+	}
+	if len(urls) != 0 {
+		t.Errorf("tenant B GetUserURLs() returned %d URLs, want 0", len(urls))
+	}
+
+	if err := tenantB.DeleteUserURLs("user1", []string{id}); err != nil {
+		t.Fatalf("Storage.DeleteUserURLs() error = %v", err)
+	}
+	if _, found := tenantA.Get(id); !found {
+		t.Errorf("delete in tenant B removed URL from tenant A")
+	}
+
+	if err := tenantA.DeleteUserURLs("user1", []string{id}); err != nil {
+		t.Fatalf("Storage.DeleteUserURLs() error = %v", err)
+	}
+	if _, err := tenantA.GetWithDeletedStatus(id); err == nil {
+		t.Errorf("tenant A GetWithDeletedStatus() error = nil, want deleted")
+	}
+}
+
+func TestStorage_GetUserURLsPaged(t *testing.T) {
+	storage := NewStorage()
+	var ids []string
+	for _, u := range []string{"https://a.example.com", "https://b.example.com", "https://c.example.com"} {
+		id, err := storage.SaveWithUser(u, "user1")
+		if err != nil {
+			t.Fatalf("Storage.SaveWithUser() error = %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	tests := []struct {
+		name    string
+		userID  string
+		limit   int
+		offset  int
+		wantIDs []string
+		total   int
+	}{
+		{"First page", "user1", 2, 0, ids[:2], 3},
+		{"Last partial page", "user1", 2, 2, ids[2:], 3},
+		{"Offset at end", "user1", 2, 3, nil, 3},
+		{"Offset past end", "user1", 2, 10, nil, 3},
+		{"Limit larger than total", "user1", 10, 0, ids, 3},
+		{"User without URLs", "user2", 10, 0, nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urls, total, err := storage.GetUserURLsPaged(tt.userID, tt.limit, tt.offset)
+			if err != nil {
+				t.Fatalf("Storage.GetUserURLsPaged() error = %v", err)
+			}
+
+			if total != tt.total {
+				t.Errorf("Storage.GetUserURLsPaged() total = %v, want %v", total, tt.total)
+			}
+
+			if len(urls) != len(tt.wantIDs) {
+				t.Fatalf("Storage.GetUserURLsPaged() returned %d URLs, want %d", len(urls), len(tt.wantIDs))
+			}
+			for i, url := range urls {
+				if url.ShortURL != tt.wantIDs[i] {
+					t.Errorf("Storage.GetUserURLsPaged()[%d] = %v, want %v", i, url.ShortURL, tt.wantIDs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStorage_GetDeletedUserURLs(t *testing.T) {
+	storage := NewStorage()
+	keep, _ := storage.SaveWithUser("https://a.example.com", "user1")
+	gone, _ := storage.SaveWithUser("https://b.example.com", "user1")
+	other, _ := storage.SaveWithUser("https://c.example.com", "user2")
+
+	if err := storage.DeleteUserURLs("user1", []string{gone, other}); err != nil {
+		t.Fatalf("Storage.DeleteUserURLs() error = %v", err)
+	}
+
+	deleted, err := storage.GetDeletedUserURLs("user1")
+	if err != nil {
+		t.Fatalf("Storage.GetDeletedUserURLs() error = %v", err)
+	}
+	if len(deleted) != 1 || deleted[0] != gone {
+		t.Errorf("Storage.GetDeletedUserURLs() = %v, want [%v] (kept %v)", deleted, gone, keep)
+	}
+
+	deleted, err = storage.GetDeletedUserURLs("user2")
+	if err != nil {
+		t.Fatalf("Storage.GetDeletedUserURLs() error = %v", err)
+	}
+	if len(deleted) != 0 {
+		t.Errorf("Storage.GetDeletedUserURLs() for user2 = %v, want none", deleted)
+	}
+}
+
+func TestStorage_IncrementVisit(t *testing.T) {
+	storage := NewStorage()
+	id, _ := storage.Save("https://example.com")
+
+	first := time.Now()
+	last := first.Add(time.Minute)
+
+	if err := storage.IncrementVisit(id, 2, last); err != nil {
+		t.Fatalf("Storage.IncrementVisit() error = %v", err)
+	}
+	if err := storage.IncrementVisit(id, 3, first); err != nil {
+		t.Fatalf("Storage.IncrementVisit() error = %v", err)
+	}
+
+	stats, err := storage.GetStats(id)
+	if err != nil {
+		t.Fatalf("Storage.GetStats() error = %v", err)
+	}
+	if stats.Visits != 5 {
+		t.Errorf("Storage.GetStats() visits = %d, want 5", stats.Visits)
+	}
+	if !stats.LastVisited.Equal(last) {
+		t.Errorf("Storage.GetStats() last visited = %v, want %v", stats.LastVisited, last)
+	}
+
+	if other, _ := storage.ForTenant("other").(*Storage).GetStats(id); other.Visits != 0 {
+		t.Errorf("other tenant visits = %d, want 0", other.Visits)
+	}
+}
+
+func TestStorage_Expiry(t *testing.T) {
+	storage := NewStorage()
+	live, _ := storage.Save("https://live.example.com")
+	stale, _ := storage.Save("https://stale.example.com")
+
+	if err := storage.SetExpiry(live, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Storage.SetExpiry() error = %v", err)
+	}
+	if err := storage.SetExpiry(stale, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("Storage.SetExpiry() error = %v", err)
+	}
+
+	if got, err := storage.GetWithDeletedStatus(live); err != nil || got != "https://live.example.com" {
+		t.Errorf("Storage.GetWithDeletedStatus() = %v, %v, want the URL before it expires", got, err)
+	}
+	if _, err := storage.GetWithDeletedStatus(stale); !errors.Is(err, urlstorage.ErrURLExpired) {
+		t.Errorf("Storage.GetWithDeletedStatus() error = %v, want %v", err, urlstorage.ErrURLExpired)
+	}
+	if _, found := storage.Get(stale); found {
+		t.Errorf("Storage.Get() found an expired URL")
+	}
+
+	purged, err := storage.PurgeExpired(time.Now())
+	if err != nil {
+		t.Fatalf("Storage.PurgeExpired() error = %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("Storage.PurgeExpired() = %d, want 1", purged)
+	}
+	if _, err := storage.GetWithDeletedStatus(stale); err != nil {
+		t.Errorf("Storage.GetWithDeletedStatus() after purge error = %v, want nil", err)
+	}
+	if _, found := storage.Get(live); !found {
+		t.Errorf("Storage.PurgeExpired() removed a URL that has not expired")
+	}
+	if id, err := storage.Save("https://stale.example.com"); err != nil || id == stale {
+		t.Errorf("Storage.Save() of a purged URL = %v, %v, want a new ID", id, err)
+	}
+}
+
+func TestStorage_GetBatch(t *testing.T) {
+	s := NewStorage()
+
+	kept, _ := s.SaveWithUser("https://a.example.com", "user1")
+	gone, _ := s.SaveWithUser("https://b.example.com", "user1")
+	expired, _ := s.SaveWithUser("https://c.example.com", "user1")
+	if err := s.DeleteUserURLs("user1", []string{gone}); err != nil {
+		t.Fatalf("Storage.DeleteUserURLs() error = %v", err)
+	}
+	if err := s.SetExpiry(expired, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("Storage.SetExpiry() error = %v", err)
+	}
+
+	got, err := s.GetBatch([]string{kept, "missing", gone, expired})
+	if err != nil {
+		t.Fatalf("Storage.GetBatch() error = %v", err)
+	}
+
+	want := map[string]model.ExpandedURL{
+		kept:    {ID: kept, OriginalURL: "https://a.example.com"},
+		gone:    {ID: gone, OriginalURL: "https://b.example.com", Deleted: true},
+		expired: {ID: expired, OriginalURL: "https://c.example.com", Expired: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Storage.GetBatch() = %v, want %v", got, want)
+	}
+}
+
+func TestStorage_Suite(t *testing.T) {
+	storagetest.RunSuite(t, func() urlstorage.URLStorage { return NewStorage() })
+}
+
+func TestStorage_DuplicateSuite(t *testing.T) {
+	storagetest.RunDuplicateSuite(t, func() urlstorage.URLStorage { return NewStorage(WithDuplicateURLs(true)) })
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
+
 	"github.com/MikhailRaia/url-shortener/internal/storage"
 
 	"github.com/MikhailRaia/url-shortener/internal/generator"
@@ -12,21 +14,99 @@ import (
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/rs/zerolog/log"
 )
 
+// ErrNoFreeID is returned when every generated short ID collided with a
+// stored one, which points at a broken generator or a nearly full ID space.
+var ErrNoFreeID = errors.New("no free short ID found")
+
+// defaultMaxIDAttempts is how many IDs a save tries unless WithMaxIDAttempts
+// says otherwise.
+const defaultMaxIDAttempts = 10
+
+// idCollisionWarnAfter is the number of collisions in a single save after
+// which each further one is logged as a warning.
+const idCollisionWarnAfter = 2
+
 // Storage implements URLStorage using PostgreSQL.
 type Storage struct {
-	pool *pgxpool.Pool
+	pool          *pgxpool.Pool
+	db            dbtx
+	tenantID      string
+	ids           generator.IDGenerator
+	maxIDAttempts int
+	duplicates    bool
+	ctx           context.Context
+}
+
+// options collects the settings Options change.
+type options struct {
+	pool          *pgxpool.Config
+	ids           generator.IDGenerator
+	maxIDAttempts int
+	duplicates    bool
+}
+
+// Option tunes the connection pool or short ID generation.
+type Option func(*options)
+
+// WithMaxConns caps the number of pool connections. A non-positive value
+// keeps the driver default.
+func WithMaxConns(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.pool.MaxConns = int32(n)
+		}
+	}
+}
+
+// WithMinConns sets the number of connections the pool keeps open.
+func WithMinConns(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.pool.MinConns = int32(n)
+		}
+	}
+}
+
+// WithConnMaxLifetime sets how long a connection lives before it is
+// recycled. A non-positive value keeps the driver default.
+func WithConnMaxLifetime(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.pool.MaxConnLifetime = d
+		}
+	}
+}
+
+// WithIDGenerator makes the storage generate short IDs with ids instead of
+// generator.Default.
+func WithIDGenerator(ids generator.IDGenerator) Option {
+	return func(o *options) {
+		o.ids = ids
+	}
+}
+
+// WithDuplicateURLs makes every save of a URL store it under a fresh short ID
+// instead of returning the ID it was first saved under. The unique index on
+// tenant_id, original_url is replaced with a plain one; turning the option
+// off again fails while duplicate URLs are stored.
+func WithDuplicateURLs(allow bool) Option {
+	return func(o *options) {
+		o.duplicates = allow
+	}
 }
 
 // NewStorage connects to PostgreSQL using DSN and prepares schema.
-func NewStorage(dsn string) (*Storage, error) {
-	if dsn == "" {
-		return nil, errors.New("database connection string is empty")
+func NewStorage(dsn string, opts ...Option) (*Storage, error) {
+	o, err := parseOptions(dsn, opts...)
+	if err != nil {
+		return nil, err
 	}
 
 	ctx := context.Background()
-	pool, err := pgxpool.Connect(ctx, dsn)
+	pool, err := pgxpool.ConnectConfig(ctx, o.pool)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -36,7 +116,11 @@ func NewStorage(dsn string) (*Storage, error) {
 	}
 
 	storage := &Storage{
-		pool: pool,
+		pool:          pool,
+		db:            pool,
+		ids:           o.ids,
+		maxIDAttempts: o.maxIDAttempts,
+		duplicates:    o.duplicates,
 	}
 
 	if err := storage.createTable(ctx); err != nil {
@@ -46,10 +130,104 @@ func NewStorage(dsn string) (*Storage, error) {
 	return storage, nil
 }
 
+// WithMaxIDAttempts bounds how many generated short IDs a save tries before
+// giving up with ErrNoFreeID. A non-positive value keeps the default of 10.
+func WithMaxIDAttempts(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.maxIDAttempts = n
+		}
+	}
+}
+
+// parseOptions parses dsn and applies opts on top of its pool settings and
+// the default ID generator.
+func parseOptions(dsn string, opts ...Option) (options, error) {
+	if dsn == "" {
+		return options{}, errors.New("database connection string is empty")
+	}
+
+	config, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return options{}, fmt.Errorf("failed to parse database connection string: %w", err)
+	}
+
+	config.ConnConfig.Logger = queryLogger{}
+
+	o := options{pool: config, ids: generator.Default(), maxIDAttempts: defaultMaxIDAttempts}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if config.MinConns > config.MaxConns {
+		return options{}, fmt.Errorf("pool min conns %d exceeds max conns %d", config.MinConns, config.MaxConns)
+	}
+
+	return o, nil
+}
+
+// ForTenant returns a view of the storage scoped to the given tenant.
+// The view shares the connection pool with the parent storage.
+func (s *Storage) ForTenant(tenantID string) storage.URLStorage {
+	view := *s
+	view.tenantID = tenantID
+	return &view
+}
+
+// WithContext returns a view of the storage whose queries carry the values
+// of ctx, such as the trace ID, to the query logger. Cancellation of ctx is
+// not propagated, so queries behave as they do without a bound context.
+func (s *Storage) WithContext(ctx context.Context) storage.URLStorage {
+	view := *s
+	view.ctx = context.WithoutCancel(ctx)
+	return &view
+}
+
+// WithTx runs fn with a view of the storage whose operations all run in one
+// database transaction, committed if fn returns nil and rolled back
+// otherwise. Operations that use transactions themselves run in savepoints.
+// The view must not be used concurrently or after fn returns.
+func (s *Storage) WithTx(ctx context.Context, fn func(storage.URLStorage) error) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	view := *s
+	view.db = tx
+	view.ctx = ctx
+	if err := fn(&view); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+// dbtx is satisfied by both *pgxpool.Pool and pgx.Tx, so a Storage can run
+// its queries on the pool or within a transaction.
+type dbtx interface {
+	rowQuerier
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
+// queryContext returns the context queries run in.
+func (s *Storage) queryContext() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
 func (s *Storage) createTable(ctx context.Context) error {
 	createTableQuery := `
 		CREATE TABLE IF NOT EXISTS urls (
-			id VARCHAR(12) PRIMARY KEY,
+			id VARCHAR(12) NOT NULL,
 			original_url TEXT NOT NULL,
 			user_id VARCHAR(32),
 			is_deleted BOOLEAN DEFAULT FALSE,
@@ -57,7 +235,7 @@ func (s *Storage) createTable(ctx context.Context) error {
 		);
 	`
 
-	if _, err := s.pool.Exec(ctx, createTableQuery); err != nil {
+	if _, err := s.db.Exec(ctx, createTableQuery); err != nil {
 		return fmt.Errorf("failed to create urls table: %w", err)
 	}
 
@@ -65,24 +243,123 @@ func (s *Storage) createTable(ctx context.Context) error {
 		ALTER TABLE urls ADD COLUMN IF NOT EXISTS is_deleted BOOLEAN DEFAULT FALSE;
 	`
 
-	if _, err := s.pool.Exec(ctx, alterTableQuery); err != nil {
+	if _, err := s.db.Exec(ctx, alterTableQuery); err != nil {
 		return fmt.Errorf("failed to alter urls table: %w", err)
 	}
 
+	alterIDLengthQuery := `
+		ALTER TABLE urls ALTER COLUMN id TYPE VARCHAR(64);
+	`
+
+	if _, err := s.db.Exec(ctx, alterIDLengthQuery); err != nil {
+		return fmt.Errorf("failed to widen id column: %w", err)
+	}
+
+	alterTenantQuery := `
+		ALTER TABLE urls ADD COLUMN IF NOT EXISTS tenant_id VARCHAR(255) NOT NULL DEFAULT '';
+	`
+
+	if _, err := s.db.Exec(ctx, alterTenantQuery); err != nil {
+		return fmt.Errorf("failed to add tenant_id column: %w", err)
+	}
+
+	alterPasswordQuery := `
+		ALTER TABLE urls ADD COLUMN IF NOT EXISTS password_hash TEXT;
+	`
+
+	if _, err := s.db.Exec(ctx, alterPasswordQuery); err != nil {
+		return fmt.Errorf("failed to add password_hash column: %w", err)
+	}
+
+	alterVisitsQuery := `
+		ALTER TABLE urls ADD COLUMN IF NOT EXISTS visits BIGINT NOT NULL DEFAULT 0;
+		ALTER TABLE urls ADD COLUMN IF NOT EXISTS last_visited TIMESTAMP WITH TIME ZONE;
+	`
+
+	if _, err := s.db.Exec(ctx, alterVisitsQuery); err != nil {
+		return fmt.Errorf("failed to add visit columns: %w", err)
+	}
+
+	alterExpiryQuery := `
+		ALTER TABLE urls ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP WITH TIME ZONE;
+		CREATE INDEX IF NOT EXISTS idx_urls_expires_at ON urls(expires_at) WHERE expires_at IS NOT NULL;
+	`
+
+	if _, err := s.db.Exec(ctx, alterExpiryQuery); err != nil {
+		return fmt.Errorf("failed to add expires_at column: %w", err)
+	}
+
+	alterForwardQueryQuery := `
+		ALTER TABLE urls ADD COLUMN IF NOT EXISTS forward_query BOOLEAN NOT NULL DEFAULT FALSE;
+	`
+
+	if _, err := s.db.Exec(ctx, alterForwardQueryQuery); err != nil {
+		return fmt.Errorf("failed to add forward_query column: %w", err)
+	}
+
+	alterCreatorQuery := `
+		ALTER TABLE urls ADD COLUMN IF NOT EXISTS creator_ip TEXT;
+		ALTER TABLE urls ADD COLUMN IF NOT EXISTS creator_user_agent TEXT;
+	`
+
+	if _, err := s.db.Exec(ctx, alterCreatorQuery); err != nil {
+		return fmt.Errorf("failed to add creator columns: %w", err)
+	}
+
+	// Short IDs and original URLs are unique per tenant rather than globally.
+	dropPrimaryKeyQuery := `
+		ALTER TABLE urls DROP CONSTRAINT IF EXISTS urls_pkey;
+	`
+
+	if _, err := s.db.Exec(ctx, dropPrimaryKeyQuery); err != nil {
+		return fmt.Errorf("failed to drop primary key on id: %w", err)
+	}
+
+	dropOriginalURLIndexQuery := `
+		DROP INDEX IF EXISTS idx_urls_original_url;
+	`
+
+	if _, err := s.db.Exec(ctx, dropOriginalURLIndexQuery); err != nil {
+		return fmt.Errorf("failed to drop unique index on original_url: %w", err)
+	}
+
 	createIndexQuery := `
 		CREATE INDEX IF NOT EXISTS idx_urls_id ON urls(id);
 	`
 
-	if _, err := s.pool.Exec(ctx, createIndexQuery); err != nil {
+	if _, err := s.db.Exec(ctx, createIndexQuery); err != nil {
 		return fmt.Errorf("failed to create index on id: %w", err)
 	}
 
+	createTenantIDIndexQuery := `
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_urls_tenant_id ON urls(tenant_id, id);
+	`
+
+	if _, err := s.db.Exec(ctx, createTenantIDIndexQuery); err != nil {
+		return fmt.Errorf("failed to create unique index on tenant_id, id: %w", err)
+	}
+
+	// With duplicate URLs allowed the original URL index only speeds up lookups.
+	if s.duplicates {
+		createOriginalURLIndexQuery := `
+			DROP INDEX IF EXISTS idx_urls_tenant_original_url;
+			CREATE INDEX IF NOT EXISTS idx_urls_tenant_original_url_lookup ON urls(tenant_id, original_url);
+		`
+
+		if _, err := s.db.Exec(ctx, createOriginalURLIndexQuery); err != nil {
+			return fmt.Errorf("failed to create index on tenant_id, original_url: %w", err)
+		}
+
+		return nil
+	}
+
 	createUniqueIndexQuery := `
-		CREATE UNIQUE INDEX IF NOT EXISTS idx_urls_original_url ON urls(original_url);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_urls_tenant_original_url ON urls(tenant_id, original_url);
+		DROP INDEX IF EXISTS idx_urls_tenant_original_url_lookup;
 	`
 
-	if _, err := s.pool.Exec(ctx, createUniqueIndexQuery); err != nil {
-		return fmt.Errorf("failed to create unique index on original_url: %w", err)
+	if _, err := s.db.Exec(ctx, createUniqueIndexQuery); err != nil {
+		return fmt.Errorf("failed to create unique index on tenant_id, original_url: %w", err)
 	}
 
 	return nil
@@ -90,43 +367,28 @@ func (s *Storage) createTable(ctx context.Context) error {
 
 // Save stores a new URL and returns its generated short ID.
 func (s *Storage) Save(originalURL string) (string, error) {
-	ctx := context.Background()
+	ctx := s.queryContext()
 
 	var existingID string
-	err := s.pool.QueryRow(ctx, "SELECT id FROM urls WHERE original_url = $1", originalURL).Scan(&existingID)
-	if err == nil {
-		return existingID, storage.ErrURLExists
-	} else if !errors.Is(err, pgx.ErrNoRows) {
-		return "", fmt.Errorf("error checking if URL exists: %w", err)
+	if !s.duplicates {
+		err := s.db.QueryRow(ctx, "SELECT id FROM urls WHERE tenant_id = $1 AND original_url = $2", s.tenantID, originalURL).Scan(&existingID)
+		if err == nil {
+			return existingID, storage.ErrURLExists
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			return "", fmt.Errorf("error checking if URL exists: %w", err)
+		}
 	}
 
-	id, err := generator.GenerateID(8)
+	id, err := s.freeID(ctx, s.db)
 	if err != nil {
-		return "", fmt.Errorf("error generating ID: %w", err)
+		return "", err
 	}
 
-	var exists bool
-	for {
-		err := s.pool.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM urls WHERE id = $1)", id).Scan(&exists)
-		if err != nil {
-			return "", fmt.Errorf("error checking if ID exists: %w", err)
-		}
-
-		if !exists {
-			break
-		}
-
-		id, err = generator.GenerateID(8)
-		if err != nil {
-			return "", fmt.Errorf("error generating new ID: %w", err)
-		}
-	}
-
-	_, err = s.pool.Exec(ctx, "INSERT INTO urls (id, original_url) VALUES ($1, $2)", id, originalURL)
+	_, err = s.db.Exec(ctx, "INSERT INTO urls (tenant_id, id, original_url) VALUES ($1, $2, $3)", s.tenantID, id, originalURL)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
-			if err := s.pool.QueryRow(ctx, "SELECT id FROM urls WHERE original_url = $1", originalURL).Scan(&existingID); err == nil {
+			if err := s.db.QueryRow(ctx, "SELECT id FROM urls WHERE tenant_id = $1 AND original_url = $2", s.tenantID, originalURL).Scan(&existingID); err == nil {
 				return existingID, storage.ErrURLExists
 			}
 		}
@@ -136,13 +398,49 @@ func (s *Storage) Save(originalURL string) (string, error) {
 	return id, nil
 }
 
+// rowQuerier is satisfied by both *pgxpool.Pool and pgx.Tx.
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// freeID generates short IDs until one is not yet taken in the tenant,
+// checking with q so that it can run inside a transaction. It gives up with
+// ErrNoFreeID after maxIDAttempts collisions.
+func (s *Storage) freeID(ctx context.Context, q rowQuerier) (string, error) {
+	maxAttempts := s.maxIDAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxIDAttempts
+	}
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		id, err := s.ids.Generate()
+		if err != nil {
+			return "", fmt.Errorf("error generating ID: %w", err)
+		}
+
+		var exists bool
+		if err := q.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM urls WHERE tenant_id = $1 AND id = $2)", s.tenantID, id).Scan(&exists); err != nil {
+			return "", fmt.Errorf("error checking if ID exists: %w", err)
+		}
+		if !exists {
+			return id, nil
+		}
+
+		if attempt >= idCollisionWarnAfter {
+			log.Warn().Str("tenant", s.tenantID).Int("attempt", attempt).Int("maxAttempts", maxAttempts).Msg("Generated short ID collided again")
+		}
+	}
+
+	return "", fmt.Errorf("%w after %d attempts", ErrNoFreeID, maxAttempts)
+}
+
 // Get retrieves the original URL for a given short ID.
 func (s *Storage) Get(id string) (string, bool) {
-	ctx := context.Background()
+	ctx := s.queryContext()
 
 	var originalURL string
-	var isDeleted bool
-	err := s.pool.QueryRow(ctx, "SELECT original_url, is_deleted FROM urls WHERE id = $1", id).Scan(&originalURL, &isDeleted)
+	var isDeleted, isExpired bool
+	err := s.db.QueryRow(ctx, "SELECT original_url, is_deleted, COALESCE(expires_at <= NOW(), FALSE) FROM urls WHERE tenant_id = $1 AND id = $2", s.tenantID, id).Scan(&originalURL, &isDeleted, &isExpired)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return "", false
@@ -151,20 +449,50 @@ func (s *Storage) Get(id string) (string, bool) {
 		return "", false
 	}
 
-	if isDeleted {
+	if isDeleted || isExpired {
 		return "", false
 	}
 
 	return originalURL, true
 }
 
+// GetBatch looks up the given short IDs in one query, leaving out unknown ones.
+func (s *Storage) GetBatch(ids []string) (map[string]model.ExpandedURL, error) {
+	result := make(map[string]model.ExpandedURL, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	ctx := s.queryContext()
+
+	rows, err := s.db.Query(ctx, "SELECT id, original_url, is_deleted, COALESCE(expires_at <= NOW(), FALSE) FROM urls WHERE tenant_id = $1 AND id = ANY($2)", s.tenantID, ids)
+	if err != nil {
+		return nil, fmt.Errorf("error querying database: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var url model.ExpandedURL
+		if err := rows.Scan(&url.ID, &url.OriginalURL, &url.Deleted, &url.Expired); err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		result[url.ID] = url
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return result, nil
+}
+
 // GetWithDeletedStatus retrieves the original URL and checks if it has been deleted.
 func (s *Storage) GetWithDeletedStatus(id string) (string, error) {
-	ctx := context.Background()
+	ctx := s.queryContext()
 
 	var originalURL string
-	var isDeleted bool
-	err := s.pool.QueryRow(ctx, "SELECT original_url, is_deleted FROM urls WHERE id = $1", id).Scan(&originalURL, &isDeleted)
+	var isDeleted, isExpired bool
+	err := s.db.QueryRow(ctx, "SELECT original_url, is_deleted, COALESCE(expires_at <= NOW(), FALSE) FROM urls WHERE tenant_id = $1 AND id = $2", s.tenantID, id).Scan(&originalURL, &isDeleted, &isExpired)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return "", nil
@@ -176,6 +504,10 @@ func (s *Storage) GetWithDeletedStatus(id string) (string, error) {
 		return "", storage.ErrURLDeleted
 	}
 
+	if isExpired {
+		return "", storage.ErrURLExpired
+	}
+
 	return originalURL, nil
 }
 
@@ -184,63 +516,48 @@ func (s *Storage) Ping(ctx context.Context) error {
 	return s.pool.Ping(ctx)
 }
 
-// SaveBatch stores multiple URLs and returns a map of correlation IDs to short IDs.
-func (s *Storage) SaveBatch(items []model.BatchRequestItem) (map[string]string, error) {
-	ctx := context.Background()
-	tx, err := s.pool.Begin(ctx)
+// SaveBatch stores multiple URLs and returns the short ID saved for each correlation ID.
+func (s *Storage) SaveBatch(items []model.BatchRequestItem) (map[string]model.BatchSaveResult, error) {
+	ctx := s.queryContext()
+	tx, err := s.db.Begin(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("error starting transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
-	result := make(map[string]string)
+	result := make(map[string]model.BatchSaveResult)
 
 	for _, item := range items {
 		var existingID string
-		err := tx.QueryRow(ctx, "SELECT id FROM urls WHERE original_url = $1 FOR UPDATE", item.OriginalURL).Scan(&existingID)
-		if err == nil {
-			result[item.CorrelationID] = existingID
-			continue
-		} else if !errors.Is(err, pgx.ErrNoRows) {
-			return nil, fmt.Errorf("error checking if URL exists: %w", err)
+		if !s.duplicates {
+			err := tx.QueryRow(ctx, "SELECT id FROM urls WHERE tenant_id = $1 AND original_url = $2 FOR UPDATE", s.tenantID, item.OriginalURL).Scan(&existingID)
+			if err == nil {
+				result[item.CorrelationID] = model.BatchSaveResult{ID: existingID}
+				continue
+			} else if !errors.Is(err, pgx.ErrNoRows) {
+				return nil, fmt.Errorf("error checking if URL exists: %w", err)
+			}
 		}
 
-		id, err := generator.GenerateID(8)
+		id, err := s.freeID(ctx, tx)
 		if err != nil {
-			return nil, fmt.Errorf("error generating ID: %w", err)
+			return nil, err
 		}
 
-		var exists bool
-		for {
-			err := tx.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM urls WHERE id = $1)", id).Scan(&exists)
-			if err != nil {
-				return nil, fmt.Errorf("error checking if ID exists: %w", err)
-			}
-
-			if !exists {
-				break
-			}
-
-			id, err = generator.GenerateID(8)
-			if err != nil {
-				return nil, fmt.Errorf("error generating new ID: %w", err)
-			}
-		}
-
-		_, err = tx.Exec(ctx, "INSERT INTO urls (id, original_url) VALUES ($1, $2)",
-			id, item.OriginalURL)
+		_, err = tx.Exec(ctx, "INSERT INTO urls (tenant_id, id, original_url) VALUES ($1, $2, $3)",
+			s.tenantID, id, item.OriginalURL)
 		if err != nil {
 			var pgErr *pgconn.PgError
 			if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
-				if err := tx.QueryRow(ctx, "SELECT id FROM urls WHERE original_url = $1", item.OriginalURL).Scan(&existingID); err == nil {
-					result[item.CorrelationID] = existingID
+				if err := tx.QueryRow(ctx, "SELECT id FROM urls WHERE tenant_id = $1 AND original_url = $2", s.tenantID, item.OriginalURL).Scan(&existingID); err == nil {
+					result[item.CorrelationID] = model.BatchSaveResult{ID: existingID}
 					continue
 				}
 			}
 			return nil, fmt.Errorf("error inserting URL into database: %w", err)
 		}
 
-		result[item.CorrelationID] = id
+		result[item.CorrelationID] = model.BatchSaveResult{ID: id, Created: true}
 	}
 
 	if err := tx.Commit(ctx); err != nil {
@@ -259,109 +576,219 @@ func (s *Storage) Close() {
 
 // SaveWithUser stores a new URL associated with a user and returns its generated short ID.
 func (s *Storage) SaveWithUser(originalURL, userID string) (string, error) {
-	ctx := context.Background()
+	ctx := s.queryContext()
 
 	var existingID string
-	err := s.pool.QueryRow(ctx, "SELECT id FROM urls WHERE original_url = $1", originalURL).Scan(&existingID)
-	if err == nil {
-		return existingID, storage.ErrURLExists
-	} else if !errors.Is(err, pgx.ErrNoRows) {
-		return "", fmt.Errorf("error checking if URL exists: %w", err)
+	if !s.duplicates {
+		err := s.db.QueryRow(ctx, "SELECT id FROM urls WHERE tenant_id = $1 AND original_url = $2", s.tenantID, originalURL).Scan(&existingID)
+		if err == nil {
+			return existingID, storage.ErrURLExists
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			return "", fmt.Errorf("error checking if URL exists: %w", err)
+		}
 	}
 
-	id, err := generator.GenerateID(8)
+	id, err := s.freeID(ctx, s.db)
 	if err != nil {
-		return "", fmt.Errorf("error generating ID: %w", err)
+		return "", err
 	}
 
-	var exists bool
-	for {
-		err := s.pool.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM urls WHERE id = $1)", id).Scan(&exists)
-		if err != nil {
-			return "", fmt.Errorf("error checking if ID exists: %w", err)
+	_, err = s.db.Exec(ctx, "INSERT INTO urls (tenant_id, id, original_url, user_id) VALUES ($1, $2, $3, $4)", s.tenantID, id, originalURL, userID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			if err := s.db.QueryRow(ctx, "SELECT id FROM urls WHERE tenant_id = $1 AND original_url = $2", s.tenantID, originalURL).Scan(&existingID); err == nil {
+				return existingID, storage.ErrURLExists
+			}
 		}
+		return "", fmt.Errorf("error inserting URL into database: %w", err)
+	}
 
-		if !exists {
-			break
-		}
+	return id, nil
+}
 
-		id, err = generator.GenerateID(8)
-		if err != nil {
-			return "", fmt.Errorf("error generating new ID: %w", err)
+// SaveWithAlias stores a URL under the given alias, optionally associated with a user.
+// Saving the same URL under the same alias again is a no-op.
+func (s *Storage) SaveWithAlias(alias, originalURL, userID string) error {
+	ctx := s.queryContext()
+
+	var existingURL string
+	err := s.db.QueryRow(ctx, "SELECT original_url FROM urls WHERE tenant_id = $1 AND id = $2 AND is_deleted = FALSE", s.tenantID, alias).Scan(&existingURL)
+	if err == nil {
+		if existingURL == originalURL {
+			return nil
 		}
+		return storage.ErrAliasTaken
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("error checking if alias exists: %w", err)
+	}
+
+	var user interface{}
+	if userID != "" {
+		user = userID
 	}
 
-	_, err = s.pool.Exec(ctx, "INSERT INTO urls (id, original_url, user_id) VALUES ($1, $2, $3)", id, originalURL, userID)
+	_, err = s.db.Exec(ctx, "INSERT INTO urls (tenant_id, id, original_url, user_id) VALUES ($1, $2, $3, $4)", s.tenantID, alias, originalURL, user)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
-			if err := s.pool.QueryRow(ctx, "SELECT id FROM urls WHERE original_url = $1", originalURL).Scan(&existingID); err == nil {
-				return existingID, storage.ErrURLExists
+			if pgErr.ConstraintName == "idx_urls_tenant_original_url" {
+				return storage.ErrURLExists
 			}
+			return storage.ErrAliasTaken
 		}
-		return "", fmt.Errorf("error inserting URL into database: %w", err)
+		return fmt.Errorf("error inserting URL into database: %w", err)
 	}
 
-	return id, nil
+	return nil
 }
 
-// SaveBatchWithUser stores multiple URLs associated with a user and returns a map of correlation IDs to short IDs.
-func (s *Storage) SaveBatchWithUser(items []model.BatchRequestItem, userID string) (map[string]string, error) {
-	ctx := context.Background()
-	tx, err := s.pool.Begin(ctx)
+// ImportURLs stores records under their own short IDs, together with their
+// deletion state, password hash and expiry, in one transaction, skipping
+// records that conflict with stored URLs. The records' tenants are ignored in
+// favor of the storage's.
+func (s *Storage) ImportURLs(records []model.URLRecord) error {
+	ctx := s.queryContext()
+	tx, err := s.db.Begin(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("error starting transaction: %w", err)
+		return fmt.Errorf("error starting transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
-	result := make(map[string]string)
+	var conflicts []string
+	for _, record := range records {
+		var user interface{}
+		if record.UserID != "" {
+			user = record.UserID
+		}
 
-	for _, item := range items {
-		var existingID string
-		err := tx.QueryRow(ctx, "SELECT id FROM urls WHERE original_url = $1 FOR UPDATE", item.OriginalURL).Scan(&existingID)
-		if err == nil {
-			result[item.CorrelationID] = existingID
-			continue
-		} else if !errors.Is(err, pgx.ErrNoRows) {
-			return nil, fmt.Errorf("error checking if URL exists: %w", err)
+		var passwordHash interface{}
+		if record.PasswordHash != "" {
+			passwordHash = record.PasswordHash
 		}
+		var expiresAt interface{}
+		if record.ExpiresAt != nil && !record.ExpiresAt.IsZero() {
+			expiresAt = *record.ExpiresAt
+		}
+
+		forwardQuery := record.ForwardQuery != nil && *record.ForwardQuery
 
-		id, err := generator.GenerateID(8)
+		var creatorIP, creatorUserAgent interface{}
+		if record.Creator != nil {
+			creatorIP, creatorUserAgent = nullString(record.Creator.IP), nullString(record.Creator.UserAgent)
+		}
+
+		tag, err := tx.Exec(ctx, "INSERT INTO urls (tenant_id, id, original_url, user_id, is_deleted, password_hash, expires_at, forward_query, creator_ip, creator_user_agent) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) ON CONFLICT DO NOTHING",
+			s.tenantID, record.ShortURL, record.OriginalURL, user, record.IsDeleted, passwordHash, expiresAt, forwardQuery, creatorIP, creatorUserAgent)
 		if err != nil {
-			return nil, fmt.Errorf("error generating ID: %w", err)
+			return fmt.Errorf("error importing URL: %w", err)
+		}
+		if tag.RowsAffected() == 1 {
+			continue
 		}
 
-		var exists bool
-		for {
-			err := tx.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM urls WHERE id = $1)", id).Scan(&exists)
-			if err != nil {
-				return nil, fmt.Errorf("error checking if ID exists: %w", err)
-			}
+		var existingURL string
+		err = tx.QueryRow(ctx, "SELECT original_url FROM urls WHERE tenant_id = $1 AND id = $2", s.tenantID, record.ShortURL).Scan(&existingURL)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("error checking imported URL: %w", err)
+		}
+		if existingURL != record.OriginalURL {
+			conflicts = append(conflicts, record.ShortURL)
+		}
+	}
 
-			if !exists {
-				break
-			}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	if len(conflicts) > 0 {
+		return &storage.ImportConflictError{ShortIDs: conflicts}
+	}
+
+	return nil
+}
+
+// ExportURLs calls fn with each of the tenant's URLs in creation order. Rows
+// are streamed from the server as fn consumes them rather than loaded at once.
+func (s *Storage) ExportURLs(fn func(model.URLRecord) error) error {
+	ctx := s.queryContext()
+
+	rows, err := s.db.Query(ctx, "SELECT id, original_url, COALESCE(user_id, ''), is_deleted, COALESCE(password_hash, ''), expires_at, forward_query, COALESCE(creator_ip, ''), COALESCE(creator_user_agent, '') FROM urls WHERE tenant_id = $1 ORDER BY created_at, id", s.tenantID)
+	if err != nil {
+		return fmt.Errorf("error querying URLs for export: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		record := model.URLRecord{TenantID: s.tenantID}
+		var isDeleted *bool
+		var forwardQuery bool
+		var creator model.Creator
+		if err := rows.Scan(&record.ShortURL, &record.OriginalURL, &record.UserID, &isDeleted, &record.PasswordHash, &record.ExpiresAt, &forwardQuery, &creator.IP, &creator.UserAgent); err != nil {
+			return fmt.Errorf("error scanning row: %w", err)
+		}
+		record.IsDeleted = isDeleted != nil && *isDeleted
+		if forwardQuery {
+			record.ForwardQuery = &forwardQuery
+		}
+		if !creator.IsZero() {
+			record.Creator = &creator
+		}
+
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
 
-			id, err = generator.GenerateID(8)
-			if err != nil {
-				return nil, fmt.Errorf("error generating new ID: %w", err)
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return nil
+}
+
+// SaveBatchWithUser stores multiple URLs associated with a user and returns the short ID saved for each correlation ID.
+func (s *Storage) SaveBatchWithUser(items []model.BatchRequestItem, userID string) (map[string]model.BatchSaveResult, error) {
+	ctx := s.queryContext()
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	result := make(map[string]model.BatchSaveResult)
+
+	for _, item := range items {
+		var existingID string
+		if !s.duplicates {
+			err := tx.QueryRow(ctx, "SELECT id FROM urls WHERE tenant_id = $1 AND original_url = $2 FOR UPDATE", s.tenantID, item.OriginalURL).Scan(&existingID)
+			if err == nil {
+				result[item.CorrelationID] = model.BatchSaveResult{ID: existingID}
+				continue
+			} else if !errors.Is(err, pgx.ErrNoRows) {
+				return nil, fmt.Errorf("error checking if URL exists: %w", err)
 			}
 		}
 
-		_, err = tx.Exec(ctx, "INSERT INTO urls (id, original_url, user_id) VALUES ($1, $2, $3)",
-			id, item.OriginalURL, userID)
+		id, err := s.freeID(ctx, tx)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Exec(ctx, "INSERT INTO urls (tenant_id, id, original_url, user_id) VALUES ($1, $2, $3, $4)",
+			s.tenantID, id, item.OriginalURL, userID)
 		if err != nil {
 			var pgErr *pgconn.PgError
 			if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
-				if err := tx.QueryRow(ctx, "SELECT id FROM urls WHERE original_url = $1", item.OriginalURL).Scan(&existingID); err == nil {
-					result[item.CorrelationID] = existingID
+				if err := tx.QueryRow(ctx, "SELECT id FROM urls WHERE tenant_id = $1 AND original_url = $2", s.tenantID, item.OriginalURL).Scan(&existingID); err == nil {
+					result[item.CorrelationID] = model.BatchSaveResult{ID: existingID}
 					continue
 				}
 			}
 			return nil, fmt.Errorf("error inserting URL into database: %w", err)
 		}
 
-		result[item.CorrelationID] = id
+		result[item.CorrelationID] = model.BatchSaveResult{ID: id, Created: true}
 	}
 
 	if err := tx.Commit(ctx); err != nil {
@@ -373,9 +800,9 @@ func (s *Storage) SaveBatchWithUser(items []model.BatchRequestItem, userID strin
 
 // GetUserURLs retrieves all non-deleted URLs associated with a user.
 func (s *Storage) GetUserURLs(userID string) ([]model.UserURL, error) {
-	ctx := context.Background()
+	ctx := s.queryContext()
 
-	rows, err := s.pool.Query(ctx, "SELECT id, original_url FROM urls WHERE user_id = $1 AND is_deleted = FALSE", userID)
+	rows, err := s.db.Query(ctx, "SELECT id, original_url, created_at FROM urls WHERE tenant_id = $1 AND user_id = $2 AND is_deleted = FALSE ORDER BY created_at, id", s.tenantID, userID)
 	if err != nil {
 		return nil, fmt.Errorf("error querying user URLs: %w", err)
 	}
@@ -384,14 +811,19 @@ func (s *Storage) GetUserURLs(userID string) ([]model.UserURL, error) {
 	var result []model.UserURL
 	for rows.Next() {
 		var id, originalURL string
-		if err := rows.Scan(&id, &originalURL); err != nil {
+		var createdAt *time.Time
+		if err := rows.Scan(&id, &originalURL, &createdAt); err != nil {
 			return nil, fmt.Errorf("error scanning row: %w", err)
 		}
 
-		result = append(result, model.UserURL{
+		url := model.UserURL{
 			ShortURL:    id,
 			OriginalURL: originalURL,
-		})
+		}
+		if createdAt != nil {
+			url.CreatedAt = createdAt.UTC()
+		}
+		result = append(result, url)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -401,20 +833,343 @@ func (s *Storage) GetUserURLs(userID string) ([]model.UserURL, error) {
 	return result, nil
 }
 
+// GetUserURLsPaged retrieves a window of a user's non-deleted URLs in creation order
+// along with the total number of such URLs.
+func (s *Storage) GetUserURLsPaged(userID string, limit, offset int) ([]model.UserURL, int, error) {
+	ctx := s.queryContext()
+
+	total, err := s.CountUserURLs(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if total == 0 || offset >= total {
+		return []model.UserURL{}, total, nil
+	}
+
+	query := "SELECT id, original_url, created_at FROM urls WHERE tenant_id = $1 AND user_id = $2 AND is_deleted = FALSE ORDER BY created_at, id LIMIT $3 OFFSET $4"
+	var pageLimit interface{}
+	if limit > 0 {
+		pageLimit = limit
+	}
+
+	rows, err := s.db.Query(ctx, query, s.tenantID, userID, pageLimit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error querying user URLs page: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]model.UserURL, 0)
+	for rows.Next() {
+		var id, originalURL string
+		var createdAt *time.Time
+		if err := rows.Scan(&id, &originalURL, &createdAt); err != nil {
+			return nil, 0, fmt.Errorf("error scanning row: %w", err)
+		}
+
+		url := model.UserURL{
+			ShortURL:    id,
+			OriginalURL: originalURL,
+		}
+		if createdAt != nil {
+			url.CreatedAt = createdAt.UTC()
+		}
+		result = append(result, url)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return result, total, nil
+}
+
+// CountUserURLs returns the number of a user's non-deleted URLs.
+func (s *Storage) CountUserURLs(userID string) (int, error) {
+	var total int
+	if err := s.db.QueryRow(s.queryContext(), "SELECT COUNT(*) FROM urls WHERE tenant_id = $1 AND user_id = $2 AND is_deleted = FALSE", s.tenantID, userID).Scan(&total); err != nil {
+		return 0, fmt.Errorf("error counting user URLs: %w", err)
+	}
+
+	return total, nil
+}
+
 // DeleteUserURLs marks specified URLs as deleted for a user.
 func (s *Storage) DeleteUserURLs(userID string, urlIDs []string) error {
 	if len(urlIDs) == 0 {
 		return nil
 	}
 
-	ctx := context.Background()
+	ctx := s.queryContext()
 
-	query := `UPDATE urls SET is_deleted = TRUE WHERE user_id = $1 AND id = ANY($2) AND is_deleted = FALSE`
+	query := `UPDATE urls SET is_deleted = TRUE WHERE tenant_id = $1 AND user_id = $2 AND id = ANY($3) AND is_deleted = FALSE`
 
-	_, err := s.pool.Exec(ctx, query, userID, urlIDs)
+	_, err := s.db.Exec(ctx, query, s.tenantID, userID, urlIDs)
 	if err != nil {
 		return fmt.Errorf("error deleting URLs: %w", err)
 	}
 
 	return nil
 }
+
+// OwnsURL reports whether the user owns the short URL, deleted or not.
+// It returns storage.ErrURLNotFound for unknown short IDs.
+func (s *Storage) OwnsURL(userID, id string) (bool, error) {
+	ctx := s.queryContext()
+
+	var owner string
+	err := s.db.QueryRow(ctx, "SELECT COALESCE(user_id, '') FROM urls WHERE tenant_id = $1 AND id = $2", s.tenantID, id).Scan(&owner)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, storage.ErrURLNotFound
+	}
+	if err != nil {
+		return false, fmt.Errorf("error getting URL owner: %w", err)
+	}
+
+	return userID != "" && owner == userID, nil
+}
+
+// FindID returns the short ID originalURL is stored under, or
+// storage.ErrURLNotFound if it is not stored or duplicate URLs are allowed.
+func (s *Storage) FindID(originalURL string) (string, error) {
+	if s.duplicates {
+		return "", storage.ErrURLNotFound
+	}
+
+	var id string
+	err := s.db.QueryRow(s.queryContext(), "SELECT id FROM urls WHERE tenant_id = $1 AND original_url = $2", s.tenantID, originalURL).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", storage.ErrURLNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("error finding URL: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetDeletedUserURLs returns the short IDs of a user's URLs that are marked deleted.
+func (s *Storage) GetDeletedUserURLs(userID string) ([]string, error) {
+	ctx := s.queryContext()
+
+	rows, err := s.db.Query(ctx, "SELECT id FROM urls WHERE tenant_id = $1 AND user_id = $2 AND is_deleted = TRUE ORDER BY created_at, id", s.tenantID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying deleted user URLs: %w", err)
+	}
+	defer rows.Close()
+
+	result := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		result = append(result, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return result, nil
+}
+
+// Count returns the number of stored, non-deleted URLs across all tenants.
+func (s *Storage) Count() (int, error) {
+	ctx := s.queryContext()
+
+	var count int
+	if err := s.db.QueryRow(ctx, "SELECT COUNT(*) FROM urls WHERE is_deleted = FALSE").Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting URLs: %w", err)
+	}
+
+	return count, nil
+}
+
+// SetPasswordHash gates the short URL behind the given password hash.
+func (s *Storage) SetPasswordHash(id, hash string) error {
+	ctx := s.queryContext()
+
+	tag, err := s.db.Exec(ctx, "UPDATE urls SET password_hash = $1 WHERE tenant_id = $2 AND id = $3", hash, s.tenantID, id)
+	if err != nil {
+		return fmt.Errorf("error setting password hash: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("short URL %s not found", id)
+	}
+
+	return nil
+}
+
+// PasswordHash returns the password hash gating the short URL, or "" if it is not protected.
+func (s *Storage) PasswordHash(id string) (string, error) {
+	ctx := s.queryContext()
+
+	var hash string
+	err := s.db.QueryRow(ctx, "SELECT COALESCE(password_hash, '') FROM urls WHERE tenant_id = $1 AND id = $2", s.tenantID, id).Scan(&hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error getting password hash: %w", err)
+	}
+
+	return hash, nil
+}
+
+// SetForwardQuery sets whether redirects of the short URL forward their query string.
+func (s *Storage) SetForwardQuery(id string, enabled bool) error {
+	ctx := s.queryContext()
+
+	tag, err := s.db.Exec(ctx, "UPDATE urls SET forward_query = $1 WHERE tenant_id = $2 AND id = $3", enabled, s.tenantID, id)
+	if err != nil {
+		return fmt.Errorf("error setting query forwarding: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("short URL %s not found", id)
+	}
+
+	return nil
+}
+
+// ForwardQuery reports whether redirects of the short URL forward their query string.
+func (s *Storage) ForwardQuery(id string) (bool, error) {
+	ctx := s.queryContext()
+
+	var enabled bool
+	err := s.db.QueryRow(ctx, "SELECT forward_query FROM urls WHERE tenant_id = $1 AND id = $2", s.tenantID, id).Scan(&enabled)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error getting query forwarding: %w", err)
+	}
+
+	return enabled, nil
+}
+
+// SetCreator records the client that created the short URL.
+func (s *Storage) SetCreator(id string, creator model.Creator) error {
+	ctx := s.queryContext()
+
+	tag, err := s.db.Exec(ctx, "UPDATE urls SET creator_ip = $1, creator_user_agent = $2 WHERE tenant_id = $3 AND id = $4",
+		nullString(creator.IP), nullString(creator.UserAgent), s.tenantID, id)
+	if err != nil {
+		return fmt.Errorf("error setting creator: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("short URL %s not found", id)
+	}
+
+	return nil
+}
+
+// Creator returns the client that created the short URL, if it was recorded.
+func (s *Storage) Creator(id string) (model.Creator, error) {
+	ctx := s.queryContext()
+
+	var creator model.Creator
+	err := s.db.QueryRow(ctx, "SELECT COALESCE(creator_ip, ''), COALESCE(creator_user_agent, '') FROM urls WHERE tenant_id = $1 AND id = $2", s.tenantID, id).Scan(&creator.IP, &creator.UserAgent)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Creator{}, nil
+	}
+	if err != nil {
+		return model.Creator{}, fmt.Errorf("error getting creator: %w", err)
+	}
+
+	return creator, nil
+}
+
+// nullString maps an empty string to SQL NULL.
+func nullString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// IncrementVisit adds n visits to the short URL, the latest at the given time.
+func (s *Storage) IncrementVisit(id string, n int64, at time.Time) error {
+	ctx := s.queryContext()
+
+	_, err := s.db.Exec(ctx, "UPDATE urls SET visits = visits + $1, last_visited = GREATEST(COALESCE(last_visited, $2), $2) WHERE tenant_id = $3 AND id = $4", n, at, s.tenantID, id)
+	if err != nil {
+		return fmt.Errorf("error incrementing visits: %w", err)
+	}
+
+	return nil
+}
+
+// GetStats returns the visit statistics of the short URL.
+func (s *Storage) GetStats(id string) (model.URLStats, error) {
+	ctx := s.queryContext()
+
+	var stats model.URLStats
+	var lastVisited *time.Time
+	err := s.db.QueryRow(ctx, "SELECT visits, last_visited FROM urls WHERE tenant_id = $1 AND id = $2", s.tenantID, id).Scan(&stats.Visits, &lastVisited)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.URLStats{}, nil
+	}
+	if err != nil {
+		return model.URLStats{}, fmt.Errorf("error getting URL stats: %w", err)
+	}
+
+	if lastVisited != nil {
+		stats.LastVisited = *lastVisited
+	}
+
+	return stats, nil
+}
+
+// SetExpiry makes the short URL expire at the given time. A zero time removes the expiry.
+func (s *Storage) SetExpiry(id string, expiresAt time.Time) error {
+	ctx := s.queryContext()
+
+	var expiry *time.Time
+	if !expiresAt.IsZero() {
+		expiry = &expiresAt
+	}
+
+	tag, err := s.db.Exec(ctx, "UPDATE urls SET expires_at = $1 WHERE tenant_id = $2 AND id = $3", expiry, s.tenantID, id)
+	if err != nil {
+		return fmt.Errorf("error setting expiry: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("short URL %s not found", id)
+	}
+
+	return nil
+}
+
+// Expiry returns when the short URL expires, or the zero time if it never does.
+func (s *Storage) Expiry(id string) (time.Time, error) {
+	ctx := s.queryContext()
+
+	var expiresAt *time.Time
+	err := s.db.QueryRow(ctx, "SELECT expires_at FROM urls WHERE tenant_id = $1 AND id = $2", s.tenantID, id).Scan(&expiresAt)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && expiresAt == nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error getting expiry: %w", err)
+	}
+
+	return *expiresAt, nil
+}
+
+// PurgeExpired deletes URLs of all tenants that expired before the given time
+// and returns how many were deleted.
+func (s *Storage) PurgeExpired(before time.Time) (int, error) {
+	ctx := s.queryContext()
+
+	tag, err := s.db.Exec(ctx, "DELETE FROM urls WHERE expires_at < $1", before)
+	if err != nil {
+		return 0, fmt.Errorf("error purging expired URLs: %w", err)
+	}
+
+	return int(tag.RowsAffected()), nil
+}
@@ -0,0 +1,189 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/MikhailRaia/url-shortener/internal/generator"
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/storage"
+	"github.com/MikhailRaia/url-shortener/internal/storage/storagetest"
+)
+
+// TestStorage_Suite runs the shared storage suite against the database named by
+// TEST_DATABASE_DSN. Run it with: go test -tags integration ./internal/storage/postgres
+func TestStorage_Suite(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	s, err := NewStorage(dsn)
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	t.Cleanup(s.Close)
+
+	// Every subtest gets its own tenant so runs never see each other's rows.
+	run := strconv.FormatInt(time.Now().UnixNano(), 36)
+	n := 0
+	storagetest.RunSuite(t, func() storage.URLStorage {
+		n++
+		return s.ForTenant("suite-" + run + "-" + strconv.Itoa(n))
+	})
+}
+
+// TestStorage_DuplicateSuite runs the duplicate URL suite with the unique
+// index on original URLs dropped, restoring it once the suite's rows are gone.
+func TestStorage_DuplicateSuite(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	s, err := NewStorage(dsn, WithDuplicateURLs(true))
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+
+	run := "dups-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	t.Cleanup(func() {
+		defer s.Close()
+		if _, err := s.pool.Exec(context.Background(), "DELETE FROM urls WHERE tenant_id LIKE $1", run+"-%"); err != nil {
+			t.Errorf("failed to delete duplicate URLs: %v", err)
+			return
+		}
+		restored, err := NewStorage(dsn)
+		if err != nil {
+			t.Errorf("NewStorage() without duplicate URLs error = %v", err)
+			return
+		}
+		restored.Close()
+	})
+
+	n := 0
+	storagetest.RunDuplicateSuite(t, func() storage.URLStorage {
+		n++
+		return s.ForTenant(run + "-" + strconv.Itoa(n))
+	})
+}
+
+// TestStorage_CreatorSurvivesReconnect checks that creator metadata is read
+// back from the database rather than from process memory.
+func TestStorage_CreatorSurvivesReconnect(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	tenant := "creator-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	want := model.Creator{IP: "203.0.113.7", UserAgent: "curl/8.5.0"}
+
+	s, err := NewStorage(dsn)
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	tenantStorage := s.ForTenant(tenant).(*Storage)
+	id, err := tenantStorage.Save("https://example.com/creator")
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := tenantStorage.SetCreator(id, want); err != nil {
+		t.Fatalf("SetCreator() error = %v", err)
+	}
+	s.Close()
+
+	reopened, err := NewStorage(dsn)
+	if err != nil {
+		t.Fatalf("NewStorage() reopen error = %v", err)
+	}
+	t.Cleanup(reopened.Close)
+
+	if got, err := reopened.ForTenant(tenant).(*Storage).Creator(id); err != nil || got != want {
+		t.Errorf("Creator(%q) = %+v, %v, want %+v", id, got, err, want)
+	}
+}
+
+// TestStorage_IDCollision forces the first generated ID to collide with a
+// stored one and checks that the save retries with the next ID.
+func TestStorage_IDCollision(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	s, err := NewStorage(dsn, WithIDGenerator(generator.NewSequence("collide1", "collide1", "collide2")))
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	t.Cleanup(s.Close)
+
+	tenant := s.ForTenant("collision-" + strconv.FormatInt(time.Now().UnixNano(), 36))
+	first, err := tenant.Save("https://example.com/first")
+	if err != nil || first != "collide1" {
+		t.Fatalf("Save() = %q, %v, want collide1", first, err)
+	}
+
+	second, err := tenant.SaveWithUser("https://example.com/second", "user1")
+	if err != nil || second != "collide2" {
+		t.Fatalf("SaveWithUser() after a collision = %q, %v, want collide2", second, err)
+	}
+
+	if got, _ := tenant.Get(first); got != "https://example.com/first" {
+		t.Errorf("Get(%q) = %q, want the first URL to be kept", first, got)
+	}
+}
+
+// TestStorage_WithTxRollsBack checks that nothing written within WithTx is
+// kept when fn fails, including writes made in nested transactions.
+func TestStorage_WithTxRollsBack(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	s, err := NewStorage(dsn)
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	t.Cleanup(s.Close)
+
+	tenant := s.ForTenant("tx-" + strconv.FormatInt(time.Now().UnixNano(), 36)).(*Storage)
+	errFailed := errors.New("failed after writing")
+
+	var id string
+	err = tenant.WithTx(context.Background(), func(tx storage.URLStorage) error {
+		var err error
+		if id, err = tx.SaveWithUser("https://example.com/tx/single", "user1"); err != nil {
+			return err
+		}
+		if err := tx.(storage.PasswordProtector).SetPasswordHash(id, "hash"); err != nil {
+			return err
+		}
+		if _, err := tx.SaveBatch([]model.BatchRequestItem{
+			{CorrelationID: "1", OriginalURL: "https://example.com/tx/batch"},
+		}); err != nil {
+			return err
+		}
+		return errFailed
+	})
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("WithTx() error = %v, want %v", err, errFailed)
+	}
+
+	if got, ok := tenant.Get(id); ok {
+		t.Errorf("Get(%q) = %q after rollback, want nothing", id, got)
+	}
+	if _, err := tenant.Save("https://example.com/tx/batch"); err != nil {
+		t.Errorf("Save() of the batch URL after rollback error = %v, want it stored anew", err)
+	}
+	if urls, err := tenant.GetUserURLs("user1"); err != nil || len(urls) != 0 {
+		t.Errorf("GetUserURLs() after rollback = %v, %v, want none", urls, err)
+	}
+}
@@ -0,0 +1,50 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/MikhailRaia/url-shortener/internal/trace"
+	"github.com/jackc/pgx/v4"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// queryLogger logs pgx events through zerolog, tagged with the trace ID of
+// the query context so database work can be matched to the request behind
+// it. Per-query events are logged at debug level; query arguments are left
+// out because they hold user data.
+type queryLogger struct{}
+
+// Log implements pgx.Logger.
+func (queryLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	event := log.WithLevel(zerologLevel(level))
+	if !event.Enabled() {
+		return
+	}
+
+	if traceID := trace.IDFromContext(ctx); traceID != "" {
+		event = event.Str("trace_id", traceID)
+	}
+	for key, value := range data {
+		if key == "args" {
+			continue
+		}
+		event = event.Interface(key, value)
+	}
+	event.Msg("pgx: " + msg)
+}
+
+// zerologLevel maps a pgx log level to zerolog, demoting pgx's per-query
+// info events to debug.
+func zerologLevel(level pgx.LogLevel) zerolog.Level {
+	switch level {
+	case pgx.LogLevelError:
+		return zerolog.ErrorLevel
+	case pgx.LogLevelWarn:
+		return zerolog.WarnLevel
+	case pgx.LogLevelInfo, pgx.LogLevelDebug:
+		return zerolog.DebugLevel
+	default:
+		return zerolog.TraceLevel
+	}
+}
@@ -0,0 +1,774 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MikhailRaia/url-shortener/internal/generator"
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/storage"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// DSN prefixes that select Redis storage, e.g. redis://localhost:6379/0.
+const (
+	DSNPrefix    = "redis://"
+	TLSDSNPrefix = "rediss://"
+)
+
+// maxIDAttempts bounds retries when a generated short ID collides with an existing one.
+const maxIDAttempts = 10
+
+// countKey holds the number of non-deleted URLs across all tenants.
+const countKey = "urls:count"
+
+// expiriesKey is a sorted set of expiring URLs across all tenants, scored by
+// expiry time in Unix nanoseconds. Members are tenant prefix and short ID
+// joined by a newline.
+const expiriesKey = "urls:expiries"
+
+// Save outcomes reported by saveScript.
+const (
+	saveStored    = 1
+	saveExists    = 0
+	saveIDTaken   = 2
+	aliasTaken    = 3
+	aliasRepeated = 4
+)
+
+// saveScript stores a URL under a short ID unless the URL is already indexed.
+// Redis cannot enforce uniqueness of the original URL on its own, so the
+// reverse-index check and the writes run atomically in one script. With
+// duplicates allowed the check is skipped and the index points to the latest ID.
+//
+// KEYS: orig:{url}, url:{id}, owner:{id}, user:{userID}, seq, count, created:{id}
+// ARGV: original URL, id, user ID (may be empty), alias mode ("1" or "0"),
+// creation time (RFC 3339), duplicates allowed ("1" or "0")
+var saveScript = goredis.NewScript(`
+local existing = redis.call('GET', KEYS[2])
+if existing then
+	if ARGV[4] == '1' then
+		if existing == ARGV[1] and redis.call('GET', KEYS[1]) == ARGV[2] then
+			return {4, ARGV[2]}
+		end
+		return {3, ''}
+	end
+	return {2, ''}
+end
+if ARGV[6] ~= '1' then
+	local indexed = redis.call('GET', KEYS[1])
+	if indexed then
+		return {0, indexed}
+	end
+end
+redis.call('SET', KEYS[2], ARGV[1])
+redis.call('SET', KEYS[1], ARGV[2])
+redis.call('SET', KEYS[7], ARGV[5])
+if ARGV[3] ~= '' then
+	redis.call('SET', KEYS[3], ARGV[3])
+	redis.call('ZADD', KEYS[4], redis.call('INCR', KEYS[5]), ARGV[2])
+end
+redis.call('INCR', KEYS[6])
+return {1, ARGV[2]}
+`)
+
+// deleteScript marks the given short IDs deleted if they belong to the user.
+//
+// KEYS: count, then owner:{id}, deleted:{id} pairs
+// ARGV: user ID
+var deleteScript = goredis.NewScript(`
+for i = 2, #KEYS, 2 do
+	if redis.call('GET', KEYS[i]) == ARGV[1] and redis.call('SETNX', KEYS[i + 1], '1') == 1 then
+		redis.call('DECR', KEYS[1])
+	end
+end
+return 0
+`)
+
+// Storage implements URLStorage on top of Redis.
+// Each short ID is stored as url:{id}, with orig:{url} as the reverse index used
+// for deduplication, a user:{id} sorted set of a user's short IDs in creation
+// order, and a deleted:{id} marker for deleted URLs.
+type Storage struct {
+	client     *goredis.Client
+	prefix     string
+	ids        generator.IDGenerator
+	duplicates bool
+}
+
+// Option configures optional Redis storage features.
+type Option func(*Storage)
+
+// WithIDGenerator makes the storage generate short IDs with ids instead of
+// generator.Default.
+func WithIDGenerator(ids generator.IDGenerator) Option {
+	return func(s *Storage) {
+		s.ids = ids
+	}
+}
+
+// WithDuplicateURLs makes every save of a URL store it under a fresh short ID
+// instead of returning the ID it was first saved under.
+func WithDuplicateURLs(allow bool) Option {
+	return func(s *Storage) {
+		s.duplicates = allow
+	}
+}
+
+// NewStorage connects to the Redis server described by dsn.
+func NewStorage(dsn string, storageOpts ...Option) (*Storage, error) {
+	opts, err := goredis.ParseURL(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse redis DSN: %w", err)
+	}
+
+	client := goredis.NewClient(opts)
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to ping redis: %w", err)
+	}
+
+	s := &Storage{
+		client: client,
+		ids:    generator.Default(),
+	}
+	for _, opt := range storageOpts {
+		opt(s)
+	}
+
+	return s, nil
+}
+
+// IsDSN reports whether dsn selects Redis storage.
+func IsDSN(dsn string) bool {
+	return strings.HasPrefix(dsn, DSNPrefix) || strings.HasPrefix(dsn, TLSDSNPrefix)
+}
+
+// ForTenant returns a view of the storage scoped to the given tenant.
+// The view shares the client with the parent storage.
+func (s *Storage) ForTenant(tenantID string) storage.URLStorage {
+	prefix := ""
+	if tenantID != "" {
+		prefix = "tenant:" + tenantID + ":"
+	}
+
+	return &Storage{
+		client:     s.client,
+		prefix:     prefix,
+		ids:        s.ids,
+		duplicates: s.duplicates,
+	}
+}
+
+func (s *Storage) key(kind, id string) string {
+	return s.prefix + kind + ":" + id
+}
+
+// save runs saveScript and interprets its reply.
+func (s *Storage) save(ctx context.Context, id, originalURL, userID string, alias bool) (int64, string, error) {
+	keys := []string{
+		s.key("orig", originalURL),
+		s.key("url", id),
+		s.key("owner", id),
+		s.key("user", userID),
+		s.prefix + "seq",
+		countKey,
+		s.key("created", id),
+	}
+
+	mode := "0"
+	if alias {
+		mode = "1"
+	}
+	duplicates := "0"
+	if s.duplicates {
+		duplicates = "1"
+	}
+
+	reply, err := saveScript.Run(ctx, s.client, keys, originalURL, id, userID, mode, time.Now().UTC().Format(time.RFC3339Nano), duplicates).Slice()
+	if err != nil {
+		return 0, "", fmt.Errorf("error saving URL: %w", err)
+	}
+	if len(reply) != 2 {
+		return 0, "", fmt.Errorf("unexpected save reply: %v", reply)
+	}
+
+	status, _ := reply[0].(int64)
+	storedID, _ := reply[1].(string)
+
+	return status, storedID, nil
+}
+
+// insert stores originalURL under a freshly generated short ID. If the URL is
+// already stored, its existing ID is returned with storage.ErrURLExists.
+func (s *Storage) insert(ctx context.Context, originalURL, userID string) (string, error) {
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		id, err := s.ids.Generate()
+		if err != nil {
+			return "", fmt.Errorf("error generating ID: %w", err)
+		}
+
+		status, storedID, err := s.save(ctx, id, originalURL, userID, false)
+		if err != nil {
+			return "", err
+		}
+
+		switch status {
+		case saveStored:
+			return storedID, nil
+		case saveExists:
+			return storedID, storage.ErrURLExists
+		}
+	}
+
+	return "", errors.New("failed to generate a unique ID")
+}
+
+// Save stores a new URL and returns its generated short ID.
+func (s *Storage) Save(originalURL string) (string, error) {
+	return s.insert(context.Background(), originalURL, "")
+}
+
+// SaveWithUser stores a new URL associated with a user and returns its generated short ID.
+func (s *Storage) SaveWithUser(originalURL, userID string) (string, error) {
+	return s.insert(context.Background(), originalURL, userID)
+}
+
+// SaveWithAlias stores a URL under the given alias, optionally associated with a user.
+// Saving the same URL under the same alias again is a no-op.
+func (s *Storage) SaveWithAlias(alias, originalURL, userID string) error {
+	status, _, err := s.save(context.Background(), alias, originalURL, userID, true)
+	if err != nil {
+		return err
+	}
+
+	switch status {
+	case saveExists:
+		return storage.ErrURLExists
+	case aliasTaken:
+		return storage.ErrAliasTaken
+	}
+
+	return nil
+}
+
+// Get retrieves the original URL for a given short ID.
+func (s *Storage) Get(id string) (string, bool) {
+	originalURL, err := s.GetWithDeletedStatus(id)
+	if err != nil || originalURL == "" {
+		return "", false
+	}
+
+	return originalURL, true
+}
+
+// GetBatch looks up the given short IDs in one round trip, leaving out
+// unknown ones.
+func (s *Storage) GetBatch(ids []string) (map[string]model.ExpandedURL, error) {
+	result := make(map[string]model.ExpandedURL, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	ctx := context.Background()
+
+	pipe := s.client.Pipeline()
+	urlCmds := make([]*goredis.StringCmd, len(ids))
+	deletedCmds := make([]*goredis.IntCmd, len(ids))
+	expiryCmds := make([]*goredis.FloatCmd, len(ids))
+	for i, id := range ids {
+		urlCmds[i] = pipe.Get(ctx, s.key("url", id))
+		deletedCmds[i] = pipe.Exists(ctx, s.key("deleted", id))
+		expiryCmds[i] = pipe.ZScore(ctx, expiriesKey, s.expiryMember(id))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, goredis.Nil) {
+		return nil, fmt.Errorf("error querying redis: %w", err)
+	}
+
+	now := time.Now().UnixNano()
+	for i, id := range ids {
+		originalURL, err := urlCmds[i].Result()
+		if errors.Is(err, goredis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error querying redis: %w", err)
+		}
+
+		result[id] = model.ExpandedURL{
+			ID:          id,
+			OriginalURL: originalURL,
+			Deleted:     deletedCmds[i].Val() > 0,
+			Expired:     expiryCmds[i].Err() == nil && now >= int64(expiryCmds[i].Val()),
+		}
+	}
+
+	return result, nil
+}
+
+// GetWithDeletedStatus retrieves the original URL and checks if it has been deleted.
+func (s *Storage) GetWithDeletedStatus(id string) (string, error) {
+	ctx := context.Background()
+
+	pipe := s.client.Pipeline()
+	urlCmd := pipe.Get(ctx, s.key("url", id))
+	deletedCmd := pipe.Exists(ctx, s.key("deleted", id))
+	expiryCmd := pipe.ZScore(ctx, expiriesKey, s.expiryMember(id))
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, goredis.Nil) {
+		return "", fmt.Errorf("error querying redis: %w", err)
+	}
+
+	originalURL, err := urlCmd.Result()
+	if errors.Is(err, goredis.Nil) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error querying redis: %w", err)
+	}
+
+	if deletedCmd.Val() > 0 {
+		return "", storage.ErrURLDeleted
+	}
+
+	if expiryCmd.Err() == nil && time.Now().UnixNano() >= int64(expiryCmd.Val()) {
+		return "", storage.ErrURLExpired
+	}
+
+	return originalURL, nil
+}
+
+// SaveBatch stores multiple URLs and returns the short ID saved for each correlation ID.
+func (s *Storage) SaveBatch(items []model.BatchRequestItem) (map[string]model.BatchSaveResult, error) {
+	return s.SaveBatchWithUser(items, "")
+}
+
+// SaveBatchWithUser stores multiple URLs associated with a user and returns the short ID saved for each correlation ID.
+// Each URL is saved atomically, but the batch as a whole is not: a failure part way
+// through leaves the earlier URLs stored.
+func (s *Storage) SaveBatchWithUser(items []model.BatchRequestItem, userID string) (map[string]model.BatchSaveResult, error) {
+	ctx := context.Background()
+	result := make(map[string]model.BatchSaveResult)
+
+	for _, item := range items {
+		id, err := s.insert(ctx, item.OriginalURL, userID)
+		if err != nil && !errors.Is(err, storage.ErrURLExists) {
+			return nil, err
+		}
+		result[item.CorrelationID] = model.BatchSaveResult{ID: id, Created: err == nil}
+	}
+
+	return result, nil
+}
+
+// userURLs returns the user's short IDs in creation order with their URLs and deleted status.
+func (s *Storage) userURLs(ctx context.Context, userID string) ([]model.UserURL, []bool, error) {
+	ids, err := s.client.ZRange(ctx, s.key("user", userID), 0, -1).Result()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error querying user URLs: %w", err)
+	}
+
+	pipe := s.client.Pipeline()
+	urlCmds := make([]*goredis.StringCmd, len(ids))
+	createdCmds := make([]*goredis.StringCmd, len(ids))
+	deletedCmds := make([]*goredis.IntCmd, len(ids))
+	for i, id := range ids {
+		urlCmds[i] = pipe.Get(ctx, s.key("url", id))
+		createdCmds[i] = pipe.Get(ctx, s.key("created", id))
+		deletedCmds[i] = pipe.Exists(ctx, s.key("deleted", id))
+	}
+	if len(ids) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, goredis.Nil) {
+			return nil, nil, fmt.Errorf("error querying user URLs: %w", err)
+		}
+	}
+
+	urls := make([]model.UserURL, 0, len(ids))
+	deleted := make([]bool, 0, len(ids))
+	for i, id := range ids {
+		originalURL, err := urlCmds[i].Result()
+		if errors.Is(err, goredis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("error querying user URLs: %w", err)
+		}
+
+		// URLs stored before creation times were recorded have none.
+		createdAt, _ := time.Parse(time.RFC3339Nano, createdCmds[i].Val())
+		urls = append(urls, model.UserURL{
+			ShortURL:    id,
+			OriginalURL: originalURL,
+			CreatedAt:   createdAt,
+		})
+		deleted = append(deleted, deletedCmds[i].Val() > 0)
+	}
+
+	return urls, deleted, nil
+}
+
+// GetUserURLs retrieves all non-deleted URLs associated with a user.
+func (s *Storage) GetUserURLs(userID string) ([]model.UserURL, error) {
+	urls, _, err := s.GetUserURLsPaged(userID, 0, 0)
+	return urls, err
+}
+
+// GetUserURLsPaged retrieves a window of a user's non-deleted URLs in creation order
+// along with the total number of such URLs. A non-positive limit returns all URLs.
+func (s *Storage) GetUserURLsPaged(userID string, limit, offset int) ([]model.UserURL, int, error) {
+	urls, deleted, err := s.userURLs(context.Background(), userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	live := make([]model.UserURL, 0, len(urls))
+	for i, url := range urls {
+		if !deleted[i] {
+			live = append(live, url)
+		}
+	}
+
+	total := len(live)
+	if offset >= total {
+		return []model.UserURL{}, total, nil
+	}
+
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+
+	return live[offset:end], total, nil
+}
+
+// CountUserURLs returns the number of a user's non-deleted URLs.
+func (s *Storage) CountUserURLs(userID string) (int, error) {
+	_, total, err := s.GetUserURLsPaged(userID, 1, 0)
+	return total, err
+}
+
+// DeleteUserURLs marks specified URLs as deleted for a user.
+func (s *Storage) DeleteUserURLs(userID string, urlIDs []string) error {
+	if len(urlIDs) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, 1+2*len(urlIDs))
+	keys = append(keys, countKey)
+	for _, id := range urlIDs {
+		keys = append(keys, s.key("owner", id), s.key("deleted", id))
+	}
+
+	if err := deleteScript.Run(context.Background(), s.client, keys, userID).Err(); err != nil {
+		return fmt.Errorf("error deleting URLs: %w", err)
+	}
+
+	return nil
+}
+
+// OwnsURL reports whether the user owns the short URL, deleted or not.
+// It returns storage.ErrURLNotFound for unknown short IDs.
+func (s *Storage) OwnsURL(userID, id string) (bool, error) {
+	ctx := context.Background()
+
+	pipe := s.client.Pipeline()
+	existsCmd := pipe.Exists(ctx, s.key("url", id))
+	ownerCmd := pipe.Get(ctx, s.key("owner", id))
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, goredis.Nil) {
+		return false, fmt.Errorf("error getting URL owner: %w", err)
+	}
+
+	if existsCmd.Val() == 0 {
+		return false, storage.ErrURLNotFound
+	}
+
+	return userID != "" && ownerCmd.Val() == userID, nil
+}
+
+// FindID returns the short ID originalURL is stored under, or
+// storage.ErrURLNotFound if it is not stored or duplicate URLs are allowed.
+func (s *Storage) FindID(originalURL string) (string, error) {
+	if s.duplicates {
+		return "", storage.ErrURLNotFound
+	}
+
+	id, err := s.client.Get(context.Background(), s.key("orig", originalURL)).Result()
+	if errors.Is(err, goredis.Nil) {
+		return "", storage.ErrURLNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("error finding URL: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetDeletedUserURLs returns the short IDs of a user's URLs that are marked deleted.
+func (s *Storage) GetDeletedUserURLs(userID string) ([]string, error) {
+	urls, deleted, err := s.userURLs(context.Background(), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []string{}
+	for i, url := range urls {
+		if deleted[i] {
+			result = append(result, url.ShortURL)
+		}
+	}
+
+	return result, nil
+}
+
+// Count returns the number of stored, non-deleted URLs across all tenants.
+func (s *Storage) Count() (int, error) {
+	count, err := s.client.Get(context.Background(), countKey).Int()
+	if errors.Is(err, goredis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error counting URLs: %w", err)
+	}
+
+	return count, nil
+}
+
+// SetPasswordHash gates the short URL behind the given password hash.
+func (s *Storage) SetPasswordHash(id, hash string) error {
+	ctx := context.Background()
+
+	exists, err := s.client.Exists(ctx, s.key("url", id)).Result()
+	if err != nil {
+		return fmt.Errorf("error setting password hash: %w", err)
+	}
+	if exists == 0 {
+		return fmt.Errorf("short URL %s not found", id)
+	}
+
+	if err := s.client.Set(ctx, s.key("pw", id), hash, 0).Err(); err != nil {
+		return fmt.Errorf("error setting password hash: %w", err)
+	}
+
+	return nil
+}
+
+// PasswordHash returns the password hash gating the short URL, or "" if it is not protected.
+func (s *Storage) PasswordHash(id string) (string, error) {
+	hash, err := s.client.Get(context.Background(), s.key("pw", id)).Result()
+	if errors.Is(err, goredis.Nil) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error getting password hash: %w", err)
+	}
+
+	return hash, nil
+}
+
+// SetForwardQuery sets whether redirects of the short URL forward their query
+// string, kept as a fwd:{id} marker.
+func (s *Storage) SetForwardQuery(id string, enabled bool) error {
+	ctx := context.Background()
+
+	exists, err := s.client.Exists(ctx, s.key("url", id)).Result()
+	if err != nil {
+		return fmt.Errorf("error setting query forwarding: %w", err)
+	}
+	if exists == 0 {
+		return fmt.Errorf("short URL %s not found", id)
+	}
+
+	if enabled {
+		err = s.client.Set(ctx, s.key("fwd", id), "1", 0).Err()
+	} else {
+		err = s.client.Del(ctx, s.key("fwd", id)).Err()
+	}
+	if err != nil {
+		return fmt.Errorf("error setting query forwarding: %w", err)
+	}
+
+	return nil
+}
+
+// ForwardQuery reports whether redirects of the short URL forward their query string.
+func (s *Storage) ForwardQuery(id string) (bool, error) {
+	exists, err := s.client.Exists(context.Background(), s.key("fwd", id)).Result()
+	if err != nil {
+		return false, fmt.Errorf("error getting query forwarding: %w", err)
+	}
+
+	return exists == 1, nil
+}
+
+// SetCreator records the client that created the short URL in a creator:{id} hash.
+func (s *Storage) SetCreator(id string, creator model.Creator) error {
+	ctx := context.Background()
+
+	exists, err := s.client.Exists(ctx, s.key("url", id)).Result()
+	if err != nil {
+		return fmt.Errorf("error setting creator: %w", err)
+	}
+	if exists == 0 {
+		return fmt.Errorf("short URL %s not found", id)
+	}
+
+	if err := s.client.HSet(ctx, s.key("creator", id), "ip", creator.IP, "user_agent", creator.UserAgent).Err(); err != nil {
+		return fmt.Errorf("error setting creator: %w", err)
+	}
+
+	return nil
+}
+
+// Creator returns the client that created the short URL, if it was recorded.
+func (s *Storage) Creator(id string) (model.Creator, error) {
+	fields, err := s.client.HGetAll(context.Background(), s.key("creator", id)).Result()
+	if err != nil {
+		return model.Creator{}, fmt.Errorf("error getting creator: %w", err)
+	}
+
+	return model.Creator{IP: fields["ip"], UserAgent: fields["user_agent"]}, nil
+}
+
+// Ping verifies the Redis connection is alive.
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.client.Ping(ctx).Err()
+}
+
+// Close closes the Redis client.
+func (s *Storage) Close() error {
+	return s.client.Close()
+}
+
+// IncrementVisit adds n visits to the short URL, the latest at the given time.
+// Statistics live in a stats:{id} hash with visits and last_visited fields.
+func (s *Storage) IncrementVisit(id string, n int64, at time.Time) error {
+	ctx := context.Background()
+	key := s.key("stats", id)
+
+	pipe := s.client.TxPipeline()
+	pipe.HIncrBy(ctx, key, "visits", n)
+	pipe.HSet(ctx, key, "last_visited", at.UnixNano())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("error incrementing visits: %w", err)
+	}
+
+	return nil
+}
+
+// GetStats returns the visit statistics of the short URL.
+func (s *Storage) GetStats(id string) (model.URLStats, error) {
+	fields, err := s.client.HGetAll(context.Background(), s.key("stats", id)).Result()
+	if err != nil {
+		return model.URLStats{}, fmt.Errorf("error getting URL stats: %w", err)
+	}
+
+	var stats model.URLStats
+	if v, ok := fields["visits"]; ok {
+		stats.Visits, _ = strconv.ParseInt(v, 10, 64)
+	}
+	if v, ok := fields["last_visited"]; ok {
+		if nanos, err := strconv.ParseInt(v, 10, 64); err == nil {
+			stats.LastVisited = time.Unix(0, nanos)
+		}
+	}
+
+	return stats, nil
+}
+
+func (s *Storage) expiryMember(id string) string {
+	return s.prefix + "\n" + id
+}
+
+// SetExpiry makes the short URL expire at the given time. A zero time removes the expiry.
+func (s *Storage) SetExpiry(id string, expiresAt time.Time) error {
+	ctx := context.Background()
+
+	exists, err := s.client.Exists(ctx, s.key("url", id)).Result()
+	if err != nil {
+		return fmt.Errorf("error setting expiry: %w", err)
+	}
+	if exists == 0 {
+		return fmt.Errorf("short URL %s not found", id)
+	}
+
+	if expiresAt.IsZero() {
+		err = s.client.ZRem(ctx, expiriesKey, s.expiryMember(id)).Err()
+	} else {
+		err = s.client.ZAdd(ctx, expiriesKey, goredis.Z{Score: float64(expiresAt.UnixNano()), Member: s.expiryMember(id)}).Err()
+	}
+	if err != nil {
+		return fmt.Errorf("error setting expiry: %w", err)
+	}
+
+	return nil
+}
+
+// Expiry returns when the short URL expires, or the zero time if it never does.
+func (s *Storage) Expiry(id string) (time.Time, error) {
+	score, err := s.client.ZScore(context.Background(), expiriesKey, s.expiryMember(id)).Result()
+	if errors.Is(err, goredis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error getting expiry: %w", err)
+	}
+
+	return time.Unix(0, int64(score)), nil
+}
+
+// PurgeExpired removes URLs of all tenants that expired before the given time
+// and returns how many were removed.
+func (s *Storage) PurgeExpired(before time.Time) (int, error) {
+	ctx := context.Background()
+
+	members, err := s.client.ZRangeByScore(ctx, expiriesKey, &goredis.ZRangeBy{
+		Min: "-inf",
+		Max: "(" + strconv.FormatInt(before.UnixNano(), 10),
+	}).Result()
+	if err != nil {
+		return 0, fmt.Errorf("error listing expired URLs: %w", err)
+	}
+
+	for _, member := range members {
+		prefix, id, _ := strings.Cut(member, "\n")
+		if err := (&Storage{client: s.client, prefix: prefix}).remove(ctx, id); err != nil {
+			return 0, err
+		}
+		if err := s.client.ZRem(ctx, expiriesKey, member).Err(); err != nil {
+			return 0, fmt.Errorf("error purging expired URL: %w", err)
+		}
+	}
+
+	return len(members), nil
+}
+
+// remove deletes every key belonging to the short URL.
+func (s *Storage) remove(ctx context.Context, id string) error {
+	pipe := s.client.Pipeline()
+	urlCmd := pipe.Get(ctx, s.key("url", id))
+	ownerCmd := pipe.Get(ctx, s.key("owner", id))
+	deletedCmd := pipe.Exists(ctx, s.key("deleted", id))
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, goredis.Nil) {
+		return fmt.Errorf("error purging expired URL: %w", err)
+	}
+
+	tx := s.client.TxPipeline()
+	if originalURL, err := urlCmd.Result(); err == nil {
+		tx.Del(ctx, s.key("orig", originalURL))
+		if deletedCmd.Val() == 0 {
+			tx.Decr(ctx, countKey)
+		}
+	}
+	if owner, err := ownerCmd.Result(); err == nil {
+		tx.ZRem(ctx, s.key("user", owner), id)
+	}
+	tx.Del(ctx, s.key("url", id), s.key("owner", id), s.key("deleted", id), s.key("pw", id), s.key("fwd", id), s.key("creator", id), s.key("stats", id), s.key("created", id))
+	if _, err := tx.Exec(ctx); err != nil {
+		return fmt.Errorf("error purging expired URL: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,203 @@
+package redis
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/storage"
+	"github.com/MikhailRaia/url-shortener/internal/storage/storagetest"
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newTestStorage(t *testing.T, opts ...Option) *Storage {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+
+	s, err := NewStorage(DSNPrefix+mr.Addr(), opts...)
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func TestStorage_SaveDuplicate(t *testing.T) {
+	s := newTestStorage(t)
+
+	id, err := s.SaveWithUser("https://example.com", "user1")
+	if err != nil {
+		t.Fatalf("Storage.SaveWithUser() error = %v", err)
+	}
+
+	dupID, err := s.Save("https://example.com")
+	if !errors.Is(err, storage.ErrURLExists) {
+		t.Errorf("Storage.Save() duplicate error = %v, want %v", err, storage.ErrURLExists)
+	}
+	if dupID != id {
+		t.Errorf("Storage.Save() duplicate ID = %v, want %v", dupID, id)
+	}
+
+	got, found := s.Get(id)
+	if !found || got != "https://example.com" {
+		t.Errorf("Storage.Get() = %v, %v, want %v, true", got, found, "https://example.com")
+	}
+}
+
+func TestStorage_SaveBatchDuplicate(t *testing.T) {
+	s := newTestStorage(t)
+
+	existing, _ := s.Save("https://a.example.com")
+
+	result, err := s.SaveBatchWithUser([]model.BatchRequestItem{
+		{CorrelationID: "1", OriginalURL: "https://a.example.com"},
+		{CorrelationID: "2", OriginalURL: "https://b.example.com"},
+	}, "user1")
+	if err != nil {
+		t.Fatalf("Storage.SaveBatchWithUser() error = %v", err)
+	}
+
+	if result["1"].ID != existing || result["1"].Created {
+		t.Errorf("Storage.SaveBatchWithUser() duplicate = %+v, want existing ID %v not created", result["1"], existing)
+	}
+	if result["2"].ID == "" || result["2"].ID == existing || !result["2"].Created {
+		t.Errorf("Storage.SaveBatchWithUser() new = %+v, want a fresh created ID", result["2"])
+	}
+}
+
+func TestStorage_DeleteUserURLs(t *testing.T) {
+	s := newTestStorage(t)
+
+	mine, _ := s.SaveWithUser("https://a.example.com", "user1")
+	theirs, _ := s.SaveWithUser("https://b.example.com", "user2")
+
+	if err := s.DeleteUserURLs("user1", []string{mine, theirs}); err != nil {
+		t.Fatalf("Storage.DeleteUserURLs() error = %v", err)
+	}
+
+	if _, err := s.GetWithDeletedStatus(mine); !errors.Is(err, storage.ErrURLDeleted) {
+		t.Errorf("Storage.GetWithDeletedStatus() error = %v, want %v", err, storage.ErrURLDeleted)
+	}
+	if got, err := s.GetWithDeletedStatus(theirs); err != nil || got != "https://b.example.com" {
+		t.Errorf("Storage.GetWithDeletedStatus() = %v, %v, want another user's URL untouched", got, err)
+	}
+
+	deleted, err := s.GetDeletedUserURLs("user1")
+	if err != nil {
+		t.Fatalf("Storage.GetDeletedUserURLs() error = %v", err)
+	}
+	if len(deleted) != 1 || deleted[0] != mine {
+		t.Errorf("Storage.GetDeletedUserURLs() = %v, want [%v]", deleted, mine)
+	}
+
+	urls, err := s.GetUserURLs("user1")
+	if err != nil {
+		t.Fatalf("Storage.GetUserURLs() error = %v", err)
+	}
+	if len(urls) != 0 {
+		t.Errorf("Storage.GetUserURLs() = %v, want none", urls)
+	}
+}
+
+func TestStorage_SaveWithAlias(t *testing.T) {
+	s := newTestStorage(t)
+
+	if err := s.SaveWithAlias("docs", "https://docs.example.com", "user1"); err != nil {
+		t.Fatalf("Storage.SaveWithAlias() error = %v", err)
+	}
+	if err := s.SaveWithAlias("docs", "https://docs.example.com", "user1"); err != nil {
+		t.Errorf("Storage.SaveWithAlias() repeat error = %v, want nil", err)
+	}
+	if err := s.SaveWithAlias("docs", "https://other.example.com", "user1"); !errors.Is(err, storage.ErrAliasTaken) {
+		t.Errorf("Storage.SaveWithAlias() taken error = %v, want %v", err, storage.ErrAliasTaken)
+	}
+}
+
+func TestStorage_GetUserURLsPaged(t *testing.T) {
+	s := newTestStorage(t)
+
+	urls := []string{"https://a.example.com", "https://b.example.com", "https://c.example.com"}
+	for _, u := range urls {
+		if _, err := s.SaveWithUser(u, "user1"); err != nil {
+			t.Fatalf("Storage.SaveWithUser() error = %v", err)
+		}
+	}
+
+	page, total, err := s.GetUserURLsPaged("user1", 2, 1)
+	if err != nil {
+		t.Fatalf("Storage.GetUserURLsPaged() error = %v", err)
+	}
+	if total != len(urls) {
+		t.Errorf("Storage.GetUserURLsPaged() total = %d, want %d", total, len(urls))
+	}
+	if len(page) != 2 || page[0].OriginalURL != urls[1] || page[1].OriginalURL != urls[2] {
+		t.Errorf("Storage.GetUserURLsPaged() = %v, want %v", page, urls[1:])
+	}
+}
+
+func TestStorage_ForTenant(t *testing.T) {
+	s := newTestStorage(t)
+
+	a := s.ForTenant("a")
+	b := s.ForTenant("b")
+
+	idA, err := a.Save("https://example.com")
+	if err != nil {
+		t.Fatalf("tenant a Save() error = %v", err)
+	}
+	if _, err := b.Save("https://example.com"); err != nil {
+		t.Errorf("tenant b Save() error = %v, want the same URL to be accepted", err)
+	}
+
+	if _, found := b.Get(idA); found {
+		t.Errorf("tenant b Get(%v) found tenant a's URL", idA)
+	}
+
+	count, err := s.Count()
+	if err != nil {
+		t.Fatalf("Storage.Count() error = %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Storage.Count() = %d, want 2", count)
+	}
+}
+
+func TestStorage_GetBatch(t *testing.T) {
+	s := newTestStorage(t)
+
+	kept, _ := s.SaveWithUser("https://a.example.com", "user1")
+	gone, _ := s.SaveWithUser("https://b.example.com", "user1")
+	expired, _ := s.SaveWithUser("https://c.example.com", "user1")
+	if err := s.DeleteUserURLs("user1", []string{gone}); err != nil {
+		t.Fatalf("Storage.DeleteUserURLs() error = %v", err)
+	}
+	if err := s.SetExpiry(expired, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("Storage.SetExpiry() error = %v", err)
+	}
+
+	got, err := s.GetBatch([]string{kept, "missing", gone, expired})
+	if err != nil {
+		t.Fatalf("Storage.GetBatch() error = %v", err)
+	}
+
+	want := map[string]model.ExpandedURL{
+		kept:    {ID: kept, OriginalURL: "https://a.example.com"},
+		gone:    {ID: gone, OriginalURL: "https://b.example.com", Deleted: true},
+		expired: {ID: expired, OriginalURL: "https://c.example.com", Expired: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Storage.GetBatch() = %v, want %v", got, want)
+	}
+}
+
+func TestStorage_Suite(t *testing.T) {
+	storagetest.RunSuite(t, func() storage.URLStorage { return newTestStorage(t) })
+}
+
+func TestStorage_DuplicateSuite(t *testing.T) {
+	storagetest.RunDuplicateSuite(t, func() storage.URLStorage { return newTestStorage(t, WithDuplicateURLs(true)) })
+}
@@ -0,0 +1,692 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MikhailRaia/url-shortener/internal/generator"
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/storage"
+	sqlitedriver "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// DSNPrefix marks a DATABASE_DSN that selects SQLite storage, e.g. sqlite:///var/lib/urls.db.
+const DSNPrefix = "sqlite://"
+
+// maxIDAttempts bounds retries when a generated short ID collides with an existing one.
+const maxIDAttempts = 10
+
+// Storage implements URLStorage using an SQLite database file.
+type Storage struct {
+	db         *sql.DB
+	tenantID   string
+	ids        generator.IDGenerator
+	duplicates bool
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Option configures optional SQLite storage features.
+type Option func(*Storage)
+
+// WithIDGenerator makes the storage generate short IDs with ids instead of
+// generator.Default.
+func WithIDGenerator(ids generator.IDGenerator) Option {
+	return func(s *Storage) {
+		s.ids = ids
+	}
+}
+
+// WithDuplicateURLs makes every save of a URL store it under a fresh short ID
+// instead of returning the ID it was first saved under. Turning the option off
+// again fails while duplicate URLs are stored.
+func WithDuplicateURLs(allow bool) Option {
+	return func(s *Storage) {
+		s.duplicates = allow
+	}
+}
+
+// NewStorage opens the SQLite database at path and prepares the schema.
+// The path may carry the DSNPrefix.
+func NewStorage(path string, opts ...Option) (*Storage, error) {
+	path = strings.TrimPrefix(path, DSNPrefix)
+	if path == "" {
+		return nil, errors.New("sqlite database path is empty")
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// SQLite serializes writers; a single connection avoids SQLITE_BUSY errors
+	// and keeps in-memory databases shared.
+	db.SetMaxOpenConns(1)
+
+	ctx := context.Background()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	storage := &Storage{
+		db:  db,
+		ids: generator.Default(),
+	}
+	for _, opt := range opts {
+		opt(storage)
+	}
+
+	if err := storage.createTable(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create database tables: %w", err)
+	}
+
+	return storage, nil
+}
+
+// ForTenant returns a view of the storage scoped to the given tenant.
+// The view shares the database handle with the parent storage.
+func (s *Storage) ForTenant(tenantID string) storage.URLStorage {
+	return &Storage{
+		db:         s.db,
+		tenantID:   tenantID,
+		ids:        s.ids,
+		duplicates: s.duplicates,
+	}
+}
+
+func (s *Storage) createTable(ctx context.Context) error {
+	originalURLIndex := []string{
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_urls_tenant_original_url ON urls(tenant_id, original_url)`,
+		`DROP INDEX IF EXISTS idx_urls_tenant_original_url_lookup`,
+	}
+	if s.duplicates {
+		originalURLIndex = []string{
+			`DROP INDEX IF EXISTS idx_urls_tenant_original_url`,
+			`CREATE INDEX IF NOT EXISTS idx_urls_tenant_original_url_lookup ON urls(tenant_id, original_url)`,
+		}
+	}
+
+	queries := []string{
+		`PRAGMA journal_mode = WAL`,
+		`CREATE TABLE IF NOT EXISTS urls (
+			tenant_id TEXT NOT NULL DEFAULT '',
+			id TEXT NOT NULL,
+			original_url TEXT NOT NULL,
+			user_id TEXT,
+			is_deleted INTEGER NOT NULL DEFAULT 0,
+			password_hash TEXT,
+			visits INTEGER NOT NULL DEFAULT 0,
+			last_visited TIMESTAMP,
+			expires_at TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			forward_query INTEGER NOT NULL DEFAULT 0,
+			creator_ip TEXT,
+			creator_user_agent TEXT
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_urls_tenant_id ON urls(tenant_id, id)`,
+		`CREATE INDEX IF NOT EXISTS idx_urls_tenant_user ON urls(tenant_id, user_id)`,
+	}
+	queries = append(queries, originalURLIndex...)
+
+	for _, query := range queries {
+		if _, err := s.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to prepare schema: %w", err)
+		}
+	}
+
+	// Databases created before query forwarding lack its column.
+	if err := s.addColumn(ctx, "forward_query", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return fmt.Errorf("failed to add forward_query column: %w", err)
+	}
+	// Or the columns of creator capture.
+	for _, column := range []string{"creator_ip", "creator_user_agent"} {
+		if err := s.addColumn(ctx, column, "TEXT"); err != nil {
+			return fmt.Errorf("failed to add %s column: %w", column, err)
+		}
+	}
+
+	return nil
+}
+
+// addColumn adds a column to the urls table unless it already exists, as
+// SQLite has no ADD COLUMN IF NOT EXISTS.
+func (s *Storage) addColumn(ctx context.Context, name, definition string) error {
+	var exists bool
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) > 0 FROM pragma_table_info('urls') WHERE name = ?", name).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, "ALTER TABLE urls ADD COLUMN "+name+" "+definition)
+	return err
+}
+
+// uniqueViolation reports which unique index err violated: "original_url", "id", or "".
+func uniqueViolation(err error) string {
+	var sqliteErr *sqlitedriver.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.Code() != sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+		return ""
+	}
+
+	if strings.Contains(sqliteErr.Error(), "original_url") {
+		return "original_url"
+	}
+	return "id"
+}
+
+// nullableUser maps an empty user ID to NULL.
+func nullableUser(userID string) interface{} {
+	if userID == "" {
+		return nil
+	}
+	return userID
+}
+
+// insert stores originalURL under a freshly generated short ID. If the URL is
+// already stored, its existing ID is returned with storage.ErrURLExists.
+func (s *Storage) insert(ctx context.Context, q querier, originalURL, userID string) (string, error) {
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		id, err := s.ids.Generate()
+		if err != nil {
+			return "", fmt.Errorf("error generating ID: %w", err)
+		}
+
+		_, err = q.ExecContext(ctx, "INSERT INTO urls (tenant_id, id, original_url, user_id) VALUES (?, ?, ?, ?)", s.tenantID, id, originalURL, nullableUser(userID))
+		switch uniqueViolation(err) {
+		case "":
+			if err != nil {
+				return "", fmt.Errorf("error inserting URL into database: %w", err)
+			}
+			return id, nil
+		case "original_url":
+			var existingID string
+			if err := q.QueryRowContext(ctx, "SELECT id FROM urls WHERE tenant_id = ? AND original_url = ?", s.tenantID, originalURL).Scan(&existingID); err != nil {
+				return "", fmt.Errorf("error getting existing URL: %w", err)
+			}
+			return existingID, storage.ErrURLExists
+		}
+	}
+
+	return "", errors.New("failed to generate a unique ID")
+}
+
+// Save stores a new URL and returns its generated short ID.
+func (s *Storage) Save(originalURL string) (string, error) {
+	return s.insert(context.Background(), s.db, originalURL, "")
+}
+
+// SaveWithUser stores a new URL associated with a user and returns its generated short ID.
+func (s *Storage) SaveWithUser(originalURL, userID string) (string, error) {
+	return s.insert(context.Background(), s.db, originalURL, userID)
+}
+
+// SaveWithAlias stores a URL under the given alias, optionally associated with a user.
+// Saving the same URL under the same alias again is a no-op.
+func (s *Storage) SaveWithAlias(alias, originalURL, userID string) error {
+	ctx := context.Background()
+
+	var existingURL string
+	err := s.db.QueryRowContext(ctx, "SELECT original_url FROM urls WHERE tenant_id = ? AND id = ? AND is_deleted = 0", s.tenantID, alias).Scan(&existingURL)
+	if err == nil {
+		if existingURL == originalURL {
+			return nil
+		}
+		return storage.ErrAliasTaken
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("error checking if alias exists: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, "INSERT INTO urls (tenant_id, id, original_url, user_id) VALUES (?, ?, ?, ?)", s.tenantID, alias, originalURL, nullableUser(userID))
+	switch uniqueViolation(err) {
+	case "original_url":
+		return storage.ErrURLExists
+	case "id":
+		return storage.ErrAliasTaken
+	}
+	if err != nil {
+		return fmt.Errorf("error inserting URL into database: %w", err)
+	}
+
+	return nil
+}
+
+// Get retrieves the original URL for a given short ID.
+func (s *Storage) Get(id string) (string, bool) {
+	originalURL, err := s.GetWithDeletedStatus(id)
+	if err != nil || originalURL == "" {
+		return "", false
+	}
+
+	return originalURL, true
+}
+
+// GetBatch looks up the given short IDs in one query, leaving out unknown ones.
+func (s *Storage) GetBatch(ids []string) (map[string]model.ExpandedURL, error) {
+	result := make(map[string]model.ExpandedURL, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	query := "SELECT id, original_url, is_deleted, expires_at FROM urls WHERE tenant_id = ? AND id IN (" + placeholders + ")"
+
+	args := make([]interface{}, 0, len(ids)+1)
+	args = append(args, s.tenantID)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	rows, err := s.db.QueryContext(context.Background(), query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying database: %w", err)
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	for rows.Next() {
+		var url model.ExpandedURL
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&url.ID, &url.OriginalURL, &url.Deleted, &expiresAt); err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		url.Expired = expiresAt.Valid && !now.Before(expiresAt.Time)
+		result[url.ID] = url
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return result, nil
+}
+
+// GetWithDeletedStatus retrieves the original URL and checks if it has been deleted.
+func (s *Storage) GetWithDeletedStatus(id string) (string, error) {
+	var originalURL string
+	var isDeleted bool
+	var expiresAt sql.NullTime
+	err := s.db.QueryRowContext(context.Background(), "SELECT original_url, is_deleted, expires_at FROM urls WHERE tenant_id = ? AND id = ?", s.tenantID, id).Scan(&originalURL, &isDeleted, &expiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("error querying database: %w", err)
+	}
+
+	if isDeleted {
+		return "", storage.ErrURLDeleted
+	}
+
+	if expiresAt.Valid && !time.Now().Before(expiresAt.Time) {
+		return "", storage.ErrURLExpired
+	}
+
+	return originalURL, nil
+}
+
+// SaveBatch stores multiple URLs and returns the short ID saved for each correlation ID.
+func (s *Storage) SaveBatch(items []model.BatchRequestItem) (map[string]model.BatchSaveResult, error) {
+	return s.SaveBatchWithUser(items, "")
+}
+
+// SaveBatchWithUser stores multiple URLs associated with a user and returns the short ID saved for each correlation ID.
+func (s *Storage) SaveBatchWithUser(items []model.BatchRequestItem, userID string) (map[string]model.BatchSaveResult, error) {
+	ctx := context.Background()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result := make(map[string]model.BatchSaveResult)
+
+	for _, item := range items {
+		id, err := s.insert(ctx, tx, item.OriginalURL, userID)
+		if err != nil && !errors.Is(err, storage.ErrURLExists) {
+			return nil, err
+		}
+		result[item.CorrelationID] = model.BatchSaveResult{ID: id, Created: err == nil}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return result, nil
+}
+
+// GetUserURLs retrieves all non-deleted URLs associated with a user.
+func (s *Storage) GetUserURLs(userID string) ([]model.UserURL, error) {
+	urls, _, err := s.GetUserURLsPaged(userID, 0, 0)
+	return urls, err
+}
+
+// GetUserURLsPaged retrieves a window of a user's non-deleted URLs in creation order
+// along with the total number of such URLs. A non-positive limit returns all URLs.
+func (s *Storage) GetUserURLsPaged(userID string, limit, offset int) ([]model.UserURL, int, error) {
+	ctx := context.Background()
+
+	total, err := s.CountUserURLs(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if total == 0 || offset >= total {
+		return []model.UserURL{}, total, nil
+	}
+
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT id, original_url, created_at FROM urls WHERE tenant_id = ? AND user_id = ? AND is_deleted = 0 ORDER BY rowid LIMIT ? OFFSET ?", s.tenantID, userID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error querying user URLs: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]model.UserURL, 0)
+	for rows.Next() {
+		var id, originalURL string
+		var createdAt sql.NullTime
+		if err := rows.Scan(&id, &originalURL, &createdAt); err != nil {
+			return nil, 0, fmt.Errorf("error scanning row: %w", err)
+		}
+
+		result = append(result, model.UserURL{
+			ShortURL:    id,
+			OriginalURL: originalURL,
+			CreatedAt:   createdAt.Time.UTC(),
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return result, total, nil
+}
+
+// CountUserURLs returns the number of a user's non-deleted URLs.
+func (s *Storage) CountUserURLs(userID string) (int, error) {
+	var total int
+	if err := s.db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM urls WHERE tenant_id = ? AND user_id = ? AND is_deleted = 0", s.tenantID, userID).Scan(&total); err != nil {
+		return 0, fmt.Errorf("error counting user URLs: %w", err)
+	}
+
+	return total, nil
+}
+
+// DeleteUserURLs marks specified URLs as deleted for a user.
+func (s *Storage) DeleteUserURLs(userID string, urlIDs []string) error {
+	if len(urlIDs) == 0 {
+		return nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(urlIDs)), ", ")
+	query := "UPDATE urls SET is_deleted = 1 WHERE tenant_id = ? AND user_id = ? AND is_deleted = 0 AND id IN (" + placeholders + ")"
+
+	args := make([]interface{}, 0, len(urlIDs)+2)
+	args = append(args, s.tenantID, userID)
+	for _, id := range urlIDs {
+		args = append(args, id)
+	}
+
+	if _, err := s.db.ExecContext(context.Background(), query, args...); err != nil {
+		return fmt.Errorf("error deleting URLs: %w", err)
+	}
+
+	return nil
+}
+
+// OwnsURL reports whether the user owns the short URL, deleted or not.
+// It returns storage.ErrURLNotFound for unknown short IDs.
+func (s *Storage) OwnsURL(userID, id string) (bool, error) {
+	var owner string
+	err := s.db.QueryRowContext(context.Background(), "SELECT COALESCE(user_id, '') FROM urls WHERE tenant_id = ? AND id = ?", s.tenantID, id).Scan(&owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, storage.ErrURLNotFound
+	}
+	if err != nil {
+		return false, fmt.Errorf("error getting URL owner: %w", err)
+	}
+
+	return userID != "" && owner == userID, nil
+}
+
+// FindID returns the short ID originalURL is stored under, or
+// storage.ErrURLNotFound if it is not stored or duplicate URLs are allowed.
+func (s *Storage) FindID(originalURL string) (string, error) {
+	if s.duplicates {
+		return "", storage.ErrURLNotFound
+	}
+
+	var id string
+	err := s.db.QueryRowContext(context.Background(), "SELECT id FROM urls WHERE tenant_id = ? AND original_url = ?", s.tenantID, originalURL).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", storage.ErrURLNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("error finding URL: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetDeletedUserURLs returns the short IDs of a user's URLs that are marked deleted.
+func (s *Storage) GetDeletedUserURLs(userID string) ([]string, error) {
+	rows, err := s.db.QueryContext(context.Background(), "SELECT id FROM urls WHERE tenant_id = ? AND user_id = ? AND is_deleted = 1 ORDER BY rowid", s.tenantID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying deleted user URLs: %w", err)
+	}
+	defer rows.Close()
+
+	result := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		result = append(result, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return result, nil
+}
+
+// Count returns the number of stored, non-deleted URLs across all tenants.
+func (s *Storage) Count() (int, error) {
+	var count int
+	if err := s.db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM urls WHERE is_deleted = 0").Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting URLs: %w", err)
+	}
+
+	return count, nil
+}
+
+// SetPasswordHash gates the short URL behind the given password hash.
+func (s *Storage) SetPasswordHash(id, hash string) error {
+	res, err := s.db.ExecContext(context.Background(), "UPDATE urls SET password_hash = ? WHERE tenant_id = ? AND id = ?", hash, s.tenantID, id)
+	if err != nil {
+		return fmt.Errorf("error setting password hash: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("short URL %s not found", id)
+	}
+
+	return nil
+}
+
+// PasswordHash returns the password hash gating the short URL, or "" if it is not protected.
+func (s *Storage) PasswordHash(id string) (string, error) {
+	var hash string
+	err := s.db.QueryRowContext(context.Background(), "SELECT COALESCE(password_hash, '') FROM urls WHERE tenant_id = ? AND id = ?", s.tenantID, id).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error getting password hash: %w", err)
+	}
+
+	return hash, nil
+}
+
+// SetForwardQuery sets whether redirects of the short URL forward their query string.
+func (s *Storage) SetForwardQuery(id string, enabled bool) error {
+	res, err := s.db.ExecContext(context.Background(), "UPDATE urls SET forward_query = ? WHERE tenant_id = ? AND id = ?", enabled, s.tenantID, id)
+	if err != nil {
+		return fmt.Errorf("error setting query forwarding: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("short URL %s not found", id)
+	}
+
+	return nil
+}
+
+// ForwardQuery reports whether redirects of the short URL forward their query string.
+func (s *Storage) ForwardQuery(id string) (bool, error) {
+	var enabled bool
+	err := s.db.QueryRowContext(context.Background(), "SELECT forward_query FROM urls WHERE tenant_id = ? AND id = ?", s.tenantID, id).Scan(&enabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error getting query forwarding: %w", err)
+	}
+
+	return enabled, nil
+}
+
+// SetCreator records the client that created the short URL.
+func (s *Storage) SetCreator(id string, creator model.Creator) error {
+	res, err := s.db.ExecContext(context.Background(), "UPDATE urls SET creator_ip = NULLIF(?, ''), creator_user_agent = NULLIF(?, '') WHERE tenant_id = ? AND id = ?",
+		creator.IP, creator.UserAgent, s.tenantID, id)
+	if err != nil {
+		return fmt.Errorf("error setting creator: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("short URL %s not found", id)
+	}
+
+	return nil
+}
+
+// Creator returns the client that created the short URL, if it was recorded.
+func (s *Storage) Creator(id string) (model.Creator, error) {
+	var creator model.Creator
+	err := s.db.QueryRowContext(context.Background(), "SELECT COALESCE(creator_ip, ''), COALESCE(creator_user_agent, '') FROM urls WHERE tenant_id = ? AND id = ?", s.tenantID, id).Scan(&creator.IP, &creator.UserAgent)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Creator{}, nil
+	}
+	if err != nil {
+		return model.Creator{}, fmt.Errorf("error getting creator: %w", err)
+	}
+
+	return creator, nil
+}
+
+// Ping verifies the database connection is alive.
+func (s *Storage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close closes the database.
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+// IncrementVisit adds n visits to the short URL, the latest at the given time.
+func (s *Storage) IncrementVisit(id string, n int64, at time.Time) error {
+	_, err := s.db.ExecContext(context.Background(), "UPDATE urls SET visits = visits + ?, last_visited = MAX(COALESCE(last_visited, ?), ?) WHERE tenant_id = ? AND id = ?", n, at.UTC(), at.UTC(), s.tenantID, id)
+	if err != nil {
+		return fmt.Errorf("error incrementing visits: %w", err)
+	}
+
+	return nil
+}
+
+// GetStats returns the visit statistics of the short URL.
+func (s *Storage) GetStats(id string) (model.URLStats, error) {
+	var stats model.URLStats
+	var lastVisited sql.NullTime
+	err := s.db.QueryRowContext(context.Background(), "SELECT visits, last_visited FROM urls WHERE tenant_id = ? AND id = ?", s.tenantID, id).Scan(&stats.Visits, &lastVisited)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.URLStats{}, nil
+	}
+	if err != nil {
+		return model.URLStats{}, fmt.Errorf("error getting URL stats: %w", err)
+	}
+
+	if lastVisited.Valid {
+		stats.LastVisited = lastVisited.Time
+	}
+
+	return stats, nil
+}
+
+// SetExpiry makes the short URL expire at the given time. A zero time removes the expiry.
+func (s *Storage) SetExpiry(id string, expiresAt time.Time) error {
+	expiry := sql.NullTime{Time: expiresAt.UTC(), Valid: !expiresAt.IsZero()}
+
+	res, err := s.db.ExecContext(context.Background(), "UPDATE urls SET expires_at = ? WHERE tenant_id = ? AND id = ?", expiry, s.tenantID, id)
+	if err != nil {
+		return fmt.Errorf("error setting expiry: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("short URL %s not found", id)
+	}
+
+	return nil
+}
+
+// Expiry returns when the short URL expires, or the zero time if it never does.
+func (s *Storage) Expiry(id string) (time.Time, error) {
+	var expiresAt sql.NullTime
+	err := s.db.QueryRowContext(context.Background(), "SELECT expires_at FROM urls WHERE tenant_id = ? AND id = ?", s.tenantID, id).Scan(&expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error getting expiry: %w", err)
+	}
+
+	if !expiresAt.Valid {
+		return time.Time{}, nil
+	}
+	return expiresAt.Time, nil
+}
+
+// PurgeExpired deletes URLs of all tenants that expired before the given time
+// and returns how many were deleted.
+func (s *Storage) PurgeExpired(before time.Time) (int, error) {
+	res, err := s.db.ExecContext(context.Background(), "DELETE FROM urls WHERE expires_at IS NOT NULL AND expires_at < ?", before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("error purging expired URLs: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error purging expired URLs: %w", err)
+	}
+
+	return int(n), nil
+}
@@ -0,0 +1,404 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/storage"
+	"github.com/MikhailRaia/url-shortener/internal/storage/storagetest"
+)
+
+func newTestStorage(t *testing.T, opts ...Option) *Storage {
+	t.Helper()
+
+	s, err := NewStorage(DSNPrefix+filepath.Join(t.TempDir(), "urls.db"), opts...)
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func TestStorage_SaveDuplicate(t *testing.T) {
+	s := newTestStorage(t)
+
+	id, err := s.SaveWithUser("https://example.com", "user1")
+	if err != nil {
+		t.Fatalf("Storage.SaveWithUser() error = %v", err)
+	}
+
+	dupID, err := s.Save("https://example.com")
+	if !errors.Is(err, storage.ErrURLExists) {
+		t.Errorf("Storage.Save() duplicate error = %v, want %v", err, storage.ErrURLExists)
+	}
+	if dupID != id {
+		t.Errorf("Storage.Save() duplicate ID = %v, want %v", dupID, id)
+	}
+
+	got, found := s.Get(id)
+	if !found || got != "https://example.com" {
+		t.Errorf("Storage.Get() = %v, %v, want %v, true", got, found, "https://example.com")
+	}
+}
+
+func TestStorage_SaveBatchDuplicate(t *testing.T) {
+	s := newTestStorage(t)
+
+	existing, _ := s.Save("https://a.example.com")
+
+	result, err := s.SaveBatchWithUser([]model.BatchRequestItem{
+		{CorrelationID: "1", OriginalURL: "https://a.example.com"},
+		{CorrelationID: "2", OriginalURL: "https://b.example.com"},
+	}, "user1")
+	if err != nil {
+		t.Fatalf("Storage.SaveBatchWithUser() error = %v", err)
+	}
+
+	if result["1"].ID != existing || result["1"].Created {
+		t.Errorf("Storage.SaveBatchWithUser() duplicate = %+v, want existing ID %v not created", result["1"], existing)
+	}
+	if result["2"].ID == "" || result["2"].ID == existing || !result["2"].Created {
+		t.Errorf("Storage.SaveBatchWithUser() new = %+v, want a fresh created ID", result["2"])
+	}
+}
+
+func TestStorage_DeleteUserURLs(t *testing.T) {
+	s := newTestStorage(t)
+
+	mine, _ := s.SaveWithUser("https://a.example.com", "user1")
+	theirs, _ := s.SaveWithUser("https://b.example.com", "user2")
+
+	if err := s.DeleteUserURLs("user1", []string{mine, theirs}); err != nil {
+		t.Fatalf("Storage.DeleteUserURLs() error = %v", err)
+	}
+
+	if _, err := s.GetWithDeletedStatus(mine); !errors.Is(err, storage.ErrURLDeleted) {
+		t.Errorf("Storage.GetWithDeletedStatus() error = %v, want %v", err, storage.ErrURLDeleted)
+	}
+	if got, err := s.GetWithDeletedStatus(theirs); err != nil || got != "https://b.example.com" {
+		t.Errorf("Storage.GetWithDeletedStatus() = %v, %v, want another user's URL untouched", got, err)
+	}
+
+	deleted, err := s.GetDeletedUserURLs("user1")
+	if err != nil {
+		t.Fatalf("Storage.GetDeletedUserURLs() error = %v", err)
+	}
+	if len(deleted) != 1 || deleted[0] != mine {
+		t.Errorf("Storage.GetDeletedUserURLs() = %v, want [%v]", deleted, mine)
+	}
+
+	urls, err := s.GetUserURLs("user1")
+	if err != nil {
+		t.Fatalf("Storage.GetUserURLs() error = %v", err)
+	}
+	if len(urls) != 0 {
+		t.Errorf("Storage.GetUserURLs() = %v, want none", urls)
+	}
+}
+
+func TestStorage_SaveWithAlias(t *testing.T) {
+	s := newTestStorage(t)
+
+	if err := s.SaveWithAlias("docs", "https://docs.example.com", "user1"); err != nil {
+		t.Fatalf("Storage.SaveWithAlias() error = %v", err)
+	}
+	if err := s.SaveWithAlias("docs", "https://docs.example.com", "user1"); err != nil {
+		t.Errorf("Storage.SaveWithAlias() repeat error = %v, want nil", err)
+	}
+	if err := s.SaveWithAlias("docs", "https://other.example.com", "user1"); !errors.Is(err, storage.ErrAliasTaken) {
+		t.Errorf("Storage.SaveWithAlias() taken error = %v, want %v", err, storage.ErrAliasTaken)
+	}
+}
+
+func TestStorage_GetUserURLsPaged(t *testing.T) {
+	s := newTestStorage(t)
+
+	urls := []string{"https://a.example.com", "https://b.example.com", "https://c.example.com"}
+	for _, u := range urls {
+		if _, err := s.SaveWithUser(u, "user1"); err != nil {
+			t.Fatalf("Storage.SaveWithUser() error = %v", err)
+		}
+	}
+
+	page, total, err := s.GetUserURLsPaged("user1", 2, 1)
+	if err != nil {
+		t.Fatalf("Storage.GetUserURLsPaged() error = %v", err)
+	}
+	if total != len(urls) {
+		t.Errorf("Storage.GetUserURLsPaged() total = %d, want %d", total, len(urls))
+	}
+	if len(page) != 2 || page[0].OriginalURL != urls[1] || page[1].OriginalURL != urls[2] {
+		t.Errorf("Storage.GetUserURLsPaged() = %v, want %v", page, urls[1:])
+	}
+}
+
+func TestStorage_CountUserURLs(t *testing.T) {
+	s := newTestStorage(t)
+
+	var ids []string
+	for _, u := range []string{"https://a.example.com", "https://b.example.com", "https://c.example.com"} {
+		id, err := s.SaveWithUser(u, "user1")
+		if err != nil {
+			t.Fatalf("Storage.SaveWithUser() error = %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if _, err := s.SaveWithUser("https://d.example.com", "user2"); err != nil {
+		t.Fatalf("Storage.SaveWithUser() error = %v", err)
+	}
+	if err := s.DeleteUserURLs("user1", ids[:1]); err != nil {
+		t.Fatalf("Storage.DeleteUserURLs() error = %v", err)
+	}
+
+	count, err := s.CountUserURLs("user1")
+	if err != nil {
+		t.Fatalf("Storage.CountUserURLs() error = %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Storage.CountUserURLs() = %d, want 2", count)
+	}
+
+	if count, _ := s.ForTenant("other").(storage.UserURLCounter).CountUserURLs("user1"); count != 0 {
+		t.Errorf("other tenant CountUserURLs() = %d, want 0", count)
+	}
+}
+
+func TestStorage_ForTenant(t *testing.T) {
+	s := newTestStorage(t)
+
+	a := s.ForTenant("a")
+	b := s.ForTenant("b")
+
+	idA, err := a.Save("https://example.com")
+	if err != nil {
+		t.Fatalf("tenant a Save() error = %v", err)
+	}
+	if _, err := b.Save("https://example.com"); err != nil {
+		t.Errorf("tenant b Save() error = %v, want the same URL to be accepted", err)
+	}
+
+	if _, found := b.Get(idA); found {
+		t.Errorf("tenant b Get(%v) found tenant a's URL", idA)
+	}
+
+	count, err := s.Count()
+	if err != nil {
+		t.Fatalf("Storage.Count() error = %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Storage.Count() = %d, want 2", count)
+	}
+}
+
+func TestStorage_IncrementVisit(t *testing.T) {
+	s := newTestStorage(t)
+
+	id, _ := s.Save("https://example.com")
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		if err := s.IncrementVisit(id, 2, at); err != nil {
+			t.Fatalf("Storage.IncrementVisit() error = %v", err)
+		}
+	}
+
+	stats, err := s.GetStats(id)
+	if err != nil {
+		t.Fatalf("Storage.GetStats() error = %v", err)
+	}
+	if stats.Visits != 6 {
+		t.Errorf("Storage.GetStats() visits = %d, want 6", stats.Visits)
+	}
+	if !stats.LastVisited.Equal(at) {
+		t.Errorf("Storage.GetStats() last visited = %v, want %v", stats.LastVisited, at)
+	}
+}
+
+func TestStorage_Expiry(t *testing.T) {
+	s := newTestStorage(t)
+
+	id, _ := s.Save("https://example.com")
+	expiresAt := time.Now().Add(-time.Minute)
+
+	if err := s.SetExpiry(id, expiresAt); err != nil {
+		t.Fatalf("Storage.SetExpiry() error = %v", err)
+	}
+	if _, err := s.GetWithDeletedStatus(id); !errors.Is(err, storage.ErrURLExpired) {
+		t.Errorf("Storage.GetWithDeletedStatus() error = %v, want %v", err, storage.ErrURLExpired)
+	}
+
+	got, err := s.Expiry(id)
+	if err != nil {
+		t.Fatalf("Storage.Expiry() error = %v", err)
+	}
+	if !got.Equal(expiresAt) {
+		t.Errorf("Storage.Expiry() = %v, want %v", got, expiresAt)
+	}
+
+	if err := s.SetExpiry(id, time.Time{}); err != nil {
+		t.Fatalf("Storage.SetExpiry() clear error = %v", err)
+	}
+	if _, err := s.GetWithDeletedStatus(id); err != nil {
+		t.Errorf("Storage.GetWithDeletedStatus() after clearing expiry error = %v, want nil", err)
+	}
+
+	s.SetExpiry(id, expiresAt)
+	purged, err := s.PurgeExpired(time.Now())
+	if err != nil {
+		t.Fatalf("Storage.PurgeExpired() error = %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("Storage.PurgeExpired() = %d, want 1", purged)
+	}
+	if _, found := s.Get(id); found {
+		t.Errorf("Storage.Get() found a purged URL")
+	}
+}
+
+func TestStorage_ForwardQuery(t *testing.T) {
+	s := newTestStorage(t)
+
+	id, _ := s.Save("https://example.com")
+	if forward, err := s.ForwardQuery(id); err != nil || forward {
+		t.Errorf("ForwardQuery() = %v, %v, want false by default", forward, err)
+	}
+
+	if err := s.SetForwardQuery(id, true); err != nil {
+		t.Fatalf("SetForwardQuery() error = %v", err)
+	}
+	if forward, err := s.ForwardQuery(id); err != nil || !forward {
+		t.Errorf("ForwardQuery() = %v, %v, want true", forward, err)
+	}
+	if forward, _ := s.ForTenant("other").(*Storage).ForwardQuery(id); forward {
+		t.Errorf("ForwardQuery() in another tenant = true, want false")
+	}
+
+	if err := s.SetForwardQuery("missing", true); err == nil {
+		t.Errorf("SetForwardQuery() of an unknown ID error = nil, want an error")
+	}
+}
+
+func TestStorage_AddsForwardQueryColumn(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "urls.db")
+
+	// Create the schema as it was before query forwarding.
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	_, err = db.Exec(`CREATE TABLE urls (
+		tenant_id TEXT NOT NULL DEFAULT '',
+		id TEXT NOT NULL,
+		original_url TEXT NOT NULL,
+		user_id TEXT,
+		is_deleted INTEGER NOT NULL DEFAULT 0,
+		password_hash TEXT,
+		visits INTEGER NOT NULL DEFAULT 0,
+		last_visited TIMESTAMP,
+		expires_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err == nil {
+		_, err = db.Exec("INSERT INTO urls (id, original_url) VALUES ('old', 'https://old.example.com')")
+	}
+	db.Close()
+	if err != nil {
+		t.Fatalf("creating old schema error = %v", err)
+	}
+
+	s, err := NewStorage(DSNPrefix + path)
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	defer s.Close()
+
+	if forward, err := s.ForwardQuery("old"); err != nil || forward {
+		t.Errorf("ForwardQuery() on a migrated row = %v, %v, want false", forward, err)
+	}
+	if err := s.SetForwardQuery("old", true); err != nil {
+		t.Errorf("SetForwardQuery() on a migrated row error = %v", err)
+	}
+}
+
+func TestStorage_GetBatch(t *testing.T) {
+	s := newTestStorage(t)
+
+	kept, _ := s.SaveWithUser("https://a.example.com", "user1")
+	gone, _ := s.SaveWithUser("https://b.example.com", "user1")
+	expired, _ := s.SaveWithUser("https://c.example.com", "user1")
+	if err := s.DeleteUserURLs("user1", []string{gone}); err != nil {
+		t.Fatalf("Storage.DeleteUserURLs() error = %v", err)
+	}
+	if err := s.SetExpiry(expired, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("Storage.SetExpiry() error = %v", err)
+	}
+
+	got, err := s.GetBatch([]string{kept, "missing", gone, expired})
+	if err != nil {
+		t.Fatalf("Storage.GetBatch() error = %v", err)
+	}
+
+	want := map[string]model.ExpandedURL{
+		kept:    {ID: kept, OriginalURL: "https://a.example.com"},
+		gone:    {ID: gone, OriginalURL: "https://b.example.com", Deleted: true},
+		expired: {ID: expired, OriginalURL: "https://c.example.com", Expired: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Storage.GetBatch() = %v, want %v", got, want)
+	}
+}
+
+func TestStorage_Suite(t *testing.T) {
+	storagetest.RunSuite(t, func() storage.URLStorage { return newTestStorage(t) })
+}
+
+func TestStorage_DuplicateSuite(t *testing.T) {
+	storagetest.RunDuplicateSuite(t, func() storage.URLStorage { return newTestStorage(t, WithDuplicateURLs(true)) })
+}
+
+func TestStorage_DisallowDuplicatesAgain(t *testing.T) {
+	path := DSNPrefix + filepath.Join(t.TempDir(), "urls.db")
+
+	s, err := NewStorage(path, WithDuplicateURLs(true))
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := s.Save("https://example.com/dup"); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+	s.Close()
+
+	if s, err := NewStorage(path); err == nil {
+		s.Close()
+		t.Fatal("NewStorage() without duplicate URLs succeeded over stored duplicates, want error")
+	}
+
+	s, err = NewStorage(path, WithDuplicateURLs(true))
+	if err != nil {
+		t.Fatalf("NewStorage() error = %v", err)
+	}
+	defer s.Close()
+	if _, err := s.db.Exec("DELETE FROM urls"); err != nil {
+		t.Fatalf("failed to delete URLs: %v", err)
+	}
+
+	s2, err := NewStorage(path)
+	if err != nil {
+		t.Fatalf("NewStorage() without duplicate URLs error = %v", err)
+	}
+	defer s2.Close()
+	if _, err := s2.Save("https://example.com/dup"); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if _, err := s2.Save("https://example.com/dup"); !errors.Is(err, storage.ErrURLExists) {
+		t.Errorf("Save() of a stored URL error = %v, want ErrURLExists", err)
+	}
+}
@@ -1,7 +1,10 @@
 package storage
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/MikhailRaia/url-shortener/internal/model"
 )
@@ -12,23 +15,155 @@ var (
 	ErrURLExists = errors.New("url already exists")
 	// ErrURLDeleted indicates the short URL was deleted by the user.
 	ErrURLDeleted = errors.New("url has been deleted")
+	// ErrAliasTaken indicates the requested alias already maps to a different URL.
+	ErrAliasTaken = errors.New("alias already taken")
+	// ErrURLExpired indicates the short URL has passed its expiry time.
+	ErrURLExpired = errors.New("url has expired")
+	// ErrURLNotFound indicates no URL is stored under the short ID.
+	ErrURLNotFound = errors.New("url not found")
 )
 
+// ImportConflictError reports the records an import skipped because their
+// short ID or original URL is already stored with a different counterpart.
+type ImportConflictError struct {
+	// ShortIDs are the short IDs of the skipped records, in input order.
+	ShortIDs []string
+}
+
+func (e *ImportConflictError) Error() string {
+	return fmt.Sprintf("%d imported URLs conflict with stored URLs", len(e.ShortIDs))
+}
+
 // URLStorage defines persistence operations for shortened URLs.
 type URLStorage interface {
 	Save(originalURL string) (string, error)
 
 	SaveWithUser(originalURL, userID string) (string, error)
 
+	SaveWithAlias(alias, originalURL, userID string) error
+
 	Get(id string) (string, bool)
 
 	GetWithDeletedStatus(id string) (string, error)
 
-	SaveBatch(items []model.BatchRequestItem) (map[string]string, error)
+	// SaveBatch saves items and returns the result of each by correlation ID,
+	// reporting whether the item created its short URL or the URL was already stored.
+	SaveBatch(items []model.BatchRequestItem) (map[string]model.BatchSaveResult, error)
 
-	SaveBatchWithUser(items []model.BatchRequestItem, userID string) (map[string]string, error)
+	SaveBatchWithUser(items []model.BatchRequestItem, userID string) (map[string]model.BatchSaveResult, error)
 
 	GetUserURLs(userID string) ([]model.UserURL, error)
 
+	GetUserURLsPaged(userID string, limit, offset int) ([]model.UserURL, int, error)
+
 	DeleteUserURLs(userID string, urlIDs []string) error
+
+	GetDeletedUserURLs(userID string) ([]string, error)
+}
+
+// URLCounter is implemented by storages that can report how many URLs they hold.
+type URLCounter interface {
+	Count() (int, error)
+}
+
+// UserURLCounter is implemented by storages that can report how many live
+// (non-deleted) URLs a user owns without listing them.
+type UserURLCounter interface {
+	CountUserURLs(userID string) (int, error)
+}
+
+// OwnershipChecker is implemented by storages that can tell whether a user owns
+// a short URL without listing the user's URLs. Deleted URLs still have their
+// owner; OwnsURL returns ErrURLNotFound for unknown short IDs.
+type OwnershipChecker interface {
+	OwnsURL(userID, id string) (bool, error)
+}
+
+// URLFinder is implemented by storages that can look up the short ID an
+// original URL is stored under, the one Save would return with ErrURLExists.
+// FindID returns ErrURLNotFound if there is none, which is always the case
+// when duplicate URLs are allowed.
+type URLFinder interface {
+	FindID(originalURL string) (string, error)
+}
+
+// TenantScoper is implemented by storages that can partition their keyspace by tenant.
+// The returned view shares the underlying data but only sees the given tenant's URLs,
+// so the same short ID may exist independently in different tenants.
+type TenantScoper interface {
+	ForTenant(tenantID string) URLStorage
+}
+
+// ContextBinder is implemented by storages that can carry request-scoped
+// values, such as the trace ID, into the queries they run. The returned view
+// shares the underlying data and is meant to serve a single request.
+type ContextBinder interface {
+	WithContext(ctx context.Context) URLStorage
+}
+
+// TxStorage is implemented by storages that can apply several operations
+// atomically. WithTx runs fn with a view of the storage whose operations take
+// effect together if fn returns nil; fn's error is returned as is. How far the
+// operations are isolated from concurrent ones depends on the storage.
+type TxStorage interface {
+	WithTx(ctx context.Context, fn func(URLStorage) error) error
+}
+
+// PasswordProtector is implemented by storages that can gate short URLs behind a password.
+// Only password hashes are stored; an empty hash means the URL is not protected.
+type PasswordProtector interface {
+	SetPasswordHash(id, hash string) error
+	PasswordHash(id string) (string, error)
+}
+
+// QueryForwarder is implemented by storages that can remember which short URLs
+// forward the query string of a redirect request to their original URL.
+type QueryForwarder interface {
+	SetForwardQuery(id string, enabled bool) error
+	ForwardQuery(id string) (bool, error)
+}
+
+// CreatorRecorder is implemented by storages that can keep the client that
+// created a short URL. Creator returns a zero model.Creator if none was recorded.
+type CreatorRecorder interface {
+	SetCreator(id string, creator model.Creator) error
+	Creator(id string) (model.Creator, error)
+}
+
+// VisitTracker is implemented by storages that can count redirects per short URL.
+// IncrementVisit adds n visits at once so callers can batch increments.
+type VisitTracker interface {
+	IncrementVisit(id string, n int64, at time.Time) error
+	GetStats(id string) (model.URLStats, error)
+}
+
+// Expirer is implemented by storages that can expire short URLs.
+// Expired URLs are reported with ErrURLExpired until PurgeExpired removes them.
+type Expirer interface {
+	SetExpiry(id string, expiresAt time.Time) error
+	Expiry(id string) (time.Time, error)
+	PurgeExpired(before time.Time) (int, error)
+}
+
+// Importer is implemented by storages that can bulk-load URLs under the short
+// IDs they had elsewhere, e.g. when migrating from another shortener. Records
+// already stored with the same short ID and URL are skipped silently; records
+// whose short ID or URL is taken otherwise are skipped and reported with an
+// *ImportConflictError once the rest have been imported.
+type Importer interface {
+	ImportURLs(records []model.URLRecord) error
+}
+
+// BatchGetter is implemented by storages that can look up many short IDs in
+// one query. GetBatch returns an entry for each known ID, with the original
+// URL even if it was deleted or has expired; unknown IDs are left out.
+type BatchGetter interface {
+	GetBatch(ids []string) (map[string]model.ExpandedURL, error)
+}
+
+// Exporter is implemented by storages that can stream all their URLs, e.g. for
+// backups and migrations. ExportURLs calls fn with the latest state of each URL,
+// deleted ones included, and stops at the first error fn returns.
+type Exporter interface {
+	ExportURLs(fn func(model.URLRecord) error) error
 }
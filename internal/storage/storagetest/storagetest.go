@@ -0,0 +1,331 @@
+// Package storagetest provides an in-memory fake of storage.URLStorage for tests
+// of the layers above storage.
+//
+// Unlike the memory storage, the fake hands out predictable short IDs
+// ("id1", "id2", ...) and lets tests inject errors into any method.
+package storagetest
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/storage"
+)
+
+// Method names a storage.URLStorage method that can be made to fail.
+type Method string
+
+// Methods of storage.URLStorage accepted by Storage.FailOn.
+const (
+	MethodSave                 Method = "Save"
+	MethodSaveWithUser         Method = "SaveWithUser"
+	MethodSaveWithAlias        Method = "SaveWithAlias"
+	MethodGet                  Method = "Get"
+	MethodGetWithDeletedStatus Method = "GetWithDeletedStatus"
+	MethodSaveBatch            Method = "SaveBatch"
+	MethodSaveBatchWithUser    Method = "SaveBatchWithUser"
+	MethodGetUserURLs          Method = "GetUserURLs"
+	MethodGetUserURLsPaged     Method = "GetUserURLsPaged"
+	MethodDeleteUserURLs       Method = "DeleteUserURLs"
+	MethodGetDeletedUserURLs   Method = "GetDeletedUserURLs"
+)
+
+var _ storage.URLStorage = (*Storage)(nil)
+
+type entry struct {
+	originalURL string
+	userID      string
+	deleted     bool
+	createdAt   time.Time
+}
+
+// Storage is a fake storage.URLStorage keeping URLs in memory.
+// It is safe for concurrent use.
+type Storage struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+	idByURL map[string]string
+	order   []string
+	nextID  int
+	errs    map[Method]error
+	calls   map[Method]int
+}
+
+// New creates an empty fake storage.
+func New() *Storage {
+	return &Storage{
+		entries: make(map[string]*entry),
+		idByURL: make(map[string]string),
+		errs:    make(map[Method]error),
+		calls:   make(map[Method]int),
+	}
+}
+
+// FailOn makes every later call of method return err. A nil err clears the failure.
+// Get has no error result and reports a failure as not found.
+func (s *Storage) FailOn(method Method, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err == nil {
+		delete(s.errs, method)
+		return
+	}
+	s.errs[method] = err
+}
+
+// Calls returns how many times method has been called, failed calls included.
+func (s *Storage) Calls(method Method) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.calls[method]
+}
+
+// Put stores originalURL under id for userID, which may be empty, replacing any
+// existing URL under that ID. It seeds the fake without going through FailOn.
+func (s *Storage) Put(id, originalURL, userID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.put(id, originalURL, userID)
+}
+
+// IsDeleted reports whether the URL under id has been deleted.
+func (s *Storage) IsDeleted(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[id]
+	return ok && e.deleted
+}
+
+// call records a call of method and returns the error injected for it.
+// Callers must hold the mutex.
+func (s *Storage) call(method Method) error {
+	s.calls[method]++
+	return s.errs[method]
+}
+
+// put stores a URL. Callers must hold the mutex.
+func (s *Storage) put(id, originalURL, userID string) {
+	if _, exists := s.entries[id]; !exists {
+		s.order = append(s.order, id)
+	}
+	s.entries[id] = &entry{originalURL: originalURL, userID: userID, createdAt: time.Now().UTC()}
+	if _, exists := s.idByURL[originalURL]; !exists {
+		s.idByURL[originalURL] = id
+	}
+}
+
+// newID returns the next unused generated ID. Callers must hold the mutex.
+func (s *Storage) newID() string {
+	for {
+		s.nextID++
+		id := "id" + strconv.Itoa(s.nextID)
+		if _, exists := s.entries[id]; !exists {
+			return id
+		}
+	}
+}
+
+// Save stores a new URL and returns its generated short ID.
+func (s *Storage) Save(originalURL string) (string, error) {
+	return s.SaveWithUser(originalURL, "")
+}
+
+// SaveWithUser stores a new URL associated with a user and returns its generated short ID.
+// If the URL is already stored, its existing ID is returned with storage.ErrURLExists.
+func (s *Storage) SaveWithUser(originalURL, userID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	method := MethodSaveWithUser
+	if userID == "" {
+		method = MethodSave
+	}
+	if err := s.call(method); err != nil {
+		return "", err
+	}
+
+	if existingID, exists := s.idByURL[originalURL]; exists {
+		return existingID, storage.ErrURLExists
+	}
+
+	id := s.newID()
+	s.put(id, originalURL, userID)
+	return id, nil
+}
+
+// SaveWithAlias stores a URL under the given alias, optionally associated with a user.
+// Saving the same URL under the same alias again is a no-op.
+func (s *Storage) SaveWithAlias(alias, originalURL, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(MethodSaveWithAlias); err != nil {
+		return err
+	}
+
+	if e, exists := s.entries[alias]; exists {
+		if e.originalURL == originalURL && !e.deleted {
+			return nil
+		}
+		return storage.ErrAliasTaken
+	}
+
+	s.put(alias, originalURL, userID)
+	return nil
+}
+
+// Get retrieves the original URL for a given short ID, hiding deleted URLs.
+func (s *Storage) Get(id string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(MethodGet); err != nil {
+		return "", false
+	}
+
+	e, ok := s.entries[id]
+	if !ok || e.deleted {
+		return "", false
+	}
+	return e.originalURL, true
+}
+
+// GetWithDeletedStatus retrieves the original URL, returning storage.ErrURLDeleted
+// for deleted URLs and an empty URL for unknown IDs.
+func (s *Storage) GetWithDeletedStatus(id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(MethodGetWithDeletedStatus); err != nil {
+		return "", err
+	}
+
+	e, ok := s.entries[id]
+	if !ok {
+		return "", nil
+	}
+	if e.deleted {
+		return "", storage.ErrURLDeleted
+	}
+	return e.originalURL, nil
+}
+
+// SaveBatch stores multiple URLs and returns the short ID saved for each correlation ID.
+// URLs that are already stored keep their existing ID.
+func (s *Storage) SaveBatch(items []model.BatchRequestItem) (map[string]model.BatchSaveResult, error) {
+	return s.saveBatch(MethodSaveBatch, items, "")
+}
+
+// SaveBatchWithUser stores multiple URLs associated with a user and returns the short ID saved for each correlation ID.
+func (s *Storage) SaveBatchWithUser(items []model.BatchRequestItem, userID string) (map[string]model.BatchSaveResult, error) {
+	return s.saveBatch(MethodSaveBatchWithUser, items, userID)
+}
+
+func (s *Storage) saveBatch(method Method, items []model.BatchRequestItem, userID string) (map[string]model.BatchSaveResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(method); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]model.BatchSaveResult, len(items))
+	for _, item := range items {
+		if existingID, exists := s.idByURL[item.OriginalURL]; exists {
+			result[item.CorrelationID] = model.BatchSaveResult{ID: existingID}
+			continue
+		}
+
+		id := s.newID()
+		s.put(id, item.OriginalURL, userID)
+		result[item.CorrelationID] = model.BatchSaveResult{ID: id, Created: true}
+	}
+	return result, nil
+}
+
+// GetUserURLs retrieves all non-deleted URLs associated with a user in creation order.
+func (s *Storage) GetUserURLs(userID string) ([]model.UserURL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(MethodGetUserURLs); err != nil {
+		return nil, err
+	}
+	return s.userURLs(userID), nil
+}
+
+// GetUserURLsPaged retrieves a window of a user's non-deleted URLs in creation order
+// along with the total number of such URLs.
+func (s *Storage) GetUserURLsPaged(userID string, limit, offset int) ([]model.UserURL, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(MethodGetUserURLsPaged); err != nil {
+		return nil, 0, err
+	}
+
+	urls := s.userURLs(userID)
+	total := len(urls)
+	if offset >= total {
+		return []model.UserURL{}, total, nil
+	}
+
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+	return urls[offset:end], total, nil
+}
+
+// userURLs lists a user's non-deleted URLs. Callers must hold the mutex.
+func (s *Storage) userURLs(userID string) []model.UserURL {
+	urls := []model.UserURL{}
+	for _, id := range s.order {
+		e := s.entries[id]
+		if e.userID == userID && userID != "" && !e.deleted {
+			urls = append(urls, model.UserURL{ShortURL: id, OriginalURL: e.originalURL, CreatedAt: e.createdAt})
+		}
+	}
+	return urls
+}
+
+// DeleteUserURLs marks the given URLs as deleted, skipping those the user does not own.
+func (s *Storage) DeleteUserURLs(userID string, urlIDs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(MethodDeleteUserURLs); err != nil {
+		return err
+	}
+
+	for _, id := range urlIDs {
+		if e, ok := s.entries[id]; ok && e.userID == userID && userID != "" {
+			e.deleted = true
+		}
+	}
+	return nil
+}
+
+// GetDeletedUserURLs returns the IDs of a user's deleted URLs in creation order.
+func (s *Storage) GetDeletedUserURLs(userID string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(MethodGetDeletedUserURLs); err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, id := range s.order {
+		if e := s.entries[id]; e.userID == userID && userID != "" && e.deleted {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,91 @@
+package storagetest
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/storage"
+)
+
+func TestStorage_UserFlow(t *testing.T) {
+	s := New()
+
+	kept, err := s.SaveWithUser("https://a.example.com", "user1")
+	if err != nil {
+		t.Fatalf("SaveWithUser() error = %v", err)
+	}
+	gone, _ := s.SaveWithUser("https://b.example.com", "user1")
+	other, _ := s.SaveWithUser("https://c.example.com", "user2")
+	anonymous, _ := s.Save("https://d.example.com")
+
+	if err := s.DeleteUserURLs("user1", []string{gone, other, anonymous}); err != nil {
+		t.Fatalf("DeleteUserURLs() error = %v", err)
+	}
+
+	if got, err := s.GetWithDeletedStatus(kept); err != nil || got != "https://a.example.com" {
+		t.Errorf("Expected kept URL, got %q, %v", got, err)
+	}
+	if _, err := s.GetWithDeletedStatus(gone); !errors.Is(err, storage.ErrURLDeleted) {
+		t.Errorf("Expected ErrURLDeleted, got %v", err)
+	}
+	if _, found := s.Get(gone); found {
+		t.Errorf("Expected deleted URL to be hidden from Get")
+	}
+	if s.IsDeleted(other) || s.IsDeleted(anonymous) {
+		t.Errorf("Expected URLs of other owners to survive deletion")
+	}
+
+	urls, err := s.GetUserURLs("user1")
+	if err != nil {
+		t.Fatalf("GetUserURLs() error = %v", err)
+	}
+	want := []model.UserURL{{ShortURL: kept, OriginalURL: "https://a.example.com"}}
+	if urls := withoutCreatedAt(urls); !reflect.DeepEqual(urls, want) {
+		t.Errorf("Expected %v, got %v", want, urls)
+	}
+
+	deleted, err := s.GetDeletedUserURLs("user1")
+	if err != nil {
+		t.Fatalf("GetDeletedUserURLs() error = %v", err)
+	}
+	if !reflect.DeepEqual(deleted, []string{gone}) {
+		t.Errorf("Expected deleted IDs [%s], got %v", gone, deleted)
+	}
+
+	if err := s.SaveWithAlias(gone, "https://b.example.com", "user1"); !errors.Is(err, storage.ErrAliasTaken) {
+		t.Errorf("Expected ErrAliasTaken for a deleted alias, got %v", err)
+	}
+}
+
+func TestStorage_FailOn(t *testing.T) {
+	s := New()
+	errBoom := errors.New("boom")
+
+	s.FailOn(MethodSave, errBoom)
+	if _, err := s.Save("https://example.com"); !errors.Is(err, errBoom) {
+		t.Errorf("Expected injected error, got %v", err)
+	}
+	if _, err := s.SaveWithUser("https://example.com", "user1"); err != nil {
+		t.Errorf("Expected SaveWithUser to be unaffected, got %v", err)
+	}
+
+	s.FailOn(MethodSave, nil)
+	if _, err := s.Save("https://other.example.com"); err != nil {
+		t.Errorf("Expected cleared failure, got %v", err)
+	}
+	if calls := s.Calls(MethodSave); calls != 2 {
+		t.Errorf("Expected 2 Save calls, got %d", calls)
+	}
+
+	s.Put("abc", "https://example.com", "")
+	s.FailOn(MethodGet, errBoom)
+	if _, found := s.Get("abc"); found {
+		t.Errorf("Expected failing Get to report not found")
+	}
+}
+
+func TestStorage_Suite(t *testing.T) {
+	RunSuite(t, func() storage.URLStorage { return New() })
+}
@@ -0,0 +1,426 @@
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/MikhailRaia/url-shortener/internal/model"
+	"github.com/MikhailRaia/url-shortener/internal/storage"
+)
+
+// RunSuite checks the behaviour every storage.URLStorage backend must share.
+// factory is called once per subtest and must return an empty storage.
+// Subtests of optional interfaces are skipped for storages without them.
+func RunSuite(t *testing.T, factory func() storage.URLStorage) {
+	t.Helper()
+
+	t.Run("SaveAndGet", func(t *testing.T) { testSaveAndGet(t, factory()) })
+	t.Run("SaveDedup", func(t *testing.T) { testSaveDedup(t, factory()) })
+	t.Run("SaveBatch", func(t *testing.T) { testSaveBatch(t, factory()) })
+	t.Run("SaveWithAlias", func(t *testing.T) { testSaveWithAlias(t, factory()) })
+	t.Run("UserURLs", func(t *testing.T) { testUserURLs(t, factory()) })
+	t.Run("DeleteUserURLs", func(t *testing.T) { testDeleteUserURLs(t, factory()) })
+	t.Run("OwnsURL", func(t *testing.T) { testOwnsURL(t, factory()) })
+	t.Run("Creator", func(t *testing.T) { testCreator(t, factory()) })
+	t.Run("Tx", func(t *testing.T) { testTx(t, factory()) })
+	t.Run("FindID", func(t *testing.T) { testFindID(t, factory()) })
+}
+
+// RunDuplicateSuite checks a backend configured to allow duplicate URLs:
+// every save of a URL must store it under a fresh short ID. factory is called
+// once per subtest and must return an empty storage.
+func RunDuplicateSuite(t *testing.T, factory func() storage.URLStorage) {
+	t.Helper()
+
+	t.Run("SaveDuplicates", func(t *testing.T) { testSaveDuplicates(t, factory()) })
+	t.Run("SaveBatchDuplicates", func(t *testing.T) { testSaveBatchDuplicates(t, factory()) })
+	t.Run("FindIDDuplicates", func(t *testing.T) { testFindIDDuplicates(t, factory()) })
+}
+
+func testSaveAndGet(t *testing.T, s storage.URLStorage) {
+	id, err := s.Save("https://example.com/get")
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if id == "" {
+		t.Fatal("Save() returned an empty ID")
+	}
+
+	if got, found := s.Get(id); !found || got != "https://example.com/get" {
+		t.Errorf("Get(%q) = %q, %v, want the saved URL", id, got, found)
+	}
+	if got, err := s.GetWithDeletedStatus(id); err != nil || got != "https://example.com/get" {
+		t.Errorf("GetWithDeletedStatus(%q) = %q, %v, want the saved URL", id, got, err)
+	}
+
+	if got, found := s.Get("missing"); found {
+		t.Errorf("Get(missing) = %q, want not found", got)
+	}
+	if got, err := s.GetWithDeletedStatus("missing"); err != nil || got != "" {
+		t.Errorf("GetWithDeletedStatus(missing) = %q, %v, want empty URL and no error", got, err)
+	}
+}
+
+func testSaveDedup(t *testing.T, s storage.URLStorage) {
+	id, err := s.Save("https://example.com/dup")
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	again, err := s.Save("https://example.com/dup")
+	if !errors.Is(err, storage.ErrURLExists) {
+		t.Errorf("Save() of a stored URL error = %v, want ErrURLExists", err)
+	}
+	if again != id {
+		t.Errorf("Save() of a stored URL = %q, want existing ID %q", again, id)
+	}
+
+	again, err = s.SaveWithUser("https://example.com/dup", "user1")
+	if !errors.Is(err, storage.ErrURLExists) {
+		t.Errorf("SaveWithUser() of a stored URL error = %v, want ErrURLExists", err)
+	}
+	if again != id {
+		t.Errorf("SaveWithUser() of a stored URL = %q, want existing ID %q", again, id)
+	}
+}
+
+func testSaveBatch(t *testing.T, s storage.URLStorage) {
+	existing, err := s.Save("https://example.com/batch/old")
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	result, err := s.SaveBatch([]model.BatchRequestItem{
+		{CorrelationID: "new", OriginalURL: "https://example.com/batch/new"},
+		{CorrelationID: "old", OriginalURL: "https://example.com/batch/old"},
+	})
+	if err != nil {
+		t.Fatalf("SaveBatch() error = %v", err)
+	}
+
+	created, ok := result["new"]
+	if !ok || !created.Created || created.ID == "" {
+		t.Errorf("SaveBatch() new item = %+v, %v, want a created ID", created, ok)
+	}
+	if got, found := s.Get(created.ID); !found || got != "https://example.com/batch/new" {
+		t.Errorf("Get(%q) = %q, %v, want the batch URL", created.ID, got, found)
+	}
+
+	if old := result["old"]; old.Created || old.ID != existing {
+		t.Errorf("SaveBatch() stored item = %+v, want existing ID %q not created", old, existing)
+	}
+}
+
+func testSaveWithAlias(t *testing.T, s storage.URLStorage) {
+	if err := s.SaveWithAlias("alias", "https://example.com/alias", "user1"); err != nil {
+		t.Fatalf("SaveWithAlias() error = %v", err)
+	}
+	if got, found := s.Get("alias"); !found || got != "https://example.com/alias" {
+		t.Errorf("Get(alias) = %q, %v, want the aliased URL", got, found)
+	}
+
+	if err := s.SaveWithAlias("alias", "https://example.com/alias", "user1"); err != nil {
+		t.Errorf("SaveWithAlias() repeated error = %v, want nil", err)
+	}
+	if err := s.SaveWithAlias("alias", "https://example.com/other", "user1"); !errors.Is(err, storage.ErrAliasTaken) {
+		t.Errorf("SaveWithAlias() of a taken alias error = %v, want ErrAliasTaken", err)
+	}
+}
+
+func testUserURLs(t *testing.T, s storage.URLStorage) {
+	// Some storages keep creation times to the second.
+	before := time.Now().Truncate(time.Second)
+	var want []model.UserURL
+	for _, originalURL := range []string{"https://example.com/u/1", "https://example.com/u/2", "https://example.com/u/3"} {
+		id, err := s.SaveWithUser(originalURL, "user1")
+		if err != nil {
+			t.Fatalf("SaveWithUser() error = %v", err)
+		}
+		want = append(want, model.UserURL{ShortURL: id, OriginalURL: originalURL})
+	}
+	if _, err := s.SaveWithUser("https://example.com/u/other", "user2"); err != nil {
+		t.Fatalf("SaveWithUser() error = %v", err)
+	}
+
+	urls, err := s.GetUserURLs("user1")
+	if err != nil {
+		t.Fatalf("GetUserURLs() error = %v", err)
+	}
+	for _, url := range urls {
+		if url.CreatedAt.Before(before) || url.CreatedAt.After(time.Now()) || url.CreatedAt.Location() != time.UTC {
+			t.Errorf("GetUserURLs() creation time of %q = %v, want a UTC time since %v", url.ShortURL, url.CreatedAt, before)
+		}
+	}
+	if urls := withoutCreatedAt(urls); !reflect.DeepEqual(urls, want) {
+		t.Errorf("GetUserURLs() = %v, want %v", urls, want)
+	}
+
+	page, total, err := s.GetUserURLsPaged("user1", 2, 1)
+	if err != nil {
+		t.Fatalf("GetUserURLsPaged() error = %v", err)
+	}
+	if page := withoutCreatedAt(page); total != 3 || !reflect.DeepEqual(page, want[1:]) {
+		t.Errorf("GetUserURLsPaged(2, 1) = %v, %d, want %v, 3", page, total, want[1:])
+	}
+
+	if urls, err := s.GetUserURLs("nobody"); err != nil || len(urls) != 0 {
+		t.Errorf("GetUserURLs(nobody) = %v, %v, want no URLs", urls, err)
+	}
+}
+
+// withoutCreatedAt returns urls with their creation times cleared, for
+// comparing listings with URLs whose creation times are not known upfront.
+func withoutCreatedAt(urls []model.UserURL) []model.UserURL {
+	cleared := make([]model.UserURL, len(urls))
+	for i, url := range urls {
+		url.CreatedAt = time.Time{}
+		cleared[i] = url
+	}
+	return cleared
+}
+
+func testDeleteUserURLs(t *testing.T, s storage.URLStorage) {
+	kept, _ := s.SaveWithUser("https://example.com/d/kept", "user1")
+	gone, _ := s.SaveWithUser("https://example.com/d/gone", "user1")
+	other, _ := s.SaveWithUser("https://example.com/d/other", "user2")
+
+	if err := s.DeleteUserURLs("user1", []string{gone, other}); err != nil {
+		t.Fatalf("DeleteUserURLs() error = %v", err)
+	}
+
+	if _, err := s.GetWithDeletedStatus(gone); !errors.Is(err, storage.ErrURLDeleted) {
+		t.Errorf("GetWithDeletedStatus() of a deleted URL error = %v, want ErrURLDeleted", err)
+	}
+	if got, found := s.Get(gone); found {
+		t.Errorf("Get() of a deleted URL = %q, want not found", got)
+	}
+	if got, err := s.GetWithDeletedStatus(other); err != nil || got != "https://example.com/d/other" {
+		t.Errorf("GetWithDeletedStatus() of another user's URL = %q, %v, want it kept", got, err)
+	}
+
+	urls, err := s.GetUserURLs("user1")
+	if err != nil {
+		t.Fatalf("GetUserURLs() error = %v", err)
+	}
+	want := []model.UserURL{{ShortURL: kept, OriginalURL: "https://example.com/d/kept"}}
+	if urls := withoutCreatedAt(urls); !reflect.DeepEqual(urls, want) {
+		t.Errorf("GetUserURLs() after delete = %v, want %v", urls, want)
+	}
+
+	deleted, err := s.GetDeletedUserURLs("user1")
+	if err != nil {
+		t.Fatalf("GetDeletedUserURLs() error = %v", err)
+	}
+	if !reflect.DeepEqual(deleted, []string{gone}) {
+		t.Errorf("GetDeletedUserURLs() = %v, want [%s]", deleted, gone)
+	}
+}
+
+func testOwnsURL(t *testing.T, s storage.URLStorage) {
+	checker, ok := s.(storage.OwnershipChecker)
+	if !ok {
+		t.Skip("storage does not implement storage.OwnershipChecker")
+	}
+
+	owned, _ := s.SaveWithUser("https://example.com/o/owned", "user1")
+	deleted, _ := s.SaveWithUser("https://example.com/o/deleted", "user1")
+	anonymous, _ := s.Save("https://example.com/o/anonymous")
+	if err := s.DeleteUserURLs("user1", []string{deleted}); err != nil {
+		t.Fatalf("DeleteUserURLs() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		userID string
+		id     string
+		want   bool
+	}{
+		{name: "owner", userID: "user1", id: owned, want: true},
+		{name: "owner of a deleted URL", userID: "user1", id: deleted, want: true},
+		{name: "other user", userID: "user2", id: owned, want: false},
+		{name: "anonymous URL", userID: "user1", id: anonymous, want: false},
+		{name: "no user", userID: "", id: anonymous, want: false},
+	}
+	for _, tt := range tests {
+		if got, err := checker.OwnsURL(tt.userID, tt.id); err != nil || got != tt.want {
+			t.Errorf("%s: OwnsURL(%q, %q) = %v, %v, want %v", tt.name, tt.userID, tt.id, got, err, tt.want)
+		}
+	}
+
+	if _, err := checker.OwnsURL("user1", "missing"); !errors.Is(err, storage.ErrURLNotFound) {
+		t.Errorf("OwnsURL() of an unknown ID error = %v, want ErrURLNotFound", err)
+	}
+}
+
+func testCreator(t *testing.T, s storage.URLStorage) {
+	recorder, ok := s.(storage.CreatorRecorder)
+	if !ok {
+		t.Skip("storage does not implement storage.CreatorRecorder")
+	}
+
+	want := model.Creator{IP: "2001:db8::1", UserAgent: "Mozilla/5.0 (X11; Linux x86_64)"}
+	recorded, _ := s.SaveWithUser("https://example.com/c/recorded", "user1")
+	partial, _ := s.Save("https://example.com/c/partial")
+	anonymous, _ := s.Save("https://example.com/c/anonymous")
+	if err := recorder.SetCreator(recorded, want); err != nil {
+		t.Fatalf("SetCreator() error = %v", err)
+	}
+	if err := recorder.SetCreator(partial, model.Creator{IP: "192.0.2.1"}); err != nil {
+		t.Fatalf("SetCreator() error = %v", err)
+	}
+
+	if got, err := recorder.Creator(recorded); err != nil || got != want {
+		t.Errorf("Creator(%q) = %+v, %v, want %+v", recorded, got, err, want)
+	}
+	if got, err := recorder.Creator(partial); err != nil || got != (model.Creator{IP: "192.0.2.1"}) {
+		t.Errorf("Creator(%q) = %+v, %v, want only the IP", partial, got, err)
+	}
+	if got, err := recorder.Creator(anonymous); err != nil || !got.IsZero() {
+		t.Errorf("Creator(%q) = %+v, %v, want none", anonymous, got, err)
+	}
+	if urls, _ := s.GetUserURLs("user1"); len(urls) != 1 {
+		t.Errorf("GetUserURLs() after SetCreator() returned %d URLs, want 1", len(urls))
+	}
+}
+
+func testTx(t *testing.T, s storage.URLStorage) {
+	txStorage, ok := s.(storage.TxStorage)
+	if !ok {
+		t.Skip("storage does not implement storage.TxStorage")
+	}
+
+	var id string
+	err := txStorage.WithTx(context.Background(), func(tx storage.URLStorage) error {
+		var err error
+		if id, err = tx.SaveWithUser("https://example.com/tx/committed", "user1"); err != nil {
+			return err
+		}
+		if _, ok := tx.Get(id); !ok {
+			t.Errorf("Get(%q) within the transaction found nothing", id)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx() error = %v", err)
+	}
+	if got, ok := s.Get(id); !ok || got != "https://example.com/tx/committed" {
+		t.Errorf("Get(%q) after WithTx() = %q, %v, want the committed URL", id, got, ok)
+	}
+
+	errFailed := errors.New("failed")
+	if err := txStorage.WithTx(context.Background(), func(storage.URLStorage) error { return errFailed }); !errors.Is(err, errFailed) {
+		t.Errorf("WithTx() error = %v, want the error of fn", err)
+	}
+}
+
+func testFindID(t *testing.T, s storage.URLStorage) {
+	finder, ok := s.(storage.URLFinder)
+	if !ok {
+		t.Skip("storage does not implement storage.URLFinder")
+	}
+
+	saved, _ := s.Save("https://example.com/f/saved")
+	owned, _ := s.SaveWithUser("https://example.com/f/owned", "user1")
+	if err := s.SaveWithAlias("find-alias", "https://example.com/f/alias", ""); err != nil {
+		t.Fatalf("SaveWithAlias() error = %v", err)
+	}
+
+	for originalURL, want := range map[string]string{
+		"https://example.com/f/saved": saved,
+		"https://example.com/f/owned": owned,
+		"https://example.com/f/alias": "find-alias",
+	} {
+		if got, err := finder.FindID(originalURL); err != nil || got != want {
+			t.Errorf("FindID(%q) = %q, %v, want %q", originalURL, got, err, want)
+		}
+	}
+
+	if _, err := finder.FindID("https://example.com/f/missing"); !errors.Is(err, storage.ErrURLNotFound) {
+		t.Errorf("FindID() of an unknown URL error = %v, want ErrURLNotFound", err)
+	}
+}
+
+func testSaveDuplicates(t *testing.T, s storage.URLStorage) {
+	const originalURL = "https://example.com/dup"
+
+	first, err := s.Save(originalURL)
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	second, err := s.Save(originalURL)
+	if err != nil {
+		t.Fatalf("Save() of a stored URL error = %v, want nil", err)
+	}
+	third, err := s.SaveWithUser(originalURL, "user1")
+	if err != nil {
+		t.Fatalf("SaveWithUser() of a stored URL error = %v, want nil", err)
+	}
+	fourth, err := s.SaveWithUser(originalURL, "user1")
+	if err != nil {
+		t.Fatalf("SaveWithUser() of a stored URL error = %v, want nil", err)
+	}
+
+	ids := []string{first, second, third, fourth}
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if seen[id] {
+			t.Errorf("Saves of a stored URL returned IDs %v, want distinct IDs", ids)
+		}
+		seen[id] = true
+
+		if got, found := s.Get(id); !found || got != originalURL {
+			t.Errorf("Get(%q) = %q, %v, want %q", id, got, found, originalURL)
+		}
+	}
+
+	urls, err := s.GetUserURLs("user1")
+	if err != nil {
+		t.Fatalf("GetUserURLs() error = %v", err)
+	}
+	want := []model.UserURL{
+		{ShortURL: third, OriginalURL: originalURL},
+		{ShortURL: fourth, OriginalURL: originalURL},
+	}
+	if got := withoutCreatedAt(urls); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetUserURLs() = %v, want %v", got, want)
+	}
+}
+
+func testSaveBatchDuplicates(t *testing.T, s storage.URLStorage) {
+	existing, err := s.Save("https://example.com/batch/dup")
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	saved, err := s.SaveBatchWithUser([]model.BatchRequestItem{
+		{CorrelationID: "1", OriginalURL: "https://example.com/batch/dup"},
+	}, "user1")
+	if err != nil {
+		t.Fatalf("SaveBatchWithUser() error = %v", err)
+	}
+
+	got := saved["1"]
+	if !got.Created || got.ID == existing {
+		t.Errorf("SaveBatchWithUser() of a stored URL = %+v, want a created result with an ID other than %q", got, existing)
+	}
+	if url, found := s.Get(got.ID); !found || url != "https://example.com/batch/dup" {
+		t.Errorf("Get(%q) = %q, %v, want the saved URL", got.ID, url, found)
+	}
+}
+
+func testFindIDDuplicates(t *testing.T, s storage.URLStorage) {
+	finder, ok := s.(storage.URLFinder)
+	if !ok {
+		t.Skip("storage does not implement storage.URLFinder")
+	}
+
+	if _, err := s.Save("https://example.com/f/dup"); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if _, err := finder.FindID("https://example.com/f/dup"); !errors.Is(err, storage.ErrURLNotFound) {
+		t.Errorf("FindID() with duplicate URLs allowed error = %v, want ErrURLNotFound", err)
+	}
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ttlCacheItem[V any] struct {
+	key     string
+	entry   V
+	expires time.Time
+}
+
+// TTLCache is a fixed-capacity least-recently-used cache whose entries expire after a TTL.
+// It is safe for concurrent use.
+type TTLCache[V any] struct {
+	capacity int
+	ttl      time.Duration
+	now      func() time.Time
+	order    *list.List
+	items    map[string]*list.Element
+	mu       sync.Mutex
+}
+
+// NewTTLCache creates a cache of at most capacity entries, each kept for ttl.
+// A non-positive ttl keeps entries until they are evicted or removed.
+func NewTTLCache[V any](capacity int, ttl time.Duration) *TTLCache[V] {
+	return &TTLCache[V]{
+		capacity: capacity,
+		ttl:      ttl,
+		now:      time.Now,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get returns the live entry for key and marks it as most recently used.
+// Expired entries are dropped.
+func (c *TTLCache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	elem, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+
+	item := elem.Value.(*ttlCacheItem[V])
+	if c.ttl > 0 && !c.now().Before(item.expires) {
+		c.order.Remove(elem)
+		delete(c.items, key)
+		return zero, false
+	}
+
+	c.order.MoveToFront(elem)
+	return item.entry, true
+}
+
+// Add caches entry under key, evicting the least recently used entry when full.
+func (c *TTLCache[V]) Add(key string, entry V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.now().Add(c.ttl)
+
+	if elem, ok := c.items[key]; ok {
+		item := elem.Value.(*ttlCacheItem[V])
+		item.entry = entry
+		item.expires = expires
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&ttlCacheItem[V]{key: key, entry: entry, expires: expires})
+
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*ttlCacheItem[V]).key)
+	}
+}
+
+// Remove drops key from the cache.
+func (c *TTLCache[V]) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.order.Remove(elem)
+		delete(c.items, key)
+	}
+}
+
+// RemovePrefix drops every key starting with prefix from the cache.
+func (c *TTLCache[V]) RemovePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, elem := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.order.Remove(elem)
+			delete(c.items, key)
+		}
+	}
+}
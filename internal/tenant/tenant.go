@@ -0,0 +1,20 @@
+package tenant
+
+import "context"
+
+type contextKey string
+
+// IDKey is the context key used to store the resolved tenant ID.
+const IDKey contextKey = "tenantID"
+
+// WithID returns a copy of ctx carrying the given tenant ID.
+func WithID(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, IDKey, tenantID)
+}
+
+// FromContext extracts the tenant ID from context.
+// An empty string denotes the default tenant.
+func FromContext(ctx context.Context) string {
+	tenantID, _ := ctx.Value(IDKey).(string)
+	return tenantID
+}
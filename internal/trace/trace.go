@@ -0,0 +1,82 @@
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// Header is the W3C Trace Context header a trace ID is read from and
+// propagated in.
+const Header = "traceparent"
+
+// ResponseHeader echoes the request trace ID back to the client.
+const ResponseHeader = "X-Trace-ID"
+
+type contextKey string
+
+// IDKey is the context key used to store the request trace ID.
+const IDKey contextKey = "traceID"
+
+// WithID returns a copy of ctx carrying the given trace ID.
+func WithID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, IDKey, traceID)
+}
+
+// IDFromContext extracts the trace ID from context.
+// An empty string means the request is not traced.
+func IDFromContext(ctx context.Context) string {
+	traceID, _ := ctx.Value(IDKey).(string)
+	return traceID
+}
+
+// NewID returns a random trace ID of 32 lowercase hex characters.
+func NewID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// ParseTraceparent returns the trace ID of a traceparent header value such
+// as "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01". It reports
+// false for malformed values and the all-zero trace ID.
+func ParseTraceparent(value string) (string, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return "", false
+	}
+
+	traceID := parts[1]
+	if len(traceID) != 32 || !isLowerHex(traceID) || traceID == strings.Repeat("0", 32) {
+		return "", false
+	}
+	if len(parts[2]) != 16 || !isLowerHex(parts[2]) {
+		return "", false
+	}
+	return traceID, true
+}
+
+// Traceparent returns a traceparent header value carrying the trace ID of
+// ctx and a new span ID, for calls to downstream services. It returns an
+// empty string when ctx is not traced.
+func Traceparent(ctx context.Context) string {
+	traceID := IDFromContext(ctx)
+	if traceID == "" {
+		return ""
+	}
+
+	var span [8]byte
+	_, _ = rand.Read(span[:])
+	return "00-" + traceID + "-" + hex.EncodeToString(span[:]) + "-01"
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package trace
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+		ok    bool
+	}{
+		{name: "valid", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", want: "4bf92f3577b34da6a3ce929d0e0e4736", ok: true},
+		{name: "future version with extra fields", value: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", want: "4bf92f3577b34da6a3ce929d0e0e4736", ok: true},
+		{name: "empty", value: ""},
+		{name: "invalid version", value: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{name: "uppercase trace ID", value: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"},
+		{name: "zero trace ID", value: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{name: "short span ID", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa-01"},
+		{name: "missing flags", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseTraceparent(tt.value)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("Expected (%q, %v), got (%q, %v)", tt.want, tt.ok, got, ok)
+			}
+		})
+	}
+}
+
+func TestTraceparent(t *testing.T) {
+	if got := Traceparent(context.Background()); got != "" {
+		t.Errorf("Expected no traceparent without a trace ID, got %q", got)
+	}
+
+	traceID := NewID()
+	value := Traceparent(WithID(context.Background(), traceID))
+	if !strings.HasPrefix(value, "00-"+traceID+"-") {
+		t.Errorf("Expected traceparent carrying %q, got %q", traceID, value)
+	}
+	if got, ok := ParseTraceparent(value); !ok || got != traceID {
+		t.Errorf("Expected traceparent to parse back to %q, got %q", traceID, got)
+	}
+}
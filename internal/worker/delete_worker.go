@@ -2,6 +2,7 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"time"
 
@@ -10,8 +11,9 @@ import (
 
 // DeleteRequest describes a user's URLs to delete.
 type DeleteRequest struct {
-	UserID string
-	URLIDs []string
+	TenantID string
+	UserID   string
+	URLIDs   []string
 }
 
 // DeleteService deletes user URLs in the underlying storage.
@@ -19,25 +21,84 @@ type DeleteService interface {
 	DeleteUserURLs(userID string, urlIDs []string) error
 }
 
+// TenantDeleteService deletes user URLs within a specific tenant.
+// Requests with a non-empty TenantID use it when the service implements it.
+type TenantDeleteService interface {
+	DeleteTenantUserURLs(tenantID, userID string, urlIDs []string) error
+}
+
+// Observer receives delete worker pool events, e.g. to feed them to a
+// metrics system. Methods may be called from several workers at once.
+type Observer interface {
+	// OnBatchProcessed reports a processed batch of deletions for users
+	// distinct users and urls URL IDs in total.
+	OnBatchProcessed(users, urls int)
+	// OnError reports a failed deletion.
+	OnError(err error)
+	// OnDropped reports n URL IDs left unprocessed by a forced shutdown.
+	OnDropped(n int)
+}
+
+// noopObserver is the Observer used when none is configured.
+type noopObserver struct{}
+
+func (noopObserver) OnBatchProcessed(users, urls int) {}
+func (noopObserver) OnError(err error)                {}
+func (noopObserver) OnDropped(n int)                  {}
+
+// batchKey groups pending deletions by tenant and user.
+type batchKey struct {
+	tenantID string
+	userID   string
+}
+
+// ErrQueueFull is returned by Submit when the request queue has no room, so
+// callers can ask clients to retry instead of waiting for a slot.
+var ErrQueueFull = errors.New("delete queue is full")
+
 // DeleteWorkerPool batches and processes asynchronous delete requests.
+// Requests from all submitters are collected into shared batches of up to
+// BatchSize URL IDs, which WorkerCount workers delete concurrently.
 type DeleteWorkerPool struct {
 	service      DeleteService
 	requestChan  chan DeleteRequest
+	batches      chan pendingBatch
 	batchSize    int
 	batchTimeout time.Duration
 	workerCount  int
+	observer     Observer
 	ctx          context.Context
 	cancel       context.CancelFunc
 	wg           sync.WaitGroup
 	shutdownOnce sync.Once
+
+	// submitMu guards stopped, which Shutdown sets before closing
+	// requestChan, so that no submit sends on the closed channel.
+	submitMu sync.RWMutex
+	stopped  bool
+
+	// aggregatorWG tracks the aggregator, which sets abandoned to the URL
+	// IDs of a batch it could not hand to a worker on a forced shutdown.
+	aggregatorWG sync.WaitGroup
+	abandoned    int
+
+	sampleInterval time.Duration
+	history        *queueHistory
+	stopSampling   chan struct{}
+	samplerWG      sync.WaitGroup
 }
 
 // Config configures the worker pool behavior.
 type Config struct {
 	WorkerCount  int           // Количество воркеров
 	BufferSize   int           // Размер буфера канала
-	BatchSize    int           // Максимальный размер батча
+	BatchSize    int           // Размер батча по всем воркерам
 	BatchTimeout time.Duration // Таймаут для накопления батча
+
+	SampleInterval time.Duration // Интервал замера глубины очереди (0 - отключено)
+	HistorySize    int           // Количество хранимых замеров
+
+	Observer Observer // Получатель событий пула (nil - без уведомлений)
 }
 
 // DefaultConfig returns sane defaults for the worker pool.
@@ -47,6 +108,7 @@ func DefaultConfig() Config {
 		BufferSize:   100,
 		BatchSize:    10,
 		BatchTimeout: 5 * time.Second,
+		HistorySize:  60,
 	}
 }
 
@@ -57,11 +119,23 @@ func NewDeleteWorkerPool(service DeleteService, config Config) *DeleteWorkerPool
 	pool := &DeleteWorkerPool{
 		service:      service,
 		requestChan:  make(chan DeleteRequest, config.BufferSize),
+		batches:      make(chan pendingBatch),
 		batchSize:    config.BatchSize,
 		batchTimeout: config.BatchTimeout,
 		workerCount:  config.WorkerCount,
+		observer:     config.Observer,
 		ctx:          ctx,
 		cancel:       cancel,
+		stopSampling: make(chan struct{}),
+	}
+
+	if pool.observer == nil {
+		pool.observer = noopObserver{}
+	}
+
+	if config.SampleInterval > 0 && config.HistorySize > 0 {
+		pool.sampleInterval = config.SampleInterval
+		pool.history = newQueueHistory(config.HistorySize)
 	}
 
 	return pool
@@ -75,129 +149,188 @@ func (p *DeleteWorkerPool) Start() {
 		Dur("batchTimeout", p.batchTimeout).
 		Msg("Starting delete worker pool")
 
+	p.wg.Add(1)
+	p.aggregatorWG.Add(1)
+	go p.aggregate()
+
 	for i := 0; i < p.workerCount; i++ {
 		p.wg.Add(1)
 		go p.worker(i)
 	}
+
+	if p.history != nil {
+		p.samplerWG.Add(1)
+		go p.sampler()
+	}
 }
 
-func (p *DeleteWorkerPool) worker(id int) {
-	defer p.wg.Done()
+func (p *DeleteWorkerPool) sampler() {
+	defer p.samplerWG.Done()
 
-	log.Debug().Int("workerID", id).Msg("Worker started")
+	ticker := time.NewTicker(p.sampleInterval)
+	defer ticker.Stop()
 
-	batch := make(map[string][]string) // userID -> []urlIDs
-	totalURLs := 0
-	var timer *time.Timer
-	var timerC <-chan time.Time
-
-	processBatch := func() {
-		if len(batch) == 0 {
+	for {
+		select {
+		case <-p.stopSampling:
 			return
+		case t := <-ticker.C:
+			p.history.add(QueueSample{Time: t, QueueSize: len(p.requestChan)})
 		}
+	}
+}
 
-		log.Debug().
-			Int("workerID", id).
-			Int("users", len(batch)).
-			Msg("Processing batch")
-
-		for userID, urlIDs := range batch {
-			if err := p.service.DeleteUserURLs(userID, urlIDs); err != nil {
-				log.Error().
-					Err(err).
-					Int("workerID", id).
-					Str("userID", userID).
-					Int("urlCount", len(urlIDs)).
-					Msg("Failed to delete user URLs")
-			} else {
-				log.Debug().
-					Int("workerID", id).
-					Str("userID", userID).
-					Int("urlCount", len(urlIDs)).
-					Msg("Successfully deleted user URLs")
-			}
-		}
+// pendingBatch is a batch of deletions handed from the aggregator to a worker.
+type pendingBatch struct {
+	urls      map[batchKey][]string
+	totalURLs int
+}
 
-		for k := range batch {
-			delete(batch, k)
-		}
-		totalURLs = 0
-	}
+// aggregate collects the requests of all submitters into one shared batch and
+// hands it to the next free worker once it holds BatchSize URL IDs or its
+// first request has waited BatchTimeout, so batch sizes follow the total load
+// rather than the load of a single worker. It closes the batch channel, which
+// stops the workers, when the request channel is closed or the pool is
+// cancelled; a pending batch is still handed over first.
+func (p *DeleteWorkerPool) aggregate() {
+	defer p.wg.Done()
+	defer p.aggregatorWG.Done()
+	defer close(p.batches)
 
-	startOrResetTimer := func() {
-		if timer == nil {
-			timer = time.NewTimer(p.batchTimeout)
-			timerC = timer.C
-			return
-		}
-		if !timer.Stop() {
-			select {
-			case <-timer.C:
-			default:
-			}
-		}
-		timer.Reset(p.batchTimeout)
-		timerC = timer.C
-	}
+	batch := make(map[batchKey][]string)
+	totalURLs := 0
+	timer := time.NewTimer(p.batchTimeout)
+	timer.Stop()
+	var timerC <-chan time.Time
 
-	stopTimer := func() {
-		if timer == nil {
+	flush := func() {
+		timer.Stop()
+		timerC = nil
+		if len(batch) == 0 {
 			return
 		}
-		if !timer.Stop() {
-			select {
-			case <-timer.C:
-			default:
-			}
+
+		select {
+		case p.batches <- pendingBatch{urls: batch, totalURLs: totalURLs}:
+		case <-p.ctx.Done():
+			// Все воркеры заняты, а время на остановку вышло
+			p.abandoned += totalURLs
 		}
-		timerC = nil
+		batch = make(map[batchKey][]string)
+		totalURLs = 0
 	}
 
 	for {
 		select {
 		case <-p.ctx.Done():
-			log.Debug().Int("workerID", id).Msg("Worker shutting down")
-			processBatch()
-			stopTimer()
+			log.Debug().Msg("Delete aggregator shutting down")
+			p.abandoned += totalURLs
 			return
 
 		case req, ok := <-p.requestChan:
 			if !ok {
-				// Канал закрыт - обрабатываем оставшиеся запросы и выходим
-				log.Debug().Int("workerID", id).Msg("Request channel closed, processing remaining batch")
-				processBatch()
-				stopTimer()
+				// Канал закрыт - отдаем оставшийся батч и выходим
+				log.Debug().Msg("Request channel closed, flushing remaining batch")
+				flush()
 				return
 			}
 
-			batchWasEmpty := len(batch) == 0
-			batch[req.UserID] = append(batch[req.UserID], req.URLIDs...)
+			if len(batch) == 0 {
+				timer.Reset(p.batchTimeout)
+				timerC = timer.C
+			}
+
+			key := batchKey{tenantID: req.TenantID, userID: req.UserID}
+			batch[key] = append(batch[key], req.URLIDs...)
 			totalURLs += len(req.URLIDs)
 
 			if totalURLs >= p.batchSize {
-				processBatch()
-				if len(batch) == 0 {
-					stopTimer()
-				} else {
-					startOrResetTimer()
-				}
-			} else if batchWasEmpty {
-				startOrResetTimer()
+				flush()
 			}
 
 		case <-timerC:
-			processBatch()
-			stopTimer()
+			flush()
+		}
+	}
+}
+
+func (p *DeleteWorkerPool) worker(id int) {
+	defer p.wg.Done()
+
+	log.Debug().Int("workerID", id).Msg("Worker started")
+
+	for batch := range p.batches {
+		p.processBatch(id, batch)
+	}
+
+	log.Debug().Int("workerID", id).Msg("Worker shutting down")
+}
+
+func (p *DeleteWorkerPool) processBatch(workerID int, batch pendingBatch) {
+	log.Debug().
+		Int("workerID", workerID).
+		Int("users", len(batch.urls)).
+		Int("urlCount", batch.totalURLs).
+		Msg("Processing batch")
+
+	for key, urlIDs := range batch.urls {
+		if err := p.delete(key, urlIDs); err != nil {
+			log.Error().
+				Err(err).
+				Int("workerID", workerID).
+				Str("tenantID", key.tenantID).
+				Str("userID", key.userID).
+				Int("urlCount", len(urlIDs)).
+				Msg("Failed to delete user URLs")
+			p.observer.OnError(err)
+		} else {
+			log.Debug().
+				Int("workerID", workerID).
+				Str("tenantID", key.tenantID).
+				Str("userID", key.userID).
+				Int("urlCount", len(urlIDs)).
+				Msg("Successfully deleted user URLs")
+		}
+	}
+
+	p.observer.OnBatchProcessed(len(batch.urls), batch.totalURLs)
+}
+
+func (p *DeleteWorkerPool) delete(key batchKey, urlIDs []string) error {
+	return deleteUserURLs(p.service, key.tenantID, key.userID, urlIDs)
+}
+
+// deleteUserURLs deletes the user's URLs within the tenant when the service
+// supports tenants, and in the default tenant otherwise.
+func deleteUserURLs(service DeleteService, tenantID, userID string, urlIDs []string) error {
+	if tenantID != "" {
+		if tenantService, ok := service.(TenantDeleteService); ok {
+			return tenantService.DeleteTenantUserURLs(tenantID, userID, urlIDs)
 		}
 	}
+	return service.DeleteUserURLs(userID, urlIDs)
 }
 
 // Submit queues a delete request for processing.
 func (p *DeleteWorkerPool) Submit(userID string, urlIDs []string) error {
-	select {
-	case <-p.ctx.Done():
+	return p.SubmitForTenant("", userID, urlIDs)
+}
+
+// SubmitForTenant queues a delete request scoped to the given tenant. It
+// does not wait for room: a full queue returns ErrQueueFull, and a pool that
+// is shutting down returns context.Canceled.
+func (p *DeleteWorkerPool) SubmitForTenant(tenantID, userID string, urlIDs []string) error {
+	req := DeleteRequest{TenantID: tenantID, UserID: userID, URLIDs: urlIDs}
+
+	p.submitMu.RLock()
+	defer p.submitMu.RUnlock()
+
+	if p.stopped || p.ctx.Err() != nil {
 		return context.Canceled
-	case p.requestChan <- DeleteRequest{UserID: userID, URLIDs: urlIDs}:
+	}
+
+	select {
+	case p.requestChan <- req:
 		log.Debug().
 			Str("userID", userID).
 			Int("urlCount", len(urlIDs)).
@@ -207,25 +340,27 @@ func (p *DeleteWorkerPool) Submit(userID string, urlIDs []string) error {
 		log.Warn().
 			Str("userID", userID).
 			Int("urlCount", len(urlIDs)).
-			Msg("Request channel is full, blocking")
-
-		select {
-		case <-p.ctx.Done():
-			return context.Canceled
-		case p.requestChan <- DeleteRequest{UserID: userID, URLIDs: urlIDs}:
-			return nil
-		}
+			Msg("Request channel is full, rejecting")
+		return ErrQueueFull
 	}
 }
 
 // Shutdown gracefully stops workers, waiting up to the provided timeout.
+// When the timeout expires it returns context.DeadlineExceeded without
+// waiting for deletions still in progress.
 func (p *DeleteWorkerPool) Shutdown(timeout time.Duration) error {
 	var shutdownErr error
 
 	p.shutdownOnce.Do(func() {
 		log.Info().Msg("Shutting down delete worker pool")
 
+		close(p.stopSampling)
+		p.samplerWG.Wait()
+
+		p.submitMu.Lock()
+		p.stopped = true
 		close(p.requestChan)
+		p.submitMu.Unlock()
 
 		done := make(chan struct{})
 		go func() {
@@ -238,8 +373,10 @@ func (p *DeleteWorkerPool) Shutdown(timeout time.Duration) error {
 			log.Info().Msg("Delete worker pool shut down gracefully")
 		case <-time.After(timeout):
 			log.Warn().Msg("Delete worker pool shutdown timeout, forcing shutdown")
+			// Workers still inside the service are left to finish on their own.
 			p.cancel()
-			<-done
+			p.aggregatorWG.Wait()
+			p.reportDropped()
 			shutdownErr = context.DeadlineExceeded
 		}
 	})
@@ -247,6 +384,21 @@ func (p *DeleteWorkerPool) Shutdown(timeout time.Duration) error {
 	return shutdownErr
 }
 
+// reportDropped drains the closed request channel after a forced shutdown
+// and reports the URL IDs that will not be deleted, including those the
+// aggregator abandoned.
+func (p *DeleteWorkerPool) reportDropped() {
+	dropped := p.abandoned
+	for req := range p.requestChan {
+		dropped += len(req.URLIDs)
+	}
+
+	if dropped > 0 {
+		log.Warn().Int("urlCount", dropped).Msg("Delete requests dropped on shutdown")
+		p.observer.OnDropped(dropped)
+	}
+}
+
 // Stats returns current pool statistics.
 func (p *DeleteWorkerPool) Stats() PoolStats {
 	return PoolStats{
@@ -256,6 +408,20 @@ func (p *DeleteWorkerPool) Stats() PoolStats {
 	}
 }
 
+// History returns sampled queue depths from oldest to newest.
+// It returns nil when sampling is disabled.
+func (p *DeleteWorkerPool) History() []QueueSample {
+	if p.history == nil {
+		return nil
+	}
+	return p.history.list()
+}
+
+// SampleInterval returns the queue sampling interval, or 0 when disabled.
+func (p *DeleteWorkerPool) SampleInterval() time.Duration {
+	return p.sampleInterval
+}
+
 // PoolStats contains worker pool metrics.
 type PoolStats struct {
 	QueueSize   int
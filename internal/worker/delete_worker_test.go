@@ -1,6 +1,7 @@
 package worker
 
 import (
+	"context"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -240,6 +241,37 @@ func TestDeleteWorkerPool_GracefulShutdown(t *testing.T) {
 	assert.NotEmpty(t, calls)
 }
 
+func TestDeleteWorkerPool_ShutdownTimeoutWithStuckService(t *testing.T) {
+	service := &MockDeleteService{
+		deleteDelay: 5 * time.Second,
+	}
+	observer := &recordingObserver{}
+	config := Config{
+		WorkerCount:  1,
+		BufferSize:   10,
+		BatchSize:    1,
+		BatchTimeout: time.Millisecond,
+		Observer:     observer,
+	}
+
+	pool := NewDeleteWorkerPool(service, config)
+	pool.Start()
+
+	require.NoError(t, pool.Submit("user1", []string{"url1"}))
+	require.Eventually(t, func() bool { return service.callCount.Load() == 1 }, time.Second, time.Millisecond)
+	require.NoError(t, pool.Submit("user1", []string{"url2"}))
+	require.NoError(t, pool.Submit("user1", []string{"url3"}))
+
+	start := time.Now()
+	err := pool.Shutdown(100 * time.Millisecond)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	assert.Equal(t, 2, observer.dropped)
+}
+
 func TestDeleteWorkerPool_Stats(t *testing.T) {
 	service := &MockDeleteService{}
 	config := Config{
@@ -296,3 +328,160 @@ func TestDefaultConfig(t *testing.T) {
 	assert.Equal(t, 10, config.BatchSize)
 	assert.Equal(t, 5*time.Second, config.BatchTimeout)
 }
+
+type MockTenantDeleteService struct {
+	MockDeleteService
+	mu      sync.Mutex
+	tenants []string
+}
+
+func (m *MockTenantDeleteService) DeleteTenantUserURLs(tenantID, userID string, urlIDs []string) error {
+	m.mu.Lock()
+	m.tenants = append(m.tenants, tenantID)
+	m.mu.Unlock()
+	return m.DeleteUserURLs(userID, urlIDs)
+}
+
+func TestDeleteWorkerPool_SubmitForTenant(t *testing.T) {
+	service := &MockTenantDeleteService{}
+	config := Config{
+		WorkerCount:  1,
+		BufferSize:   10,
+		BatchSize:    1,
+		BatchTimeout: 50 * time.Millisecond,
+	}
+
+	pool := NewDeleteWorkerPool(service, config)
+	pool.Start()
+
+	require.NoError(t, pool.SubmitForTenant("tenant-a", "user1", []string{"url1"}))
+	require.NoError(t, pool.Shutdown(time.Second))
+
+	calls := service.GetCalls()
+	require.Len(t, calls, 1)
+	assert.Equal(t, "user1", calls[0].UserID)
+
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	assert.Equal(t, []string{"tenant-a"}, service.tenants)
+}
+
+type recordingObserver struct {
+	mu      sync.Mutex
+	batches int
+	users   int
+	urls    int
+	sizes   []int
+	errs    []error
+	dropped int
+}
+
+func (o *recordingObserver) OnBatchProcessed(users, urls int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.batches++
+	o.users += users
+	o.urls += urls
+	o.sizes = append(o.sizes, urls)
+}
+
+func (o *recordingObserver) OnError(err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.errs = append(o.errs, err)
+}
+
+func (o *recordingObserver) OnDropped(n int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.dropped += n
+}
+
+func TestDeleteWorkerPool_Observer(t *testing.T) {
+	service := &MockDeleteService{shouldFail: true}
+	observer := &recordingObserver{}
+	config := Config{
+		WorkerCount:  1,
+		BufferSize:   10,
+		BatchSize:    3,
+		BatchTimeout: time.Second,
+		Observer:     observer,
+	}
+
+	pool := NewDeleteWorkerPool(service, config)
+	pool.Start()
+
+	require.NoError(t, pool.Submit("user1", []string{"url1", "url2"}))
+	require.NoError(t, pool.Submit("user2", []string{"url3"}))
+	require.NoError(t, pool.Shutdown(time.Second))
+
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	assert.Equal(t, 1, observer.batches)
+	assert.Equal(t, 2, observer.users)
+	assert.Equal(t, 3, observer.urls)
+	assert.Len(t, observer.errs, 2)
+	assert.Zero(t, observer.dropped)
+}
+
+func TestDeleteWorkerPool_ObserverDropped(t *testing.T) {
+	service := &MockDeleteService{deleteDelay: 100 * time.Millisecond}
+	observer := &recordingObserver{}
+	config := Config{
+		WorkerCount:  1,
+		BufferSize:   20,
+		BatchSize:    1,
+		BatchTimeout: time.Second,
+		Observer:     observer,
+	}
+
+	pool := NewDeleteWorkerPool(service, config)
+	pool.Start()
+
+	for i := 0; i < 20; i++ {
+		require.NoError(t, pool.Submit("user1", []string{"url"}))
+	}
+
+	err := pool.Shutdown(50 * time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The batch in progress at the timeout still completes afterwards.
+	assert.Eventually(t, func() bool {
+		observer.mu.Lock()
+		defer observer.mu.Unlock()
+		return observer.dropped > 0 && observer.urls+observer.dropped == 20
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestDeleteWorkerPool_BatchesAcrossWorkers(t *testing.T) {
+	service := &MockDeleteService{}
+	observer := &recordingObserver{}
+	config := Config{
+		WorkerCount:  5,
+		BufferSize:   100,
+		BatchSize:    10,
+		BatchTimeout: 10 * time.Second,
+		Observer:     observer,
+	}
+
+	pool := NewDeleteWorkerPool(service, config)
+	pool.Start()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(userNum int) {
+			defer wg.Done()
+			err := pool.Submit(string(rune('A'+userNum%5)), []string{"url"})
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	require.NoError(t, pool.Shutdown(time.Second))
+
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	assert.Equal(t, 50, observer.urls)
+	assert.Equal(t, []int{10, 10, 10, 10, 10}, observer.sizes)
+}
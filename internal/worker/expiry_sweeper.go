@@ -0,0 +1,80 @@
+package worker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ExpiredPurger removes URLs that expired before a given time.
+type ExpiredPurger interface {
+	PurgeExpired(before time.Time) (int, error)
+}
+
+// ExpirySweeper periodically purges URLs that expired more than a grace period
+// ago. Until purged, expired URLs keep answering 410 Gone.
+type ExpirySweeper struct {
+	purger   ExpiredPurger
+	interval time.Duration
+	grace    time.Duration
+	now      func() time.Time
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewExpirySweeper creates a sweeper that runs every interval and purges URLs
+// that expired at least grace ago.
+func NewExpirySweeper(purger ExpiredPurger, interval, grace time.Duration) *ExpirySweeper {
+	return &ExpirySweeper{
+		purger:   purger,
+		interval: interval,
+		grace:    grace,
+		now:      time.Now,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start launches the sweeping goroutine.
+func (s *ExpirySweeper) Start() {
+	s.wg.Add(1)
+	go s.run()
+}
+
+func (s *ExpirySweeper) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.Sweep()
+		}
+	}
+}
+
+// Sweep purges URLs that expired at least the grace period ago.
+func (s *ExpirySweeper) Sweep() {
+	purged, err := s.purger.PurgeExpired(s.now().Add(-s.grace))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to purge expired URLs")
+		return
+	}
+
+	if purged > 0 {
+		log.Info().Int("count", purged).Msg("Purged expired URLs")
+	}
+}
+
+// Stop stops the sweeper and waits for an in-progress sweep to finish.
+func (s *ExpirySweeper) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	s.wg.Wait()
+}
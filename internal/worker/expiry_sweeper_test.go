@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type MockPurger struct {
+	mu      sync.Mutex
+	befores []time.Time
+}
+
+func (m *MockPurger) PurgeExpired(before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.befores = append(m.befores, before)
+	return 1, nil
+}
+
+func (m *MockPurger) Calls() []time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]time.Time{}, m.befores...)
+}
+
+func TestExpirySweeper_SweepAppliesGrace(t *testing.T) {
+	purger := &MockPurger{}
+	sweeper := NewExpirySweeper(purger, time.Hour, 10*time.Minute)
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	sweeper.now = func() time.Time { return now }
+
+	sweeper.Sweep()
+
+	calls := purger.Calls()
+	if assert.Len(t, calls, 1) {
+		assert.Equal(t, now.Add(-10*time.Minute), calls[0])
+	}
+}
+
+func TestExpirySweeper_RunsPeriodically(t *testing.T) {
+	purger := &MockPurger{}
+	sweeper := NewExpirySweeper(purger, 20*time.Millisecond, 0)
+	sweeper.Start()
+
+	assert.Eventually(t, func() bool {
+		return len(purger.Calls()) >= 2
+	}, time.Second, 10*time.Millisecond)
+
+	sweeper.Stop()
+	calls := len(purger.Calls())
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, calls, len(purger.Calls()), "no sweeps after Stop")
+}
@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"sync"
+	"time"
+)
+
+// QueueSample is a single observation of the delete queue depth.
+type QueueSample struct {
+	Time      time.Time `json:"time"`
+	QueueSize int       `json:"queue_size"`
+}
+
+// queueHistory is a bounded ring buffer of queue samples.
+type queueHistory struct {
+	mu      sync.Mutex
+	samples []QueueSample
+	next    int
+	full    bool
+}
+
+func newQueueHistory(size int) *queueHistory {
+	return &queueHistory{
+		samples: make([]QueueSample, size),
+	}
+}
+
+// add records a sample, evicting the oldest one when the buffer is full.
+func (h *queueHistory) add(sample QueueSample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.samples[h.next] = sample
+	h.next = (h.next + 1) % len(h.samples)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list returns the recorded samples ordered from oldest to newest.
+func (h *queueHistory) list() []QueueSample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.full {
+		return append([]QueueSample{}, h.samples[:h.next]...)
+	}
+
+	result := make([]QueueSample, 0, len(h.samples))
+	result = append(result, h.samples[h.next:]...)
+	result = append(result, h.samples[:h.next]...)
+	return result
+}
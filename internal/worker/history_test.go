@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueueHistory_EvictsOldest(t *testing.T) {
+	h := newQueueHistory(3)
+	start := time.Now()
+
+	for i := 0; i < 5; i++ {
+		h.add(QueueSample{Time: start.Add(time.Duration(i) * time.Second), QueueSize: i})
+	}
+
+	samples := h.list()
+	require.Len(t, samples, 3)
+	assert.Equal(t, 2, samples[0].QueueSize)
+	assert.Equal(t, 3, samples[1].QueueSize)
+	assert.Equal(t, 4, samples[2].QueueSize)
+}
+
+func TestQueueHistory_Partial(t *testing.T) {
+	h := newQueueHistory(3)
+	assert.Empty(t, h.list())
+
+	h.add(QueueSample{QueueSize: 1})
+	h.add(QueueSample{QueueSize: 2})
+
+	samples := h.list()
+	require.Len(t, samples, 2)
+	assert.Equal(t, 1, samples[0].QueueSize)
+	assert.Equal(t, 2, samples[1].QueueSize)
+}
+
+func TestDeleteWorkerPool_History(t *testing.T) {
+	service := &MockDeleteService{}
+	config := DefaultConfig()
+	config.SampleInterval = 10 * time.Millisecond
+	config.HistorySize = 3
+
+	pool := NewDeleteWorkerPool(service, config)
+	pool.Start()
+
+	assert.Eventually(t, func() bool {
+		return len(pool.History()) == 3
+	}, time.Second, 5*time.Millisecond)
+
+	first := pool.History()[0].Time
+	assert.Eventually(t, func() bool {
+		return pool.History()[0].Time.After(first)
+	}, time.Second, 5*time.Millisecond)
+	assert.Len(t, pool.History(), 3)
+
+	require.NoError(t, pool.Shutdown(time.Second))
+}
+
+func TestDeleteWorkerPool_HistoryDisabled(t *testing.T) {
+	pool := NewDeleteWorkerPool(&MockDeleteService{}, DefaultConfig())
+	pool.Start()
+	defer pool.Shutdown(time.Second)
+
+	assert.Nil(t, pool.History())
+	assert.Equal(t, time.Duration(0), pool.SampleInterval())
+}
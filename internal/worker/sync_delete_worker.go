@@ -0,0 +1,36 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrDeleteFailed wraps storage errors returned by SyncDeleteWorker, telling
+// them apart from a pool that cannot accept requests.
+var ErrDeleteFailed = errors.New("delete failed")
+
+// SyncDeleteWorker deletes URLs inside Submit instead of queueing them, for
+// small deployments that don't need a pool and for deterministic tests.
+// Deletions are visible as soon as Submit returns.
+type SyncDeleteWorker struct {
+	service DeleteService
+}
+
+// NewSyncDeleteWorker creates a SyncDeleteWorker deleting through service.
+func NewSyncDeleteWorker(service DeleteService) *SyncDeleteWorker {
+	return &SyncDeleteWorker{service: service}
+}
+
+// Submit deletes the user's URLs and returns once they are deleted.
+func (w *SyncDeleteWorker) Submit(userID string, urlIDs []string) error {
+	return w.SubmitForTenant("", userID, urlIDs)
+}
+
+// SubmitForTenant deletes the user's URLs within the given tenant and returns
+// once they are deleted.
+func (w *SyncDeleteWorker) SubmitForTenant(tenantID, userID string, urlIDs []string) error {
+	if err := deleteUserURLs(w.service, tenantID, userID, urlIDs); err != nil {
+		return fmt.Errorf("%w: %w", ErrDeleteFailed, err)
+	}
+	return nil
+}
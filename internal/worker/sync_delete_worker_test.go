@@ -0,0 +1,42 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncDeleteWorker_Submit(t *testing.T) {
+	service := &MockDeleteService{}
+	w := NewSyncDeleteWorker(service)
+
+	require.NoError(t, w.Submit("user1", []string{"url1", "url2"}))
+
+	calls := service.GetCalls()
+	require.Len(t, calls, 1)
+	assert.Equal(t, "user1", calls[0].UserID)
+	assert.Equal(t, []string{"url1", "url2"}, calls[0].URLIDs)
+}
+
+func TestSyncDeleteWorker_SubmitForTenant(t *testing.T) {
+	service := &MockTenantDeleteService{}
+	w := NewSyncDeleteWorker(service)
+
+	require.NoError(t, w.SubmitForTenant("acme", "user1", []string{"url1"}))
+	require.NoError(t, w.Submit("user1", []string{"url2"}))
+
+	assert.Len(t, service.GetCalls(), 2)
+
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	assert.Equal(t, []string{"acme"}, service.tenants)
+}
+
+func TestSyncDeleteWorker_Error(t *testing.T) {
+	w := NewSyncDeleteWorker(&MockDeleteService{shouldFail: true})
+
+	err := w.Submit("user1", []string{"url1"})
+	assert.ErrorIs(t, err, ErrDeleteFailed)
+	assert.ErrorIs(t, err, assert.AnError)
+}
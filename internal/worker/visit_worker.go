@@ -0,0 +1,185 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// VisitService persists aggregated redirect counts.
+type VisitService interface {
+	RecordVisits(tenantID, id string, n int64, at time.Time) error
+}
+
+// VisitConfig configures the visit recorder.
+type VisitConfig struct {
+	BufferSize    int           // Размер буфера канала
+	BatchSize     int           // Количество визитов, после которого батч сбрасывается
+	FlushInterval time.Duration // Максимальное время накопления батча
+}
+
+// DefaultVisitConfig returns sane defaults for the visit recorder.
+func DefaultVisitConfig() VisitConfig {
+	return VisitConfig{
+		BufferSize:    1000,
+		BatchSize:     500,
+		FlushInterval: time.Second,
+	}
+}
+
+type visit struct {
+	tenantID string
+	id       string
+	at       time.Time
+}
+
+// visitKey groups pending visits by tenant and short ID.
+type visitKey struct {
+	tenantID string
+	id       string
+}
+
+type visitCount struct {
+	n    int64
+	last time.Time
+}
+
+// VisitRecorder counts redirects asynchronously. Visits are aggregated per
+// short ID and flushed to the service in batches, so recording a visit never
+// waits on storage.
+type VisitRecorder struct {
+	service       VisitService
+	visits        chan visit
+	batchSize     int
+	flushInterval time.Duration
+	done          chan struct{}
+	wg            sync.WaitGroup
+	shutdownOnce  sync.Once
+	dropped       atomic.Int64
+}
+
+// NewVisitRecorder creates a visit recorder with the given config.
+func NewVisitRecorder(service VisitService, config VisitConfig) *VisitRecorder {
+	return &VisitRecorder{
+		service:       service,
+		visits:        make(chan visit, config.BufferSize),
+		batchSize:     config.BatchSize,
+		flushInterval: config.FlushInterval,
+		done:          make(chan struct{}),
+	}
+}
+
+// Start launches the goroutine that aggregates and flushes visits.
+func (r *VisitRecorder) Start() {
+	r.wg.Add(1)
+	go r.run()
+}
+
+// Record queues a visit to the short ID. It never blocks: when the buffer is
+// full the visit is dropped and counted in Dropped.
+func (r *VisitRecorder) Record(tenantID, id string) {
+	select {
+	case <-r.done:
+		return
+	default:
+	}
+
+	select {
+	case r.visits <- visit{tenantID: tenantID, id: id, at: time.Now()}:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of visits discarded because the buffer was full.
+func (r *VisitRecorder) Dropped() int64 {
+	return r.dropped.Load()
+}
+
+func (r *VisitRecorder) run() {
+	defer r.wg.Done()
+
+	batch := make(map[visitKey]visitCount)
+	pending := 0
+
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	flush := func() {
+		for key, count := range batch {
+			if err := r.service.RecordVisits(key.tenantID, key.id, count.n, count.last); err != nil {
+				log.Error().
+					Err(err).
+					Str("tenantID", key.tenantID).
+					Str("id", key.id).
+					Int64("visits", count.n).
+					Msg("Failed to record visits")
+			}
+			delete(batch, key)
+		}
+		pending = 0
+	}
+
+	add := func(v visit) {
+		key := visitKey{tenantID: v.tenantID, id: v.id}
+		count := batch[key]
+		count.n++
+		count.last = v.at
+		batch[key] = count
+		pending++
+
+		if pending >= r.batchSize {
+			flush()
+		}
+	}
+
+	for {
+		select {
+		case <-r.done:
+			// Drain what was queued before shutdown.
+			for {
+				select {
+				case v := <-r.visits:
+					add(v)
+				default:
+					flush()
+					return
+				}
+			}
+
+		case v := <-r.visits:
+			add(v)
+
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// Shutdown flushes queued visits and stops the recorder, waiting up to the provided timeout.
+func (r *VisitRecorder) Shutdown(timeout time.Duration) error {
+	var shutdownErr error
+
+	r.shutdownOnce.Do(func() {
+		close(r.done)
+
+		finished := make(chan struct{})
+		go func() {
+			r.wg.Wait()
+			close(finished)
+		}()
+
+		select {
+		case <-finished:
+			log.Info().Msg("Visit recorder shut down gracefully")
+		case <-time.After(timeout):
+			log.Warn().Msg("Visit recorder shutdown timeout, pending visits may be lost")
+			shutdownErr = context.DeadlineExceeded
+		}
+	})
+
+	return shutdownErr
+}
@@ -0,0 +1,94 @@
+package worker
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type MockVisitService struct {
+	mu     sync.Mutex
+	visits map[visitKey]int64
+	calls  int
+}
+
+func (m *MockVisitService) RecordVisits(tenantID, id string, n int64, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.visits == nil {
+		m.visits = make(map[visitKey]int64)
+	}
+	m.visits[visitKey{tenantID: tenantID, id: id}] += n
+	m.calls++
+
+	return nil
+}
+
+func (m *MockVisitService) Get(tenantID, id string) (int64, int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.visits[visitKey{tenantID: tenantID, id: id}], m.calls
+}
+
+func TestVisitRecorder_AggregatesVisits(t *testing.T) {
+	service := &MockVisitService{}
+	recorder := NewVisitRecorder(service, VisitConfig{
+		BufferSize:    100,
+		BatchSize:     100,
+		FlushInterval: time.Hour,
+	})
+	recorder.Start()
+
+	for i := 0; i < 5; i++ {
+		recorder.Record("", "abc")
+	}
+	recorder.Record("tenant", "abc")
+
+	require.NoError(t, recorder.Shutdown(time.Second))
+
+	visits, calls := service.Get("", "abc")
+	assert.Equal(t, int64(5), visits)
+	assert.Equal(t, 2, calls, "visits should be flushed once per tenant and ID")
+
+	visits, _ = service.Get("tenant", "abc")
+	assert.Equal(t, int64(1), visits)
+}
+
+func TestVisitRecorder_FlushesOnInterval(t *testing.T) {
+	service := &MockVisitService{}
+	recorder := NewVisitRecorder(service, VisitConfig{
+		BufferSize:    100,
+		BatchSize:     100,
+		FlushInterval: 50 * time.Millisecond,
+	})
+	recorder.Start()
+	defer recorder.Shutdown(time.Second)
+
+	recorder.Record("", "abc")
+	recorder.Record("", "abc")
+
+	assert.Eventually(t, func() bool {
+		visits, _ := service.Get("", "abc")
+		return visits == 2
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestVisitRecorder_DropsWhenFull(t *testing.T) {
+	service := &MockVisitService{}
+	recorder := NewVisitRecorder(service, VisitConfig{
+		BufferSize:    1,
+		BatchSize:     100,
+		FlushInterval: time.Hour,
+	})
+
+	// Not started, so the buffer fills up after one visit.
+	recorder.Record("", "abc")
+	recorder.Record("", "abc")
+	recorder.Record("", "abc")
+
+	assert.Equal(t, int64(2), recorder.Dropped())
+}